@@ -16,6 +16,7 @@
 package core
 
 import (
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -28,11 +29,26 @@ type Account struct {
 	State *AccountState `json:"state"`
 }
 
+func (account *Account) GetMeta() *ObjectMeta {
+	return account.Meta
+}
+
 type AccountState struct {
 	// map describing pod's uuid and pod state
 	Pods map[string]AccountPodState `json:"pods"`
 	// map describing nid and timestamp of keepalive
 	Nodes map[string]AccountNodeState `json:"nodes"`
+	// public keys of the nodes of the account to verify the resources owned by the account, key: key id
+	Keys map[string]AccountKey `json:"keys,omitempty"`
+}
+
+type AccountKey struct {
+	// ed25519 public key
+	PublicKey []byte `json:"publicKey"`
+	// the last time the key is used by a node of the account
+	Timestamp string `json:"timestamp"`
+	// Certificate is made by the authority for the key, see CertifyKey
+	Certificate []byte `json:"certificate"`
 }
 
 type AccountPodState struct {
@@ -67,6 +83,12 @@ type AccountNodeState struct {
 	Position  *Vector3 `json:"position,omitempty"`
 }
 
+// GetSignedContent returns nil because the states are written by the nodes of other accounts, the keys are
+// not signed either because each of them has the certificate.
+func (account *Account) GetSignedContent() any {
+	return nil
+}
+
 // use sha256 hash as account's uuid
 func GenerateAccountUuid(name string) string {
 	hash := sha256.Sum256([]byte(name))
@@ -131,5 +153,20 @@ func (state *AccountState) validate() error {
 		}
 	}
 
+	for keyID, key := range state.Keys {
+		if len(key.PublicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("size of the public key %s is wrong", keyID)
+		}
+		if keyID != GetKeyID(key.PublicKey) {
+			return fmt.Errorf("key id %s does not match the public key", keyID)
+		}
+		if err := ValidateTimestamp(key.Timestamp); err != nil {
+			return fmt.Errorf("there is an invalid timestamp for key %s: %w", keyID, err)
+		}
+		if len(key.Certificate) != ed25519.SignatureSize {
+			return fmt.Errorf("size of the certificate for key %s is wrong", keyID)
+		}
+	}
+
 	return nil
 }
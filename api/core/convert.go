@@ -0,0 +1,192 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	// apiVersion of the records written before the field was introduced
+	API_VERSION_LEGACY  = ""
+	API_VERSION_CORE_V1 = "core/v1"
+)
+
+// ErrUnknownAPIVersion is returned for records written by a newer node or broken records,
+// readers should keep them instead of removing.
+var ErrUnknownAPIVersion = errors.New("unknown api version")
+
+// ConvertFunc modifies the JSON object of a resource from one version to the next version.
+// The object is decoded with json.Number for numbers.
+type ConvertFunc func(obj map[string]any) error
+
+type converter struct {
+	to      string
+	convert ConvertFunc
+}
+
+// ConverterRegistry upgrades the stored JSON records to the current version of each resource type.
+type ConverterRegistry struct {
+	mtx     sync.RWMutex
+	current map[ResourceType]string
+	// key of the inner map: the version converted from
+	converters map[ResourceType]map[string]converter
+}
+
+// DefaultConverters is used by the KVS stores and the node manager
+var DefaultConverters = NewConverterRegistry()
+
+func init() {
+	for _, t := range []ResourceType{ResourceTypeAccount, ResourceTypePod, ResourceTypePodIndex, ResourceTypeRecord} {
+		DefaultConverters.SetCurrentVersion(t, API_VERSION_CORE_V1)
+	}
+
+	// maps of the legacy records might be null, fill them not to fail the validation
+	DefaultConverters.Register(ResourceTypeAccount, API_VERSION_LEGACY, API_VERSION_CORE_V1, func(obj map[string]any) error {
+		state, ok := obj["state"].(map[string]any)
+		if !ok {
+			return nil
+		}
+		fillMap(state, "pods")
+		fillMap(state, "nodes")
+		return nil
+	})
+	DefaultConverters.Register(ResourceTypePod, API_VERSION_LEGACY, API_VERSION_CORE_V1, nil)
+	DefaultConverters.Register(ResourceTypePodIndex, API_VERSION_LEGACY, API_VERSION_CORE_V1, nil)
+	DefaultConverters.Register(ResourceTypeRecord, API_VERSION_LEGACY, API_VERSION_CORE_V1, func(obj map[string]any) error {
+		data, ok := obj["data"].(map[string]any)
+		if !ok {
+			return nil
+		}
+		fillMap(data, "entries")
+		return nil
+	})
+}
+
+func fillMap(obj map[string]any, key string) {
+	if obj[key] == nil {
+		obj[key] = map[string]any{}
+	}
+}
+
+func NewConverterRegistry() *ConverterRegistry {
+	return &ConverterRegistry{
+		current:    make(map[ResourceType]string),
+		converters: make(map[ResourceType]map[string]converter),
+	}
+}
+
+// SetCurrentVersion sets the version written by this node
+func (registry *ConverterRegistry) SetCurrentVersion(t ResourceType, version string) {
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+	registry.current[t] = version
+}
+
+// GetCurrentVersion returns API_VERSION_LEGACY for the types not versioned
+func (registry *ConverterRegistry) GetCurrentVersion(t ResourceType) string {
+	registry.mtx.RLock()
+	defer registry.mtx.RUnlock()
+	return registry.current[t]
+}
+
+// Register adds the converter from a version to the next version, convert can be nil if only apiVersion is changed
+func (registry *ConverterRegistry) Register(t ResourceType, from, to string, convert ConvertFunc) error {
+	if from == to {
+		return fmt.Errorf("converter should change the version of %s", t)
+	}
+
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+
+	converters, ok := registry.converters[t]
+	if !ok {
+		converters = make(map[string]converter)
+		registry.converters[t] = converters
+	}
+	if _, ok := converters[from]; ok {
+		return fmt.Errorf("converter from %s for %s is already registered", from, t)
+	}
+	converters[from] = converter{
+		to:      to,
+		convert: convert,
+	}
+	return nil
+}
+
+// Upgrade converts the JSON record to the current version, it returns the raw data as it is
+// if the record is the current version or the type is not versioned.
+func (registry *ConverterRegistry) Upgrade(t ResourceType, raw []byte) ([]byte, error) {
+	registry.mtx.RLock()
+	defer registry.mtx.RUnlock()
+
+	current, ok := registry.current[t]
+	if !ok {
+		return raw, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	obj := make(map[string]any)
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("failed to decode %s record: %w", t, err)
+	}
+
+	version := getAPIVersion(obj)
+	if version == current {
+		return raw, nil
+	}
+
+	// each converter is used once at most, it prevents the loop of the versions
+	for steps := 0; version != current; steps++ {
+		converter, ok := registry.converters[t][version]
+		if !ok || steps > len(registry.converters[t]) {
+			return nil, fmt.Errorf("failed to upgrade %s record from %q to %q: %w", t, version, current, ErrUnknownAPIVersion)
+		}
+		if converter.convert != nil {
+			if err := converter.convert(obj); err != nil {
+				return nil, fmt.Errorf("failed to convert %s record from %q: %w", t, version, err)
+			}
+		}
+		version = converter.to
+	}
+
+	meta, ok := obj["meta"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("meta field of %s record should be an object", t)
+	}
+	meta["apiVersion"] = current
+
+	upgraded, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode upgraded %s record: %w", t, err)
+	}
+	return upgraded, nil
+}
+
+func getAPIVersion(obj map[string]any) string {
+	meta, ok := obj["meta"].(map[string]any)
+	if !ok {
+		return API_VERSION_LEGACY
+	}
+	version, _ := meta["apiVersion"].(string)
+	return version
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConverterRegistry(t *testing.T) {
+	assert := assert.New(t)
+	resourceType := ResourceType("test")
+	registry := NewConverterRegistry()
+
+	// types not versioned are not converted
+	raw := []byte(`{"meta":{"name":"test"}}`)
+	upgraded, err := registry.Upgrade(resourceType, raw)
+	assert.NoError(err)
+	assert.Equal(raw, upgraded)
+	assert.Equal(API_VERSION_LEGACY, registry.GetCurrentVersion(resourceType))
+
+	registry.SetCurrentVersion(resourceType, "test/v2")
+	assert.NoError(registry.Register(resourceType, API_VERSION_LEGACY, "test/v1", func(obj map[string]any) error {
+		obj["value"] = obj["old"]
+		delete(obj, "old")
+		return nil
+	}))
+	assert.NoError(registry.Register(resourceType, "test/v1", "test/v2", func(obj map[string]any) error {
+		obj["values"] = []any{obj["value"]}
+		delete(obj, "value")
+		return nil
+	}))
+	assert.Error(registry.Register(resourceType, "test/v1", "test/v3", nil))
+	assert.Error(registry.Register(resourceType, "test/v3", "test/v3", nil))
+
+	type testResource struct {
+		Meta   ObjectMeta `json:"meta"`
+		Values []uint64   `json:"values"`
+	}
+
+	// legacy records are converted through all versions, large numbers are kept
+	upgraded, err = registry.Upgrade(resourceType, []byte(`{"meta":{"name":"test"},"old":18446744073709551615}`))
+	assert.NoError(err)
+	resource := testResource{}
+	assert.NoError(json.Unmarshal(upgraded, &resource))
+	assert.Equal("test/v2", resource.Meta.APIVersion)
+	assert.Equal("test", resource.Meta.Name)
+	assert.Equal([]uint64{18446744073709551615}, resource.Values)
+
+	upgraded, err = registry.Upgrade(resourceType, []byte(`{"meta":{"apiVersion":"test/v1"},"value":1}`))
+	assert.NoError(err)
+	resource = testResource{}
+	assert.NoError(json.Unmarshal(upgraded, &resource))
+	assert.Equal([]uint64{1}, resource.Values)
+
+	// records of the current version are not modified
+	raw = []byte(`{"meta":{"apiVersion":"test/v2"},"values":[1]}`)
+	upgraded, err = registry.Upgrade(resourceType, raw)
+	assert.NoError(err)
+	assert.Equal(raw, upgraded)
+
+	// unknown versions
+	_, err = registry.Upgrade(resourceType, []byte(`{"meta":{"apiVersion":"test/v3"}}`))
+	assert.ErrorIs(err, ErrUnknownAPIVersion)
+	_, err = registry.Upgrade(resourceType, []byte(`broken`))
+	assert.Error(err)
+	assert.NotErrorIs(err, ErrUnknownAPIVersion)
+
+	// loop of the versions
+	loopType := ResourceType("loop")
+	registry.SetCurrentVersion(loopType, "loop/v3")
+	assert.NoError(registry.Register(loopType, "loop/v1", "loop/v2", nil))
+	assert.NoError(registry.Register(loopType, "loop/v2", "loop/v1", nil))
+	_, err = registry.Upgrade(loopType, []byte(`{"meta":{"apiVersion":"loop/v1"}}`))
+	assert.ErrorIs(err, ErrUnknownAPIVersion)
+}
+
+func TestDefaultConverters(t *testing.T) {
+	assert := assert.New(t)
+	account := "account"
+
+	raw, err := json.Marshal(Account{
+		Meta: &ObjectMeta{
+			Type:        ResourceTypeAccount,
+			Name:        account,
+			Owner:       account,
+			CreatorNode: "0123456789abcdef0123456789abcdef",
+			Uuid:        GenerateAccountUuid(account),
+		},
+		State: &AccountState{},
+	})
+	assert.NoError(err)
+	upgraded, err := DefaultConverters.Upgrade(ResourceTypeAccount, raw)
+	assert.NoError(err)
+	accountUpgraded := &Account{}
+	assert.NoError(json.Unmarshal(upgraded, accountUpgraded))
+	assert.Equal(API_VERSION_CORE_V1, accountUpgraded.Meta.APIVersion)
+	assert.NoError(accountUpgraded.Validate())
+
+	for _, resourceType := range []ResourceType{ResourceTypeAccount, ResourceTypePod, ResourceTypePodIndex, ResourceTypeRecord} {
+		assert.Equal(API_VERSION_CORE_V1, DefaultConverters.GetCurrentVersion(resourceType))
+		upgraded, err := DefaultConverters.Upgrade(resourceType, []byte(`{"meta":{}}`))
+		assert.NoError(err)
+		assert.JSONEq(`{"meta":{"apiVersion":"core/v1"}}`, string(upgraded))
+	}
+}
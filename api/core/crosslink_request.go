@@ -33,6 +33,31 @@ type OutputResponse struct {
 	Length int `json:"length"`
 }
 
+type LogRequest struct {
+	Entries []LogEntry `json:"entries"`
+}
+
+type LogResponse struct {
+	// empty
+}
+
+type SetTimerRequest struct {
+	Timer *Timer `json:"timer"`
+}
+
+type SetTimerResponse struct {
+	ID     string `json:"id"`
+	NextAt string `json:"nextAt"`
+}
+
+type CancelTimerRequest struct {
+	ID string `json:"id"`
+}
+
+type CancelTimerResponse struct {
+	// empty
+}
+
 // types to pass from node manager to application
 type SetupRequest struct {
 	IsInitialize bool   `json:"isInitialize"`
@@ -58,3 +83,14 @@ type TeardownRequest struct {
 type TeardownResponse struct {
 	Record []byte `json:"record"`
 }
+
+type FireTimerRequest struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Payload     []byte `json:"payload"`
+	ScheduledAt string `json:"scheduledAt"`
+}
+
+type FireTimerResponse struct {
+	// empty
+}
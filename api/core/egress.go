@@ -0,0 +1,172 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	EGRESS_DEFAULT_RESPONSE_SIZE = 8 * 1024 * 1024
+	EGRESS_MAX_RESPONSE_SIZE     = 64 * 1024 * 1024
+)
+
+var EgressMethodAccepted = []string{
+	"GET",
+	"HEAD",
+	"POST",
+	"PUT",
+	"PATCH",
+	"DELETE",
+	"OPTIONS",
+}
+
+// EgressSpec declares destinations the containers in the pod can access via the node.
+// All requests are rejected if it is not specified.
+type EgressSpec struct {
+	Allow []EgressRule `json:"allow"`
+	// max size of a response body in bytes, EGRESS_DEFAULT_RESPONSE_SIZE is used when 0
+	MaxResponseSize int64 `json:"maxResponseSize,omitempty"`
+}
+
+type EgressRule struct {
+	// host name with optional port, `*.example.com` matches sub domains of example.com
+	Host string `json:"host"`
+	// allowed methods, all accepted methods are allowed when empty
+	Methods []string `json:"methods,omitempty"`
+	// allowed path prefix, all paths are allowed when empty,
+	// the paths having `.` or `..` segments are not allowed by the rule having the prefix
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// allow plain http scheme, only https is allowed by default
+	AllowHTTP bool `json:"allowHTTP,omitempty"`
+}
+
+func (spec *EgressSpec) validate() error {
+	if spec.MaxResponseSize < 0 || spec.MaxResponseSize > EGRESS_MAX_RESPONSE_SIZE {
+		return fmt.Errorf("max response size of egress should be in 0 to %d", EGRESS_MAX_RESPONSE_SIZE)
+	}
+
+	for _, rule := range spec.Allow {
+		host := strings.TrimPrefix(rule.Host, "*.")
+		if len(host) == 0 || strings.ContainsAny(host, "*/?#@") {
+			return fmt.Errorf("invalid host in the egress rule: %s", rule.Host)
+		}
+
+		for _, method := range rule.Methods {
+			if !slices.Contains(EgressMethodAccepted, method) {
+				return fmt.Errorf("unsupported method in the egress rule: %s", method)
+			}
+		}
+
+		if len(rule.PathPrefix) != 0 && !strings.HasPrefix(rule.PathPrefix, "/") {
+			return fmt.Errorf("path prefix of the egress rule should start with `/`")
+		}
+		if hasDotSegment(rule.PathPrefix) {
+			return fmt.Errorf("path prefix of the egress rule should not have `.` or `..` segments")
+		}
+	}
+
+	return nil
+}
+
+// GetMaxResponseSize returns the max size of a response body applying default value
+func (spec *EgressSpec) GetMaxResponseSize() int64 {
+	if spec == nil || spec.MaxResponseSize == 0 {
+		return EGRESS_DEFAULT_RESPONSE_SIZE
+	}
+	return spec.MaxResponseSize
+}
+
+// IsAllowed returns true if any rule allows to access the url by the method
+func (spec *EgressSpec) IsAllowed(method string, u *url.URL) bool {
+	if spec == nil || !slices.Contains(EgressMethodAccepted, method) {
+		return false
+	}
+
+	for _, rule := range spec.Allow {
+		if rule.match(method, u) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (rule *EgressRule) match(method string, u *url.URL) bool {
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !rule.AllowHTTP {
+			return false
+		}
+	default:
+		return false
+	}
+
+	// compare with the port only if the rule has port
+	host := strings.ToLower(u.Hostname())
+	ruleHost := strings.ToLower(rule.Host)
+	if strings.Contains(ruleHost, ":") {
+		host = strings.ToLower(u.Host)
+	}
+
+	if suffix, ok := strings.CutPrefix(ruleHost, "*"); ok {
+		if !strings.HasSuffix(host, suffix) || len(host) == len(suffix) {
+			return false
+		}
+	} else if host != ruleHost {
+		return false
+	}
+
+	if len(rule.Methods) != 0 && !slices.Contains(rule.Methods, method) {
+		return false
+	}
+
+	if len(rule.PathPrefix) == 0 {
+		return true
+	}
+
+	// the dot segments are resolved after the check by the client or the server, so the path having them is
+	// not compared with the prefix
+	path := u.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+	if hasDotSegment(path) {
+		return false
+	}
+	return strings.HasPrefix(path, rule.PathPrefix)
+}
+
+// hasDotSegment returns true if the escaped path has `.` or `..` segments including the encoded ones,
+// or segments having the encoded separators which may be decoded by the server
+func hasDotSegment(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return true
+		}
+		if unescaped == "." || unescaped == ".." || strings.ContainsAny(unescaped, "/\\") {
+			return true
+		}
+	}
+	return false
+}
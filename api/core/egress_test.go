@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package core
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEgressSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, spec := range []*EgressSpec{
+		{},
+		{
+			Allow: []EgressRule{
+				{
+					Host: "example.com",
+				},
+				{
+					Host:       "*.example.com:8080",
+					Methods:    []string{"GET", "POST"},
+					PathPrefix: "/api/",
+					AllowHTTP:  true,
+				},
+			},
+			MaxResponseSize: EGRESS_MAX_RESPONSE_SIZE,
+		},
+	} {
+		assert.NoError(spec.validate())
+	}
+
+	for title, spec := range map[string]*EgressSpec{
+		"negative response size": {
+			MaxResponseSize: -1,
+		},
+		"too large response size": {
+			MaxResponseSize: EGRESS_MAX_RESPONSE_SIZE + 1,
+		},
+		"empty host": {
+			Allow: []EgressRule{{}},
+		},
+		"wildcard only": {
+			Allow: []EgressRule{{Host: "*."}},
+		},
+		"wildcard in the middle": {
+			Allow: []EgressRule{{Host: "api.*.example.com"}},
+		},
+		"host with path": {
+			Allow: []EgressRule{{Host: "example.com/api"}},
+		},
+		"unsupported method": {
+			Allow: []EgressRule{{Host: "example.com", Methods: []string{"CONNECT"}}},
+		},
+		"relative path prefix": {
+			Allow: []EgressRule{{Host: "example.com", PathPrefix: "api"}},
+		},
+		"path prefix with dot segments": {
+			Allow: []EgressRule{{Host: "example.com", PathPrefix: "/api/../"}},
+		},
+	} {
+		assert.Error(spec.validate(), title)
+	}
+}
+
+func TestEgressIsAllowed(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &EgressSpec{
+		Allow: []EgressRule{
+			{
+				Host: "example.com",
+			},
+			{
+				Host:       "*.example.net",
+				Methods:    []string{"GET"},
+				PathPrefix: "/api/",
+			},
+			{
+				Host:      "localhost:8080",
+				AllowHTTP: true,
+			},
+		},
+	}
+
+	for title, tc := range map[string]struct {
+		method  string
+		url     string
+		allowed bool
+	}{
+		"exact host":              {"POST", "https://example.com/path", true},
+		"exact host with port":    {"GET", "https://example.com:8443/", true},
+		"upper case host":         {"GET", "https://EXAMPLE.com/", true},
+		"sub domain not allowed":  {"GET", "https://www.example.com/", false},
+		"http not allowed":        {"GET", "http://example.com/", false},
+		"wildcard":                {"GET", "https://api.example.net/api/v1", true},
+		"wildcard apex":           {"GET", "https://example.net/api/v1", false},
+		"wildcard method":         {"POST", "https://api.example.net/api/v1", false},
+		"wildcard path":           {"GET", "https://api.example.net/other", false},
+		"dot segments":            {"GET", "https://api.example.net/api/../admin", false},
+		"current segment":         {"GET", "https://api.example.net/api/./v1", false},
+		"encoded dot segments":    {"GET", "https://api.example.net/api/%2e%2E/admin", false},
+		"partly encoded segments": {"GET", "https://api.example.net/api/.%2e/admin", false},
+		"encoded separator":       {"GET", "https://api.example.net/api/..%2Fadmin", false},
+		"encoded back slash":      {"GET", "https://api.example.net/api/..%5Cadmin", false},
+		"dots in the name":        {"GET", "https://api.example.net/api/v1..2/.well", true},
+		"dots without prefix":     {"GET", "https://example.com/a/../b", true},
+		"rule with port":          {"GET", "http://localhost:8080/", true},
+		"rule with port mismatch": {"GET", "http://localhost:8081/", false},
+		"unsupported scheme":      {"GET", "ftp://example.com/", false},
+		"unsupported method":      {"CONNECT", "https://example.com/", false},
+		"unknown host":            {"GET", "https://example.org/", false},
+	} {
+		u, err := url.Parse(tc.url)
+		assert.NoError(err, title)
+		assert.Equal(tc.allowed, spec.IsAllowed(tc.method, u), title)
+	}
+
+	// nothing allowed without egress spec
+	var nilSpec *EgressSpec
+	u, _ := url.Parse("https://example.com/")
+	assert.False(nilSpec.IsAllowed("GET", u))
+	assert.Equal(int64(EGRESS_DEFAULT_RESPONSE_SIZE), nilSpec.GetMaxResponseSize())
+}
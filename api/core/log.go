@@ -0,0 +1,66 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package core
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+type LogLevel string
+
+const (
+	LogLevelDebug LogLevel = "debug"
+	LogLevelInfo  LogLevel = "info"
+	LogLevelWarn  LogLevel = "warn"
+	LogLevelError LogLevel = "error"
+)
+
+var LogLevelAccepted = []LogLevel{
+	LogLevelDebug,
+	LogLevelInfo,
+	LogLevelWarn,
+	LogLevelError,
+}
+
+type LogEntry struct {
+	// sequence number and container name are filled by the node
+	Sequence  uint64            `json:"sequence,omitempty"`
+	Container string            `json:"container,omitempty"`
+	Timestamp string            `json:"timestamp"`
+	Level     LogLevel          `json:"level"`
+	Message   string            `json:"message"`
+	Attrs     map[string]string `json:"attrs,omitempty"`
+}
+
+func (entry *LogEntry) Validate() error {
+	if err := ValidateTimestamp(entry.Timestamp); err != nil {
+		return fmt.Errorf("invalid timestamp of the log entry: %w", err)
+	}
+
+	if !slices.Contains(LogLevelAccepted, entry.Level) {
+		return fmt.Errorf("there is an unsupported log level in the log entry")
+	}
+
+	return nil
+}
+
+type PodLog struct {
+	Entries []LogEntry `json:"entries"`
+	// the sequence number of the last entry, use it to get newer entries
+	Last uint64 `json:"last"`
+}
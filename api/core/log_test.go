@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLogEntry(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, entry := range []LogEntry{
+		{
+			Timestamp: "2023-04-10T00:00:10Z",
+			Level:     LogLevelInfo,
+			Message:   "message",
+		},
+		{
+			Timestamp: "2023-04-10T00:00:10Z",
+			Level:     LogLevelError,
+			Message:   "",
+			Attrs: map[string]string{
+				"key": "value",
+			},
+		},
+	} {
+		assert.NoError(entry.Validate())
+	}
+
+	for title, entry := range map[string]LogEntry{
+		"empty timestamp": {
+			Level:   LogLevelInfo,
+			Message: "message",
+		},
+		"invalid timestamp": {
+			Timestamp: "2023-04-32T00:00:10Z",
+			Level:     LogLevelInfo,
+			Message:   "message",
+		},
+		"empty level": {
+			Timestamp: "2023-04-10T00:00:10Z",
+			Message:   "message",
+		},
+		"unsupported level": {
+			Timestamp: "2023-04-10T00:00:10Z",
+			Level:     LogLevel("fatal"),
+			Message:   "message",
+		},
+	} {
+		assert.Error(entry.Validate(), title)
+	}
+}
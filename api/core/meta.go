@@ -20,10 +20,11 @@ import "fmt"
 type ResourceType string
 
 const (
-	ResourceTypeAccount = ResourceType("account")
-	ResourceTypeNode    = ResourceType("node")
-	ResourceTypePod     = ResourceType("pod")
-	ResourceTypeRecord  = ResourceType("record")
+	ResourceTypeAccount  = ResourceType("account")
+	ResourceTypeNode     = ResourceType("node")
+	ResourceTypePod      = ResourceType("pod")
+	ResourceTypePodIndex = ResourceType("podIndex")
+	ResourceTypeRecord   = ResourceType("record")
 )
 
 type ObjectMeta struct {
@@ -33,8 +34,17 @@ type ObjectMeta struct {
 	CreatorNode       string       `json:"creatorNode"`
 	Uuid              string       `json:"uuid"`
 	DeletionTimestamp string       `json:"deletionTimestamp"`
-	// TODO: implement parent parameter to cleanup current resource
-	// Parent string `json:"parent"`
+	// uuid of the pod which the resource belongs to, the resource is removed by garbage collection
+	// after the parent pod is removed. the resource is not collected if empty.
+	Parent string `json:"parent,omitempty"`
+	// ResourceVersion is incremented each time the resource is written to the KVS, the update is rejected
+	// if it is not the same as the stored one. 0 means the update does not check the version.
+	ResourceVersion uint64 `json:"resourceVersion,omitempty"`
+	// APIVersion is the schema version of the stored resource, older records are upgraded by ConverterRegistry
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Signature is made by the owner for the resources implementing SignedResource. Validate checks the form
+	// of it, VerifySignature checks the owner with the key of the authority.
+	Signature *Signature `json:"signature,omitempty"`
 }
 
 func (meta *ObjectMeta) Validate(t ResourceType) error {
@@ -63,6 +73,10 @@ func (meta *ObjectMeta) Validate(t ResourceType) error {
 		return fmt.Errorf("uuid of the resource should be specify")
 	}
 
+	if len(meta.Parent) != 0 && meta.Parent == meta.Uuid {
+		return fmt.Errorf("parent of the resource should not be itself")
+	}
+
 	if len(meta.DeletionTimestamp) != 0 {
 		if err := ValidateTimestamp(meta.DeletionTimestamp); err != nil {
 			return fmt.Errorf("invalid deletion timestamp was specified (%s): %w",
@@ -70,5 +84,11 @@ func (meta *ObjectMeta) Validate(t ResourceType) error {
 		}
 	}
 
+	if meta.Signature != nil {
+		if err := meta.Signature.validate(); err != nil {
+			return fmt.Errorf("invalid signature: %w", err)
+		}
+	}
+
 	return nil
 }
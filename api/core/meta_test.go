@@ -41,6 +41,14 @@ func TestMetaValidate(t *testing.T) {
 			Uuid:              "uuid",
 			DeletionTimestamp: "2023-04-10T00:00:10Z",
 		},
+		{
+			Type:        ResourceTypeAccount,
+			Name:        "name",
+			Owner:       "owner",
+			CreatorNode: "01234567890123456789012345678901",
+			Uuid:        "uuid",
+			Parent:      "parent",
+		},
 	} {
 		assert.NoError(meta.Validate(ResourceTypeAccount))
 	}
@@ -70,6 +78,14 @@ func TestMetaValidate(t *testing.T) {
 			Uuid:              "uuid",
 			DeletionTimestamp: "",
 		},
+		"parent is itself": {
+			Type:        ResourceTypeAccount,
+			Name:        "name",
+			Owner:       "owner",
+			CreatorNode: "01234567890123456789012345678901",
+			Uuid:        "uuid",
+			Parent:      "uuid",
+		},
 		"empty owner": {
 			Type: ResourceTypeAccount,
 			Name: "name",
@@ -37,11 +37,34 @@ type Pod struct {
 	Status *PodStatus  `json:"status"`
 }
 
+func (pod *Pod) GetMeta() *ObjectMeta {
+	return pod.Meta
+}
+
+// GetSignedContent returns the spec written by the owner. The target node is signed when the owner migrates the pod,
+// the scheduler of other nodes can set only the creator node, it is treated as not set.
+// LIMITATION: the status including the running node and the restore generation are not signed because they are
+// written by the nodes of other accounts, a peer can still forge them although it can not move the target node.
+func (pod *Pod) GetSignedContent() any {
+	if pod.Spec == nil {
+		return nil
+	}
+	spec := *pod.Spec
+	if pod.Meta != nil && spec.TargetNode == pod.Meta.CreatorNode {
+		spec.TargetNode = ""
+	}
+	spec.RestoreGeneration = 0
+	return &spec
+}
+
 type PodSpec struct {
 	Containers    []ContainerSpec `json:"containers"`
 	TargetNode    string          `json:"targetNode"`
 	Scheduler     *SchedulerSpec  `json:"scheduler"`
 	EnableMigrate bool            `json:"enableMigrate"`
+	Egress        *EgressSpec     `json:"egress,omitempty"`
+	// generation of the record to restart the containers from, it is cleared after the containers terminated
+	RestoreGeneration uint64 `json:"restoreGeneration,omitempty"`
 }
 
 type RestartPolicy string
@@ -114,6 +137,9 @@ type PodStatus struct {
 	RunningNode       string            `json:"runningNode"`
 	Position          *Vector3          `json:"position,omitempty"`
 	ContainerStatuses []ContainerStatus `json:"containerStatuses"`
+	// reason the generation requested by the restart could not be restored,
+	// the containers are not started again until the restore succeeds
+	RestoreFailure string `json:"restoreFailure,omitempty"`
 }
 
 type Vector2 struct {
@@ -241,6 +267,12 @@ func (spec *PodSpec) validate() error {
 		return fmt.Errorf("invalid target node id specified in the pod spec")
 	}
 
+	if spec.Egress != nil {
+		if err := spec.Egress.validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
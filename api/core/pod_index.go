@@ -0,0 +1,161 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+// pods of an account are indexed in POD_INDEX_SHARD_COUNT records to limit the size of each record
+// and the contention of the writers
+const POD_INDEX_SHARD_COUNT = 16
+
+type PodPhase string
+
+const (
+	// the pod is not running on any node yet
+	PodPhasePending     PodPhase = "Pending"
+	PodPhaseRunning     PodPhase = "Running"
+	PodPhaseTerminating PodPhase = "Terminating"
+	PodPhaseTerminated  PodPhase = "Terminated"
+	PodPhaseUnknown     PodPhase = "Unknown"
+)
+
+var PodPhaseAccepted = []PodPhase{
+	PodPhasePending,
+	PodPhaseRunning,
+	PodPhaseTerminating,
+	PodPhaseTerminated,
+	PodPhaseUnknown,
+}
+
+// PodIndex is a shard of the pod index of the account, the name and the owner in the meta are the account.
+type PodIndex struct {
+	Meta  *ObjectMeta `json:"meta"`
+	Shard int         `json:"shard"`
+	// map describing pod's uuid and the entry
+	Entries map[string]PodIndexEntry `json:"entries"`
+}
+
+type PodIndexEntry struct {
+	Name        string   `json:"name"`
+	RunningNode string   `json:"runningNode"`
+	Phase       PodPhase `json:"phase"`
+	Timestamp   string   `json:"timestamp"`
+}
+
+func (index *PodIndex) GetMeta() *ObjectMeta {
+	return index.Meta
+}
+
+// GetPodIndexShard returns the shard number the pod is indexed in
+func GetPodIndexShard(podUuid string) int {
+	hash := sha256.Sum256([]byte(podUuid))
+	return int(hash[0]) % POD_INDEX_SHARD_COUNT
+}
+
+// use sha256 hash of the account and the shard number as the uuid of the shard
+func GeneratePodIndexUuid(account string, shard int) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", account, shard)))
+	return hex.EncodeToString(hash[:])
+}
+
+// GetPhase summarizes the status of the pod
+func (pod *Pod) GetPhase() PodPhase {
+	if len(pod.Meta.DeletionTimestamp) != 0 {
+		return PodPhaseTerminating
+	}
+	if pod.Status == nil || len(pod.Status.RunningNode) == 0 {
+		return PodPhasePending
+	}
+
+	running := 0
+	terminated := 0
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.State.Unknown != nil {
+			return PodPhaseUnknown
+		}
+		if containerStatus.State.Terminated != nil {
+			terminated += 1
+		} else if containerStatus.State.Running != nil {
+			running += 1
+		}
+	}
+
+	if len(pod.Status.ContainerStatuses) != 0 && terminated == len(pod.Status.ContainerStatuses) {
+		return PodPhaseTerminated
+	}
+	if len(pod.Status.ContainerStatuses) != 0 && running+terminated == len(pod.Status.ContainerStatuses) {
+		return PodPhaseRunning
+	}
+	return PodPhasePending
+}
+
+func (index *PodIndex) Validate() error {
+	if index.Meta == nil {
+		return fmt.Errorf("metadata field should be filled")
+	}
+
+	if err := index.Meta.Validate(ResourceTypePodIndex); err != nil {
+		return fmt.Errorf("invalid metadata for pod index of %s %w", index.Meta.Name, err)
+	}
+
+	if index.Meta.Name != index.Meta.Owner {
+		return fmt.Errorf("name of the pod index should be the same as the owner")
+	}
+
+	if index.Shard < 0 || index.Shard >= POD_INDEX_SHARD_COUNT {
+		return fmt.Errorf("shard of the pod index should be in [0, %d)", POD_INDEX_SHARD_COUNT)
+	}
+
+	if index.Meta.Uuid != GeneratePodIndexUuid(index.Meta.Owner, index.Shard) {
+		return fmt.Errorf("invalid uuid for pod index of %s", index.Meta.Owner)
+	}
+
+	if index.Entries == nil {
+		return fmt.Errorf("entries field should be filled")
+	}
+
+	for podUuid, entry := range index.Entries {
+		if err := ValidatePodUuid(podUuid); err != nil {
+			return fmt.Errorf("there is an invalid pod uuid for entries: %w", err)
+		}
+		if GetPodIndexShard(podUuid) != index.Shard {
+			return fmt.Errorf("pod %s should not be indexed in shard %d", podUuid, index.Shard)
+		}
+		if len(entry.Name) == 0 {
+			return fmt.Errorf("name of pod %s should be filled", podUuid)
+		}
+		if len(entry.RunningNode) != 0 {
+			if err := ValidateNodeId(entry.RunningNode); err != nil {
+				return fmt.Errorf("there is an invalid node id for pod %s: %w", podUuid, err)
+			}
+		}
+		if !slices.Contains(PodPhaseAccepted, entry.Phase) {
+			return fmt.Errorf("there is an unsupported phase for pod %s", podUuid)
+		}
+		if err := ValidateTimestamp(entry.Timestamp); err != nil {
+			return fmt.Errorf("there is an invalid timestamp for pod %s: %w", podUuid, err)
+		}
+	}
+
+	return nil
+}
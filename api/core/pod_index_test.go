@@ -0,0 +1,145 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPodIndexShard(t *testing.T) {
+	assert := assert.New(t)
+
+	shards := make(map[int]bool)
+	for i := 0; i < 256; i++ {
+		podUuid := GeneratePodUuid()
+		shard := GetPodIndexShard(podUuid)
+		assert.Equal(shard, GetPodIndexShard(podUuid))
+		assert.GreaterOrEqual(shard, 0)
+		assert.Less(shard, POD_INDEX_SHARD_COUNT)
+		shards[shard] = true
+	}
+	// pods should be spread over the shards
+	assert.Greater(len(shards), POD_INDEX_SHARD_COUNT/2)
+
+	assert.Equal(GeneratePodIndexUuid("account", 1), GeneratePodIndexUuid("account", 1))
+	assert.NotEqual(GeneratePodIndexUuid("account", 1), GeneratePodIndexUuid("account", 2))
+	assert.NotEqual(GeneratePodIndexUuid("account", 1), GeneratePodIndexUuid("other", 1))
+}
+
+func TestPodPhase(t *testing.T) {
+	assert := assert.New(t)
+	nid := "0123456789abcdef0123456789abcdef"
+	running := ContainerState{Running: &ContainerStateRunning{StartedAt: "2023-04-15T17:30:40+09:00"}}
+
+	pod := &Pod{
+		Meta:   &ObjectMeta{},
+		Status: &PodStatus{},
+	}
+	assert.Equal(PodPhasePending, pod.GetPhase())
+
+	pod.Status.RunningNode = nid
+	pod.Status.ContainerStatuses = []ContainerStatus{{}, {State: running}}
+	assert.Equal(PodPhasePending, pod.GetPhase())
+
+	pod.Status.ContainerStatuses[0].State = running
+	assert.Equal(PodPhaseRunning, pod.GetPhase())
+
+	pod.Status.ContainerStatuses[0].State.Terminated = &ContainerStateTerminated{}
+	assert.Equal(PodPhaseRunning, pod.GetPhase())
+	pod.Status.ContainerStatuses[1].State = ContainerState{
+		Running:    running.Running,
+		Terminated: &ContainerStateTerminated{},
+	}
+	assert.Equal(PodPhaseTerminated, pod.GetPhase())
+
+	pod.Status.ContainerStatuses[1].State = ContainerState{Unknown: &ContainerStateUnknown{}}
+	assert.Equal(PodPhaseUnknown, pod.GetPhase())
+
+	pod.Meta.DeletionTimestamp = "2023-04-15T17:30:40+09:00"
+	assert.Equal(PodPhaseTerminating, pod.GetPhase())
+}
+
+func TestPodIndexValidate(t *testing.T) {
+	assert := assert.New(t)
+	account := "account"
+	podUuid := GeneratePodUuid()
+	shard := GetPodIndexShard(podUuid)
+
+	makeIndex := func() *PodIndex {
+		return &PodIndex{
+			Meta: &ObjectMeta{
+				Type:        ResourceTypePodIndex,
+				Name:        account,
+				Owner:       account,
+				CreatorNode: "0123456789abcdef0123456789abcdef",
+				Uuid:        GeneratePodIndexUuid(account, shard),
+			},
+			Shard: shard,
+			Entries: map[string]PodIndexEntry{
+				podUuid: {
+					Name:        "pod",
+					RunningNode: "0123456789abcdef0123456789abcdef",
+					Phase:       PodPhaseRunning,
+					Timestamp:   "2023-04-15T17:30:40+09:00",
+				},
+			},
+		}
+	}
+	assert.NoError(makeIndex().Validate())
+
+	index := makeIndex()
+	index.Meta.Name = "other"
+	assert.Error(index.Validate())
+
+	index = makeIndex()
+	index.Shard = POD_INDEX_SHARD_COUNT
+	assert.Error(index.Validate())
+
+	index = makeIndex()
+	index.Meta.Uuid = GeneratePodIndexUuid(account, (shard+1)%POD_INDEX_SHARD_COUNT)
+	assert.Error(index.Validate())
+
+	index = makeIndex()
+	index.Entries = nil
+	assert.Error(index.Validate())
+
+	// pod in the wrong shard
+	index = makeIndex()
+	for {
+		other := GeneratePodUuid()
+		if GetPodIndexShard(other) != shard {
+			index.Entries[other] = index.Entries[podUuid]
+			break
+		}
+	}
+	assert.Error(index.Validate())
+
+	for _, modify := range []func(entry *PodIndexEntry){
+		func(entry *PodIndexEntry) { entry.Name = "" },
+		func(entry *PodIndexEntry) { entry.RunningNode = "node" },
+		func(entry *PodIndexEntry) { entry.Phase = "Sleeping" },
+		func(entry *PodIndexEntry) { entry.Timestamp = "now" },
+	} {
+		index = makeIndex()
+		entry := index.Entries[podUuid]
+		modify(&entry)
+		index.Entries[podUuid] = entry
+		assert.Error(index.Validate())
+	}
+}
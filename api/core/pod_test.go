@@ -228,6 +228,23 @@ func TestValidatePodSpec(t *testing.T) {
 				},
 			},
 		},
+		"with egress": {
+			Containers: []ContainerSpec{
+				{
+					Name:          "test",
+					Image:         "http://localhost/test.wasm",
+					Runtime:       []string{"go:1.20"},
+					RestartPolicy: RestartPolicyAlways,
+				},
+			},
+			Egress: &EgressSpec{
+				Allow: []EgressRule{
+					{
+						Host: "example.com",
+					},
+				},
+			},
+		},
 	} {
 		assert.NoError(spec.validate(), title)
 	}
@@ -315,6 +332,23 @@ func TestValidatePodSpec(t *testing.T) {
 				},
 			},
 		},
+		"invalid egress": {
+			Containers: []ContainerSpec{
+				{
+					Name:          "test",
+					Image:         "http://localhost/test.wasm",
+					Runtime:       []string{"go:1.20"},
+					RestartPolicy: RestartPolicyAlways,
+				},
+			},
+			Egress: &EgressSpec{
+				Allow: []EgressRule{
+					{
+						Host: "",
+					},
+				},
+			},
+		},
 	} {
 		assert.Error(spec.validate(), title)
 	}
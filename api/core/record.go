@@ -22,9 +22,15 @@ type Record struct {
 	Data *RecordData `json:"data"`
 }
 
+func (record *Record) GetMeta() *ObjectMeta {
+	return record.Meta
+}
+
 type RecordEntry struct {
 	Timestamp string `json:"timestamp"`
 	Record    []byte
+	// pending timers of the container, they are restored when the container is set up again
+	Timers []Timer `json:"timers,omitempty"`
 }
 
 type RecordData struct {
@@ -32,6 +38,25 @@ type RecordData struct {
 	Entries map[string]RecordEntry `json:"entries"`
 }
 
+// RecordGeneration is the summary of a generation of the record kept in the history
+type RecordGeneration struct {
+	// generation number, it increases every time the record is written
+	Generation uint64 `json:"generation"`
+	Timestamp  string `json:"timestamp"`
+	// the node wrote the generation
+	SourceNode string `json:"sourceNode"`
+	// generation copied to this generation by the restore, 0 if it is written by the containers
+	RestoredFrom uint64 `json:"restoredFrom,omitempty"`
+	// key: container name
+	Containers map[string]RecordGenerationContainer `json:"containers"`
+}
+
+type RecordGenerationContainer struct {
+	Timestamp string `json:"timestamp"`
+	// size of the record
+	Size int `json:"size"`
+}
+
 func (record *Record) Validate() error {
 	if err := record.Meta.Validate(ResourceTypeRecord); err != nil {
 		return fmt.Errorf("invalid meta field: %s", err)
@@ -57,6 +82,12 @@ func (data *RecordData) Validate() error {
 		if err := ValidateTimestamp(entry.Timestamp); err != nil {
 			return fmt.Errorf("invalid timestamp: %s", err)
 		}
+
+		for _, timer := range entry.Timers {
+			if err := timer.Validate(true); err != nil {
+				return fmt.Errorf("invalid timer: %s", err)
+			}
+		}
 	}
 
 	return nil
@@ -0,0 +1,228 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	nodeKeyInfo     = "oinari/node/signing-key"
+	certificateInfo = "oinari/account-key-certificate"
+	credentialInfo  = "oinari/request-credential"
+)
+
+var ErrInvalidSignature = errors.New("the signature of the resource is invalid")
+
+// Signature is made by a node of the owner account. The public key of the node and the certificate of the key
+// made by the authority (the seed) are embedded, so that the signature is verified only with the public key
+// of the authority and nobody other than the owner can make a valid signature even by rewriting the account record.
+type Signature struct {
+	KeyID       string `json:"keyID"`
+	Value       []byte `json:"value"`
+	PublicKey   []byte `json:"publicKey"`
+	Certificate []byte `json:"certificate"`
+}
+
+// Credential proves the request is sent by a node of the account. It is bound to the destination node,
+// the time and the content of the request, so it can not be reused for other requests for long.
+type Credential struct {
+	Account   string     `json:"account"`
+	Target    string     `json:"target"`
+	Timestamp string     `json:"timestamp"`
+	Signature *Signature `json:"signature"`
+}
+
+// SignedResource is a resource having the content signed by the owner, other fields of the resource can be
+// written by the nodes of other accounts.
+type SignedResource interface {
+	GetMeta() *ObjectMeta
+	// GetSignedContent returns the part of the resource written only by the owner, it is encoded to JSON
+	GetSignedContent() any
+}
+
+// signedMeta is the part of ObjectMeta covered by the signature, the versions are written by any writer
+type signedMeta struct {
+	Type              ResourceType `json:"type"`
+	Name              string       `json:"name"`
+	Owner             string       `json:"owner"`
+	CreatorNode       string       `json:"creatorNode"`
+	Uuid              string       `json:"uuid"`
+	DeletionTimestamp string       `json:"deletionTimestamp"`
+	Parent            string       `json:"parent"`
+}
+
+// GetKeyID returns the id of the public key published on the account record
+func GetKeyID(publicKey ed25519.PublicKey) string {
+	hash := sha256.Sum256(publicKey)
+	return hex.EncodeToString(hash[:8])
+}
+
+// DeriveNodeKey returns the signing key derived from the key shared by the nodes of the account,
+// the seed derives the same key to certify it.
+func DeriveNodeKey(accountKey []byte) ed25519.PrivateKey {
+	mac := hmac.New(sha256.New, accountKey)
+	mac.Write([]byte(nodeKeyInfo))
+	return ed25519.NewKeyFromSeed(mac.Sum(nil)[:ed25519.SeedSize])
+}
+
+func getCertificatePayload(account string, publicKey []byte) []byte {
+	payload := []byte(certificateInfo)
+	payload = append(payload, 0)
+	payload = append(payload, []byte(account)...)
+	payload = append(payload, 0)
+	return append(payload, publicKey...)
+}
+
+// CertifyKey returns the certificate telling the public key belongs to the account, it is made by the authority
+func CertifyKey(authority ed25519.PrivateKey, account string, publicKey ed25519.PublicKey) []byte {
+	return ed25519.Sign(authority, getCertificatePayload(account, publicKey))
+}
+
+// VerifyKeyCertificate checks the public key is certified for the account by the authority
+func VerifyKeyCertificate(authority ed25519.PublicKey, account string, publicKey, certificate []byte) error {
+	if len(authority) != ed25519.PublicKeySize {
+		return fmt.Errorf("the key of the authority is not available: %w", ErrInvalidSignature)
+	}
+	if len(publicKey) != ed25519.PublicKeySize ||
+		!ed25519.Verify(authority, getCertificatePayload(account, publicKey), certificate) {
+		return fmt.Errorf("the key %s is not certified for %s: %w", GetKeyID(publicKey), account, ErrInvalidSignature)
+	}
+	return nil
+}
+
+// GetSignedPayload returns the data signed by the owner
+func GetSignedPayload(resource SignedResource) ([]byte, error) {
+	meta := resource.GetMeta()
+	payload, err := json.Marshal(struct {
+		Meta    signedMeta `json:"meta"`
+		Content any        `json:"content"`
+	}{
+		Meta: signedMeta{
+			Type:              meta.Type,
+			Name:              meta.Name,
+			Owner:             meta.Owner,
+			CreatorNode:       meta.CreatorNode,
+			Uuid:              meta.Uuid,
+			DeletionTimestamp: meta.DeletionTimestamp,
+			Parent:            meta.Parent,
+		},
+		Content: resource.GetSignedContent(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the signed payload: %w", err)
+	}
+	return payload, nil
+}
+
+// Sign sets the signature of the resource made with the private key and the certificate of the key
+func Sign(resource SignedResource, privateKey ed25519.PrivateKey, certificate []byte) error {
+	payload, err := GetSignedPayload(resource)
+	if err != nil {
+		return err
+	}
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	resource.GetMeta().Signature = &Signature{
+		KeyID:       GetKeyID(publicKey),
+		Value:       ed25519.Sign(privateKey, payload),
+		PublicKey:   publicKey,
+		Certificate: certificate,
+	}
+	return nil
+}
+
+// VerifySignature checks the resource is signed with a key certified for the owner by the authority,
+// the resources without the signature are rejected.
+func VerifySignature(resource SignedResource, authority ed25519.PublicKey) error {
+	payload, err := GetSignedPayload(resource)
+	if err != nil {
+		return err
+	}
+	return resource.GetMeta().VerifySignature(payload, authority)
+}
+
+func (meta *ObjectMeta) VerifySignature(payload []byte, authority ed25519.PublicKey) error {
+	return verifySignature(meta.Signature, meta.Owner, payload, authority)
+}
+
+func getCredentialPayload(credential *Credential, content []byte) []byte {
+	payload := []byte(credentialInfo)
+	for _, field := range []string{credential.Account, credential.Target, credential.Timestamp} {
+		payload = append(payload, 0)
+		payload = append(payload, []byte(field)...)
+	}
+	payload = append(payload, 0)
+	return append(payload, content...)
+}
+
+// SignCredential sets the signature of the credential for the content of the request
+func SignCredential(credential *Credential, content []byte, privateKey ed25519.PrivateKey, certificate []byte) {
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	credential.Signature = &Signature{
+		KeyID:       GetKeyID(publicKey),
+		Value:       ed25519.Sign(privateKey, getCredentialPayload(credential, content)),
+		PublicKey:   publicKey,
+		Certificate: certificate,
+	}
+}
+
+// VerifyCredential checks the credential for the content is signed with a key certified for the account by
+// the authority, the destination and the time should be checked by the caller.
+func VerifyCredential(credential *Credential, content []byte, authority ed25519.PublicKey) error {
+	if credential == nil {
+		return fmt.Errorf("the credential is not given: %w", ErrInvalidSignature)
+	}
+	return verifySignature(credential.Signature, credential.Account, getCredentialPayload(credential, content), authority)
+}
+
+func verifySignature(signature *Signature, owner string, payload []byte, authority ed25519.PublicKey) error {
+	if signature == nil {
+		return fmt.Errorf("the resource is not signed: %w", ErrInvalidSignature)
+	}
+	if err := signature.validate(); err != nil {
+		return fmt.Errorf("%s: %w", err.Error(), ErrInvalidSignature)
+	}
+	if err := VerifyKeyCertificate(authority, owner, signature.PublicKey, signature.Certificate); err != nil {
+		return err
+	}
+	if !ed25519.Verify(signature.PublicKey, payload, signature.Value) {
+		return fmt.Errorf("the signature does not match the key %s: %w", signature.KeyID, ErrInvalidSignature)
+	}
+	return nil
+}
+
+func (signature *Signature) validate() error {
+	if len(signature.PublicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("size of the public key should be %d", ed25519.PublicKeySize)
+	}
+	if signature.KeyID != GetKeyID(signature.PublicKey) {
+		return fmt.Errorf("key id of the signature does not match the public key")
+	}
+	if len(signature.Value) != ed25519.SignatureSize {
+		return fmt.Errorf("size of the signature should be %d", ed25519.SignatureSize)
+	}
+	if len(signature.Certificate) != ed25519.SignatureSize {
+		return fmt.Errorf("size of the certificate should be %d", ed25519.SignatureSize)
+	}
+	return nil
+}
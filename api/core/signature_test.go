@@ -0,0 +1,208 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignature(t *testing.T) {
+	assert := assert.New(t)
+	authorityPublicKey, authorityKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(err)
+	privateKey := DeriveNodeKey([]byte("account key"))
+	certificate := CertifyKey(authorityKey, "owner", privateKey.Public().(ed25519.PublicKey))
+	assert.NoError(VerifyKeyCertificate(authorityPublicKey, "owner", privateKey.Public().(ed25519.PublicKey), certificate))
+
+	pod := &Pod{
+		Meta: &ObjectMeta{
+			Type:        ResourceTypePod,
+			Name:        "pod",
+			Owner:       "owner",
+			CreatorNode: "0123456789abcdef0123456789abcdef",
+			Uuid:        GeneratePodUuid(),
+		},
+		Spec: &PodSpec{
+			Containers: []ContainerSpec{
+				{
+					Name:  "container",
+					Image: "http://localhost/dummy.wasm",
+				},
+			},
+		},
+		Status: &PodStatus{},
+	}
+	assert.ErrorIs(VerifySignature(pod, authorityPublicKey), ErrInvalidSignature)
+
+	assert.NoError(Sign(pod, privateKey, certificate))
+	assert.NoError(pod.Meta.Validate(ResourceTypePod))
+	assert.NoError(VerifySignature(pod, authorityPublicKey))
+
+	// signature is kept by encoding and the fields written by other nodes
+	raw, err := json.Marshal(pod)
+	assert.NoError(err)
+	decoded := &Pod{}
+	assert.NoError(json.Unmarshal(raw, decoded))
+	decoded.Meta.ResourceVersion = 10
+	decoded.Meta.APIVersion = API_VERSION_CORE_V1
+	decoded.Spec.TargetNode = decoded.Meta.CreatorNode
+	decoded.Spec.RestoreGeneration = 1
+	decoded.Status.RunningNode = decoded.Meta.CreatorNode
+	assert.NoError(VerifySignature(decoded, authorityPublicKey))
+
+	// the fields written by the owner
+	for _, modify := range []func(pod *Pod){
+		func(pod *Pod) { pod.Meta.Owner = "other" },
+		func(pod *Pod) { pod.Meta.Name = "other" },
+		func(pod *Pod) { pod.Meta.DeletionTimestamp = "2024-01-01T00:00:00Z" },
+		func(pod *Pod) { pod.Spec.Containers[0].Image = "http://localhost/other.wasm" },
+		func(pod *Pod) { pod.Spec.EnableMigrate = true },
+		func(pod *Pod) { pod.Spec.TargetNode = "fedcba9876543210fedcba9876543210" },
+	} {
+		modified := &Pod{}
+		assert.NoError(json.Unmarshal(raw, modified))
+		modify(modified)
+		assert.ErrorIs(VerifySignature(modified, authorityPublicKey), ErrInvalidSignature)
+	}
+
+	// the target node set by the owner
+	pod.Spec.TargetNode = "fedcba9876543210fedcba9876543210"
+	assert.NoError(Sign(pod, privateKey, certificate))
+	assert.NoError(VerifySignature(pod, authorityPublicKey))
+
+	// keys not certified by the authority
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(err)
+	assert.NoError(Sign(pod, otherKey, certificate))
+	assert.ErrorIs(VerifySignature(pod, authorityPublicKey), ErrInvalidSignature)
+	assert.NoError(Sign(pod, otherKey, CertifyKey(otherKey, "owner", otherKey.Public().(ed25519.PublicKey))))
+	assert.ErrorIs(VerifySignature(pod, authorityPublicKey), ErrInvalidSignature)
+
+	// the key certified for another account
+	otherCertificate := CertifyKey(authorityKey, "other", otherKey.Public().(ed25519.PublicKey))
+	assert.NoError(Sign(pod, otherKey, otherCertificate))
+	assert.ErrorIs(VerifySignature(pod, authorityPublicKey), ErrInvalidSignature)
+
+	// invalid signature format
+	assert.NoError(Sign(pod, privateKey, certificate))
+	pod.Meta.Signature.Value = pod.Meta.Signature.Value[1:]
+	assert.Error(pod.Meta.Validate(ResourceTypePod))
+	assert.NoError(Sign(pod, privateKey, certificate))
+	pod.Meta.Signature.KeyID = "0123456789abcdef"
+	assert.Error(pod.Meta.Validate(ResourceTypePod))
+	pod.Meta.Signature = &Signature{Value: make([]byte, ed25519.SignatureSize)}
+	assert.Error(pod.Meta.Validate(ResourceTypePod))
+}
+
+func TestAccountKeys(t *testing.T) {
+	assert := assert.New(t)
+	authorityPublicKey, authorityKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(err)
+	privateKey := DeriveNodeKey([]byte("account key"))
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	certificate := CertifyKey(authorityKey, "account", publicKey)
+
+	account := &Account{
+		Meta: &ObjectMeta{
+			Type:        ResourceTypeAccount,
+			Name:        "account",
+			Owner:       "account",
+			CreatorNode: "0123456789abcdef0123456789abcdef",
+			Uuid:        GenerateAccountUuid("account"),
+		},
+		State: &AccountState{
+			Pods:  map[string]AccountPodState{},
+			Nodes: map[string]AccountNodeState{},
+			Keys: map[string]AccountKey{
+				GetKeyID(publicKey): {
+					PublicKey:   publicKey,
+					Timestamp:   "2024-01-01T00:00:00Z",
+					Certificate: certificate,
+				},
+			},
+		},
+	}
+	assert.NoError(account.Validate())
+	assert.NoError(Sign(account, privateKey, certificate))
+	assert.NoError(VerifySignature(account, authorityPublicKey))
+
+	// the states and the keys written by other nodes are not signed
+	account.State.Pods[GeneratePodUuid()] = AccountPodState{Timestamp: "2024-01-01T00:00:00Z"}
+	delete(account.State.Keys, GetKeyID(publicKey))
+	assert.NoError(VerifySignature(account, authorityPublicKey))
+
+	// key id should match the public key
+	account.State.Keys["0123456789abcdef"] = AccountKey{
+		PublicKey:   publicKey,
+		Timestamp:   "2024-01-01T00:00:00Z",
+		Certificate: certificate,
+	}
+	assert.Error(account.Validate())
+
+	// the certificate should be set
+	account.State.Keys = map[string]AccountKey{
+		GetKeyID(publicKey): {
+			PublicKey: publicKey,
+			Timestamp: "2024-01-01T00:00:00Z",
+		},
+	}
+	assert.Error(account.Validate())
+}
+
+func TestCredential(t *testing.T) {
+	assert := assert.New(t)
+	authorityPublicKey, authorityKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(err)
+	privateKey := DeriveNodeKey([]byte("account key"))
+	certificate := CertifyKey(authorityKey, "owner", privateKey.Public().(ed25519.PublicKey))
+
+	credential := &Credential{
+		Account:   "owner",
+		Target:    "0123456789abcdef0123456789abcdef",
+		Timestamp: "2024-01-01T00:00:00Z",
+	}
+	assert.ErrorIs(VerifyCredential(credential, []byte("content"), authorityPublicKey), ErrInvalidSignature)
+	assert.ErrorIs(VerifyCredential(nil, []byte("content"), authorityPublicKey), ErrInvalidSignature)
+
+	SignCredential(credential, []byte("content"), privateKey, certificate)
+	assert.NoError(VerifyCredential(credential, []byte("content"), authorityPublicKey))
+	assert.ErrorIs(VerifyCredential(credential, []byte("other"), authorityPublicKey), ErrInvalidSignature)
+
+	for _, modify := range []func(credential *Credential){
+		func(credential *Credential) { credential.Account = "other" },
+		func(credential *Credential) { credential.Target = "fedcba9876543210fedcba9876543210" },
+		func(credential *Credential) { credential.Timestamp = "2024-01-01T00:00:01Z" },
+	} {
+		modified := *credential
+		modify(&modified)
+		assert.ErrorIs(VerifyCredential(&modified, []byte("content"), authorityPublicKey), ErrInvalidSignature)
+	}
+
+	// the credential signed with the key of other account
+	otherKey := DeriveNodeKey([]byte("other key"))
+	forged := &Credential{
+		Account:   "owner",
+		Target:    "0123456789abcdef0123456789abcdef",
+		Timestamp: "2024-01-01T00:00:00Z",
+	}
+	SignCredential(forged, []byte("content"), otherKey, CertifyKey(authorityKey, "other", otherKey.Public().(ed25519.PublicKey)))
+	assert.ErrorIs(VerifyCredential(forged, []byte("content"), authorityPublicKey), ErrInvalidSignature)
+}
@@ -0,0 +1,302 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+type TimerType string
+
+const (
+	// fire once after the interval
+	TimerTypeAfter TimerType = "after"
+	// fire repeatedly at the interval
+	TimerTypeEvery TimerType = "every"
+	// fire at the time matched with the cron expression
+	TimerTypeCron TimerType = "cron"
+)
+
+var TimerTypeAccepted = []TimerType{
+	TimerTypeAfter,
+	TimerTypeEvery,
+	TimerTypeCron,
+}
+
+const (
+	TIMER_NAME_MAX_LENGTH  = 64
+	TIMER_PAYLOAD_MAX_SIZE = 4096
+	TIMER_MIN_INTERVAL     = 1000
+)
+
+type Timer struct {
+	ID   string    `json:"id"`
+	Name string    `json:"name"`
+	Type TimerType `json:"type"`
+	// interval in milliseconds for after and every type timer
+	Interval int64 `json:"interval,omitempty"`
+	// cron expression for cron type timer
+	Cron    string `json:"cron,omitempty"`
+	Payload []byte `json:"payload,omitempty"`
+	// timestamp when the timer will fire next time, it is filled by the node
+	NextAt string `json:"nextAt,omitempty"`
+}
+
+func GenerateTimerID() string {
+	return uuid.Must(uuid.NewRandom()).String()
+}
+
+// Validate checks the timer specified by the application, mustSchedule requires the fields filled by the node
+func (timer *Timer) Validate(mustSchedule bool) error {
+	if len(timer.Name) == 0 || len(timer.Name) > TIMER_NAME_MAX_LENGTH {
+		return fmt.Errorf("length of the timer name should be 1 to %d", TIMER_NAME_MAX_LENGTH)
+	}
+
+	if !slices.Contains(TimerTypeAccepted, timer.Type) {
+		return fmt.Errorf("unsupported timer type: %s", timer.Type)
+	}
+
+	switch timer.Type {
+	case TimerTypeAfter:
+		if timer.Interval < 0 {
+			return fmt.Errorf("interval of the timer should not be negative")
+		}
+		if len(timer.Cron) != 0 {
+			return fmt.Errorf("cron field should be empty for %s timer", timer.Type)
+		}
+
+	case TimerTypeEvery:
+		if timer.Interval < TIMER_MIN_INTERVAL {
+			return fmt.Errorf("interval of the timer should be %d ms or more", TIMER_MIN_INTERVAL)
+		}
+		if len(timer.Cron) != 0 {
+			return fmt.Errorf("cron field should be empty for %s timer", timer.Type)
+		}
+
+	case TimerTypeCron:
+		if timer.Interval != 0 {
+			return fmt.Errorf("interval field should be empty for %s timer", timer.Type)
+		}
+		if _, err := ParseCron(timer.Cron); err != nil {
+			return fmt.Errorf("invalid cron expression: %w", err)
+		}
+	}
+
+	if len(timer.Payload) > TIMER_PAYLOAD_MAX_SIZE {
+		return fmt.Errorf("payload of the timer should be %d bytes or less", TIMER_PAYLOAD_MAX_SIZE)
+	}
+
+	if !mustSchedule {
+		return nil
+	}
+
+	if _, err := uuid.Parse(timer.ID); err != nil {
+		return fmt.Errorf("invalid timer id (%s): %w", timer.ID, err)
+	}
+
+	if err := ValidateTimestamp(timer.NextAt); err != nil {
+		return fmt.Errorf("invalid nextAt field of the timer: %w", err)
+	}
+
+	return nil
+}
+
+// Next returns the time when the timer should fire after `from`
+// the second value is false if the timer should not fire any more.
+func (timer *Timer) Next(from time.Time, fired bool) (time.Time, bool, error) {
+	switch timer.Type {
+	case TimerTypeAfter:
+		if fired {
+			return time.Time{}, false, nil
+		}
+		return from.Add(time.Duration(timer.Interval) * time.Millisecond), true, nil
+
+	case TimerTypeEvery:
+		return from.Add(time.Duration(timer.Interval) * time.Millisecond), true, nil
+
+	case TimerTypeCron:
+		schedule, err := ParseCron(timer.Cron)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		next, ok := schedule.Next(from)
+		return next, ok, nil
+	}
+
+	return time.Time{}, false, fmt.Errorf("unsupported timer type: %s", timer.Type)
+}
+
+// CronSchedule is a parsed cron expression, it has 5 fields "minute hour day-of-month month day-of-week".
+// Each field accepts `*`, numbers, ranges `a-b`, lists `a,b` and steps `*/n` or `a-b/n`. Times are evaluated in UTC.
+type CronSchedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	anyDom bool
+	anyDow bool
+}
+
+type cronField struct {
+	min int
+	max int
+}
+
+var cronFields = []cronField{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, 0 and 7 are sunday
+}
+
+// cron expression never matches if there is no matched time in this period
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+func ParseCron(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression should have %d fields", len(cronFields))
+	}
+
+	bits := make([]uint64, len(cronFields))
+	for idx, field := range fields {
+		var err error
+		bits[idx], err = parseCronField(field, cronFields[idx])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron field (%s): %w", field, err)
+		}
+	}
+
+	// sunday can be specified as 0 or 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &CronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		// same as the standard cron, the day fields starting with `*` like `*/2` are not treated as restricted
+		anyDom: strings.HasPrefix(fields[2], "*"),
+		anyDow: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("step should be positive number")
+			}
+		}
+
+		var begin, end int
+		if rangePart == "*" {
+			begin = spec.min
+			end = spec.max
+
+		} else if b, e, isRange := strings.Cut(rangePart, "-"); isRange {
+			var err error
+			if begin, err = strconv.Atoi(b); err != nil {
+				return 0, fmt.Errorf("range should be numbers")
+			}
+			if end, err = strconv.Atoi(e); err != nil {
+				return 0, fmt.Errorf("range should be numbers")
+			}
+
+		} else {
+			var err error
+			if begin, err = strconv.Atoi(rangePart); err != nil {
+				return 0, fmt.Errorf("value should be number or `*`")
+			}
+			end = begin
+			// `a/n` means from a to the max
+			if hasStep {
+				end = spec.max
+			}
+		}
+
+		if begin < spec.min || end > spec.max || begin > end {
+			return 0, fmt.Errorf("value should be in %d to %d", spec.min, spec.max)
+		}
+
+		for v := begin; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	// same as the standard cron, match either if both of day fields are restricted
+	if !s.anyDom && !s.anyDow {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next returns the earliest time matched with the schedule after `from`, false if there is no such time.
+func (s *CronSchedule) Next(from time.Time) (time.Time, bool) {
+	t := from.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t, true
+	}
+
+	return time.Time{}, false
+}
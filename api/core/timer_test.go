@@ -0,0 +1,204 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTimer(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, timer := range []Timer{
+		{
+			Name:     "after",
+			Type:     TimerTypeAfter,
+			Interval: 0,
+		},
+		{
+			Name:     "every",
+			Type:     TimerTypeEvery,
+			Interval: 1000,
+			Payload:  []byte("payload"),
+		},
+		{
+			Name: "cron",
+			Type: TimerTypeCron,
+			Cron: "*/5 0-12 * * 1,3,5",
+		},
+	} {
+		assert.NoError(timer.Validate(false))
+	}
+
+	assert.NoError((&Timer{
+		ID:       "23f8f1e2-8acd-4ee7-bb2f-0b0c0e5f2a05",
+		Name:     "scheduled",
+		Type:     TimerTypeAfter,
+		Interval: 10,
+		NextAt:   "2023-04-10T00:00:10Z",
+	}).Validate(true))
+
+	for title, timer := range map[string]Timer{
+		"empty name": {
+			Type:     TimerTypeAfter,
+			Interval: 10,
+		},
+		"unsupported type": {
+			Name: "timer",
+			Type: TimerType("once"),
+		},
+		"negative interval": {
+			Name:     "timer",
+			Type:     TimerTypeAfter,
+			Interval: -1,
+		},
+		"too short interval": {
+			Name:     "timer",
+			Type:     TimerTypeEvery,
+			Interval: 10,
+		},
+		"cron with interval": {
+			Name:     "timer",
+			Type:     TimerTypeCron,
+			Interval: 1000,
+			Cron:     "* * * * *",
+		},
+		"every with cron": {
+			Name:     "timer",
+			Type:     TimerTypeEvery,
+			Interval: 1000,
+			Cron:     "* * * * *",
+		},
+		"invalid cron": {
+			Name: "timer",
+			Type: TimerTypeCron,
+			Cron: "* * *",
+		},
+		"too large payload": {
+			Name:    "timer",
+			Type:    TimerTypeAfter,
+			Payload: make([]byte, TIMER_PAYLOAD_MAX_SIZE+1),
+		},
+	} {
+		assert.Error(timer.Validate(false), title)
+	}
+
+	for title, timer := range map[string]Timer{
+		"empty id": {
+			Name:   "timer",
+			Type:   TimerTypeAfter,
+			NextAt: "2023-04-10T00:00:10Z",
+		},
+		"empty nextAt": {
+			ID:   "23f8f1e2-8acd-4ee7-bb2f-0b0c0e5f2a05",
+			Name: "timer",
+			Type: TimerTypeAfter,
+		},
+	} {
+		assert.Error(timer.Validate(true), title)
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, expr := range []string{
+		"* * * * *",
+		"0 0 1 1 0",
+		"59 23 31 12 7",
+		"*/15 9-17/2 1,15 * 1-5",
+		"5/10 * * * *",
+	} {
+		_, err := ParseCron(expr)
+		assert.NoError(err, expr)
+	}
+
+	for title, expr := range map[string]string{
+		"empty":              "",
+		"too few fields":     "* * * *",
+		"too many fields":    "* * * * * *",
+		"out of range":       "60 * * * *",
+		"zero day of month":  "* * 0 * *",
+		"reversed range":     "* 10-5 * * *",
+		"zero step":          "*/0 * * * *",
+		"not a number":       "a * * * *",
+		"empty list element": "1,,2 * * * *",
+	} {
+		_, err := ParseCron(expr)
+		assert.Error(err, title)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	assert := assert.New(t)
+	base := time.Date(2023, 4, 10, 10, 20, 30, 0, time.UTC) // monday
+
+	for expr, expected := range map[string]time.Time{
+		"* * * * *":    time.Date(2023, 4, 10, 10, 21, 0, 0, time.UTC),
+		"*/15 * * * *": time.Date(2023, 4, 10, 10, 30, 0, 0, time.UTC),
+		"0 9 * * *":    time.Date(2023, 4, 11, 9, 0, 0, 0, time.UTC),
+		"0 0 1 * *":    time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 0":    time.Date(2023, 4, 16, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":    time.Date(2023, 4, 16, 0, 0, 0, 0, time.UTC),
+		// either of day of month or day of week matches if both are restricted
+		"0 0 13 * 3": time.Date(2023, 4, 12, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *": time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		// both of day fields should match if one of them starts with `*`
+		"0 0 */2 * 1": time.Date(2023, 4, 17, 0, 0, 0, 0, time.UTC),
+		"0 0 1 * */2": time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		schedule, err := ParseCron(expr)
+		assert.NoError(err, expr)
+		next, ok := schedule.Next(base)
+		assert.True(ok, expr)
+		assert.Equal(expected, next, expr)
+	}
+
+	// never matches
+	schedule, err := ParseCron("0 0 31 2 *")
+	assert.NoError(err)
+	_, ok := schedule.Next(base)
+	assert.False(ok)
+}
+
+func TestTimerNext(t *testing.T) {
+	assert := assert.New(t)
+	base := time.Date(2023, 4, 10, 10, 20, 30, 0, time.UTC)
+
+	after := &Timer{Name: "after", Type: TimerTypeAfter, Interval: 1500}
+	next, ok, err := after.Next(base, false)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(base.Add(1500*time.Millisecond), next)
+	_, ok, err = after.Next(base, true)
+	assert.NoError(err)
+	assert.False(ok)
+
+	every := &Timer{Name: "every", Type: TimerTypeEvery, Interval: 2000}
+	next, ok, err = every.Next(base, true)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(base.Add(2*time.Second), next)
+
+	cron := &Timer{Name: "cron", Type: TimerTypeCron, Cron: "30 * * * *"}
+	next, ok, err = cron.Next(base, true)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(time.Date(2023, 4, 10, 10, 30, 0, 0, time.UTC), next)
+}
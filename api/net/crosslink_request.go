@@ -0,0 +1,50 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package net
+
+type FetchRequest struct {
+	Method string              `json:"method"`
+	URL    string              `json:"url"`
+	Header map[string][]string `json:"header,omitempty"`
+	Body   []byte              `json:"body,omitempty"`
+}
+
+type FetchResponse struct {
+	// id to read the response body by ReadRequest
+	StreamID string              `json:"streamID"`
+	Status   int                 `json:"status"`
+	Header   map[string][]string `json:"header"`
+}
+
+type ReadRequest struct {
+	StreamID string `json:"streamID"`
+	// max size to read
+	Size int `json:"size"`
+}
+
+type ReadResponse struct {
+	Data []byte `json:"data"`
+	EOF  bool   `json:"eof"`
+}
+
+type CloseRequest struct {
+	StreamID string `json:"streamID"`
+}
+
+type CloseResponse struct {
+	// empty
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package net
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	FETCH_REQUEST_BODY_MAX_SIZE = 1024 * 1024
+	FETCH_READ_MAX_SIZE         = 64 * 1024
+)
+
+func (req *FetchRequest) Validate() error {
+	if !slices.Contains(core.EgressMethodAccepted, req.Method) {
+		return fmt.Errorf("unsupported method: %s", req.Method)
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url should be http or https scheme")
+	}
+	if len(u.Host) == 0 {
+		return fmt.Errorf("url should have host")
+	}
+	if u.User != nil {
+		return fmt.Errorf("url should not have user info")
+	}
+
+	for key := range req.Header {
+		if len(key) == 0 {
+			return fmt.Errorf("header key should not be empty")
+		}
+		// headers to control fetch API of the browser are set by the node
+		if strings.HasPrefix(strings.ToLower(key), "js.fetch:") {
+			return fmt.Errorf("header key should not start with `js.fetch:`")
+		}
+	}
+
+	if len(req.Body) > FETCH_REQUEST_BODY_MAX_SIZE {
+		return fmt.Errorf("request body should be %d bytes or less", FETCH_REQUEST_BODY_MAX_SIZE)
+	}
+
+	return nil
+}
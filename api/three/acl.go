@@ -0,0 +1,115 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package three
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+type AccessLevel string
+type Permission string
+
+const (
+	// only the pod created the object
+	AccessLevelPod = AccessLevel("pod")
+	// pods of the account owning the object
+	AccessLevelAccount = AccessLevel("account")
+	// any pods and users
+	AccessLevelWorld = AccessLevel("world")
+
+	// get and list the object via the API
+	PermissionRead = Permission("read")
+	// update, patch and delete the object
+	PermissionWrite = Permission("write")
+	// click, hover and select the object on the frontend
+	PermissionInteract = Permission("interact")
+)
+
+var AccessLevelAccepted = []AccessLevel{
+	AccessLevelPod,
+	AccessLevelAccount,
+	AccessLevelWorld,
+}
+
+// ObjectACL is the access control of the object. empty levels mean AccessLevelAccount
+// The levels are checked by the nodes dealing the object, the object itself is stored in the KVS and spread to
+// other nodes in plaintext whatever the Read level is, so nodes not following the ACL can read it.
+type ObjectACL struct {
+	Read     AccessLevel `json:"read,omitempty"`
+	Write    AccessLevel `json:"write,omitempty"`
+	Interact AccessLevel `json:"interact,omitempty"`
+}
+
+func (acl *ObjectACL) validate() error {
+	for _, level := range []AccessLevel{acl.Read, acl.Write, acl.Interact} {
+		if len(level) != 0 && !slices.Contains(AccessLevelAccepted, level) {
+			return fmt.Errorf("unsupported access level: %s", level)
+		}
+	}
+
+	if acl.Write == AccessLevelWorld {
+		return fmt.Errorf("write access should not be opened to the world")
+	}
+
+	return nil
+}
+
+// GetLevel returns the access level for the permission, the object without ACL is treated as account-shared.
+func (acl *ObjectACL) GetLevel(permission Permission) AccessLevel {
+	var level AccessLevel
+	if acl != nil {
+		switch permission {
+		case PermissionRead:
+			level = acl.Read
+		case PermissionWrite:
+			level = acl.Write
+		case PermissionInteract:
+			level = acl.Interact
+		}
+	}
+	if len(level) == 0 {
+		return AccessLevelAccount
+	}
+	return level
+}
+
+// IsAllowed checks the permission of the pod or the user, podUUID is empty for users on the frontend.
+func (obj *Object) IsAllowed(permission Permission, account string, podUUID string) bool {
+	// the writer can always read and interact the object
+	levels := []AccessLevel{obj.ACL.GetLevel(permission)}
+	if permission != PermissionWrite {
+		levels = append(levels, obj.ACL.GetLevel(PermissionWrite))
+	}
+
+	for _, level := range levels {
+		switch level {
+		case AccessLevelWorld:
+			return true
+		case AccessLevelAccount:
+			if account == obj.Meta.Owner {
+				return true
+			}
+		case AccessLevelPod:
+			if len(podUUID) != 0 && podUUID == obj.Meta.Parent && account == obj.Meta.Owner {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package three
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectACL(t *testing.T) {
+	assert := assert.New(t)
+
+	obj := makeValidObject()
+	obj.Meta.Parent = "parent-pod"
+
+	// valid
+	for _, acl := range []*ObjectACL{
+		nil,
+		{},
+		{Read: AccessLevelWorld, Write: AccessLevelPod, Interact: AccessLevelWorld},
+		{Read: AccessLevelPod, Write: AccessLevelAccount},
+	} {
+		obj.ACL = acl
+		assert.NoError(obj.Validate())
+	}
+
+	// invalid
+	for title, acl := range map[string]*ObjectACL{
+		"unsupported level": {Read: "public"},
+		"world writable":    {Write: AccessLevelWorld},
+	} {
+		obj.ACL = acl
+		assert.Error(obj.Validate(), title)
+	}
+
+	obj.Meta.Parent = ""
+	obj.ACL = &ObjectACL{Write: AccessLevelPod}
+	assert.Error(obj.Validate(), "pod level without parent")
+}
+
+func TestObjectIsAllowed(t *testing.T) {
+	assert := assert.New(t)
+
+	obj := makeValidObject()
+	obj.Meta.Parent = "parent-pod"
+
+	type access struct {
+		permission Permission
+		account    string
+		podUUID    string
+	}
+	samePod := func(p Permission) access { return access{p, "owner", "parent-pod"} }
+	sameAccount := func(p Permission) access { return access{p, "owner", "other-pod"} }
+	otherAccount := func(p Permission) access { return access{p, "other", "other-pod"} }
+	user := func(p Permission) access { return access{p, "other", ""} }
+
+	for title, tc := range map[string]struct {
+		acl     *ObjectACL
+		allowed []access
+		denied  []access
+	}{
+		"default": {
+			acl:     nil,
+			allowed: []access{sameAccount(PermissionRead), sameAccount(PermissionWrite), sameAccount(PermissionInteract)},
+			denied:  []access{otherAccount(PermissionRead), otherAccount(PermissionWrite), user(PermissionInteract)},
+		},
+		"private": {
+			acl:     &ObjectACL{Read: AccessLevelPod, Write: AccessLevelPod, Interact: AccessLevelPod},
+			allowed: []access{samePod(PermissionRead), samePod(PermissionWrite), samePod(PermissionInteract)},
+			denied:  []access{sameAccount(PermissionRead), sameAccount(PermissionWrite), {PermissionRead, "other", "parent-pod"}},
+		},
+		"world readable": {
+			acl:     &ObjectACL{Read: AccessLevelWorld, Write: AccessLevelPod, Interact: AccessLevelAccount},
+			allowed: []access{otherAccount(PermissionRead), user(PermissionRead), samePod(PermissionWrite), sameAccount(PermissionInteract)},
+			denied:  []access{sameAccount(PermissionWrite), otherAccount(PermissionInteract), user(PermissionInteract)},
+		},
+		"interact only": {
+			acl:     &ObjectACL{Read: AccessLevelPod, Write: AccessLevelPod, Interact: AccessLevelWorld},
+			allowed: []access{user(PermissionInteract), otherAccount(PermissionInteract), samePod(PermissionRead)},
+			denied:  []access{user(PermissionRead), sameAccount(PermissionRead)},
+		},
+	} {
+		obj.ACL = tc.acl
+		for _, a := range tc.allowed {
+			assert.True(obj.IsAllowed(a.permission, a.account, a.podUUID), title)
+		}
+		for _, a := range tc.denied {
+			assert.False(obj.IsAllowed(a.permission, a.account, a.podUUID), title)
+		}
+	}
+}
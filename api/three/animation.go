@@ -0,0 +1,222 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package three
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+type AnimationLoop string
+type AnimationProperty string
+type AnimationInterpolation string
+
+const (
+	// play once and keep the last state
+	AnimationLoopOnce = AnimationLoop("once")
+	// repeat from the beginning
+	AnimationLoopRepeat = AnimationLoop("repeat")
+	// play forward and backward alternately
+	AnimationLoopPingPong = AnimationLoop("pingpong")
+
+	AnimationPropertyPosition = AnimationProperty("position")
+	AnimationPropertyRotation = AnimationProperty("rotation")
+	// scale keyframes are multiplied to the scale of the part selected by the view mode
+	AnimationPropertyScale = AnimationProperty("scale")
+
+	AnimationInterpolationLinear   = AnimationInterpolation("linear")
+	AnimationInterpolationDiscrete = AnimationInterpolation("discrete")
+)
+
+const (
+	ANIMATION_MAX            = 16
+	ANIMATION_MAX_TRACKS     = 32
+	ANIMATION_MAX_KEYFRAMES  = 256
+	ANIMATION_MAX_DURATION   = 60 * 60 * 1000 // 1 hour in milliseconds
+	ANIMATION_MAX_SHEET_SIZE = 64
+)
+
+var AnimationLoopAccepted = []AnimationLoop{
+	AnimationLoopOnce,
+	AnimationLoopRepeat,
+	AnimationLoopPingPong,
+}
+
+var AnimationPropertyAccepted = []AnimationProperty{
+	AnimationPropertyPosition,
+	AnimationPropertyRotation,
+	AnimationPropertyScale,
+}
+
+var AnimationInterpolationAccepted = []AnimationInterpolation{
+	AnimationInterpolationLinear,
+	AnimationInterpolationDiscrete,
+}
+
+// AnimationSpec is interpolated on each frontend locally, it doesn't need updates of the object while playing.
+type AnimationSpec struct {
+	Name string `json:"name"`
+	// duration of one cycle in milliseconds
+	Duration int           `json:"duration"`
+	Loop     AnimationLoop `json:"loop"`
+	// RFC3339 formatted time to align the phase of the animation on every frontend,
+	// the animation starts when the object is displayed if empty
+	StartAt     string                    `json:"startAt,omitempty"`
+	Tracks      []*AnimationTrackSpec     `json:"tracks,omitempty"`
+	SpriteSheet *SpriteSheetAnimationSpec `json:"spriteSheet,omitempty"`
+}
+
+type AnimationTrackSpec struct {
+	// name of the part to animate, the local transform of the part is overwritten while playing
+	Part          string                 `json:"part"`
+	Property      AnimationProperty      `json:"property"`
+	Interpolation AnimationInterpolation `json:"interpolation,omitempty"`
+	Keyframes     []*KeyframeSpec        `json:"keyframes"`
+}
+
+type KeyframeSpec struct {
+	// time from the beginning of the cycle in milliseconds
+	Time  int      `json:"time"`
+	Value *Vector3 `json:"value"`
+}
+
+// SpriteSheetAnimationSpec cycles frames of the map by changing offset and repeat of the texture.
+type SpriteSheetAnimationSpec struct {
+	// name of the map texture
+	Map     string `json:"map"`
+	Columns int    `json:"columns"`
+	Rows    int    `json:"rows"`
+	// indexes of the cells played in the order, numbered from the top-left cell row by row.
+	// all cells are played if empty
+	Frames []int `json:"frames,omitempty"`
+}
+
+// parts and maps are the names in the object
+func (animation *AnimationSpec) validate(parts map[string]bool, maps map[string]bool) error {
+	if len(animation.Name) == 0 {
+		return fmt.Errorf("name of the animation should be specified")
+	}
+
+	if animation.Duration <= 0 || animation.Duration > ANIMATION_MAX_DURATION {
+		return fmt.Errorf("duration should be in 1 to %d milliseconds", ANIMATION_MAX_DURATION)
+	}
+
+	if !slices.Contains(AnimationLoopAccepted, animation.Loop) {
+		return fmt.Errorf("unsupported loop: %s", animation.Loop)
+	}
+
+	if len(animation.StartAt) != 0 {
+		if _, err := time.Parse(time.RFC3339, animation.StartAt); err != nil {
+			return fmt.Errorf("invalid start time: %w", err)
+		}
+	}
+
+	if len(animation.Tracks) == 0 && animation.SpriteSheet == nil {
+		return fmt.Errorf("tracks or sprite sheet should be specified")
+	}
+
+	if len(animation.Tracks) > ANIMATION_MAX_TRACKS {
+		return fmt.Errorf("tracks of the animation should be %d or less", ANIMATION_MAX_TRACKS)
+	}
+	for _, track := range animation.Tracks {
+		if track == nil {
+			return fmt.Errorf("track should not be nil")
+		}
+		if err := track.validate(parts, animation.Duration); err != nil {
+			return fmt.Errorf("invalid track (%s %s): %w", track.Part, track.Property, err)
+		}
+	}
+
+	if animation.SpriteSheet != nil {
+		if err := animation.SpriteSheet.validate(maps); err != nil {
+			return fmt.Errorf("invalid sprite sheet: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (track *AnimationTrackSpec) validate(parts map[string]bool, duration int) error {
+	if !parts[track.Part] {
+		return fmt.Errorf("part not found: %s", track.Part)
+	}
+
+	if !slices.Contains(AnimationPropertyAccepted, track.Property) {
+		return fmt.Errorf("unsupported property: %s", track.Property)
+	}
+
+	// empty means linear
+	if len(track.Interpolation) != 0 && !slices.Contains(AnimationInterpolationAccepted, track.Interpolation) {
+		return fmt.Errorf("unsupported interpolation: %s", track.Interpolation)
+	}
+
+	if len(track.Keyframes) == 0 || len(track.Keyframes) > ANIMATION_MAX_KEYFRAMES {
+		return fmt.Errorf("keyframes should be 1 to %d", ANIMATION_MAX_KEYFRAMES)
+	}
+
+	prev := -1
+	for _, keyframe := range track.Keyframes {
+		if keyframe == nil || keyframe.Value == nil {
+			return fmt.Errorf("value of the keyframe should be specified")
+		}
+		if keyframe.Time < 0 || keyframe.Time > duration {
+			return fmt.Errorf("time of the keyframe should be in 0 to duration")
+		}
+		if keyframe.Time <= prev {
+			return fmt.Errorf("keyframes should be sorted by time without duplicates")
+		}
+		prev = keyframe.Time
+
+		v := keyframe.Value
+		if track.Property == AnimationPropertyRotation {
+			for _, r := range []float64{v.X, v.Y, v.Z} {
+				if math.IsNaN(r) || math.Abs(r) > 2*math.Pi {
+					return fmt.Errorf("rotation should be in -2π to 2π")
+				}
+			}
+		} else if err := validateLength(v.X, v.Y, v.Z); err != nil {
+			return fmt.Errorf("invalid value of the keyframe: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (sheet *SpriteSheetAnimationSpec) validate(maps map[string]bool) error {
+	if !maps[sheet.Map] {
+		return fmt.Errorf("map not found: %s", sheet.Map)
+	}
+
+	if sheet.Columns <= 0 || sheet.Columns > ANIMATION_MAX_SHEET_SIZE ||
+		sheet.Rows <= 0 || sheet.Rows > ANIMATION_MAX_SHEET_SIZE {
+		return fmt.Errorf("columns and rows should be in 1 to %d", ANIMATION_MAX_SHEET_SIZE)
+	}
+
+	if len(sheet.Frames) > ANIMATION_MAX_KEYFRAMES {
+		return fmt.Errorf("frames should be %d or less", ANIMATION_MAX_KEYFRAMES)
+	}
+	for _, frame := range sheet.Frames {
+		if frame < 0 || frame >= sheet.Columns*sheet.Rows {
+			return fmt.Errorf("frame index should be in 0 to %d", sheet.Columns*sheet.Rows-1)
+		}
+	}
+
+	return nil
+}
@@ -15,16 +15,19 @@
  */
 package three
 
+// types to pass from application to node manager
 type CreateObjectRequest struct {
 	Name string      `json:"name"`
 	Spec *ObjectSpec `json:"spec"`
+	// the object is shared in the account if nil
+	ACL *ObjectACL `json:"acl,omitempty"`
 }
 
 type CreateObjectResponse struct {
 	UUID string `json:"uuid"`
 }
 
-// This method is foolish and inefficient and needs to be improved.
+// UpdateObjectRequest replaces whole spec of the object, use PatchObjectRequest or UpdateTransformRequest for small changes.
 type UpdateObjectRequest struct {
 	UUID string      `json:"uuid"`
 	Spec *ObjectSpec `json:"spec"`
@@ -34,6 +37,28 @@ type UpdateObjectResponse struct {
 	// empty
 }
 
+type PatchObjectRequest struct {
+	UUID  string           `json:"uuid"`
+	Patch []PatchOperation `json:"patch"`
+	// spread the patch without writing to the KVS if true
+	Transient bool `json:"transient"`
+}
+
+type PatchObjectResponse struct {
+	// empty
+}
+
+type UpdateTransformRequest struct {
+	UUID      string           `json:"uuid"`
+	Transform *ObjectTransform `json:"transform"`
+	// spread the transform without writing to the KVS if true
+	Transient bool `json:"transient"`
+}
+
+type UpdateTransformResponse struct {
+	// empty
+}
+
 type GetObjectRequest struct {
 	UUID string `json:"uuid"`
 }
@@ -42,6 +67,26 @@ type GetObjectResponse struct {
 	Object *Object `json:"object"`
 }
 
+type SetObjectACLRequest struct {
+	UUID string     `json:"uuid"`
+	ACL  *ObjectACL `json:"acl"`
+}
+
+type SetObjectACLResponse struct {
+	// empty
+}
+
+type ListObjectsRequest struct {
+	// longitude, latitude and altitude
+	Center *Vector3 `json:"center"`
+	// radius in meters
+	Radius float64 `json:"radius"`
+}
+
+type ListObjectsResponse struct {
+	Objects []*Object `json:"objects"`
+}
+
 type DeleteObjectRequest struct {
 	UUID string `json:"uuid"`
 }
@@ -49,3 +94,12 @@ type DeleteObjectRequest struct {
 type DeleteObjectResponse struct {
 	// empty
 }
+
+// types to pass from node manager to application
+type InteractRequest struct {
+	Event *InteractionEvent `json:"event"`
+}
+
+type InteractResponse struct {
+	// empty
+}
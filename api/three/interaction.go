@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package three
+
+import (
+	"fmt"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"golang.org/x/exp/slices"
+)
+
+type InteractionType string
+
+const (
+	InteractionTypeClick      = InteractionType("click")
+	InteractionTypeHoverStart = InteractionType("hoverStart")
+	InteractionTypeHoverEnd   = InteractionType("hoverEnd")
+	// select action of XR controllers or tapping on XR view
+	InteractionTypeSelect = InteractionType("select")
+)
+
+var InteractionTypeAccepted = []InteractionType{
+	InteractionTypeClick,
+	InteractionTypeHoverStart,
+	InteractionTypeHoverEnd,
+	InteractionTypeSelect,
+}
+
+// InteractionEvent is sent to the application owning the object when a user interacts with it on the frontend.
+type InteractionEvent struct {
+	Type       InteractionType `json:"type"`
+	ObjectUUID string          `json:"objectUUID"`
+	// name of the part under the pointer, empty if unknown
+	Part string `json:"part,omitempty"`
+	// account and node of the user, filled by the node
+	Account   string `json:"account"`
+	Node      string `json:"node"`
+	Timestamp string `json:"timestamp"`
+}
+
+func (event *InteractionEvent) Validate() error {
+	if !slices.Contains(InteractionTypeAccepted, event.Type) {
+		return fmt.Errorf("unsupported interaction type: %s", event.Type)
+	}
+
+	if len(event.ObjectUUID) == 0 {
+		return fmt.Errorf("uuid of the object should be specified")
+	}
+
+	if len(event.Account) == 0 {
+		return fmt.Errorf("account of the user should be specified")
+	}
+
+	if err := core.ValidateNodeId(event.Node); err != nil {
+		return fmt.Errorf("invalid node id: %w", err)
+	}
+
+	if err := core.ValidateTimestamp(event.Timestamp); err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+
+	return nil
+}
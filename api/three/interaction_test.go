@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package three
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInteractionEventValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	makeEvent := func() *InteractionEvent {
+		return &InteractionEvent{
+			Type:       InteractionTypeClick,
+			ObjectUUID: GenerateObjectUUID(),
+			Part:       "sprite",
+			Account:    "account",
+			Node:       "01234567890123456789012345678901",
+			Timestamp:  "2023-04-10T00:00:10Z",
+		}
+	}
+
+	assert.NoError(makeEvent().Validate())
+	for _, eventType := range InteractionTypeAccepted {
+		event := makeEvent()
+		event.Type = eventType
+		event.Part = ""
+		assert.NoError(event.Validate())
+	}
+
+	for title, modify := range map[string]func(event *InteractionEvent){
+		"unsupported type": func(event *InteractionEvent) {
+			event.Type = "drag"
+		},
+		"empty uuid": func(event *InteractionEvent) {
+			event.ObjectUUID = ""
+		},
+		"empty account": func(event *InteractionEvent) {
+			event.Account = ""
+		},
+		"invalid node": func(event *InteractionEvent) {
+			event.Node = "node"
+		},
+		"invalid timestamp": func(event *InteractionEvent) {
+			event.Timestamp = "now"
+		},
+	} {
+		event := makeEvent()
+		modify(event)
+		assert.Error(event.Validate(), title)
+	}
+}
@@ -16,12 +16,22 @@
 package three
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+
 	"github.com/google/uuid"
 	"github.com/llamerada-jp/oinari/api/core"
+	"golang.org/x/exp/slices"
 )
 
 const (
 	ResourceTypeThreeObject = core.ResourceType("object")
+	API_VERSION_THREE_V1    = "three/v1"
+
+	// index of the objects for each geohash bucket, it is used only by the node
+	ResourceTypeThreeObjectIndex = core.ResourceType("objectIndex")
 
 	ScaleDefault   = "default"
 	ScaleLandscape = "landscape"
@@ -34,14 +44,82 @@ const (
 	RepeatWrapping         = 1000
 	ClampToEdgeWrapping    = 1001
 	MirroredRepeatWrapping = 1002
+
+	// Sides
+	FrontSide  = 0
+	BackSide   = 1
+	DoubleSide = 2
+)
+
+func init() {
+	core.DefaultConverters.SetCurrentVersion(ResourceTypeThreeObject, API_VERSION_THREE_V1)
+	core.DefaultConverters.Register(ResourceTypeThreeObject, core.API_VERSION_LEGACY, API_VERSION_THREE_V1, nil)
+}
+
+const (
+	OBJECT_MAX_SIZE      = 64 * 1024
+	OBJECT_MAX_PARTS     = 64
+	OBJECT_MAX_MATERIALS = 32
+	OBJECT_MAX_MAPS      = 32
+	OBJECT_MAX_DEPTH     = 8
+	// max value of scales, local positions and geometry sizes
+	OBJECT_MAX_LENGTH   = 10000
+	OBJECT_MAX_SEGMENTS = 256
+	// range of altitude in meters
+	OBJECT_MIN_ALTITUDE = -10000
+	OBJECT_MAX_ALTITUDE = 100000
+	// max radius in meters to list objects
+	OBJECT_LIST_MAX_RADIUS = 10000
+	// radius in meters where the object is visible
+	OBJECT_DEFAULT_VISIBLE_RADIUS = 1000
+	OBJECT_MAX_VISIBLE_RADIUS     = 10000
 )
 
+var ScaleAccepted = []string{
+	ScaleDefault,
+	ScaleLandscape,
+	ScaleXR,
+}
+
+var WrappingAccepted = []int{
+	RepeatWrapping,
+	ClampToEdgeWrapping,
+	MirroredRepeatWrapping,
+}
+
+var SideAccepted = []int{
+	FrontSide,
+	BackSide,
+	DoubleSide,
+}
+
 type Vector2 core.Vector2
 type Vector3 core.Vector3
 
+// radius of the earth in meters
+const EARTH_RADIUS = 6371000.0
+
+// Distance returns the great-circle distance in meters between the positions, altitudes are ignored.
+func Distance(a, b *Vector3) float64 {
+	lat1 := a.Y * math.Pi / 180
+	lat2 := b.Y * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.X - a.X) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EARTH_RADIUS * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
 type Object struct {
 	Meta *core.ObjectMeta `json:"meta"`
 	Spec *ObjectSpec      `json:"spec"`
+	ACL  *ObjectACL       `json:"acl,omitempty"`
+	// Generation is incremented each time the object is written to the KVS
+	Generation uint64 `json:"generation,omitempty"`
+}
+
+func (obj *Object) GetMeta() *core.ObjectMeta {
+	return obj.Meta
 }
 
 type ObjectSpec struct {
@@ -49,24 +127,36 @@ type ObjectSpec struct {
 	Materials []*MaterialSpec `json:"materials"`
 	Maps      []*TextureSpec  `json:"maps"`
 	Position  *Vector3        `json:"position"`
+	// animations are played on the frontend at the same time
+	Animations []*AnimationSpec `json:"animations,omitempty"`
+	// radius in meters where the object is visible for each scale, key is the same as the Scale of PartBaseSpec.
+	// the object is spread to nodes in the largest radius
+	VisibleRadius map[string]float64 `json:"visibleRadius,omitempty"`
 	// TODO: kind of the Z axis. (e.g. altitude, elevation)
 }
 
 type PartSpec struct {
-	Name string `json:"name"`
-	// Mesh   *MeshSpec   `json:"mesh,omitempty"`
+	// name of the part should be unique in the object including parts in the groups
+	Name   string      `json:"name"`
+	Mesh   *MeshSpec   `json:"mesh,omitempty"`
 	Sprite *SpriteSpec `json:"sprite,omitempty"`
-	// Group  *GroupSpec  `json:"group,omitempty"`
+	Group  *GroupSpec  `json:"group,omitempty"`
 }
 
 type PartBaseSpec struct {
 	Scale map[string]*Vector3 `json:"scale"`
+	// local transform relative to the parent, the parent is the object or the group containing the part
+	Position *Vector3 `json:"position,omitempty"`
+	// euler angles in radians, the order is XYZ
+	Rotation *Vector3 `json:"rotation,omitempty"`
 }
 
-/*
 type MeshSpec struct {
+	PartBaseSpec
+	// see: https://threejs.org/docs/#api/en/objects/Mesh
+	Geometry *GeometrySpec `json:"geometry"`
+	Material string        `json:"material"`
 }
-*/
 
 type SpriteSpec struct {
 	PartBaseSpec
@@ -75,24 +165,69 @@ type SpriteSpec struct {
 	Center   *Vector2 `json:"center,omitempty"`
 }
 
-/*
 type GroupSpec struct {
+	PartBaseSpec
+	// see: https://threejs.org/docs/#api/en/objects/Group
+	Parts []*PartSpec `json:"parts"`
 }
-*/
 
-/*
 type GeometrySpec struct {
+	Box      *BoxGeometrySpec      `json:"box,omitempty"`
+	Sphere   *SphereGeometrySpec   `json:"sphere,omitempty"`
+	Plane    *PlaneGeometrySpec    `json:"plane,omitempty"`
+	Cylinder *CylinderGeometrySpec `json:"cylinder,omitempty"`
+}
+
+// default values of three.js are used for the fields not specified
+type BoxGeometrySpec struct {
+	// see: https://threejs.org/docs/#api/en/geometries/BoxGeometry
+	Width          float64 `json:"width,omitempty"`
+	Height         float64 `json:"height,omitempty"`
+	Depth          float64 `json:"depth,omitempty"`
+	WidthSegments  int     `json:"widthSegments,omitempty"`
+	HeightSegments int     `json:"heightSegments,omitempty"`
+	DepthSegments  int     `json:"depthSegments,omitempty"`
+}
+
+type SphereGeometrySpec struct {
+	// see: https://threejs.org/docs/#api/en/geometries/SphereGeometry
+	Radius         float64 `json:"radius,omitempty"`
+	WidthSegments  int     `json:"widthSegments,omitempty"`
+	HeightSegments int     `json:"heightSegments,omitempty"`
+}
+
+type PlaneGeometrySpec struct {
+	// see: https://threejs.org/docs/#api/en/geometries/PlaneGeometry
+	Width          float64 `json:"width,omitempty"`
+	Height         float64 `json:"height,omitempty"`
+	WidthSegments  int     `json:"widthSegments,omitempty"`
+	HeightSegments int     `json:"heightSegments,omitempty"`
+}
+
+type CylinderGeometrySpec struct {
+	// see: https://threejs.org/docs/#api/en/geometries/CylinderGeometry
+	RadiusTop      *float64 `json:"radiusTop,omitempty"`
+	RadiusBottom   *float64 `json:"radiusBottom,omitempty"`
+	Height         float64  `json:"height,omitempty"`
+	RadialSegments int      `json:"radialSegments,omitempty"`
+	HeightSegments int      `json:"heightSegments,omitempty"`
+	OpenEnded      bool     `json:"openEnded,omitempty"`
 }
-*/
 
 type MaterialSpec struct {
-	Name           string              `json:"name"`
-	SpriteMaterial *SpriteMaterialSpec `json:"spriteMaterial,omitempty"`
+	Name                 string                    `json:"name"`
+	SpriteMaterial       *SpriteMaterialSpec       `json:"spriteMaterial,omitempty"`
+	MeshBasicMaterial    *MeshBasicMaterialSpec    `json:"meshBasicMaterial,omitempty"`
+	MeshStandardMaterial *MeshStandardMaterialSpec `json:"meshStandardMaterial,omitempty"`
 }
 
 type MaterialBaseSpec struct {
 	// see: https://threejs.org/docs/?q=material#api/en/materials/Material
-	AlphaTest float32 `json:"alphaTest,omitempty"`
+	AlphaTest   float32 `json:"alphaTest,omitempty"`
+	Side        int     `json:"side,omitempty"`
+	Transparent bool    `json:"transparent,omitempty"`
+	// opacity is used when transparent is true
+	Opacity float32 `json:"opacity,omitempty"`
 }
 
 type SpriteMaterialSpec struct {
@@ -103,6 +238,27 @@ type SpriteMaterialSpec struct {
 	MapTexture string `json:"mapTexture,omitempty"`
 }
 
+type MeshBasicMaterialSpec struct {
+	MaterialBaseSpec
+
+	// see: https://threejs.org/docs/#api/en/materials/MeshBasicMaterial
+	Color      *Color `json:"color,omitempty"`
+	MapTexture string `json:"mapTexture,omitempty"`
+	Wireframe  bool   `json:"wireframe,omitempty"`
+}
+
+type MeshStandardMaterialSpec struct {
+	MaterialBaseSpec
+
+	// see: https://threejs.org/docs/#api/en/materials/MeshStandardMaterial
+	Color      *Color   `json:"color,omitempty"`
+	MapTexture string   `json:"mapTexture,omitempty"`
+	Emissive   *Color   `json:"emissive,omitempty"`
+	Roughness  *float32 `json:"roughness,omitempty"`
+	Metalness  *float32 `json:"metalness,omitempty"`
+	Wireframe  bool     `json:"wireframe,omitempty"`
+}
+
 type TextureSpec struct {
 	Name       string          `json:"name"`
 	URLTexture *URLTextureSpec `json:"urlTexture,omitempty"`
@@ -135,5 +291,451 @@ func GenerateObjectUUID() string {
 }
 
 func (obj *Object) Validate() error {
+	if obj.Meta == nil {
+		return fmt.Errorf("meta field should be filled")
+	}
+
+	if err := obj.Meta.Validate(ResourceTypeThreeObject); err != nil {
+		return fmt.Errorf("invalid meta field: %w", err)
+	}
+
+	if _, err := uuid.Parse(obj.Meta.Uuid); err != nil {
+		return fmt.Errorf("invalid uuid in object meta field: %w", err)
+	}
+
+	if obj.Spec == nil {
+		return fmt.Errorf("object spec should be filled")
+	}
+
+	if err := obj.Spec.validate(); err != nil {
+		return err
+	}
+
+	if obj.ACL != nil {
+		if err := obj.ACL.validate(); err != nil {
+			return fmt.Errorf("invalid acl: %w", err)
+		}
+		// pod level access can not be checked without the parent
+		if len(obj.Meta.Parent) == 0 && slices.Contains([]AccessLevel{obj.ACL.Read, obj.ACL.Write, obj.ACL.Interact}, AccessLevelPod) {
+			return fmt.Errorf("pod level access requires the parent of the object")
+		}
+	}
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the object: %w", err)
+	}
+	if len(raw) > OBJECT_MAX_SIZE {
+		return fmt.Errorf("size of the object should be %d bytes or less", OBJECT_MAX_SIZE)
+	}
+
+	return nil
+}
+
+// GetVisibleRadius returns the radius for the scale, the default radius is used if it is not specified.
+func (spec *ObjectSpec) GetVisibleRadius(scale string) float64 {
+	if radius, ok := spec.VisibleRadius[scale]; ok {
+		return radius
+	}
+	if radius, ok := spec.VisibleRadius[ScaleDefault]; ok {
+		return radius
+	}
+	return OBJECT_DEFAULT_VISIBLE_RADIUS
+}
+
+// GetSpreadRadius returns the largest visible radius to spread updates of the object.
+func (spec *ObjectSpec) GetSpreadRadius() float64 {
+	r := 0.0
+	for _, scale := range ScaleAccepted {
+		r = math.Max(r, spec.GetVisibleRadius(scale))
+	}
+	return r
+}
+
+func (spec *ObjectSpec) validate() error {
+	if len(spec.Maps) > OBJECT_MAX_MAPS {
+		return fmt.Errorf("maps of the object should be %d or less", OBJECT_MAX_MAPS)
+	}
+	maps := make(map[string]bool)
+	for _, texture := range spec.Maps {
+		if err := texture.validate(); err != nil {
+			return fmt.Errorf("invalid map (%s): %w", texture.Name, err)
+		}
+		if maps[texture.Name] {
+			return fmt.Errorf("name of the map should be unique in the object: %s", texture.Name)
+		}
+		maps[texture.Name] = true
+	}
+
+	if len(spec.Materials) > OBJECT_MAX_MATERIALS {
+		return fmt.Errorf("materials of the object should be %d or less", OBJECT_MAX_MATERIALS)
+	}
+	// value: true if the material is for sprite
+	materials := make(map[string]bool)
+	for _, material := range spec.Materials {
+		if err := material.validate(maps); err != nil {
+			return fmt.Errorf("invalid material (%s): %w", material.Name, err)
+		}
+		if _, ok := materials[material.Name]; ok {
+			return fmt.Errorf("name of the material should be unique in the object: %s", material.Name)
+		}
+		materials[material.Name] = material.SpriteMaterial != nil
+	}
+
+	names := make(map[string]bool)
+	if err := validateParts(spec.Parts, materials, names, 1); err != nil {
+		return err
+	}
+
+	if len(spec.Animations) > ANIMATION_MAX {
+		return fmt.Errorf("animations of the object should be %d or less", ANIMATION_MAX)
+	}
+	animations := make(map[string]bool)
+	for _, animation := range spec.Animations {
+		if animation == nil {
+			return fmt.Errorf("animation should not be nil")
+		}
+		if err := animation.validate(names, maps); err != nil {
+			return fmt.Errorf("invalid animation (%s): %w", animation.Name, err)
+		}
+		if animations[animation.Name] {
+			return fmt.Errorf("name of the animation should be unique in the object: %s", animation.Name)
+		}
+		animations[animation.Name] = true
+	}
+
+	for key, radius := range spec.VisibleRadius {
+		if !slices.Contains(ScaleAccepted, key) {
+			return fmt.Errorf("unsupported scale key of visible radius: %s", key)
+		}
+		if math.IsNaN(radius) || radius <= 0 || radius > OBJECT_MAX_VISIBLE_RADIUS {
+			return fmt.Errorf("visible radius should be in 0 to %d meters", OBJECT_MAX_VISIBLE_RADIUS)
+		}
+	}
+
+	if spec.Position != nil {
+		if err := validateCoordinate(spec.Position); err != nil {
+			return fmt.Errorf("invalid position of the object: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func validateParts(parts []*PartSpec, materials map[string]bool, names map[string]bool, depth int) error {
+	if depth > OBJECT_MAX_DEPTH {
+		return fmt.Errorf("depth of the groups should be %d or less", OBJECT_MAX_DEPTH)
+	}
+
+	for _, part := range parts {
+		if part == nil {
+			return fmt.Errorf("part should not be nil")
+		}
+
+		if len(part.Name) == 0 {
+			return fmt.Errorf("name of the part should be specified")
+		}
+		if names[part.Name] {
+			return fmt.Errorf("name of the part should be unique in the object: %s", part.Name)
+		}
+		names[part.Name] = true
+		if len(names) > OBJECT_MAX_PARTS {
+			return fmt.Errorf("parts of the object should be %d or less", OBJECT_MAX_PARTS)
+		}
+
+		count := 0
+		if part.Mesh != nil {
+			count++
+			if err := part.Mesh.validate(materials); err != nil {
+				return fmt.Errorf("invalid mesh part (%s): %w", part.Name, err)
+			}
+		}
+		if part.Sprite != nil {
+			count++
+			if err := part.Sprite.validate(materials); err != nil {
+				return fmt.Errorf("invalid sprite part (%s): %w", part.Name, err)
+			}
+		}
+		if part.Group != nil {
+			count++
+			if err := part.Group.PartBaseSpec.validate(); err != nil {
+				return fmt.Errorf("invalid group part (%s): %w", part.Name, err)
+			}
+			if err := validateParts(part.Group.Parts, materials, names, depth+1); err != nil {
+				return err
+			}
+		}
+		if count != 1 {
+			return fmt.Errorf("just one of mesh, sprite or group should be specified for the part (%s)", part.Name)
+		}
+	}
+
+	return nil
+}
+
+func (base *PartBaseSpec) validate() error {
+	for key, scale := range base.Scale {
+		if !slices.Contains(ScaleAccepted, key) {
+			return fmt.Errorf("unsupported scale key: %s", key)
+		}
+		if scale == nil {
+			return fmt.Errorf("scale should not be nil")
+		}
+		if err := validateLength(scale.X, scale.Y, scale.Z); err != nil {
+			return fmt.Errorf("invalid scale: %w", err)
+		}
+	}
+
+	if base.Position != nil {
+		if err := validateLength(base.Position.X, base.Position.Y, base.Position.Z); err != nil {
+			return fmt.Errorf("invalid local position: %w", err)
+		}
+	}
+
+	if base.Rotation != nil {
+		for _, v := range []float64{base.Rotation.X, base.Rotation.Y, base.Rotation.Z} {
+			if math.IsNaN(v) || math.Abs(v) > 2*math.Pi {
+				return fmt.Errorf("rotation should be in -2π to 2π")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (mesh *MeshSpec) validate(materials map[string]bool) error {
+	if err := mesh.PartBaseSpec.validate(); err != nil {
+		return err
+	}
+
+	if mesh.Geometry == nil {
+		return fmt.Errorf("geometry should be specified")
+	}
+	if err := mesh.Geometry.validate(); err != nil {
+		return fmt.Errorf("invalid geometry: %w", err)
+	}
+
+	isSprite, ok := materials[mesh.Material]
+	if !ok {
+		return fmt.Errorf("material not found: %s", mesh.Material)
+	}
+	if isSprite {
+		return fmt.Errorf("sprite material can not be used for mesh: %s", mesh.Material)
+	}
+
+	return nil
+}
+
+func (sprite *SpriteSpec) validate(materials map[string]bool) error {
+	if err := sprite.PartBaseSpec.validate(); err != nil {
+		return err
+	}
+
+	isSprite, ok := materials[sprite.Material]
+	if !ok {
+		return fmt.Errorf("material not found: %s", sprite.Material)
+	}
+	if !isSprite {
+		return fmt.Errorf("material for mesh can not be used for sprite: %s", sprite.Material)
+	}
+
+	if sprite.Center != nil {
+		if err := validateRatio(sprite.Center.X, sprite.Center.Y); err != nil {
+			return fmt.Errorf("invalid center: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (geometry *GeometrySpec) validate() error {
+	count := 0
+	var lengths []float64
+	var segments []int
+
+	if g := geometry.Box; g != nil {
+		count++
+		lengths = append(lengths, g.Width, g.Height, g.Depth)
+		segments = append(segments, g.WidthSegments, g.HeightSegments, g.DepthSegments)
+	}
+	if g := geometry.Sphere; g != nil {
+		count++
+		lengths = append(lengths, g.Radius)
+		segments = append(segments, g.WidthSegments, g.HeightSegments)
+	}
+	if g := geometry.Plane; g != nil {
+		count++
+		lengths = append(lengths, g.Width, g.Height)
+		segments = append(segments, g.WidthSegments, g.HeightSegments)
+	}
+	if g := geometry.Cylinder; g != nil {
+		count++
+		lengths = append(lengths, g.Height)
+		if g.RadiusTop != nil {
+			lengths = append(lengths, *g.RadiusTop)
+		}
+		if g.RadiusBottom != nil {
+			lengths = append(lengths, *g.RadiusBottom)
+		}
+		segments = append(segments, g.RadialSegments, g.HeightSegments)
+	}
+
+	if count != 1 {
+		return fmt.Errorf("just one type of geometry should be specified")
+	}
+
+	for _, l := range lengths {
+		if math.IsNaN(l) || l < 0 || l > OBJECT_MAX_LENGTH {
+			return fmt.Errorf("size of the geometry should be in 0 to %d", OBJECT_MAX_LENGTH)
+		}
+	}
+
+	for _, s := range segments {
+		if s < 0 || s > OBJECT_MAX_SEGMENTS {
+			return fmt.Errorf("segments of the geometry should be in 0 to %d", OBJECT_MAX_SEGMENTS)
+		}
+	}
+
+	return nil
+}
+
+func (material *MaterialSpec) validate(maps map[string]bool) error {
+	if len(material.Name) == 0 {
+		return fmt.Errorf("name of the material should be specified")
+	}
+
+	count := 0
+	if m := material.SpriteMaterial; m != nil {
+		count++
+		if err := validateMaterial(&m.MaterialBaseSpec, m.Color, m.MapTexture, maps); err != nil {
+			return err
+		}
+	}
+	if m := material.MeshBasicMaterial; m != nil {
+		count++
+		if err := validateMaterial(&m.MaterialBaseSpec, m.Color, m.MapTexture, maps); err != nil {
+			return err
+		}
+	}
+	if m := material.MeshStandardMaterial; m != nil {
+		count++
+		if err := validateMaterial(&m.MaterialBaseSpec, m.Color, m.MapTexture, maps); err != nil {
+			return err
+		}
+		if m.Emissive != nil {
+			if err := m.Emissive.validate(); err != nil {
+				return fmt.Errorf("invalid emissive: %w", err)
+			}
+		}
+		for _, v := range []*float32{m.Roughness, m.Metalness} {
+			if v != nil {
+				if err := validateRatio(float64(*v)); err != nil {
+					return fmt.Errorf("invalid roughness or metalness: %w", err)
+				}
+			}
+		}
+	}
+
+	if count != 1 {
+		return fmt.Errorf("just one type of material should be specified")
+	}
+
+	return nil
+}
+
+func validateMaterial(base *MaterialBaseSpec, color *Color, mapTexture string, maps map[string]bool) error {
+	if err := validateRatio(float64(base.AlphaTest), float64(base.Opacity)); err != nil {
+		return fmt.Errorf("invalid alphaTest or opacity: %w", err)
+	}
+
+	if !slices.Contains(SideAccepted, base.Side) {
+		return fmt.Errorf("unsupported side: %d", base.Side)
+	}
+
+	if color != nil {
+		if err := color.validate(); err != nil {
+			return fmt.Errorf("invalid color: %w", err)
+		}
+	}
+
+	if len(mapTexture) != 0 && !maps[mapTexture] {
+		return fmt.Errorf("map texture not found: %s", mapTexture)
+	}
+
+	return nil
+}
+
+func (texture *TextureSpec) validate() error {
+	if len(texture.Name) == 0 {
+		return fmt.Errorf("name of the map should be specified")
+	}
+
+	if texture.URLTexture == nil {
+		return fmt.Errorf("url texture should be specified")
+	}
+
+	for _, wrap := range []int{texture.URLTexture.WrapS, texture.URLTexture.WrapT} {
+		// zero means the default of three.js
+		if wrap != 0 && !slices.Contains(WrappingAccepted, wrap) {
+			return fmt.Errorf("unsupported wrapping mode: %d", wrap)
+		}
+	}
+
+	for _, v := range []*Vector2{texture.URLTexture.Offset, texture.URLTexture.Repeat} {
+		if v != nil {
+			if err := validateLength(v.X, v.Y); err != nil {
+				return fmt.Errorf("invalid offset or repeat: %w", err)
+			}
+		}
+	}
+
+	u, err := url.Parse(texture.URLTexture.URL)
+	if err != nil || len(texture.URLTexture.URL) == 0 {
+		return fmt.Errorf("url of the texture should be specified")
+	}
+	if len(u.Scheme) == 0 {
+		// relative path is resolved on the frontend, host without scheme is not allowed
+		if len(u.Host) != 0 || len(u.Opaque) != 0 {
+			return fmt.Errorf("url of the texture should be http, https or relative path")
+		}
+	} else if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("url of the texture should be http, https or relative path")
+	}
+
+	return nil
+}
+
+func (color *Color) validate() error {
+	return validateRatio(float64(color.R), float64(color.G), float64(color.B))
+}
+
+func validateCoordinate(pos *Vector3) error {
+	if math.IsNaN(pos.X) || pos.X < -180 || pos.X > 180 {
+		return fmt.Errorf("longitude should be in -180 to 180")
+	}
+	if math.IsNaN(pos.Y) || pos.Y < -90 || pos.Y > 90 {
+		return fmt.Errorf("latitude should be in -90 to 90")
+	}
+	if math.IsNaN(pos.Z) || pos.Z < OBJECT_MIN_ALTITUDE || pos.Z > OBJECT_MAX_ALTITUDE {
+		return fmt.Errorf("altitude should be in %d to %d", OBJECT_MIN_ALTITUDE, OBJECT_MAX_ALTITUDE)
+	}
+	return nil
+}
+
+func validateLength(values ...float64) error {
+	for _, v := range values {
+		if math.IsNaN(v) || math.Abs(v) > OBJECT_MAX_LENGTH {
+			return fmt.Errorf("value should be in -%d to %d", OBJECT_MAX_LENGTH, OBJECT_MAX_LENGTH)
+		}
+	}
+	return nil
+}
+
+func validateRatio(values ...float64) error {
+	for _, v := range values {
+		if math.IsNaN(v) || v < 0 || v > 1 {
+			return fmt.Errorf("value should be in 0 to 1")
+		}
+	}
 	return nil
 }
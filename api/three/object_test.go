@@ -0,0 +1,420 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package three
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeValidObject() *Object {
+	radius := 0.5
+	return &Object{
+		Meta: &core.ObjectMeta{
+			Type:        ResourceTypeThreeObject,
+			Name:        "object",
+			Owner:       "owner",
+			CreatorNode: "01234567890123456789012345678901",
+			Uuid:        GenerateObjectUUID(),
+		},
+		Spec: &ObjectSpec{
+			Parts: []*PartSpec{
+				{
+					Name: "sprite",
+					Sprite: &SpriteSpec{
+						PartBaseSpec: PartBaseSpec{
+							Scale: map[string]*Vector3{
+								ScaleDefault: {X: 1, Y: 1, Z: 1},
+								ScaleXR:      {X: 0.1, Y: 0.1, Z: 0.1},
+							},
+						},
+						Material: "spriteMaterial",
+						Center:   &Vector2{X: 0.5, Y: 0},
+					},
+				},
+				{
+					Name: "group",
+					Group: &GroupSpec{
+						PartBaseSpec: PartBaseSpec{
+							Position: &Vector3{X: 1, Y: 2, Z: 3},
+							Rotation: &Vector3{X: 0, Y: 3.14, Z: 0},
+						},
+						Parts: []*PartSpec{
+							{
+								Name: "box",
+								Mesh: &MeshSpec{
+									Geometry: &GeometrySpec{
+										Box: &BoxGeometrySpec{Width: 1, Height: 1, Depth: 1},
+									},
+									Material: "meshMaterial",
+								},
+							},
+							{
+								Name: "cylinder",
+								Mesh: &MeshSpec{
+									Geometry: &GeometrySpec{
+										Cylinder: &CylinderGeometrySpec{RadiusTop: &radius, Height: 2, RadialSegments: 16},
+									},
+									Material: "meshMaterial",
+								},
+							},
+						},
+					},
+				},
+			},
+			Materials: []*MaterialSpec{
+				{
+					Name: "spriteMaterial",
+					SpriteMaterial: &SpriteMaterialSpec{
+						MaterialBaseSpec: MaterialBaseSpec{
+							AlphaTest: 0.1,
+						},
+						MapTexture: "map",
+					},
+				},
+				{
+					Name: "meshMaterial",
+					MeshStandardMaterial: &MeshStandardMaterialSpec{
+						MaterialBaseSpec: MaterialBaseSpec{
+							Side: DoubleSide,
+						},
+						Color: &Color{R: 1, G: 0.5, B: 0},
+					},
+				},
+			},
+			Maps: []*TextureSpec{
+				{
+					Name: "map",
+					URLTexture: &URLTextureSpec{
+						TextureBaseSpec: TextureBaseSpec{
+							WrapS: RepeatWrapping,
+							WrapT: ClampToEdgeWrapping,
+						},
+						URL: "/img/fox1b.png",
+					},
+				},
+			},
+			Position: &Vector3{X: 139.7, Y: 35.6, Z: 0},
+			Animations: []*AnimationSpec{
+				{
+					Name:     "walk",
+					Duration: 1000,
+					Loop:     AnimationLoopRepeat,
+					Tracks: []*AnimationTrackSpec{
+						{
+							Part:     "group",
+							Property: AnimationPropertyPosition,
+							Keyframes: []*KeyframeSpec{
+								{Time: 0, Value: &Vector3{X: 0, Y: 0, Z: 0}},
+								{Time: 1000, Value: &Vector3{X: 0, Y: 1, Z: 0}},
+							},
+						},
+					},
+					SpriteSheet: &SpriteSheetAnimationSpec{
+						Map:     "map",
+						Columns: 3,
+						Rows:    1,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestValidateObject(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(makeValidObject().Validate())
+
+	// valid
+	for title, modify := range map[string]func(obj *Object){
+		"no position": func(obj *Object) {
+			obj.Spec.Position = nil
+		},
+		"absolute texture url": func(obj *Object) {
+			obj.Spec.Maps[0].URLTexture.URL = "https://example.com/img/fox.png"
+		},
+		"relative texture url": func(obj *Object) {
+			obj.Spec.Maps[0].URLTexture.URL = "img/fox.png"
+		},
+		"default wrapping": func(obj *Object) {
+			obj.Spec.Maps[0].URLTexture.WrapS = 0
+		},
+		"empty parts": func(obj *Object) {
+			obj.Spec.Parts = []*PartSpec{}
+			obj.Spec.Animations = nil
+		},
+		"animation with start time": func(obj *Object) {
+			obj.Spec.Animations[0].StartAt = "2024-01-01T00:00:00Z"
+			obj.Spec.Animations[0].Loop = AnimationLoopPingPong
+		},
+		"animation of nested part": func(obj *Object) {
+			obj.Spec.Animations[0].Tracks[0].Part = "box"
+			obj.Spec.Animations[0].Tracks[0].Property = AnimationPropertyRotation
+			obj.Spec.Animations[0].Tracks[0].Interpolation = AnimationInterpolationDiscrete
+		},
+		"visible radius": func(obj *Object) {
+			obj.Spec.VisibleRadius = map[string]float64{
+				ScaleDefault: 100,
+				ScaleXR:      OBJECT_MAX_VISIBLE_RADIUS,
+			}
+		},
+		"sprite sheet only": func(obj *Object) {
+			obj.Spec.Animations[0].Tracks = nil
+			obj.Spec.Animations[0].SpriteSheet.Frames = []int{0, 1, 2, 1}
+		},
+	} {
+		obj := makeValidObject()
+		modify(obj)
+		assert.NoError(obj.Validate(), title)
+	}
+
+	// invalid
+	for title, modify := range map[string]func(obj *Object){
+		"nil meta": func(obj *Object) {
+			obj.Meta = nil
+		},
+		"wrong type": func(obj *Object) {
+			obj.Meta.Type = core.ResourceTypePod
+		},
+		"invalid uuid": func(obj *Object) {
+			obj.Meta.Uuid = "object"
+		},
+		"nil spec": func(obj *Object) {
+			obj.Spec = nil
+		},
+		"empty part name": func(obj *Object) {
+			obj.Spec.Parts[0].Name = ""
+		},
+		"duplicate part name": func(obj *Object) {
+			obj.Spec.Parts[1].Name = "sprite"
+		},
+		"duplicate part name in group": func(obj *Object) {
+			obj.Spec.Parts[1].Group.Parts[0].Name = "sprite"
+		},
+		"no part type": func(obj *Object) {
+			obj.Spec.Parts[0].Sprite = nil
+		},
+		"multiple part types": func(obj *Object) {
+			obj.Spec.Parts[0].Mesh = obj.Spec.Parts[1].Group.Parts[0].Mesh
+		},
+		"sprite material not found": func(obj *Object) {
+			obj.Spec.Parts[0].Sprite.Material = "notFound"
+		},
+		"mesh material for sprite": func(obj *Object) {
+			obj.Spec.Parts[0].Sprite.Material = "meshMaterial"
+		},
+		"sprite material for mesh": func(obj *Object) {
+			obj.Spec.Parts[1].Group.Parts[0].Mesh.Material = "spriteMaterial"
+		},
+		"no geometry": func(obj *Object) {
+			obj.Spec.Parts[1].Group.Parts[0].Mesh.Geometry = nil
+		},
+		"multiple geometries": func(obj *Object) {
+			obj.Spec.Parts[1].Group.Parts[0].Mesh.Geometry.Sphere = &SphereGeometrySpec{Radius: 1}
+		},
+		"negative geometry size": func(obj *Object) {
+			obj.Spec.Parts[1].Group.Parts[0].Mesh.Geometry.Box.Width = -1
+		},
+		"too many segments": func(obj *Object) {
+			obj.Spec.Parts[1].Group.Parts[1].Mesh.Geometry.Cylinder.RadialSegments = OBJECT_MAX_SEGMENTS + 1
+		},
+		"unsupported scale key": func(obj *Object) {
+			obj.Spec.Parts[0].Sprite.Scale["vr"] = &Vector3{X: 1, Y: 1, Z: 1}
+		},
+		"too large scale": func(obj *Object) {
+			obj.Spec.Parts[0].Sprite.Scale[ScaleDefault].X = OBJECT_MAX_LENGTH + 1
+		},
+		"too large rotation": func(obj *Object) {
+			obj.Spec.Parts[1].Group.Rotation.Y = 7
+		},
+		"center out of range": func(obj *Object) {
+			obj.Spec.Parts[0].Sprite.Center.X = 1.5
+		},
+		"duplicate material name": func(obj *Object) {
+			obj.Spec.Materials[1].Name = "spriteMaterial"
+		},
+		"no material type": func(obj *Object) {
+			obj.Spec.Materials[1].MeshStandardMaterial = nil
+		},
+		"texture not found": func(obj *Object) {
+			obj.Spec.Materials[0].SpriteMaterial.MapTexture = "notFound"
+		},
+		"color out of range": func(obj *Object) {
+			obj.Spec.Materials[1].MeshStandardMaterial.Color.R = 2
+		},
+		"unsupported side": func(obj *Object) {
+			obj.Spec.Materials[1].MeshStandardMaterial.Side = 3
+		},
+		"duplicate map name": func(obj *Object) {
+			obj.Spec.Maps = append(obj.Spec.Maps, obj.Spec.Maps[0])
+		},
+		"no url texture": func(obj *Object) {
+			obj.Spec.Maps[0].URLTexture = nil
+		},
+		"unsupported wrapping": func(obj *Object) {
+			obj.Spec.Maps[0].URLTexture.WrapT = 1003
+		},
+		"empty url": func(obj *Object) {
+			obj.Spec.Maps[0].URLTexture.URL = ""
+		},
+		"unsupported url scheme": func(obj *Object) {
+			obj.Spec.Maps[0].URLTexture.URL = "javascript:alert(1)"
+		},
+		"data url": func(obj *Object) {
+			obj.Spec.Maps[0].URLTexture.URL = "data:image/png;base64,AAAA"
+		},
+		"url without scheme": func(obj *Object) {
+			obj.Spec.Maps[0].URLTexture.URL = "//example.com/fox.png"
+		},
+		"longitude out of range": func(obj *Object) {
+			obj.Spec.Position.X = 181
+		},
+		"latitude out of range": func(obj *Object) {
+			obj.Spec.Position.Y = -91
+		},
+		"altitude out of range": func(obj *Object) {
+			obj.Spec.Position.Z = OBJECT_MAX_ALTITUDE + 1
+		},
+		"too many parts": func(obj *Object) {
+			for i := 0; i < OBJECT_MAX_PARTS; i++ {
+				obj.Spec.Parts = append(obj.Spec.Parts, &PartSpec{
+					Name: "part" + strings.Repeat("_", i),
+					Sprite: &SpriteSpec{
+						Material: "spriteMaterial",
+					},
+				})
+			}
+		},
+		"too deep groups": func(obj *Object) {
+			parent := obj.Spec.Parts[1].Group
+			for i := 0; i < OBJECT_MAX_DEPTH; i++ {
+				group := &PartSpec{
+					Name:  "nested" + strings.Repeat("_", i),
+					Group: &GroupSpec{},
+				}
+				parent.Parts = append(parent.Parts, group)
+				parent = group.Group
+			}
+		},
+		"empty animation name": func(obj *Object) {
+			obj.Spec.Animations[0].Name = ""
+		},
+		"duplicate animation name": func(obj *Object) {
+			obj.Spec.Animations = append(obj.Spec.Animations, obj.Spec.Animations[0])
+		},
+		"zero duration": func(obj *Object) {
+			obj.Spec.Animations[0].Duration = 0
+		},
+		"too long duration": func(obj *Object) {
+			obj.Spec.Animations[0].Duration = ANIMATION_MAX_DURATION + 1
+		},
+		"unsupported loop": func(obj *Object) {
+			obj.Spec.Animations[0].Loop = "reverse"
+		},
+		"invalid start time": func(obj *Object) {
+			obj.Spec.Animations[0].StartAt = "yesterday"
+		},
+		"empty animation": func(obj *Object) {
+			obj.Spec.Animations[0].Tracks = nil
+			obj.Spec.Animations[0].SpriteSheet = nil
+		},
+		"animation part not found": func(obj *Object) {
+			obj.Spec.Animations[0].Tracks[0].Part = "unknown"
+		},
+		"unsupported animation property": func(obj *Object) {
+			obj.Spec.Animations[0].Tracks[0].Property = "color"
+		},
+		"unsupported interpolation": func(obj *Object) {
+			obj.Spec.Animations[0].Tracks[0].Interpolation = "cubic"
+		},
+		"no keyframes": func(obj *Object) {
+			obj.Spec.Animations[0].Tracks[0].Keyframes = nil
+		},
+		"keyframe out of duration": func(obj *Object) {
+			obj.Spec.Animations[0].Tracks[0].Keyframes[1].Time = 1001
+		},
+		"unsorted keyframes": func(obj *Object) {
+			obj.Spec.Animations[0].Tracks[0].Keyframes[1].Time = 0
+		},
+		"keyframe without value": func(obj *Object) {
+			obj.Spec.Animations[0].Tracks[0].Keyframes[1].Value = nil
+		},
+		"keyframe rotation out of range": func(obj *Object) {
+			obj.Spec.Animations[0].Tracks[0].Property = AnimationPropertyRotation
+			obj.Spec.Animations[0].Tracks[0].Keyframes[1].Value.Y = 7
+		},
+		"sprite sheet map not found": func(obj *Object) {
+			obj.Spec.Animations[0].SpriteSheet.Map = "unknown"
+		},
+		"sprite sheet without rows": func(obj *Object) {
+			obj.Spec.Animations[0].SpriteSheet.Rows = 0
+		},
+		"sprite sheet frame out of range": func(obj *Object) {
+			obj.Spec.Animations[0].SpriteSheet.Frames = []int{3}
+		},
+		"unsupported visible radius key": func(obj *Object) {
+			obj.Spec.VisibleRadius = map[string]float64{"vr": 100}
+		},
+		"zero visible radius": func(obj *Object) {
+			obj.Spec.VisibleRadius = map[string]float64{ScaleDefault: 0}
+		},
+		"too large visible radius": func(obj *Object) {
+			obj.Spec.VisibleRadius = map[string]float64{ScaleLandscape: OBJECT_MAX_VISIBLE_RADIUS + 1}
+		},
+		"too large object": func(obj *Object) {
+			obj.Meta.Name = strings.Repeat("a", OBJECT_MAX_SIZE)
+		},
+	} {
+		obj := makeValidObject()
+		modify(obj)
+		assert.Error(obj.Validate(), title)
+	}
+}
+
+func TestVisibleRadius(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &ObjectSpec{}
+	assert.Equal(float64(OBJECT_DEFAULT_VISIBLE_RADIUS), spec.GetVisibleRadius(ScaleXR))
+	assert.Equal(float64(OBJECT_DEFAULT_VISIBLE_RADIUS), spec.GetSpreadRadius())
+
+	spec.VisibleRadius = map[string]float64{
+		ScaleDefault: 100,
+		ScaleXR:      50,
+	}
+	assert.Equal(100.0, spec.GetVisibleRadius(ScaleLandscape))
+	assert.Equal(50.0, spec.GetVisibleRadius(ScaleXR))
+	assert.Equal(100.0, spec.GetSpreadRadius())
+
+	// scales without radius use the default radius
+	spec.VisibleRadius = map[string]float64{
+		ScaleXR: 50,
+	}
+	assert.Equal(float64(OBJECT_DEFAULT_VISIBLE_RADIUS), spec.GetSpreadRadius())
+}
+
+func TestDistance(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.InDelta(111195, Distance(&Vector3{X: 0, Y: 0}, &Vector3{X: 1, Y: 0}), 1)
+	assert.InDelta(111195, Distance(&Vector3{X: 139, Y: 0}, &Vector3{X: 139, Y: 1}), 1)
+	// altitude is ignored
+	assert.Equal(0.0, Distance(&Vector3{X: 10, Y: 20, Z: 0}, &Vector3{X: 10, Y: 20, Z: 100}))
+}
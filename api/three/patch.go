@@ -0,0 +1,331 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package three
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+type PatchOp string
+
+const (
+	PatchOpAdd     PatchOp = "add"
+	PatchOpRemove  PatchOp = "remove"
+	PatchOpReplace PatchOp = "replace"
+)
+
+// PatchOperation is a subset of JSON Patch (RFC 6902), the path is a JSON Pointer (RFC 6901) to a field of ObjectSpec.
+// e.g. `{"op": "replace", "path": "/maps/0/urlTexture/offset/x", "value": 0.33}`
+type PatchOperation struct {
+	Op    PatchOp         `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ObjectTransform is a lightweight update only for the position of the object and the local transforms of the parts.
+type ObjectTransform struct {
+	Position *Vector3 `json:"position,omitempty"`
+	// key: part name
+	Parts map[string]*PartTransform `json:"parts,omitempty"`
+}
+
+type PartTransform struct {
+	Position *Vector3 `json:"position,omitempty"`
+	Rotation *Vector3 `json:"rotation,omitempty"`
+}
+
+// TransientUpdate is applied to the object on each node receiving it without writing to the KVS.
+// Successive transient updates are accumulated by the node running the pod and it is overwritten by
+// the next persistent update.
+type TransientUpdate struct {
+	Patch     []PatchOperation `json:"patch,omitempty"`
+	Transform *ObjectTransform `json:"transform,omitempty"`
+}
+
+// ApplyPatch returns a new spec applied the operations, the original spec is not modified.
+func ApplyPatch(spec *ObjectSpec, ops []PatchOperation) (*ObjectSpec, error) {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the spec: %w", err)
+	}
+
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the spec: %w", err)
+	}
+
+	for _, op := range ops {
+		doc, err = applyPatchOperation(doc, &op)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply patch (%s %s): %w", op.Op, op.Path, err)
+		}
+	}
+
+	raw, err = json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the patched spec: %w", err)
+	}
+
+	patched := &ObjectSpec{}
+	if err := json.Unmarshal(raw, patched); err != nil {
+		return nil, fmt.Errorf("patched spec is invalid: %w", err)
+	}
+
+	return patched, nil
+}
+
+func applyPatchOperation(doc any, op *PatchOperation) (any, error) {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	switch op.Op {
+	case PatchOpAdd, PatchOpReplace:
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("value is required")
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("invalid value: %w", err)
+		}
+	case PatchOpRemove:
+	default:
+		return nil, fmt.Errorf("unsupported operation")
+	}
+
+	// replace whole document
+	if len(tokens) == 0 {
+		if op.Op == PatchOpRemove {
+			return nil, fmt.Errorf("the root can not be removed")
+		}
+		return value, nil
+	}
+
+	return patchNode(doc, tokens, op.Op, value)
+}
+
+// apply the operation recursively and return the updated node
+func patchNode(node any, tokens []string, op PatchOp, value any) (any, error) {
+	token := tokens[0]
+	last := len(tokens) == 1
+
+	switch n := node.(type) {
+	case map[string]any:
+		child, exists := n[token]
+		if !last {
+			if !exists {
+				return nil, fmt.Errorf("path not found: %s", token)
+			}
+			updated, err := patchNode(child, tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			n[token] = updated
+			return n, nil
+		}
+
+		switch op {
+		case PatchOpAdd:
+			n[token] = value
+		case PatchOpReplace:
+			if !exists {
+				return nil, fmt.Errorf("path not found: %s", token)
+			}
+			n[token] = value
+		case PatchOpRemove:
+			if !exists {
+				return nil, fmt.Errorf("path not found: %s", token)
+			}
+			delete(n, token)
+		}
+		return n, nil
+
+	case []any:
+		// `-` means the end of the array only for adding
+		if last && op == PatchOpAdd && token == "-" {
+			return append(n, value), nil
+		}
+
+		idx, err := strconv.Atoi(token)
+		if err != nil || idx < 0 || idx > len(n) || (idx == len(n) && !(last && op == PatchOpAdd)) {
+			return nil, fmt.Errorf("invalid array index: %s", token)
+		}
+
+		if !last {
+			updated, err := patchNode(n[idx], tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			n[idx] = updated
+			return n, nil
+		}
+
+		switch op {
+		case PatchOpAdd:
+			n = append(n, nil)
+			copy(n[idx+1:], n[idx:])
+			n[idx] = value
+		case PatchOpReplace:
+			n[idx] = value
+		case PatchOpRemove:
+			n = append(n[:idx], n[idx+1:]...)
+		}
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("path not found: %s", token)
+}
+
+func parsePointer(path string) ([]string, error) {
+	if len(path) == 0 {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path should start with `/`")
+	}
+
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// ApplyTransform returns a new spec applied the transform, the original spec is not modified.
+func ApplyTransform(spec *ObjectSpec, transform *ObjectTransform) (*ObjectSpec, error) {
+	// deep copy via json not to modify parts shared with the original
+	patched, err := ApplyPatch(spec, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if transform.Position != nil {
+		position := *transform.Position
+		patched.Position = &position
+	}
+
+	for name, t := range transform.Parts {
+		base := findPartBase(patched.Parts, name)
+		if base == nil {
+			return nil, fmt.Errorf("part not found: %s", name)
+		}
+		if t.Position != nil {
+			position := *t.Position
+			base.Position = &position
+		}
+		if t.Rotation != nil {
+			rotation := *t.Rotation
+			base.Rotation = &rotation
+		}
+	}
+
+	return patched, nil
+}
+
+func findPartBase(parts []*PartSpec, name string) *PartBaseSpec {
+	for _, part := range parts {
+		if part == nil {
+			continue
+		}
+
+		if part.Name == name {
+			switch {
+			case part.Mesh != nil:
+				return &part.Mesh.PartBaseSpec
+			case part.Sprite != nil:
+				return &part.Sprite.PartBaseSpec
+			case part.Group != nil:
+				return &part.Group.PartBaseSpec
+			}
+			return nil
+		}
+
+		if part.Group != nil {
+			if base := findPartBase(part.Group.Parts, name); base != nil {
+				return base
+			}
+		}
+	}
+	return nil
+}
+
+// Apply returns a new spec applied the transient update.
+func (update *TransientUpdate) Apply(spec *ObjectSpec) (*ObjectSpec, error) {
+	patched, err := ApplyPatch(spec, update.Patch)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Transform != nil {
+		patched, err = ApplyTransform(patched, update.Transform)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return patched, nil
+}
+
+// Merge returns an update equivalent to applying the update and then the next one.
+// It returns nil if they can't be merged, because the patch of the next update should be applied after the transform.
+func (update *TransientUpdate) Merge(next *TransientUpdate) *TransientUpdate {
+	if update.Transform != nil && len(next.Patch) != 0 {
+		return nil
+	}
+
+	merged := &TransientUpdate{
+		Patch: append(slices.Clone(update.Patch), next.Patch...),
+	}
+
+	if update.Transform == nil && next.Transform == nil {
+		return merged
+	}
+
+	merged.Transform = &ObjectTransform{}
+	for _, t := range []*ObjectTransform{update.Transform, next.Transform} {
+		if t == nil {
+			continue
+		}
+		if t.Position != nil {
+			merged.Transform.Position = t.Position
+		}
+		for name, part := range t.Parts {
+			if merged.Transform.Parts == nil {
+				merged.Transform.Parts = make(map[string]*PartTransform)
+			}
+			mp, ok := merged.Transform.Parts[name]
+			if !ok {
+				mp = &PartTransform{}
+				merged.Transform.Parts[name] = mp
+			}
+			if part.Position != nil {
+				mp.Position = part.Position
+			}
+			if part.Rotation != nil {
+				mp.Rotation = part.Rotation
+			}
+		}
+	}
+
+	return merged
+}
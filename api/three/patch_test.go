@@ -0,0 +1,143 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package three
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyPatch(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := makeValidObject().Spec
+	patched, err := ApplyPatch(spec, []PatchOperation{
+		{Op: PatchOpReplace, Path: "/position/x", Value: json.RawMessage(`140`)},
+		{Op: PatchOpAdd, Path: "/parts/1/group/parts/-", Value: json.RawMessage(`{"name":"sphere","mesh":{"geometry":{"sphere":{"radius":1}},"material":"meshMaterial"}}`)},
+		{Op: PatchOpRemove, Path: "/parts/1/group/parts/0"},
+		{Op: PatchOpAdd, Path: "/maps/0/urlTexture/offset", Value: json.RawMessage(`{"x":0.5,"y":0}`)},
+	})
+	assert.NoError(err)
+	assert.Equal(140.0, patched.Position.X)
+	assert.Len(patched.Parts[1].Group.Parts, 2)
+	assert.Equal("cylinder", patched.Parts[1].Group.Parts[0].Name)
+	assert.Equal("sphere", patched.Parts[1].Group.Parts[1].Name)
+	assert.Equal(0.5, patched.Maps[0].URLTexture.Offset.X)
+	// the original spec should not be modified
+	assert.Equal(139.7, spec.Position.X)
+	assert.Len(spec.Parts[1].Group.Parts, 2)
+	assert.Nil(spec.Maps[0].URLTexture.Offset)
+
+	invalids := map[string][]PatchOperation{
+		"unsupported op":   {{Op: "move", Path: "/position/x", Value: json.RawMessage(`1`)}},
+		"no value":         {{Op: PatchOpReplace, Path: "/position/x"}},
+		"invalid pointer":  {{Op: PatchOpReplace, Path: "position/x", Value: json.RawMessage(`1`)}},
+		"remove root":      {{Op: PatchOpRemove, Path: ""}},
+		"not found":        {{Op: PatchOpReplace, Path: "/unknown/x", Value: json.RawMessage(`1`)}},
+		"replace missing":  {{Op: PatchOpReplace, Path: "/maps/0/urlTexture/offset", Value: json.RawMessage(`{}`)}},
+		"index over":       {{Op: PatchOpReplace, Path: "/parts/2", Value: json.RawMessage(`{}`)}},
+		"index not number": {{Op: PatchOpRemove, Path: "/parts/a"}},
+		"type mismatch":    {{Op: PatchOpReplace, Path: "/position", Value: json.RawMessage(`"string"`)}},
+	}
+	for title, ops := range invalids {
+		_, err := ApplyPatch(spec, ops)
+		assert.Error(err, title)
+	}
+}
+
+func TestApplyTransform(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := makeValidObject().Spec
+	patched, err := ApplyTransform(spec, &ObjectTransform{
+		Position: &Vector3{X: 140, Y: 36, Z: 1},
+		Parts: map[string]*PartTransform{
+			"sprite": {Position: &Vector3{X: 1}},
+			"box":    {Rotation: &Vector3{Y: 1.57}},
+		},
+	})
+	assert.NoError(err)
+	assert.Equal(&Vector3{X: 140, Y: 36, Z: 1}, patched.Position)
+	assert.Equal(&Vector3{X: 1}, patched.Parts[0].Sprite.Position)
+	assert.Equal(&Vector3{Y: 1.57}, patched.Parts[1].Group.Parts[0].Mesh.Rotation)
+	assert.Nil(spec.Parts[0].Sprite.Position)
+	assert.Equal(139.7, spec.Position.X)
+
+	_, err = ApplyTransform(spec, &ObjectTransform{
+		Parts: map[string]*PartTransform{
+			"unknown": {Position: &Vector3{X: 1}},
+		},
+	})
+	assert.Error(err)
+}
+
+func TestApplyTransientUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	update := &TransientUpdate{
+		Patch: []PatchOperation{
+			{Op: PatchOpReplace, Path: "/position/x", Value: json.RawMessage(`1`)},
+		},
+		Transform: &ObjectTransform{
+			Position: &Vector3{X: 2, Y: 3},
+		},
+	}
+	patched, err := update.Apply(makeValidObject().Spec)
+	assert.NoError(err)
+	// transform is applied after patch
+	assert.Equal(&Vector3{X: 2, Y: 3}, patched.Position)
+}
+
+func TestMergeTransientUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	first := &TransientUpdate{
+		Patch: []PatchOperation{
+			{Op: PatchOpReplace, Path: "/position/x", Value: json.RawMessage(`1`)},
+		},
+		Transform: &ObjectTransform{
+			Parts: map[string]*PartTransform{
+				"box": {Position: &Vector3{X: 1}, Rotation: &Vector3{Y: 1}},
+			},
+		},
+	}
+	merged := first.Merge(&TransientUpdate{
+		Transform: &ObjectTransform{
+			Position: &Vector3{X: 2, Y: 3},
+			Parts: map[string]*PartTransform{
+				"box":    {Rotation: &Vector3{Y: 2}},
+				"sprite": {Position: &Vector3{Z: 1}},
+			},
+		},
+	})
+	assert.NotNil(merged)
+	assert.Len(merged.Patch, 1)
+	assert.Equal(&Vector3{X: 2, Y: 3}, merged.Transform.Position)
+	assert.Equal(&PartTransform{Position: &Vector3{X: 1}, Rotation: &Vector3{Y: 2}}, merged.Transform.Parts["box"])
+	assert.Equal(&PartTransform{Position: &Vector3{Z: 1}}, merged.Transform.Parts["sprite"])
+	// original update is not modified
+	assert.Equal(&Vector3{Y: 1}, first.Transform.Parts["box"].Rotation)
+
+	merged = (&TransientUpdate{Patch: first.Patch}).Merge(&TransientUpdate{Patch: first.Patch})
+	assert.Len(merged.Patch, 2)
+	assert.Nil(merged.Transform)
+
+	// patch can't be applied after transform
+	assert.Nil(first.Merge(&TransientUpdate{Patch: first.Patch}))
+}
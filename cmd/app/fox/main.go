@@ -17,7 +17,6 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"math/rand"
@@ -30,14 +29,13 @@ import (
 	threeLib "github.com/llamerada-jp/oinari/lib/three"
 )
 
+const timerStep = "step"
+
 type fox struct {
-	mtx    sync.Mutex
-	ctx    context.Context
-	cancel context.CancelFunc
-	three  threeLib.ThreeAPI
+	mtx   sync.Mutex
+	three threeLib.ThreeAPI
 
 	ObjectUUID string `json:"objectUUID"`
-	Frame      int    `json:"frame"`
 }
 
 var _ oinari.Application = (*fox)(nil)
@@ -46,6 +44,11 @@ func (f *fox) Setup(isInitialize bool, record []byte) error {
 	if isInitialize || record == nil {
 		f.initObject()
 
+		// the timer is kept by the node even if the pod is migrated
+		if _, err := oinari.Every(timerStep, 5*time.Second, nil); err != nil {
+			return err
+		}
+
 	} else {
 		err := json.Unmarshal(record, f)
 		if err != nil {
@@ -53,8 +56,6 @@ func (f *fox) Setup(isInitialize bool, record []byte) error {
 		}
 	}
 
-	f.start()
-
 	return nil
 }
 
@@ -62,7 +63,7 @@ func (f *fox) Setup(isInitialize bool, record []byte) error {
 func (f *fox) initObject() error {
 	var err error
 	// oinari system fill position using default position when create object without specifying to position.
-	f.ObjectUUID, err = f.three.CreateObject("fox", &threeAPI.ObjectSpec{
+	f.ObjectUUID, err = f.three.CreateObjectWithACL("fox", &threeAPI.ObjectSpec{
 		Parts: []*threeAPI.PartSpec{
 			{
 				Name: "sprite",
@@ -117,6 +118,23 @@ func (f *fox) initObject() error {
 				},
 			},
 		},
+		Animations: []*threeAPI.AnimationSpec{
+			{
+				Name:     "walk",
+				Duration: 3000,
+				Loop:     threeAPI.AnimationLoopRepeat,
+				SpriteSheet: &threeAPI.SpriteSheetAnimationSpec{
+					Map:     "mapFox",
+					Columns: 3,
+					Rows:    1,
+				},
+			},
+		},
+	}, &threeAPI.ObjectACL{
+		// anyone can see and touch the fox, only this pod moves it
+		Read:     threeAPI.AccessLevelWorld,
+		Write:    threeAPI.AccessLevelPod,
+		Interact: threeAPI.AccessLevelWorld,
 	})
 
 	return err
@@ -130,8 +148,6 @@ func (f *fox) Marshal() ([]byte, error) {
 }
 
 func (f *fox) Teardown(isFinalize bool) ([]byte, error) {
-	f.cancel()
-
 	if isFinalize && f.ObjectUUID != "" {
 		f.three.DeleteObject(f.ObjectUUID)
 	}
@@ -139,46 +155,50 @@ func (f *fox) Teardown(isFinalize bool) ([]byte, error) {
 	return f.Marshal()
 }
 
-func (f *fox) start() {
-	go func() {
-		for {
-			select {
-			case <-f.ctx.Done():
-				return
-
-			default:
-				time.Sleep(1 * time.Second)
-				f.loop()
-			}
-		}
-	}()
-}
+func (f *fox) step(event *oinari.TimerEvent) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
 
-func (f *fox) loop() {
 	// get object to get current position
 	object, err := f.three.GetObject(f.ObjectUUID)
 	if err != nil {
 		fmt.Println("🦊 get object error:", err)
+		return
+	}
+	x := object.Spec.Position.X + rand.Float64()*0.00001
+	y := object.Spec.Position.Y + rand.Float64()*0.00001
+
+	// update only the position without writing to the KVS, the sprite is animated on the frontend
+	patch := []threeAPI.PatchOperation{
+		replaceOperation("/position/x", x),
+		replaceOperation("/position/y", y),
 	}
-	object.Spec.Position.X += rand.Float64() * 0.00001
-	object.Spec.Position.Y += rand.Float64() * 0.00001
+	if err := f.three.PatchObject(f.ObjectUUID, patch, true); err != nil {
+		fmt.Println("🦊 patch object error:", err)
+	}
+}
 
-	f.Frame++
-	object.Spec.Maps[0].URLTexture.Offset.X = float64(f.Frame%3) * 0.333333
+func (f *fox) interact(event *threeAPI.InteractionEvent) {
+	if event.Type == threeAPI.InteractionTypeClick || event.Type == threeAPI.InteractionTypeSelect {
+		fmt.Printf("🦊 kon kon! (%s by %s)\n", event.Type, event.Account)
+	}
+}
 
-	if err := f.three.UpdateObject(f.ObjectUUID, object.Spec); err != nil {
-		fmt.Println("🦊 update object error:", err)
+func replaceOperation(path string, value float64) threeAPI.PatchOperation {
+	raw, _ := json.Marshal(value)
+	return threeAPI.PatchOperation{
+		Op:    threeAPI.PatchOpReplace,
+		Path:  path,
+		Value: raw,
 	}
 }
 
 func main() {
-	c, cancel := context.WithCancel(context.Background())
-
 	app := &fox{
-		ctx:    c,
-		cancel: cancel,
-		three:  threeLib.NewThreeAPI(),
+		three: threeLib.NewThreeAPI(),
 	}
+	oinari.HandleTimer(timerStep, app.step)
+	app.three.SetInteractionHandler(app.interact)
 
 	mgr := oinari.NewManager()
 	mgr.Use(app.three)
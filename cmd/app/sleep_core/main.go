@@ -17,32 +17,25 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/llamerada-jp/oinari/lib/oinari"
 )
 
+const timerWakeUp = "wakeUp"
+
 type sleep struct {
-	mtx         sync.Mutex
-	ctx         context.Context
-	cancel      context.CancelFunc
-	durationSec uint64
+	durationSec int64
 	// for marshal
-	PassedSec uint64 `json:"passedSec"`
+	StartedAt time.Time `json:"startedAt"`
 }
 
-func newSleep(ctx context.Context, durationSec uint64) *sleep {
-	c, cancel := context.WithCancel(ctx)
-
+func newSleep(durationSec int64) *sleep {
 	return &sleep{
-		ctx:         c,
-		cancel:      cancel,
 		durationSec: durationSec,
 	}
 }
@@ -50,62 +43,48 @@ func newSleep(ctx context.Context, durationSec uint64) *sleep {
 func (s *sleep) Setup(isInitialize bool, record []byte) error {
 	if isInitialize || record == nil {
 		fmt.Println("😪 start sleeping")
-		s.PassedSec = 0
+		s.StartedAt = time.Now()
+
+		// the timer is kept by the node even if the pod is migrated
+		if s.durationSec > 0 {
+			if _, err := oinari.After(timerWakeUp, time.Duration(s.durationSec)*time.Second, nil); err != nil {
+				return err
+			}
+		}
 
 	} else {
 		err := json.Unmarshal(record, s)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("😪 continue to sleep for %d sec\n", s.PassedSec)
+		fmt.Printf("😪 continue to sleep for %d sec\n", s.passedSec())
 	}
 
-	return s.start()
+	return nil
 }
 
 func (s *sleep) Marshal() ([]byte, error) {
-	s.mtx.Lock()
-	defer s.mtx.Unlock()
-
 	return json.Marshal(s)
 }
 
 func (s *sleep) Teardown(isFinalize bool) ([]byte, error) {
-	s.cancel()
-	// TODO: If use mutex, the container cannot stop. Is the limitation of WebAssembly thread?
-	// s.mtx.Lock()
-	// defer s.mtx.Unlock()
-
 	if isFinalize {
 		fmt.Println("😪 finish sleeping by interrupt")
 	} else {
-		fmt.Printf("😪 pause sleeping, %d sec passed\n", s.PassedSec)
+		fmt.Printf("😪 pause sleeping, %d sec passed\n", s.passedSec())
 	}
 
 	return s.Marshal()
 }
 
-func (s *sleep) start() error {
-	go func() {
-		for {
-			select {
-			case <-s.ctx.Done():
-				return
-
-			default:
-				time.Sleep(1 * time.Second)
-				s.mtx.Lock()
-				s.PassedSec++
-				if s.durationSec > 0 && s.PassedSec >= s.durationSec {
-					fmt.Println("😪 finish sleeping by timeout")
-					os.Exit(0)
-				}
-				s.mtx.Unlock()
-			}
-		}
-	}()
+func (s *sleep) wakeUp(event *oinari.TimerEvent) {
+	fmt.Println("😪 finish sleeping by timeout")
+	oinari.FlushWriter()
+	os.Exit(0)
+}
 
-	return nil
+func (s *sleep) passedSec() int64 {
+	return int64(time.Since(s.StartedAt).Seconds())
 }
 
 func showHelp(err error) {
@@ -134,7 +113,8 @@ func main() {
 		os.Exit(0)
 	}
 
-	sleep := newSleep(context.Background(), uint64(durationSec))
+	sleep := newSleep(durationSec)
+	oinari.HandleTimer(timerWakeUp, sleep.wakeUp)
 
 	mgr := oinari.NewManager()
 	err = mgr.Run(sleep)
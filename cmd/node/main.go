@@ -26,8 +26,11 @@ import (
 	"github.com/llamerada-jp/oinari/node"
 	"github.com/llamerada-jp/oinari/node/apis/core"
 	ch "github.com/llamerada-jp/oinari/node/apis/core/handler"
+	nh "github.com/llamerada-jp/oinari/node/apis/net/handler"
+	threeDriver "github.com/llamerada-jp/oinari/node/apis/three"
 	th "github.com/llamerada-jp/oinari/node/apis/three/handler"
 	"github.com/llamerada-jp/oinari/node/controller"
+	netController "github.com/llamerada-jp/oinari/node/controller/net"
 	threeController "github.com/llamerada-jp/oinari/node/controller/three"
 	"github.com/llamerada-jp/oinari/node/cri"
 	fd "github.com/llamerada-jp/oinari/node/frontend/driver"
@@ -141,29 +144,45 @@ func (na *nodeAgent) OnConnect(nodeName string, nodeType api.NodeType) error {
 	// CRI
 	cri := cri.NewCRI(na.cl)
 
+	// the resources and requests are signed only if the seed certifies the signing key of the account
+	var signer coreKVS.ResourceSigner
+	if len(na.sysCtrl.GetAccountKey()) != 0 && len(na.sysCtrl.GetKeyCertificate()) != 0 && len(na.sysCtrl.GetAuthorityKey()) != 0 {
+		signer = coreKVS.NewResourceSigner(account, api.DeriveNodeKey(na.sysCtrl.GetAccountKey()),
+			na.sysCtrl.GetKeyCertificate(), na.sysCtrl.GetAuthorityKey())
+	} else {
+		log.Println("the resources are not signed because the certificate of the account is not given")
+	}
+
 	// messaging
-	messaging := cmd.NewMessagingDriver(na.col)
-	threeMessaging := tmd.NewThreeMessagingDriver(na.col)
+	messaging := cmd.NewMessagingDriver(na.col, signer)
+	threeMessaging := tmd.NewThreeMessagingDriver(na.col, signer)
 
 	// KVS
-	accountKvs := coreKVS.NewAccountKvs(na.col)
-	podKvs := coreKVS.NewPodKvs(na.col)
-	recordKVS := coreKVS.NewRecordKvs(na.col)
+	watchHub := coreKVS.NewWatchHub(na.ctx, na.col, messaging)
+	accountKvs := coreKVS.NewAccountKvs(na.col, watchHub, signer)
+	podKvs := coreKVS.NewPodKvs(na.col, watchHub, signer)
+	podIndexKvs := coreKVS.NewPodIndexKvs(na.col)
+	recordKVS := coreKVS.NewRecordKvs(na.col, coreKVS.NewAccountKeyring(account, na.sysCtrl.GetAccountKey()))
 	objectKVS := threeKVS.NewObjectKVS(na.col)
 
 	// api driver manager
 	coreDriverManager := core.NewCoreDriverManager(na.cl)
+	threeAPIDriver := threeDriver.NewThreeDriver(na.cl)
 
 	// controllers
-	accountCtrl := controller.NewAccountController(account, localNid, accountKvs)
-	containerCtrl := controller.NewContainerController(localNid, cri, na.appFilter, podKvs, recordKVS, coreDriverManager)
+	accountCtrl := controller.NewAccountController(account, localNid, accountKvs, signer)
+	timerCtrl := controller.NewTimerController(coreDriverManager)
+	containerCtrl := controller.NewContainerController(localNid, cri, na.appFilter, podKvs, recordKVS, coreDriverManager, timerCtrl)
 	nodeCtrl := controller.NewNodeController(ctx, na.col, messaging, account, nodeName, nodeType)
-	podCtrl := controller.NewPodController(podKvs, messaging, localNid)
-	objectCtrl := threeController.NewObjectController(objectKVS, na.frontendDriver, threeMessaging, nodeCtrl, podCtrl)
+	podCtrl := controller.NewPodController(podKvs, recordKVS, messaging, localNid)
+	podIndexCtrl := controller.NewPodIndexController(localNid, podIndexKvs)
+	logCtrl := controller.NewLogController(localNid, accountCtrl, containerCtrl, podCtrl, messaging)
+	fetchCtrl := netController.NewFetchController(ctx, logCtrl, podCtrl)
+	objectCtrl := threeController.NewObjectController(ctx, objectKVS, na.frontendDriver, threeMessaging, threeAPIDriver, nodeCtrl, podCtrl)
 
 	// manager
-	localDs := node.NewLocalDatastore(na.col)
-	manager := node.NewManager(localDs, accountCtrl, containerCtrl, nodeCtrl, podCtrl)
+	localDs := node.NewLocalDatastore(na.col, signer)
+	manager := node.NewManager(localDs, accountCtrl, containerCtrl, nodeCtrl, podCtrl, podIndexCtrl, timerCtrl, objectCtrl)
 	go func() {
 		err := manager.Start(na.ctx)
 		if err != nil {
@@ -172,10 +191,11 @@ func (na *nodeAgent) OnConnect(nodeName string, nodeType api.NodeType) error {
 	}()
 
 	// handlers
-	cmh.InitMessagingHandler(na.col, containerCtrl, nodeCtrl)
-	tmh.InitMessagingHandler(na.col, objectCtrl)
-	fh.InitResourceHandler(na.nodeMpx, accountCtrl, containerCtrl, nodeCtrl, podCtrl)
-	ch.InitHandler(na.apiMpx, coreDriverManager, cri, podKvs, recordKVS)
+	cmh.InitMessagingHandler(na.col, signer, containerCtrl, logCtrl, nodeCtrl, watchHub)
+	tmh.InitMessagingHandler(na.col, signer, objectCtrl)
+	fh.InitResourceHandler(na.nodeMpx, na.frontendDriver, accountCtrl, containerCtrl, logCtrl, nodeCtrl, podCtrl, podIndexCtrl, objectCtrl)
+	ch.InitHandler(na.apiMpx, coreDriverManager, cri, podKvs, recordKVS, logCtrl, timerCtrl)
+	nh.InitHandler(na.apiMpx, fetchCtrl)
 	th.InitHandler(na.apiMpx, nodeCtrl, objectCtrl)
 
 	return nil
@@ -20,8 +20,11 @@ import (
 
 	"github.com/llamerada-jp/oinari/lib/crosslink"
 	"github.com/llamerada-jp/oinari/node/controller"
+	netController "github.com/llamerada-jp/oinari/node/controller/net"
+	threeController "github.com/llamerada-jp/oinari/node/controller/three"
 	"github.com/llamerada-jp/oinari/node/cri"
 	"github.com/llamerada-jp/oinari/node/kvs"
+	threeKVS "github.com/llamerada-jp/oinari/node/kvs/three"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -39,11 +42,21 @@ func TestMain(t *testing.T) {
 	// test kvs
 	suite.Run(t, kvs.NewAccountKvsTest())
 	suite.Run(t, kvs.NewPodKvsTest())
+	suite.Run(t, kvs.NewRecordKvsTest())
+	suite.Run(t, kvs.NewResourceSignerTest())
+	suite.Run(t, kvs.NewStoreTest())
+	suite.Run(t, threeKVS.NewObjectKVSTest())
 
 	// test controller
 	suite.Run(t, controller.NewAccountControllerTest())
+	suite.Run(t, controller.NewLogControllerTest())
+	suite.Run(t, controller.NewTimerControllerTest())
 	suite.Run(t, controller.NewNodeControllerTest())
 	suite.Run(t, controller.NewPodControllerTest())
+	suite.Run(t, controller.NewPodIndexControllerTest())
+	suite.Run(t, netController.NewFetchControllerTest())
+	suite.Run(t, threeController.NewObjectControllerTest())
+	suite.Run(t, threeController.NewSceneTest())
 
 	// test manager
 }
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package net
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	api "github.com/llamerada-jp/oinari/api/net"
+	"github.com/llamerada-jp/oinari/lib/crosslink"
+)
+
+type netAPIImpl struct {
+	cl crosslink.Crosslink
+}
+
+var _ NetAPI = (*netAPIImpl)(nil)
+
+func NewNetAPI() NetAPI {
+	return &netAPIImpl{}
+}
+
+func (impl *netAPIImpl) Name() string {
+	return "net"
+}
+
+func (impl *netAPIImpl) Setup(cl crosslink.Crosslink, apiMpx crosslink.MultiPlexer, errCh chan error) error {
+	impl.cl = cl
+	return nil
+}
+
+func (impl *netAPIImpl) Fetch(request *api.FetchRequest) (*Response, error) {
+	if err := request.Validate(); err != nil {
+		return nil, fmt.Errorf("error on Fetch API: %w", err)
+	}
+
+	res, err := callHelper[api.FetchRequest, api.FetchResponse](impl, "fetch", request)
+	if err != nil {
+		return nil, fmt.Errorf("error on Fetch API: %w", err)
+	}
+
+	return &Response{
+		Status: res.Status,
+		Header: res.Header,
+		Body: &responseBody{
+			impl:     impl,
+			streamID: res.StreamID,
+		},
+	}, nil
+}
+
+func (impl *netAPIImpl) Get(url string) (*Response, error) {
+	return impl.Fetch(&api.FetchRequest{
+		Method: "GET",
+		URL:    url,
+	})
+}
+
+func (impl *netAPIImpl) Post(url, contentType string, body []byte) (*Response, error) {
+	return impl.Fetch(&api.FetchRequest{
+		Method: "POST",
+		URL:    url,
+		Header: map[string][]string{
+			"Content-Type": {contentType},
+		},
+		Body: body,
+	})
+}
+
+type responseBody struct {
+	mtx      sync.Mutex
+	impl     *netAPIImpl
+	streamID string
+	buf      []byte
+	eof      bool
+	closed   bool
+}
+
+var _ io.ReadCloser = (*responseBody)(nil)
+
+func (body *responseBody) Read(p []byte) (int, error) {
+	body.mtx.Lock()
+	defer body.mtx.Unlock()
+
+	if body.closed {
+		return 0, fmt.Errorf("read on closed response body")
+	}
+
+	for len(body.buf) == 0 {
+		if body.eof {
+			return 0, io.EOF
+		}
+
+		res, err := callHelper[api.ReadRequest, api.ReadResponse](body.impl, "read", &api.ReadRequest{
+			StreamID: body.streamID,
+			Size:     api.FETCH_READ_MAX_SIZE,
+		})
+		if err != nil {
+			// the stream is closed by the node when an error occurs
+			body.eof = true
+			return 0, fmt.Errorf("error on reading response body: %w", err)
+		}
+		body.buf = res.Data
+		body.eof = res.EOF
+	}
+
+	n := copy(p, body.buf)
+	body.buf = body.buf[n:]
+	return n, nil
+}
+
+func (body *responseBody) Close() error {
+	body.mtx.Lock()
+	defer body.mtx.Unlock()
+
+	if body.closed {
+		return nil
+	}
+	body.closed = true
+
+	// the stream has been closed by the node after reading all
+	if body.eof {
+		return nil
+	}
+
+	_, err := callHelper[api.CloseRequest, api.CloseResponse](body.impl, "close", &api.CloseRequest{
+		StreamID: body.streamID,
+	})
+	if err != nil {
+		return fmt.Errorf("error on closing response body: %w", err)
+	}
+	return nil
+}
+
+func callHelper[REQ any, RES any](impl *netAPIImpl, path string, request *REQ) (*RES, error) {
+	ch := make(chan *RES)
+	var funcError error
+
+	impl.cl.Call(strings.Join([]string{NodeCrosslinkPath, path}, "/"), request, nil,
+		func(response []byte, err error) {
+			defer close(ch)
+
+			if err != nil {
+				funcError = err
+				return
+			}
+
+			var res RES
+			if err := json.Unmarshal(response, &res); err != nil {
+				funcError = err
+				return
+			}
+
+			ch <- &res
+		})
+
+	res, ok := <-ch
+	if !ok {
+		return nil, funcError
+	}
+
+	return res, nil
+}
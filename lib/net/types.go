@@ -0,0 +1,43 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package net
+
+import (
+	"io"
+
+	api "github.com/llamerada-jp/oinari/api/net"
+	"github.com/llamerada-jp/oinari/lib/oinari"
+)
+
+const (
+	NodeCrosslinkPath = "node/api/net"
+)
+
+// NetAPI sends HTTP requests via the node. Destinations should be allowed by the egress spec of the pod.
+type NetAPI interface {
+	oinari.API
+
+	Fetch(request *api.FetchRequest) (*Response, error)
+	Get(url string) (*Response, error)
+	Post(url, contentType string, body []byte) (*Response, error)
+}
+
+type Response struct {
+	Status int
+	Header map[string][]string
+	// Body is read from the node as streaming, it should be closed after use.
+	Body io.ReadCloser
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package oinari
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/lib/crosslink"
+	"golang.org/x/exp/maps"
+)
+
+// Logger is a structured logger sending entries to the node, they are stored in the log buffer of the container.
+var Logger *slog.Logger
+
+// LogHandler is the slog compatible handler used by Logger. use it to make a logger with other options.
+var LogHandler slog.Handler
+
+type logHandler struct {
+	cl    crosslink.Crosslink
+	path  string
+	level slog.Leveler
+	// attributes added by WithAttrs, the keys are already prefixed by the group names
+	attrs map[string]string
+	// prefix for the keys of attributes, it is made by WithGroup
+	prefix string
+}
+
+var _ slog.Handler = (*logHandler)(nil)
+
+func initLogger(cl crosslink.Crosslink, path string) error {
+	LogHandler = &logHandler{
+		cl:    cl,
+		path:  path,
+		level: slog.LevelDebug,
+		attrs: make(map[string]string),
+	}
+	Logger = slog.New(LogHandler)
+
+	return nil
+}
+
+func (h *logHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *logHandler) Handle(_ context.Context, record slog.Record) error {
+	attrs := maps.Clone(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		appendAttr(attrs, h.prefix, attr)
+		return true
+	})
+
+	timestamp := record.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	entry := core.LogEntry{
+		Timestamp: timestamp.Format(time.RFC3339Nano),
+		Level:     convertLogLevel(record.Level),
+		Message:   record.Message,
+	}
+	if len(attrs) != 0 {
+		entry.Attrs = attrs
+	}
+
+	return h.send([]core.LogEntry{entry})
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	handler := h.clone()
+	for _, attr := range attrs {
+		appendAttr(handler.attrs, handler.prefix, attr)
+	}
+	return handler
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if len(name) == 0 {
+		return h
+	}
+
+	handler := h.clone()
+	handler.prefix = h.prefix + name + "."
+	return handler
+}
+
+func (h *logHandler) clone() *logHandler {
+	return &logHandler{
+		cl:     h.cl,
+		path:   h.path,
+		level:  h.level,
+		attrs:  maps.Clone(h.attrs),
+		prefix: h.prefix,
+	}
+}
+
+func (h *logHandler) send(entries []core.LogEntry) error {
+	errCh := make(chan error, 1)
+
+	h.cl.Call(h.path+"/log", core.LogRequest{
+		Entries: entries,
+	}, nil, func(b []byte, err error) {
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		var res core.LogResponse
+		if err := json.Unmarshal(b, &res); err != nil {
+			errCh <- fmt.Errorf("unmarshal response of log failed on oinari api: %w", err)
+			return
+		}
+
+		errCh <- nil
+	})
+
+	return <-errCh
+}
+
+// flatten the attribute to `group.key = value` format
+func appendAttr(attrs map[string]string, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if len(attr.Key) != 0 {
+			groupPrefix = prefix + attr.Key + "."
+		}
+		for _, a := range attr.Value.Group() {
+			appendAttr(attrs, groupPrefix, a)
+		}
+		return
+	}
+
+	attrs[prefix+attr.Key] = attr.Value.String()
+}
+
+func convertLogLevel(level slog.Level) core.LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return core.LogLevelDebug
+	case level < slog.LevelWarn:
+		return core.LogLevelInfo
+	case level < slog.LevelError:
+		return core.LogLevelWarn
+	default:
+		return core.LogLevelError
+	}
+}
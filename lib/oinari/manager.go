@@ -31,14 +31,22 @@ type Manager struct {
 	apis []API
 	// application
 	app Application
+	// configuration for the std writer
+	writerConfig WriterConfig
 }
 
 func NewManager() *Manager {
 	return &Manager{
-		apis: make([]API, 0),
+		apis:         make([]API, 0),
+		writerConfig: DefaultWriterConfig(),
 	}
 }
 
+// SetWriterConfig changes the configuration of Writer, it should be called before Run.
+func (m *Manager) SetWriterConfig(config WriterConfig) {
+	m.writerConfig = config
+}
+
 func (m *Manager) Use(api API) error {
 	m.apis = append(m.apis, api)
 	return nil
@@ -74,10 +82,20 @@ func (m *Manager) init(errCh chan error) error {
 	appMpx.SetHandler("api", apiMpx)
 
 	// setup std writer
-	if err := initWriter(m.cl, NodeCrosslinkPath); err != nil {
+	if err := initWriter(m.cl, NodeCrosslinkPath, m.writerConfig); err != nil {
 		return fmt.Errorf("failed to setup std writer module: %w", err)
 	}
 
+	// setup structured logger
+	if err := initLogger(m.cl, NodeCrosslinkPath); err != nil {
+		return fmt.Errorf("failed to setup logger module: %w", err)
+	}
+
+	// setup durable timers
+	if err := initTimer(m.cl, NodeCrosslinkPath); err != nil {
+		return fmt.Errorf("failed to setup timer module: %w", err)
+	}
+
 	// setup APIs
 	if err := m.setupCoreHandler(apiMpx, errCh); err != nil {
 		return fmt.Errorf("failed to setup core api: %w", err)
@@ -119,6 +137,10 @@ func (m *Manager) setupCoreHandler(apiMpx crosslink.MultiPlexer, errCh chan erro
 
 	coreAPIMpx.SetHandler("teardown", crosslink.NewFuncHandler(func(req *core.TeardownRequest, tags map[string]string, writer crosslink.ResponseWriter) {
 		record, err := m.app.Teardown(req.IsFinalize)
+		// send remaining output before the container stops
+		if err := FlushWriter(); err != nil {
+			log.Printf("failed to flush writer: %s", err.Error())
+		}
 		// ignore record if finalize
 		if req.IsFinalize {
 			record = nil
@@ -134,6 +156,14 @@ func (m *Manager) setupCoreHandler(apiMpx crosslink.MultiPlexer, errCh chan erro
 		}
 	}))
 
+	coreAPIMpx.SetHandler("fireTimer", crosslink.NewFuncHandler(func(req *core.FireTimerRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		if err := timers.fire(req); err != nil {
+			writer.ReplyError(err.Error())
+			return
+		}
+		writer.ReplySuccess(core.FireTimerResponse{})
+	}))
+
 	return nil
 }
 
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package oinari
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/lib/crosslink"
+)
+
+// TimerEvent is passed to the handler when a timer fires.
+type TimerEvent struct {
+	ID      string
+	Name    string
+	Payload []byte
+	// the time the timer was scheduled to fire, it may be past when the timer was paused by migration
+	ScheduledAt time.Time
+}
+
+type TimerHandler func(event *TimerEvent)
+
+// Timers are kept by the node and stored in the record of the pod, so they are not lost by Teardown or migration.
+// The application should set handlers by HandleTimer before Run or in the Setup method to receive restored timers.
+type timerManager struct {
+	cl   crosslink.Crosslink
+	path string
+
+	mtx sync.Mutex
+	// key: timer name
+	handlers map[string]TimerHandler
+}
+
+var timers = &timerManager{
+	handlers: make(map[string]TimerHandler),
+}
+
+func initTimer(cl crosslink.Crosslink, path string) error {
+	timers.cl = cl
+	timers.path = path
+	return nil
+}
+
+// HandleTimer sets the handler called when timers having the name fire.
+func HandleTimer(name string, handler TimerHandler) {
+	timers.mtx.Lock()
+	defer timers.mtx.Unlock()
+
+	if handler == nil {
+		delete(timers.handlers, name)
+		return
+	}
+	timers.handlers[name] = handler
+}
+
+// After sets a timer to fire once after the delay, return the id of the timer.
+func After(name string, delay time.Duration, payload []byte) (string, error) {
+	return timers.set(&core.Timer{
+		Name:     name,
+		Type:     core.TimerTypeAfter,
+		Interval: delay.Milliseconds(),
+		Payload:  payload,
+	})
+}
+
+// Every sets a timer to fire repeatedly at the interval, return the id of the timer.
+func Every(name string, interval time.Duration, payload []byte) (string, error) {
+	return timers.set(&core.Timer{
+		Name:     name,
+		Type:     core.TimerTypeEvery,
+		Interval: interval.Milliseconds(),
+		Payload:  payload,
+	})
+}
+
+// Cron sets a timer to fire at the time matched with the cron expression "minute hour day-of-month month day-of-week" in UTC.
+func Cron(name string, expr string, payload []byte) (string, error) {
+	return timers.set(&core.Timer{
+		Name:    name,
+		Type:    core.TimerTypeCron,
+		Cron:    expr,
+		Payload: payload,
+	})
+}
+
+// CancelTimer cancels the timer not to fire any more.
+func CancelTimer(id string) error {
+	_, err := callNode[core.CancelTimerRequest, core.CancelTimerResponse](timers.cl, timers.path+"/cancelTimer", &core.CancelTimerRequest{
+		ID: id,
+	})
+	return err
+}
+
+func (tm *timerManager) set(timer *core.Timer) (string, error) {
+	if err := timer.Validate(false); err != nil {
+		return "", err
+	}
+
+	res, err := callNode[core.SetTimerRequest, core.SetTimerResponse](tm.cl, tm.path+"/setTimer", &core.SetTimerRequest{
+		Timer: timer,
+	})
+	if err != nil {
+		return "", err
+	}
+	return res.ID, nil
+}
+
+func (tm *timerManager) fire(req *core.FireTimerRequest) error {
+	tm.mtx.Lock()
+	handler, ok := tm.handlers[req.Name]
+	tm.mtx.Unlock()
+
+	if !ok {
+		return fmt.Errorf("handler for the timer is not set: %s", req.Name)
+	}
+
+	scheduledAt, err := time.Parse(time.RFC3339, req.ScheduledAt)
+	if err != nil {
+		return fmt.Errorf("invalid scheduled time of the timer: %w", err)
+	}
+
+	handler(&TimerEvent{
+		ID:          req.ID,
+		Name:        req.Name,
+		Payload:     req.Payload,
+		ScheduledAt: scheduledAt,
+	})
+
+	return nil
+}
+
+func callNode[REQ any, RES any](cl crosslink.Crosslink, path string, request *REQ) (*RES, error) {
+	if cl == nil {
+		return nil, fmt.Errorf("oinari manager is not running")
+	}
+
+	type result struct {
+		res *RES
+		err error
+	}
+	ch := make(chan result, 1)
+
+	cl.Call(path, request, nil, func(b []byte, err error) {
+		if err != nil {
+			ch <- result{err: err}
+			return
+		}
+
+		var res RES
+		if err := json.Unmarshal(b, &res); err != nil {
+			ch <- result{err: fmt.Errorf("unmarshal response failed on oinari api: %w", err)}
+			return
+		}
+
+		ch <- result{res: &res}
+	})
+
+	r := <-ch
+	return r.res, r.err
+}
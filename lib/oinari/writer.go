@@ -16,59 +16,238 @@
 package oinari
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
-	"log"
+	"sync"
+	"time"
 
 	"github.com/llamerada-jp/oinari/api/core"
 	"github.com/llamerada-jp/oinari/lib/crosslink"
 )
 
+const (
+	DEFAULT_WRITER_MAX_PAYLOAD_SIZE = 16 * 1024
+	DEFAULT_WRITER_FLUSH_INTERVAL   = 100 * time.Millisecond
+	// Write method blocks while the buffered data is larger than MaxPayloadSize * WRITER_BUFFER_RATIO
+	WRITER_BUFFER_RATIO = 8
+)
+
+// Writer is an io.Writer to output messages to the node.
+// The messages are buffered and sent asynchronously in batches of lines, so Write method does not wait for the node.
+// An error occurred on sending is returned by the next Write or FlushWriter call.
 var Writer io.Writer
 
+type WriterConfig struct {
+	// max size of the payload sent to the node at once
+	MaxPayloadSize int
+	// time to wait for following writes to send them together
+	FlushInterval time.Duration
+}
+
 type writer struct {
-	cl   crosslink.Crosslink
-	path string
+	cl     crosslink.Crosslink
+	path   string
+	config WriterConfig
+
+	mtx  sync.Mutex
+	cond *sync.Cond
+	buf  []byte
+	// true while sending a payload
+	sending bool
+	// true while FlushWriter is waiting, send all buffered data including the incomplete line
+	forced    bool
+	lastWrite time.Time
+	err       error
+	signal    chan struct{}
+}
+
+var stdWriter *writer
+
+func DefaultWriterConfig() WriterConfig {
+	return WriterConfig{
+		MaxPayloadSize: DEFAULT_WRITER_MAX_PAYLOAD_SIZE,
+		FlushInterval:  DEFAULT_WRITER_FLUSH_INTERVAL,
+	}
 }
 
-func initWriter(cl crosslink.Crosslink, path string) error {
-	Writer = &writer{
-		cl:   cl,
-		path: path,
+func initWriter(cl crosslink.Crosslink, path string, config WriterConfig) error {
+	if config.MaxPayloadSize <= 0 {
+		return fmt.Errorf("max payload size of the writer should be positive value")
+	}
+	if config.FlushInterval < 0 {
+		return fmt.Errorf("flush interval of the writer should not be negative value")
+	}
+
+	w := &writer{
+		cl:     cl,
+		path:   path,
+		config: config,
+		buf:    make([]byte, 0),
+		signal: make(chan struct{}, 1),
 	}
+	w.cond = sync.NewCond(&w.mtx)
+
+	go w.run()
+
+	stdWriter = w
+	Writer = w
 
 	return nil
 }
 
+// FlushWriter sends all buffered data of Writer to the node and waits for it.
+func FlushWriter() error {
+	if stdWriter == nil {
+		return fmt.Errorf("writer is not initialized")
+	}
+	return stdWriter.flush()
+}
+
 func (w *writer) Write(p []byte) (n int, err error) {
-	type writeResponse struct {
-		len int
-		err error
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	// wait for the buffer to be sent if it is too large
+	for len(w.buf) >= w.config.MaxPayloadSize*WRITER_BUFFER_RATIO && w.err == nil {
+		w.cond.Wait()
+	}
+
+	if err := w.takeError(); err != nil {
+		return 0, err
 	}
-	resCh := make(chan writeResponse)
+
+	w.buf = append(w.buf, p...)
+	w.lastWrite = time.Now()
+	w.notify()
+
+	return len(p), nil
+}
+
+func (w *writer) flush() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.forced = true
+	w.notify()
+	for (len(w.buf) != 0 || w.sending) && w.err == nil {
+		w.cond.Wait()
+	}
+	w.forced = false
+
+	return w.takeError()
+}
+
+// should be called with locking mtx
+func (w *writer) takeError() error {
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// should be called with locking mtx
+func (w *writer) notify() {
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *writer) run() {
+	for range w.signal {
+		w.mtx.Lock()
+		forced := w.forced
+		w.mtx.Unlock()
+
+		// wait a moment to send following writes together
+		if !forced {
+			time.Sleep(w.config.FlushInterval)
+		}
+
+		for w.sendBuffered() {
+		}
+	}
+}
+
+// return true if a payload was sent
+func (w *writer) sendBuffered() bool {
+	w.mtx.Lock()
+	payload := w.takePayload()
+	if payload == nil {
+		w.cond.Broadcast()
+		w.mtx.Unlock()
+		return false
+	}
+	w.sending = true
+	w.mtx.Unlock()
+
+	err := w.send(payload)
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.sending = false
+	if err != nil {
+		w.err = err
+	}
+	w.cond.Broadcast()
+
+	return true
+}
+
+// take a payload from the buffer by splitting it at line boundaries
+// should be called with locking mtx
+func (w *writer) takePayload() []byte {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	size := len(w.buf)
+	if size > w.config.MaxPayloadSize {
+		size = w.config.MaxPayloadSize
+	}
+
+	if idx := bytes.LastIndexByte(w.buf[:size], '\n'); idx >= 0 {
+		size = idx + 1
+
+	} else if size < w.config.MaxPayloadSize {
+		// keep the incomplete line while following writes are coming
+		if !w.forced && time.Since(w.lastWrite) < w.config.FlushInterval {
+			return nil
+		}
+	}
+
+	payload := make([]byte, size)
+	copy(payload, w.buf[:size])
+	w.buf = w.buf[size:]
+
+	return payload
+}
+
+func (w *writer) send(payload []byte) error {
+	errCh := make(chan error, 1)
 
 	w.cl.Call(w.path+"/output", core.OutputRequest{
-		Payload: p,
+		Payload: payload,
 	}, nil, func(b []byte, err error) {
 		if err != nil {
-			resCh <- writeResponse{
-				len: 0,
-				err: err,
-			}
+			errCh <- err
+			return
 		}
 
 		var res core.OutputResponse
-		err = json.Unmarshal(b, &res)
-		if err != nil {
-			log.Fatalf("unmarshal response of output failed on oinari api: %s", err.Error())
+		if err := json.Unmarshal(b, &res); err != nil {
+			errCh <- fmt.Errorf("unmarshal response of output failed on oinari api: %w", err)
+			return
 		}
 
-		resCh <- writeResponse{
-			len: res.Length,
-			err: nil,
+		if res.Length != len(payload) {
+			errCh <- fmt.Errorf("only %d bytes of %d bytes were output", res.Length, len(payload))
+			return
 		}
+
+		errCh <- nil
 	})
 
-	res := <-resCh
-	return res.len, res.err
+	return <-errCh
 }
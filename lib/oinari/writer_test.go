@@ -0,0 +1,214 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package oinari
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// outputRecorder is a crosslink receiving the output requests instead of the node
+type outputRecorder struct {
+	mtx      sync.Mutex
+	paths    []string
+	payloads [][]byte
+	err      error
+	// the length replied is shorter than the payload if true
+	short bool
+}
+
+func (r *outputRecorder) Call(path string, obj any, tags map[string]string, cb func([]byte, error)) {
+	payload := obj.(core.OutputRequest).Payload
+
+	r.mtx.Lock()
+	r.paths = append(r.paths, path)
+	r.payloads = append(r.payloads, payload)
+	err := r.err
+	length := len(payload)
+	if r.short {
+		length--
+	}
+	r.mtx.Unlock()
+
+	go func() {
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		raw, _ := json.Marshal(core.OutputResponse{Length: length})
+		cb(raw, nil)
+	}()
+}
+
+func (r *outputRecorder) getPayloads() [][]byte {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([][]byte{}, r.payloads...)
+}
+
+func (r *outputRecorder) setError(err error, short bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.err = err
+	r.short = short
+}
+
+func TestInitWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Error(initWriter(&outputRecorder{}, "node", WriterConfig{
+		MaxPayloadSize: 0,
+		FlushInterval:  DEFAULT_WRITER_FLUSH_INTERVAL,
+	}))
+	assert.Error(initWriter(&outputRecorder{}, "node", WriterConfig{
+		MaxPayloadSize: DEFAULT_WRITER_MAX_PAYLOAD_SIZE,
+		FlushInterval:  -time.Second,
+	}))
+
+	recorder := &outputRecorder{}
+	assert.NoError(initWriter(recorder, "node", DefaultWriterConfig()))
+	_, err := Writer.Write([]byte("message\n"))
+	assert.NoError(err)
+	assert.NoError(FlushWriter())
+	assert.Equal([]string{"node/output"}, recorder.paths)
+}
+
+func TestWriterPartialLine(t *testing.T) {
+	assert := assert.New(t)
+	recorder := &outputRecorder{}
+	assert.NoError(initWriter(recorder, "node", WriterConfig{
+		MaxPayloadSize: DEFAULT_WRITER_MAX_PAYLOAD_SIZE,
+		FlushInterval:  50 * time.Millisecond,
+	}))
+
+	// the payload is split at the end of the line and the incomplete line is sent after the interval
+	_, err := Writer.Write([]byte("hello "))
+	assert.NoError(err)
+	_, err = Writer.Write([]byte("world\nnext"))
+	assert.NoError(err)
+	assert.Eventually(func() bool {
+		return len(recorder.getPayloads()) == 2
+	}, time.Second, 10*time.Millisecond)
+	payloads := recorder.getPayloads()
+	assert.Equal("hello world\n", string(payloads[0]))
+	assert.Equal("next", string(payloads[1]))
+
+	// the line longer than the max payload size is split by the size
+	recorder = &outputRecorder{}
+	assert.NoError(initWriter(recorder, "node", WriterConfig{
+		MaxPayloadSize: 8,
+		FlushInterval:  DEFAULT_WRITER_FLUSH_INTERVAL,
+	}))
+	_, err = Writer.Write([]byte("0123456789abcdef\nxyz\n"))
+	assert.NoError(err)
+	assert.NoError(FlushWriter())
+	payloads = recorder.getPayloads()
+	for _, payload := range payloads {
+		assert.LessOrEqual(len(payload), 8)
+	}
+	assert.Equal("0123456789abcdef\nxyz\n", string(bytes.Join(payloads, nil)))
+	assert.Equal("\nxyz\n", string(payloads[len(payloads)-1]))
+}
+
+func TestFlushWriter(t *testing.T) {
+	assert := assert.New(t)
+	recorder := &outputRecorder{}
+	assert.NoError(initWriter(recorder, "node", WriterConfig{
+		MaxPayloadSize: DEFAULT_WRITER_MAX_PAYLOAD_SIZE,
+		FlushInterval:  200 * time.Millisecond,
+	}))
+
+	// flush sends the incomplete line too
+	_, err := Writer.Write([]byte("line\nincomplete"))
+	assert.NoError(err)
+	assert.NoError(FlushWriter())
+	assert.Equal("line\nincomplete", string(bytes.Join(recorder.getPayloads(), nil)))
+
+	// nothing to send
+	assert.NoError(FlushWriter())
+
+	// the error on sending is returned by the next flush only once
+	recorder.setError(errors.New("output failed"), false)
+	_, err = Writer.Write([]byte("error\n"))
+	assert.NoError(err)
+	assert.Error(FlushWriter())
+	recorder.setError(nil, false)
+	assert.NoError(FlushWriter())
+
+	// the error is returned if the node could not output all of the payload
+	recorder.setError(nil, true)
+	_, err = Writer.Write([]byte("short\n"))
+	assert.NoError(err)
+	assert.Error(FlushWriter())
+
+	// the error is returned by the next write if it is not flushed
+	_, err = Writer.Write([]byte("short\n"))
+	assert.NoError(err)
+	assert.Eventually(func() bool {
+		_, err := Writer.Write([]byte{})
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWriterConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	recorder := &outputRecorder{}
+	assert.NoError(initWriter(recorder, "node", WriterConfig{
+		MaxPayloadSize: 64,
+		FlushInterval:  time.Millisecond,
+	}))
+
+	const writers = 10
+	const lines = 100
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for l := 0; l < lines; l++ {
+				_, err := fmt.Fprintf(Writer, "%d-%d\n", w, l)
+				assert.NoError(err)
+			}
+		}(w)
+	}
+	wg.Wait()
+	assert.NoError(FlushWriter())
+
+	// the lines are not broken and the order of the lines from each writer is kept
+	next := make([]int, writers)
+	for _, payload := range recorder.getPayloads() {
+		assert.True(bytes.HasSuffix(payload, []byte("\n")))
+		for _, line := range strings.Split(strings.TrimSuffix(string(payload), "\n"), "\n") {
+			var w, l int
+			_, err := fmt.Sscanf(line, "%d-%d", &w, &l)
+			assert.NoError(err, line)
+			assert.Equal(next[w], l, line)
+			next[w] = l + 1
+		}
+	}
+	for w := 0; w < writers; w++ {
+		assert.Equal(lines, next[w])
+	}
+}
@@ -19,13 +19,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 
 	api "github.com/llamerada-jp/oinari/api/three"
 	"github.com/llamerada-jp/oinari/lib/crosslink"
 )
 
 type threeAPIImpl struct {
-	cl crosslink.Crosslink
+	cl                 crosslink.Crosslink
+	mtx                sync.Mutex
+	interactionHandler InteractionHandler
 }
 
 var _ ThreeAPI = (*threeAPIImpl)(nil)
@@ -40,13 +43,40 @@ func (impl *threeAPIImpl) Name() string {
 
 func (impl *threeAPIImpl) Setup(cl crosslink.Crosslink, apiMpx crosslink.MultiPlexer, errCh chan error) error {
 	impl.cl = cl
+
+	mpx := crosslink.NewMultiPlexer()
+	apiMpx.SetHandler("three", mpx)
+
+	mpx.SetHandler("interact", crosslink.NewFuncHandler(func(req *api.InteractRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		impl.mtx.Lock()
+		handler := impl.interactionHandler
+		impl.mtx.Unlock()
+
+		// events are ignored if the handler is not set
+		if handler != nil && req.Event != nil {
+			handler(req.Event)
+		}
+		writer.ReplySuccess(api.InteractResponse{})
+	}))
+
 	return nil
 }
 
+func (impl *threeAPIImpl) SetInteractionHandler(handler InteractionHandler) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+	impl.interactionHandler = handler
+}
+
 func (impl *threeAPIImpl) CreateObject(name string, spec *api.ObjectSpec) (string, error) {
+	return impl.CreateObjectWithACL(name, spec, nil)
+}
+
+func (impl *threeAPIImpl) CreateObjectWithACL(name string, spec *api.ObjectSpec, acl *api.ObjectACL) (string, error) {
 	res, err := callHelper[api.CreateObjectRequest, api.CreateObjectResponse](impl, "createObject", &api.CreateObjectRequest{
 		Name: name,
 		Spec: spec,
+		ACL:  acl,
 	})
 	if err != nil {
 		return "", fmt.Errorf("error on CreateObject API: %w", err)
@@ -65,6 +95,30 @@ func (impl *threeAPIImpl) UpdateObject(uuid string, spec *api.ObjectSpec) error
 	return nil
 }
 
+func (impl *threeAPIImpl) PatchObject(uuid string, patch []api.PatchOperation, transient bool) error {
+	_, err := callHelper[api.PatchObjectRequest, api.PatchObjectResponse](impl, "patchObject", &api.PatchObjectRequest{
+		UUID:      uuid,
+		Patch:     patch,
+		Transient: transient,
+	})
+	if err != nil {
+		return fmt.Errorf("error on PatchObject API: %w", err)
+	}
+	return nil
+}
+
+func (impl *threeAPIImpl) UpdateTransform(uuid string, transform *api.ObjectTransform, transient bool) error {
+	_, err := callHelper[api.UpdateTransformRequest, api.UpdateTransformResponse](impl, "updateTransform", &api.UpdateTransformRequest{
+		UUID:      uuid,
+		Transform: transform,
+		Transient: transient,
+	})
+	if err != nil {
+		return fmt.Errorf("error on UpdateTransform API: %w", err)
+	}
+	return nil
+}
+
 func (impl *threeAPIImpl) GetObject(uuid string) (*api.Object, error) {
 	res, err := callHelper[api.GetObjectRequest, api.GetObjectResponse](impl, "getObject", &api.GetObjectRequest{
 		UUID: uuid,
@@ -75,6 +129,28 @@ func (impl *threeAPIImpl) GetObject(uuid string) (*api.Object, error) {
 	return res.Object, nil
 }
 
+func (impl *threeAPIImpl) ListObjects(center *api.Vector3, radius float64) ([]*api.Object, error) {
+	res, err := callHelper[api.ListObjectsRequest, api.ListObjectsResponse](impl, "listObjects", &api.ListObjectsRequest{
+		Center: center,
+		Radius: radius,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error on ListObjects API: %w", err)
+	}
+	return res.Objects, nil
+}
+
+func (impl *threeAPIImpl) SetObjectACL(uuid string, acl *api.ObjectACL) error {
+	_, err := callHelper[api.SetObjectACLRequest, api.SetObjectACLResponse](impl, "setObjectACL", &api.SetObjectACLRequest{
+		UUID: uuid,
+		ACL:  acl,
+	})
+	if err != nil {
+		return fmt.Errorf("error on SetObjectACL API: %w", err)
+	}
+	return nil
+}
+
 func (impl *threeAPIImpl) DeleteObject(uuid string) error {
 	_, err := callHelper[api.DeleteObjectRequest, api.DeleteObjectResponse](impl, "deleteObject", &api.DeleteObjectRequest{
 		UUID: uuid,
@@ -21,15 +21,30 @@ import (
 )
 
 const (
-	// ApplicationCrosslinkPath = "application/api/three"
-	NodeCrosslinkPath = "node/api/three"
+	ApplicationCrosslinkPath = "application/api/three"
+	NodeCrosslinkPath        = "node/api/three"
 )
 
 type ThreeAPI interface {
 	oinari.API
 
 	CreateObject(name string, spec *api.ObjectSpec) (string, error)
+	// CreateObjectWithACL creates the object with the access control, CreateObject shares the object in the account.
+	CreateObjectWithACL(name string, spec *api.ObjectSpec, acl *api.ObjectACL) (string, error)
 	UpdateObject(uuid string, spec *api.ObjectSpec) error
+	// PatchObject applies field-path operations to the spec of the object.
+	// The object in the KVS is not changed and only nearby nodes are notified if transient is true.
+	PatchObject(uuid string, patch []api.PatchOperation, transient bool) error
+	// UpdateTransform changes only the position of the object and its parts.
+	UpdateTransform(uuid string, transform *api.ObjectTransform, transient bool) error
 	GetObject(uuid string) (*api.Object, error)
+	// ListObjects returns objects within radius meters from the center.
+	ListObjects(center *api.Vector3, radius float64) ([]*api.Object, error)
+	SetObjectACL(uuid string, acl *api.ObjectACL) error
 	DeleteObject(uuid string) error
+
+	// SetInteractionHandler sets the handler called when users click, hover or select the objects owned by the pod.
+	SetInteractionHandler(handler InteractionHandler)
 }
+
+type InteractionHandler func(event *api.InteractionEvent)
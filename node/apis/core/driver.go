@@ -102,3 +102,13 @@ func (driver *coreAPIDriverImpl) Teardown(isFinalize bool) ([]byte, error) {
 	}
 	return res.Record, err
 }
+
+func (driver *coreAPIDriverImpl) FireTimer(timer *core.Timer, scheduledAt string) error {
+	_, err := callHelper[core.FireTimerRequest, core.FireTimerResponse](driver, "fireTimer", &core.FireTimerRequest{
+		ID:          timer.ID,
+		Name:        timer.Name,
+		Payload:     timer.Payload,
+		ScheduledAt: scheduledAt,
+	})
+	return err
+}
@@ -17,15 +17,19 @@ package handler
 
 import (
 	"fmt"
+	"log"
+	"strings"
 
 	"github.com/llamerada-jp/oinari/api/core"
 	"github.com/llamerada-jp/oinari/lib/crosslink"
 	nodeAPI "github.com/llamerada-jp/oinari/node/apis/core"
+	"github.com/llamerada-jp/oinari/node/controller"
 	"github.com/llamerada-jp/oinari/node/cri"
 	"github.com/llamerada-jp/oinari/node/kvs"
+	"github.com/llamerada-jp/oinari/node/misc"
 )
 
-func InitHandler(apiMpx crosslink.MultiPlexer, manager *nodeAPI.Manager, c cri.CRI, podKVS kvs.PodKvs, recordKVS kvs.RecordKvs) {
+func InitHandler(apiMpx crosslink.MultiPlexer, manager *nodeAPI.Manager, c cri.CRI, podKVS kvs.PodKvs, recordKVS kvs.RecordKvs, logCtrl controller.LogController, timerCtrl controller.TimerController) {
 	mpx := crosslink.NewMultiPlexer()
 	apiMpx.SetHandler("core", mpx)
 
@@ -92,18 +96,18 @@ func InitHandler(apiMpx crosslink.MultiPlexer, manager *nodeAPI.Manager, c cri.C
 		}
 
 		go func() {
-			if record == nil {
-				err = driver.Setup(isInitialize, nil)
-			} else {
-				entry, ok := record.Data.Entries[containerName]
-				if !ok {
-					err = driver.Setup(isInitialize, nil)
-				} else {
-					err = driver.Setup(isInitialize, entry.Record)
-				}
+			var entry core.RecordEntry
+			if record != nil {
+				entry = record.Data.Entries[containerName]
 			}
+			err = driver.Setup(isInitialize, entry.Record)
 			if err != nil {
 				// TODO: try to restart container
+				return
+			}
+			// restore timers after setup to let the application set handlers for them
+			if err := timerCtrl.Restore(containerID, entry.Timers); err != nil {
+				log.Printf("failed to restore timers of the container: %s", err.Error())
 			}
 		}()
 
@@ -111,22 +115,82 @@ func InitHandler(apiMpx crosslink.MultiPlexer, manager *nodeAPI.Manager, c cri.C
 	}))
 
 	mpx.SetHandler("output", crosslink.NewFuncHandler(func(request *core.OutputRequest, tags map[string]string, writer crosslink.ResponseWriter) {
-		_, _, err := getDriver(tags, manager)
+		podUUID, containerName, err := getContainerName(tags, manager, c)
 		if err != nil {
-			writer.ReplyError(fmt.Sprintf("`getDriver` failed on `output` handler: %s", err.Error()))
+			writer.ReplyError(fmt.Sprintf("`getContainerName` failed on `output` handler: %s", err.Error()))
 			return
 		}
 
-		// TODO: broadcast message to neighbors
-		_, err = fmt.Println(string(request.Payload))
-		if err != nil {
-			writer.ReplyError(fmt.Sprintf("`fmt.Println failed on `output` handler: %s", err.Error()))
+		// the payload may contain multiple lines batched by the writer, store them as separated entries
+		timestamp := misc.GetTimestamp()
+		entries := make([]core.LogEntry, 0)
+		for _, line := range strings.Split(strings.TrimSuffix(string(request.Payload), "\n"), "\n") {
+			entries = append(entries, core.LogEntry{
+				Timestamp: timestamp,
+				Level:     core.LogLevelInfo,
+				Message:   line,
+			})
+		}
+
+		if err := logCtrl.Append(podUUID, containerName, entries); err != nil {
+			writer.ReplyError(fmt.Sprintf("`logCtrl.Append` failed on `output` handler: %s", err.Error()))
 			return
 		}
 		writer.ReplySuccess(&core.OutputResponse{
 			Length: len(request.Payload),
 		})
 	}))
+
+	mpx.SetHandler("setTimer", crosslink.NewFuncHandler(func(request *core.SetTimerRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		containerID, _, err := getDriver(tags, manager)
+		if err != nil {
+			writer.ReplyError(fmt.Sprintf("`getDriver` failed on `setTimer` handler: %s", err.Error()))
+			return
+		}
+
+		if request.Timer == nil {
+			writer.ReplyError("timer should be specified on `setTimer` handler")
+			return
+		}
+
+		timer, err := timerCtrl.Set(containerID, request.Timer)
+		if err != nil {
+			writer.ReplyError(fmt.Sprintf("`timerCtrl.Set` failed on `setTimer` handler: %s", err.Error()))
+			return
+		}
+		writer.ReplySuccess(&core.SetTimerResponse{
+			ID:     timer.ID,
+			NextAt: timer.NextAt,
+		})
+	}))
+
+	mpx.SetHandler("cancelTimer", crosslink.NewFuncHandler(func(request *core.CancelTimerRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		containerID, _, err := getDriver(tags, manager)
+		if err != nil {
+			writer.ReplyError(fmt.Sprintf("`getDriver` failed on `cancelTimer` handler: %s", err.Error()))
+			return
+		}
+
+		if err := timerCtrl.Cancel(containerID, request.ID); err != nil {
+			writer.ReplyError(fmt.Sprintf("`timerCtrl.Cancel` failed on `cancelTimer` handler: %s", err.Error()))
+			return
+		}
+		writer.ReplySuccess(&core.CancelTimerResponse{})
+	}))
+
+	mpx.SetHandler("log", crosslink.NewFuncHandler(func(request *core.LogRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		podUUID, containerName, err := getContainerName(tags, manager, c)
+		if err != nil {
+			writer.ReplyError(fmt.Sprintf("`getContainerName` failed on `log` handler: %s", err.Error()))
+			return
+		}
+
+		if err := logCtrl.Append(podUUID, containerName, request.Entries); err != nil {
+			writer.ReplyError(fmt.Sprintf("`logCtrl.Append` failed on `log` handler: %s", err.Error()))
+			return
+		}
+		writer.ReplySuccess(&core.LogResponse{})
+	}))
 }
 
 func getDriver(tags map[string]string, manager *nodeAPI.Manager) (string, nodeAPI.CoreDriver, error) {
@@ -142,3 +206,30 @@ func getDriver(tags map[string]string, manager *nodeAPI.Manager) (string, nodeAP
 
 	return containerID, driver, nil
 }
+
+// return pod uuid and container name of the caller
+func getContainerName(tags map[string]string, manager *nodeAPI.Manager, c cri.CRI) (string, string, error) {
+	containerID, _, err := getDriver(tags, manager)
+	if err != nil {
+		return "", "", err
+	}
+
+	podUUID, ok := tags[controller.ContainerLabelPodUUID]
+	if !ok {
+		return "", "", fmt.Errorf("%s should be set when accessing core handler", controller.ContainerLabelPodUUID)
+	}
+
+	containerList, err := c.ListContainers(&cri.ListContainersRequest{
+		Filter: &cri.ContainerFilter{
+			ID: containerID,
+		},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("`ListContainers` failed: %w", err)
+	}
+	if len(containerList.Containers) == 0 {
+		return "", "", fmt.Errorf("container not found: %s", containerID)
+	}
+
+	return podUUID, containerList.Containers[0].Metadata.Name, nil
+}
@@ -15,6 +15,8 @@
  */
 package core
 
+import "github.com/llamerada-jp/oinari/api/core"
+
 type nullAPIDriverImpl struct {
 }
 
@@ -37,3 +39,7 @@ func (driver *nullAPIDriverImpl) Marshal() ([]byte, error) {
 func (driver *nullAPIDriverImpl) Teardown(isFinalize bool) ([]byte, error) {
 	return nil, nil
 }
+
+func (driver *nullAPIDriverImpl) FireTimer(timer *core.Timer, scheduledAt string) error {
+	return nil
+}
@@ -15,9 +15,12 @@
  */
 package core
 
+import "github.com/llamerada-jp/oinari/api/core"
+
 type CoreDriver interface {
 	DriverName() string
 	Setup(isInitialize bool, record []byte) error
 	Marshal() ([]byte, error)
 	Teardown(isFinalize bool) ([]byte, error)
+	FireTimer(timer *core.Timer, scheduledAt string) error
 }
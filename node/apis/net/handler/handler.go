@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package handler
+
+import (
+	"fmt"
+
+	netAPI "github.com/llamerada-jp/oinari/api/net"
+	"github.com/llamerada-jp/oinari/lib/crosslink"
+	coreCtrl "github.com/llamerada-jp/oinari/node/controller"
+	netCtrl "github.com/llamerada-jp/oinari/node/controller/net"
+)
+
+func InitHandler(apiMpx crosslink.MultiPlexer, fetchCtrl netCtrl.FetchController) {
+	mpx := crosslink.NewMultiPlexer()
+	apiMpx.SetHandler("net", mpx)
+
+	// Fetch
+	mpx.SetHandler("fetch", crosslink.NewFuncHandler(func(request *netAPI.FetchRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		podUUID := tags[coreCtrl.ContainerLabelPodUUID]
+		res, err := fetchCtrl.Fetch(podUUID, request)
+		if err != nil {
+			writer.ReplyError(fmt.Sprintf("failed to fetch: %s", err.Error()))
+			return
+		}
+		writer.ReplySuccess(res)
+	}))
+
+	// Read
+	mpx.SetHandler("read", crosslink.NewFuncHandler(func(request *netAPI.ReadRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		podUUID := tags[coreCtrl.ContainerLabelPodUUID]
+		res, err := fetchCtrl.Read(podUUID, request.StreamID, request.Size)
+		if err != nil {
+			writer.ReplyError(fmt.Sprintf("failed to read response: %s", err.Error()))
+			return
+		}
+		writer.ReplySuccess(res)
+	}))
+
+	// Close
+	mpx.SetHandler("close", crosslink.NewFuncHandler(func(request *netAPI.CloseRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		podUUID := tags[coreCtrl.ContainerLabelPodUUID]
+		if err := fetchCtrl.Close(podUUID, request.StreamID); err != nil {
+			writer.ReplyError(fmt.Sprintf("failed to close response: %s", err.Error()))
+			return
+		}
+		writer.ReplySuccess(&netAPI.CloseResponse{})
+	}))
+}
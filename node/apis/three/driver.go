@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package three
+
+import (
+	"encoding/json"
+	"strings"
+
+	api "github.com/llamerada-jp/oinari/api/three"
+	"github.com/llamerada-jp/oinari/lib/crosslink"
+	threeLib "github.com/llamerada-jp/oinari/lib/three"
+)
+
+// ThreeDriver calls the three API of the applications
+type ThreeDriver interface {
+	Interact(containerID string, event *api.InteractionEvent) error
+}
+
+type threeDriverImpl struct {
+	cl crosslink.Crosslink
+}
+
+func NewThreeDriver(cl crosslink.Crosslink) ThreeDriver {
+	return &threeDriverImpl{
+		cl: cl,
+	}
+}
+
+func callHelper[REQ any, RES any](driver *threeDriverImpl, containerID string, path string, request *REQ) (*RES, error) {
+	ch := make(chan *RES)
+	var funcError error
+
+	driver.cl.Call(strings.Join([]string{threeLib.ApplicationCrosslinkPath, path}, "/"), request,
+		map[string]string{
+			"containerID": containerID,
+		},
+		func(response []byte, err error) {
+			defer close(ch)
+
+			if err != nil {
+				funcError = err
+				return
+			}
+
+			var res RES
+			err = json.Unmarshal(response, &res)
+			if err != nil {
+				funcError = err
+				return
+			}
+
+			ch <- &res
+		})
+
+	res, ok := <-ch
+	if !ok {
+		return nil, funcError
+	}
+	return res, nil
+}
+
+func (driver *threeDriverImpl) Interact(containerID string, event *api.InteractionEvent) error {
+	_, err := callHelper[api.InteractRequest, api.InteractResponse](driver, containerID, "interact", &api.InteractRequest{
+		Event: event,
+	})
+	return err
+}
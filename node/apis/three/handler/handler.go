@@ -42,7 +42,7 @@ func InitHandler(apiMpx crosslink.MultiPlexer, nodeCtrl coreCtrl.NodeController,
 			}
 		}
 
-		uuid, err := objCtrl.Create(request.Name, podUUID, request.Spec)
+		uuid, err := objCtrl.Create(request.Name, podUUID, request.Spec, request.ACL)
 		if err != nil {
 			writer.ReplyError(fmt.Sprintf("failed to create object: %s", err.Error()))
 			return
@@ -63,6 +63,28 @@ func InitHandler(apiMpx crosslink.MultiPlexer, nodeCtrl coreCtrl.NodeController,
 		writer.ReplySuccess(&three.UpdateObjectResponse{})
 	}))
 
+	// PatchObject
+	mpx.SetHandler("patchObject", crosslink.NewFuncHandler(func(request *three.PatchObjectRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		podUUID := tags[coreCtrl.ContainerLabelPodUUID]
+		err := objCtrl.Patch(request.UUID, podUUID, request.Patch, request.Transient)
+		if err != nil {
+			writer.ReplyError(fmt.Sprintf("failed to patch object: %s", err.Error()))
+			return
+		}
+		writer.ReplySuccess(&three.PatchObjectResponse{})
+	}))
+
+	// UpdateTransform
+	mpx.SetHandler("updateTransform", crosslink.NewFuncHandler(func(request *three.UpdateTransformRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		podUUID := tags[coreCtrl.ContainerLabelPodUUID]
+		err := objCtrl.UpdateTransform(request.UUID, podUUID, request.Transform, request.Transient)
+		if err != nil {
+			writer.ReplyError(fmt.Sprintf("failed to update transform: %s", err.Error()))
+			return
+		}
+		writer.ReplySuccess(&three.UpdateTransformResponse{})
+	}))
+
 	// GetObject
 	mpx.SetHandler("getObject", crosslink.NewFuncHandler(func(request *three.GetObjectRequest, tags map[string]string, writer crosslink.ResponseWriter) {
 		podUUID := tags[coreCtrl.ContainerLabelPodUUID]
@@ -76,6 +98,30 @@ func InitHandler(apiMpx crosslink.MultiPlexer, nodeCtrl coreCtrl.NodeController,
 		})
 	}))
 
+	// SetObjectACL
+	mpx.SetHandler("setObjectACL", crosslink.NewFuncHandler(func(request *three.SetObjectACLRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		podUUID := tags[coreCtrl.ContainerLabelPodUUID]
+		err := objCtrl.SetACL(request.UUID, podUUID, request.ACL)
+		if err != nil {
+			writer.ReplyError(fmt.Sprintf("failed to set acl: %s", err.Error()))
+			return
+		}
+		writer.ReplySuccess(&three.SetObjectACLResponse{})
+	}))
+
+	// ListObjects
+	mpx.SetHandler("listObjects", crosslink.NewFuncHandler(func(request *three.ListObjectsRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		podUUID := tags[coreCtrl.ContainerLabelPodUUID]
+		objects, err := objCtrl.List(request.Center, request.Radius, podUUID)
+		if err != nil {
+			writer.ReplyError(fmt.Sprintf("failed to list objects: %s", err.Error()))
+			return
+		}
+		writer.ReplySuccess(&three.ListObjectsResponse{
+			Objects: objects,
+		})
+	}))
+
 	// DeleteObject
 	mpx.SetHandler("deleteObject", crosslink.NewFuncHandler(func(request *three.DeleteObjectRequest, tags map[string]string, writer crosslink.ResponseWriter) {
 		podUUID := tags[coreCtrl.ContainerLabelPodUUID]
@@ -17,22 +17,31 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/llamerada-jp/oinari/api/core"
 	"github.com/llamerada-jp/oinari/node/kvs"
+	"github.com/llamerada-jp/oinari/node/misc"
 )
 
 const ACCOUNT_STATE_RESOURCE_LIFETIME = 180 * time.Second
 const ACCOUNT_LIFETIME = 600 * time.Second
 
+// keys not published by any node of the account in the lifetime are removed from the account record
+// TODO: resources signed with the removed keys should be signed again by the owner
+const ACCOUNT_KEY_LIFETIME = 7 * 24 * time.Hour
+
 type AccountController interface {
 	DealLocalResource(raw []byte) (bool, error)
 
 	GetAccountName() string
 	GetPodState() (map[string]core.AccountPodState, error)
 	GetNodeState() (map[string]core.AccountNodeState, error)
+	// Watch returns the channel of the events for the account of this node
+	Watch() (<-chan *kvs.WatchEvent, func(), error)
 	UpdatePodAndNodeState(account string, pods map[string]core.AccountPodState, nodeID string, nodeState *core.AccountNodeState) error
 }
 
@@ -41,6 +50,8 @@ type accountControllerImpl struct {
 	localNid    string
 	accountKvs  kvs.AccountKvs
 	logs        map[string]*logEntry
+	// the key of the node is published on the account record, nothing is published if nil
+	signer kvs.ResourceSigner
 }
 
 type logEntry struct {
@@ -55,12 +66,13 @@ type timestampLog struct {
 	timestampStr string
 }
 
-func NewAccountController(account, localNid string, accountKvs kvs.AccountKvs) AccountController {
+func NewAccountController(account, localNid string, accountKvs kvs.AccountKvs, signer kvs.ResourceSigner) AccountController {
 	return &accountControllerImpl{
 		accountName: account,
 		localNid:    localNid,
 		accountKvs:  accountKvs,
 		logs:        make(map[string]*logEntry),
+		signer:      signer,
 	}
 }
 
@@ -150,6 +162,10 @@ func (impl *accountControllerImpl) GetAccountName() string {
 	return impl.accountName
 }
 
+func (impl *accountControllerImpl) Watch() (<-chan *kvs.WatchEvent, func(), error) {
+	return impl.accountKvs.Watch(impl.accountName)
+}
+
 func (impl *accountControllerImpl) GetPodState() (map[string]core.AccountPodState, error) {
 	acc, err := impl.accountKvs.Get(impl.accountName)
 	if err != nil {
@@ -183,6 +199,9 @@ func (impl *accountControllerImpl) UpdatePodAndNodeState(account string, pods ma
 	if err != nil {
 		return err
 	}
+	if record == nil {
+		return nil
+	}
 
 	for podUUID, podState := range pods {
 		record.State.Pods[podUUID] = podState
@@ -192,6 +211,10 @@ func (impl *accountControllerImpl) UpdatePodAndNodeState(account string, pods ma
 		record.State.Nodes[nodeID] = *nodeState
 	}
 
+	if account == impl.accountName {
+		impl.publishKey(record)
+	}
+
 	if err := impl.accountKvs.Set(record); err != nil {
 		return fmt.Errorf("failed to update account record (%s): %w", account, err)
 	}
@@ -199,6 +222,34 @@ func (impl *accountControllerImpl) UpdatePodAndNodeState(account string, pods ma
 	return nil
 }
 
+func (impl *accountControllerImpl) publishKey(record *core.Account) {
+	if impl.signer == nil {
+		return
+	}
+
+	now := time.Now()
+	publicKey := impl.signer.GetPublicKey()
+	keyID := core.GetKeyID(publicKey)
+	for id, key := range record.State.Keys {
+		if id == keyID {
+			continue
+		}
+		timestamp, err := time.Parse(time.RFC3339, key.Timestamp)
+		if err != nil || now.After(timestamp.Add(ACCOUNT_KEY_LIFETIME)) {
+			delete(record.State.Keys, id)
+		}
+	}
+
+	if record.State.Keys == nil {
+		record.State.Keys = make(map[string]core.AccountKey)
+	}
+	record.State.Keys[keyID] = core.AccountKey{
+		PublicKey:   publicKey,
+		Timestamp:   misc.TimeToTimestamp(now),
+		Certificate: impl.signer.GetCertificate(),
+	}
+}
+
 func (impl *accountControllerImpl) cleanLogs(exclude string) {
 	now := time.Now()
 	for key, log := range impl.logs {
@@ -211,13 +262,23 @@ func (impl *accountControllerImpl) cleanLogs(exclude string) {
 	}
 }
 
+// getOrCreateAccount returns nil without error if the record of other account does not exist and it can not be signed
+// by the local node.
 func (impl *accountControllerImpl) getOrCreateAccount(accountName string) (*core.Account, error) {
 	account, err := impl.accountKvs.Get(accountName)
 	if err != nil {
-		return nil, err
+		// the owner writes the record again if it is broken by other nodes, the states are written again by keepalive
+		if accountName != impl.accountName || !errors.Is(err, kvs.ErrInvalid) {
+			return nil, err
+		}
+		log.Printf("the account record is broken and written again: %s", err.Error())
+		account = nil
 	}
 
 	if account == nil {
+		if accountName != impl.accountName && impl.signer != nil {
+			return nil, nil
+		}
 		account = &core.Account{
 			Meta: &core.ObjectMeta{
 				Type:        core.ResourceTypeAccount,
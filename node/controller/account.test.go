@@ -16,6 +16,7 @@
 package controller
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"time"
 
@@ -38,7 +39,7 @@ type accountControllerTest struct {
 
 func NewAccountControllerTest() suite.TestingSuite {
 	colonioMock := mock.NewColonioMock()
-	accountKvs := kvs.NewAccountKvs(colonioMock)
+	accountKvs := kvs.NewAccountKvs(colonioMock, nil, nil)
 
 	return &accountControllerTest{
 		col:        colonioMock,
@@ -473,3 +474,79 @@ func (test *accountControllerTest) TestUpdatePodAndNodeState() {
 	test.Equal(141.767052, data.State.Nodes[nodeID2].Position.X)
 	test.Equal(12.0, data.State.Nodes[nodeID2].Position.Z)
 }
+
+func (test *accountControllerTest) TestPublishKey() {
+	test.col.DeleteKVSAll()
+	authorityPublicKey, authorityKey, err := ed25519.GenerateKey(nil)
+	test.NoError(err)
+	makeKey := func(accountKey string, account string) (ed25519.PrivateKey, []byte) {
+		privateKey := core.DeriveNodeKey([]byte(accountKey))
+		return privateKey, core.CertifyKey(authorityKey, account, privateKey.Public().(ed25519.PublicKey))
+	}
+	privateKey, certificate := makeKey("account key", ACCOUNT)
+	signer := kvs.NewResourceSigner(ACCOUNT, privateKey, certificate, authorityPublicKey)
+	accountKvs := kvs.NewAccountKvs(test.col, nil, signer)
+	impl := NewAccountController(ACCOUNT, NODE_ID, accountKvs, signer)
+	keyID := core.GetKeyID(signer.GetPublicKey())
+
+	/// normal pattern: the key is published on the record of the local account
+	test.NoError(impl.UpdatePodAndNodeState(ACCOUNT, nil, NODE_ID, &core.AccountNodeState{
+		Name:      "node name",
+		Timestamp: misc.GetTimestamp(),
+		NodeType:  core.NodeTypeServer,
+	}))
+	data, err := accountKvs.Get(ACCOUNT)
+	test.NoError(err)
+	test.Len(data.State.Keys, 1)
+	test.Equal(signer.GetPublicKey(), ed25519.PublicKey(data.State.Keys[keyID].PublicKey))
+	test.Equal(certificate, data.State.Keys[keyID].Certificate)
+	test.NotNil(data.Meta.Signature)
+
+	/// normal pattern: the keys not published in the lifetime are removed
+	oldKey, oldCertificate := makeKey("old key", ACCOUNT)
+	oldPublicKey := oldKey.Public().(ed25519.PublicKey)
+	liveKey, liveCertificate := makeKey("live key", ACCOUNT)
+	livePublicKey := liveKey.Public().(ed25519.PublicKey)
+	data.State.Keys[core.GetKeyID(oldPublicKey)] = core.AccountKey{
+		PublicKey:   oldPublicKey,
+		Timestamp:   misc.TimeToTimestamp(time.Now().Add(-ACCOUNT_KEY_LIFETIME - time.Minute)),
+		Certificate: oldCertificate,
+	}
+	data.State.Keys[core.GetKeyID(livePublicKey)] = core.AccountKey{
+		PublicKey:   livePublicKey,
+		Timestamp:   misc.GetTimestamp(),
+		Certificate: liveCertificate,
+	}
+	test.NoError(accountKvs.Set(data))
+	test.NoError(impl.UpdatePodAndNodeState(ACCOUNT, nil, "", nil))
+	data, err = accountKvs.Get(ACCOUNT)
+	test.NoError(err)
+	test.Len(data.State.Keys, 2)
+	test.Contains(data.State.Keys, keyID)
+	test.Contains(data.State.Keys, core.GetKeyID(livePublicKey))
+
+	/// normal pattern: the record broken by other nodes is written again by the owner
+	otherKey, otherCertificate := makeKey("other key", "dog")
+	test.NoError(core.Sign(data, otherKey, otherCertificate))
+	test.NoError(kvs.NewAccountKvs(test.col, nil, nil).Set(data))
+	test.NoError(impl.UpdatePodAndNodeState(ACCOUNT, nil, NODE_ID, &core.AccountNodeState{
+		Name:      "node name",
+		Timestamp: misc.GetTimestamp(),
+		NodeType:  core.NodeTypeServer,
+	}))
+	data, err = accountKvs.Get(ACCOUNT)
+	test.NoError(err)
+	test.NoError(signer.Verify(data))
+	test.Contains(data.State.Nodes, NODE_ID)
+
+	/// normal pattern: the record of other account is not created because it can not be signed
+	test.NoError(impl.UpdatePodAndNodeState("dog", map[string]core.AccountPodState{
+		core.GeneratePodUuid(): {
+			RunningNode: NODE_ID,
+			Timestamp:   misc.GetTimestamp(),
+		},
+	}, "", nil))
+	data, err = accountKvs.Get("dog")
+	test.NoError(err)
+	test.Nil(data)
+}
@@ -17,6 +17,7 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -49,6 +50,8 @@ type reconcileState struct {
 	// will delete when reconcile finished
 	willDelete    bool
 	containerInfo ContainerInfo
+	// reason the record could not be restored, written to the pod status
+	restoreFailure string
 }
 
 type containerControllerImpl struct {
@@ -58,12 +61,13 @@ type containerControllerImpl struct {
 	podKvs               kvs.PodKvs
 	recordKvs            kvs.RecordKvs
 	apiCoreDriverManager *coreAPI.Manager
+	timerCtrl            TimerController
 	// key: Pod UUID
 	reconcileStates map[string]*reconcileState
 	mtx             sync.Mutex
 }
 
-func NewContainerController(localNid string, cri cri.CRI, appFilter ApplicationFilter, podKvs kvs.PodKvs, recordKVS kvs.RecordKvs, apiCoreDriverManager *coreAPI.Manager) ContainerController {
+func NewContainerController(localNid string, cri cri.CRI, appFilter ApplicationFilter, podKvs kvs.PodKvs, recordKVS kvs.RecordKvs, apiCoreDriverManager *coreAPI.Manager, timerCtrl TimerController) ContainerController {
 	return &containerControllerImpl{
 		localNid:             localNid,
 		cri:                  cri,
@@ -71,6 +75,7 @@ func NewContainerController(localNid string, cri cri.CRI, appFilter ApplicationF
 		podKvs:               podKvs,
 		recordKvs:            recordKVS,
 		apiCoreDriverManager: apiCoreDriverManager,
+		timerCtrl:            timerCtrl,
 		reconcileStates:      make(map[string]*reconcileState),
 	}
 }
@@ -142,14 +147,17 @@ func (impl *containerControllerImpl) Reconcile(ctx context.Context, podUUID stri
 	}
 
 	// terminate containers if deletion timestamp has set
-	if len(pod.Meta.DeletionTimestamp) != 0 || (len(pod.Spec.TargetNode) != 0 && pod.Spec.TargetNode != pod.Status.RunningNode) {
+	if len(pod.Meta.DeletionTimestamp) != 0 || (len(pod.Spec.TargetNode) != 0 && pod.Spec.TargetNode != pod.Status.RunningNode) || pod.Spec.RestoreGeneration != 0 {
 		if len(state.containerInfo.SandboxID) == 0 {
+			impl.restoreRecord(state, pod)
 			return impl.updatePodInfo(state, pod)
 		}
 
 		if err := impl.letTerminate(state, pod); err != nil {
 			return err
 		}
+		// the containers will be restarted after the pod info is updated, restore the record before it
+		impl.restoreRecord(state, pod)
 
 		if err = impl.updatePodInfo(state, pod); err != nil {
 			return err
@@ -372,6 +380,8 @@ func (impl *containerControllerImpl) letTerminate(state *reconcileState, pod *co
 
 	for _, container := range containers.Containers {
 		if container.State == cri.ContainerExited {
+			// timers of the exited container will never fire
+			impl.timerCtrl.Export(container.ID)
 			continue
 		}
 
@@ -379,10 +389,13 @@ func (impl *containerControllerImpl) letTerminate(state *reconcileState, pod *co
 		if err != nil {
 			return fmt.Errorf("failed to teardown container: %w", err)
 		}
-		if raw != nil {
+		// pending timers are stored with the record to restore them on the next node
+		timers := impl.timerCtrl.Export(container.ID)
+		if !isFinalize && (raw != nil || len(timers) != 0) {
 			record.Data.Entries[container.Metadata.Name] = core.RecordEntry{
 				Record:    raw,
 				Timestamp: misc.GetTimestamp(),
+				Timers:    timers,
 			}
 		}
 
@@ -414,7 +427,37 @@ func (impl *containerControllerImpl) letTerminate(state *reconcileState, pod *co
 	return nil
 }
 
+// restoreRecord writes the generation requested by the restart as the latest record after the teardown wrote the record.
+// The failure is written to the pod status by updatePodInfo and the containers are not restarted from the latest record,
+// it is tried again on the next reconcile.
+func (impl *containerControllerImpl) restoreRecord(state *reconcileState, pod *core.Pod) {
+	state.restoreFailure = ""
+	if pod.Spec.RestoreGeneration == 0 || len(pod.Meta.DeletionTimestamp) != 0 {
+		return
+	}
+	if err := impl.recordKvs.Restore(pod.Meta.Uuid, pod.Spec.RestoreGeneration); err != nil {
+		log.Printf("failed to restore the record: %s", err.Error())
+		state.restoreFailure = fmt.Sprintf("failed to restore the record of generation %d: %s", pod.Spec.RestoreGeneration, err.Error())
+	}
+}
+
+// updatePodInfo writes the status of the containers to the pod, it applies the status to the latest pod again
+// if the pod is updated by another writer while reconciling.
 func (impl *containerControllerImpl) updatePodInfo(state *reconcileState, pod *core.Pod) error {
+	return kvs.RetryOnConflict(func() error {
+		err := impl.applyPodInfo(state, pod)
+		if errors.Is(err, kvs.ErrConflict) {
+			latest, getErr := impl.podKvs.Get(pod.Meta.Uuid)
+			if getErr != nil {
+				return fmt.Errorf("failed to get the latest pod info: %w", getErr)
+			}
+			*pod = *latest
+		}
+		return err
+	})
+}
+
+func (impl *containerControllerImpl) applyPodInfo(state *reconcileState, pod *core.Pod) error {
 	// make containers as map[container name]ContainerStatus
 	containerStatuses := make(map[string]*cri.ContainerStatus)
 	if len(state.containerInfo.SandboxID) != 0 {
@@ -490,6 +533,10 @@ func (impl *containerControllerImpl) updatePodInfo(state *reconcileState, pod *c
 		delete(containerStatuses, spec.Name)
 	}
 
+	if pod.Spec.RestoreGeneration != 0 {
+		pod.Status.RestoreFailure = state.restoreFailure
+	}
+
 	if err := impl.podKvs.Update(pod); err != nil {
 		return fmt.Errorf("failed to update pod info: %w", err)
 	}
@@ -0,0 +1,262 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/node/messaging/driver"
+)
+
+const (
+	LOG_BUFFER_SIZE       = 256
+	LOG_BUFFER_LIFETIME   = 600 * time.Second
+	LOG_CLEANUP_INTERVAL  = 60 * time.Second
+	LOG_FETCH_LIMIT       = 256
+	LOG_MESSAGE_MAX_BYTES = 4096
+)
+
+type LogController interface {
+	// append log entries of the container running on the local node
+	Append(podUUID, containerName string, entries []core.LogEntry) error
+	// get log entries of the pod stored in the local node, account is used to check the owner of the pod,
+	// it should be proved by the caller if the signer is configured
+	GetLocalLog(podUUID, account string, after uint64, limit int) (*core.PodLog, error)
+	// get log entries of the pod from the node the pod is running
+	GetLog(podUUID string, after uint64, limit int) (*core.PodLog, error)
+}
+
+// ring buffer of log entries for a container
+type logBuffer struct {
+	owner       string
+	entries     []core.LogEntry
+	next        int
+	filled      bool
+	lastUpdated time.Time
+}
+
+type logControllerImpl struct {
+	mtx           sync.Mutex
+	localNid      string
+	accountCtrl   AccountController
+	containerCtrl ContainerController
+	podCtrl       PodController
+	messaging     driver.MessagingDriver
+	sequence      uint64
+	lastCleanup   time.Time
+	// key: pod uuid, container name
+	buffers map[string]map[string]*logBuffer
+}
+
+func NewLogController(localNid string, accountCtrl AccountController, containerCtrl ContainerController, podCtrl PodController, messaging driver.MessagingDriver) LogController {
+	return &logControllerImpl{
+		localNid:      localNid,
+		accountCtrl:   accountCtrl,
+		containerCtrl: containerCtrl,
+		podCtrl:       podCtrl,
+		messaging:     messaging,
+		lastCleanup:   time.Now(),
+		buffers:       make(map[string]map[string]*logBuffer),
+	}
+}
+
+func (impl *logControllerImpl) Append(podUUID, containerName string, entries []core.LogEntry) error {
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			return fmt.Errorf("invalid log entry: %w", err)
+		}
+	}
+
+	owner := ""
+	for _, info := range impl.containerCtrl.GetContainerInfos() {
+		if info.PodUUID == podUUID {
+			owner = info.Owner
+			break
+		}
+	}
+	if len(owner) == 0 {
+		return fmt.Errorf("the pod is not running on this node: %s", podUUID)
+	}
+
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	impl.cleanup()
+
+	containers, ok := impl.buffers[podUUID]
+	if !ok {
+		containers = make(map[string]*logBuffer)
+		impl.buffers[podUUID] = containers
+	}
+	buffer, ok := containers[containerName]
+	if !ok {
+		buffer = &logBuffer{
+			entries: make([]core.LogEntry, LOG_BUFFER_SIZE),
+		}
+		containers[containerName] = buffer
+	}
+	buffer.owner = owner
+	buffer.lastUpdated = time.Now()
+
+	for _, entry := range entries {
+		impl.sequence++
+		entry.Sequence = impl.sequence
+		entry.Container = containerName
+		entry.Message = truncateMessage(entry.Message, LOG_MESSAGE_MAX_BYTES)
+		buffer.push(entry)
+	}
+
+	return nil
+}
+
+func (impl *logControllerImpl) GetLocalLog(podUUID, account string, after uint64, limit int) (*core.PodLog, error) {
+	if limit <= 0 || LOG_FETCH_LIMIT < limit {
+		limit = LOG_FETCH_LIMIT
+	}
+
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	impl.cleanup()
+
+	podLog := &core.PodLog{
+		Entries: make([]core.LogEntry, 0),
+		Last:    after,
+	}
+
+	containers, ok := impl.buffers[podUUID]
+	if !ok {
+		return podLog, nil
+	}
+
+	for _, buffer := range containers {
+		if buffer.owner != account {
+			return nil, fmt.Errorf("the log of the pod is not allowed to read from the account")
+		}
+		podLog.Entries = append(podLog.Entries, buffer.after(after)...)
+	}
+
+	sort.Slice(podLog.Entries, func(i, j int) bool {
+		return podLog.Entries[i].Sequence < podLog.Entries[j].Sequence
+	})
+	if len(podLog.Entries) > limit {
+		podLog.Entries = podLog.Entries[:limit]
+	}
+	if len(podLog.Entries) != 0 {
+		podLog.Last = podLog.Entries[len(podLog.Entries)-1].Sequence
+	}
+
+	return podLog, nil
+}
+
+func (impl *logControllerImpl) GetLog(podUUID string, after uint64, limit int) (*core.PodLog, error) {
+	pod, err := impl.podCtrl.GetPodData(podUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod data: %w", err)
+	}
+
+	account := impl.accountCtrl.GetAccountName()
+	if pod.Meta.Owner != account {
+		return nil, fmt.Errorf("the log of the pod owned by other account is not allowed to read")
+	}
+
+	runningNode := pod.Status.RunningNode
+	if len(runningNode) == 0 {
+		return &core.PodLog{
+			Entries: make([]core.LogEntry, 0),
+			Last:    after,
+		}, nil
+	}
+
+	if runningNode == impl.localNid {
+		return impl.GetLocalLog(podUUID, account, after, limit)
+	}
+
+	podLog, err := impl.messaging.FetchLog(runningNode, podUUID, account, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch log from %s: %w", runningNode, err)
+	}
+	return podLog, nil
+}
+
+// remove log buffers of pods that are not running on this node and not updated for a while
+func (impl *logControllerImpl) cleanup() {
+	now := time.Now()
+	if now.Before(impl.lastCleanup.Add(LOG_CLEANUP_INTERVAL)) {
+		return
+	}
+	impl.lastCleanup = now
+
+	running := make(map[string]bool)
+	for _, info := range impl.containerCtrl.GetContainerInfos() {
+		running[info.PodUUID] = true
+	}
+
+	for podUUID, containers := range impl.buffers {
+		if running[podUUID] {
+			continue
+		}
+		for name, buffer := range containers {
+			if now.After(buffer.lastUpdated.Add(LOG_BUFFER_LIFETIME)) {
+				delete(containers, name)
+			}
+		}
+		if len(containers) == 0 {
+			delete(impl.buffers, podUUID)
+		}
+	}
+}
+
+func (buffer *logBuffer) push(entry core.LogEntry) {
+	buffer.entries[buffer.next] = entry
+	buffer.next = (buffer.next + 1) % len(buffer.entries)
+	if buffer.next == 0 {
+		buffer.filled = true
+	}
+}
+
+// return entries having sequence number larger than `after` in order
+func (buffer *logBuffer) after(after uint64) []core.LogEntry {
+	ordered := buffer.entries[:buffer.next]
+	if buffer.filled {
+		ordered = append(append([]core.LogEntry{}, buffer.entries[buffer.next:]...), buffer.entries[:buffer.next]...)
+	}
+
+	res := make([]core.LogEntry, 0)
+	for _, entry := range ordered {
+		if entry.Sequence > after {
+			res = append(res, entry)
+		}
+	}
+	return res
+}
+
+// truncateMessage cuts the message to the max bytes at the boundary of UTF-8 characters
+func truncateMessage(message string, maxBytes int) string {
+	if len(message) <= maxBytes {
+		return message
+	}
+	end := maxBytes
+	for end > 0 && !utf8.RuneStart(message[end]) {
+		end--
+	}
+	return message[:end]
+}
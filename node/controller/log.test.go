@@ -0,0 +1,171 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/node/misc"
+	"github.com/llamerada-jp/oinari/node/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type containerControllerStub struct {
+	infos []*ContainerInfo
+}
+
+var _ ContainerController = (*containerControllerStub)(nil)
+
+func (stub *containerControllerStub) GetContainerInfos() []*ContainerInfo {
+	return stub.infos
+}
+
+func (stub *containerControllerStub) Reconcile(ctx context.Context, podUuid string) error {
+	return nil
+}
+
+type logControllerTest struct {
+	suite.Suite
+	impl *logControllerImpl
+}
+
+func NewLogControllerTest() suite.TestingSuite {
+	containerCtrl := &containerControllerStub{
+		infos: []*ContainerInfo{
+			{
+				PodUUID: "pod1",
+				Owner:   "owner1",
+			},
+			{
+				PodUUID: "pod2",
+				Owner:   "owner2",
+			},
+		},
+	}
+
+	return &logControllerTest{
+		impl: NewLogController(TEST_NID, nil, containerCtrl, nil, mock.NewMessagingDriverMock()).(*logControllerImpl),
+	}
+}
+
+func (test *logControllerTest) TestAppend() {
+	// can not append log of the pod not running on this node
+	test.Error(test.impl.Append("pod-not-exist", "container", []core.LogEntry{
+		makeLogEntry("message"),
+	}))
+
+	// can not append invalid entry
+	test.Error(test.impl.Append("pod1", "container", []core.LogEntry{
+		{
+			Timestamp: misc.GetTimestamp(),
+			Level:     core.LogLevel("fatal"),
+		},
+	}))
+
+	// entries are stored in order with sequence number
+	test.NoError(test.impl.Append("pod1", "container1", []core.LogEntry{
+		makeLogEntry("message1"),
+		makeLogEntry("message2"),
+	}))
+	test.NoError(test.impl.Append("pod1", "container2", []core.LogEntry{
+		makeLogEntry("message3"),
+	}))
+	podLog, err := test.impl.GetLocalLog("pod1", "owner1", 0, 0)
+	test.NoError(err)
+	test.Len(podLog.Entries, 3)
+	for idx, entry := range podLog.Entries {
+		test.Equal(fmt.Sprintf("message%d", idx+1), entry.Message)
+	}
+	test.Equal("container1", podLog.Entries[0].Container)
+	test.Equal("container2", podLog.Entries[2].Container)
+	test.Equal(podLog.Entries[2].Sequence, podLog.Last)
+
+	// old entries are dropped when the buffer is full
+	for i := 0; i < LOG_BUFFER_SIZE+10; i++ {
+		test.NoError(test.impl.Append("pod2", "container", []core.LogEntry{
+			makeLogEntry(fmt.Sprintf("message%d", i)),
+		}))
+	}
+	podLog, err = test.impl.GetLocalLog("pod2", "owner2", 0, LOG_BUFFER_SIZE*2)
+	test.NoError(err)
+	test.Len(podLog.Entries, LOG_BUFFER_SIZE)
+	test.Equal("message10", podLog.Entries[0].Message)
+	test.Equal(fmt.Sprintf("message%d", LOG_BUFFER_SIZE+9), podLog.Entries[LOG_BUFFER_SIZE-1].Message)
+
+	// long message is cut at the boundary of the characters
+	test.NoError(test.impl.Append("pod1", "container3", []core.LogEntry{
+		makeLogEntry(strings.Repeat("あ", LOG_MESSAGE_MAX_BYTES)),
+	}))
+	podLog, err = test.impl.GetLocalLog("pod1", "owner1", podLog.Last, 0)
+	test.NoError(err)
+	test.Len(podLog.Entries, 1)
+	message := podLog.Entries[0].Message
+	test.True(utf8.ValidString(message))
+	test.Equal(LOG_MESSAGE_MAX_BYTES/3*3, len(message))
+}
+
+func (test *logControllerTest) TestGetLocalLog() {
+	test.NoError(test.impl.Append("pod1", "container", []core.LogEntry{
+		makeLogEntry("message1"),
+		makeLogEntry("message2"),
+		makeLogEntry("message3"),
+	}))
+
+	// get entries after the last sequence number
+	podLog, err := test.impl.GetLocalLog("pod1", "owner1", 0, 0)
+	test.NoError(err)
+	last := podLog.Last
+	podLog, err = test.impl.GetLocalLog("pod1", "owner1", last, 0)
+	test.NoError(err)
+	test.Len(podLog.Entries, 0)
+	test.Equal(last, podLog.Last)
+
+	test.NoError(test.impl.Append("pod1", "container", []core.LogEntry{
+		makeLogEntry("message4"),
+		makeLogEntry("message5"),
+	}))
+
+	// limit the number of entries
+	podLog, err = test.impl.GetLocalLog("pod1", "owner1", last, 1)
+	test.NoError(err)
+	test.Len(podLog.Entries, 1)
+	test.Equal("message4", podLog.Entries[0].Message)
+	podLog, err = test.impl.GetLocalLog("pod1", "owner1", podLog.Last, 1)
+	test.NoError(err)
+	test.Len(podLog.Entries, 1)
+	test.Equal("message5", podLog.Entries[0].Message)
+
+	// empty if there is no log
+	podLog, err = test.impl.GetLocalLog("pod-not-exist", "owner1", 0, 0)
+	test.NoError(err)
+	test.Len(podLog.Entries, 0)
+
+	// other account can not read log
+	_, err = test.impl.GetLocalLog("pod1", "owner2", 0, 0)
+	test.Error(err)
+}
+
+func makeLogEntry(message string) core.LogEntry {
+	return core.LogEntry{
+		Timestamp: misc.GetTimestamp(),
+		Level:     core.LogLevelInfo,
+		Message:   message,
+	}
+}
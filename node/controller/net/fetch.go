@@ -0,0 +1,373 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package net
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	coreAPI "github.com/llamerada-jp/oinari/api/core"
+	netAPI "github.com/llamerada-jp/oinari/api/net"
+	coreController "github.com/llamerada-jp/oinari/node/controller"
+	"github.com/llamerada-jp/oinari/node/misc"
+)
+
+const (
+	// max time from sending the request to finishing reading the response
+	FETCH_TIMEOUT             = 60 * time.Second
+	FETCH_STREAM_IDLE_TIMEOUT = 30 * time.Second
+	// interval to close the streams not accessed for FETCH_STREAM_IDLE_TIMEOUT
+	FETCH_CLEANUP_INTERVAL    = 10 * time.Second
+	FETCH_MAX_STREAMS_PER_POD = 8
+	// audit log of the fetch requests are stored in the log of the pod as this container name
+	FETCH_AUDIT_LOG_CONTAINER = "oinari:net"
+)
+
+type FetchController interface {
+	// send a request and open a stream to read the response body, the request is checked by egress spec of the pod
+	Fetch(podUUID string, request *netAPI.FetchRequest) (*netAPI.FetchResponse, error)
+	Read(podUUID string, streamID string, size int) (*netAPI.ReadResponse, error)
+	Close(podUUID string, streamID string) error
+}
+
+type fetchStream struct {
+	mtx        sync.Mutex
+	podUUID    string
+	method     string
+	url        string
+	body       io.ReadCloser
+	cancel     context.CancelFunc
+	limit      int64
+	read       int64
+	lastAccess time.Time
+}
+
+type fetchControllerImpl struct {
+	mtx     sync.Mutex
+	client  *http.Client
+	logCtrl coreController.LogController
+	podCtrl coreController.PodController
+	// key: stream id
+	streams map[string]*fetchStream
+	// number of the requests sending and not opened streams yet, key: pod uuid
+	sending map[string]int
+}
+
+func NewFetchController(ctx context.Context, logCtrl coreController.LogController, podCtrl coreController.PodController) FetchController {
+	impl := &fetchControllerImpl{
+		client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logCtrl: logCtrl,
+		podCtrl: podCtrl,
+		streams: make(map[string]*fetchStream),
+		sending: make(map[string]int),
+	}
+
+	// the streams of the pods not calling fetch anymore are also closed
+	go func() {
+		ticker := time.NewTicker(FETCH_CLEANUP_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				impl.cleanup()
+			}
+		}
+	}()
+
+	return impl
+}
+
+func (impl *fetchControllerImpl) Fetch(podUUID string, request *netAPI.FetchRequest) (*netAPI.FetchResponse, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	pod, err := impl.podCtrl.GetPodData(podUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod data: %w", err)
+	}
+
+	u, err := url.Parse(request.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !pod.Spec.Egress.IsAllowed(request.Method, u) {
+		impl.audit(podUUID, coreAPI.LogLevelWarn, "denied", request.Method, request.URL, nil)
+		return nil, fmt.Errorf("the request is not allowed by the egress spec of the pod")
+	}
+
+	impl.cleanup()
+	if !impl.reserveStream(podUUID) {
+		impl.audit(podUUID, coreAPI.LogLevelWarn, "denied", request.Method, request.URL, map[string]string{
+			"reason": "too many streams",
+		})
+		return nil, fmt.Errorf("opened streams exceed the limit (%d)", FETCH_MAX_STREAMS_PER_POD)
+	}
+	// the reservation is released if the stream is not opened
+	opened := false
+	defer func() {
+		if !opened {
+			impl.releaseStream(podUUID, "", nil)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), FETCH_TIMEOUT)
+	req, err := http.NewRequestWithContext(ctx, request.Method, request.URL, bytes.NewReader(request.Body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	for key, values := range request.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	// never send credentials of the node and never follow redirects to destinations not checked
+	req.Header.Set("js.fetch:credentials", "omit")
+	req.Header.Set("js.fetch:redirect", "manual")
+
+	res, err := impl.client.Do(req)
+	if err != nil {
+		cancel()
+		impl.audit(podUUID, coreAPI.LogLevelWarn, "failed", request.Method, request.URL, map[string]string{
+			"error": err.Error(),
+		})
+		return nil, fmt.Errorf("failed to send the request: %w", err)
+	}
+
+	limit := pod.Spec.Egress.GetMaxResponseSize()
+	if res.ContentLength > limit {
+		res.Body.Close()
+		cancel()
+		impl.audit(podUUID, coreAPI.LogLevelWarn, "aborted", request.Method, request.URL, map[string]string{
+			"status": strconv.Itoa(res.StatusCode),
+			"reason": "response exceeds the size limit",
+		})
+		return nil, fmt.Errorf("response size (%d) exceeds the limit (%d)", res.ContentLength, limit)
+	}
+
+	streamID := uuid.Must(uuid.NewRandom()).String()
+	impl.releaseStream(podUUID, streamID, &fetchStream{
+		podUUID:    podUUID,
+		method:     request.Method,
+		url:        request.URL,
+		body:       res.Body,
+		cancel:     cancel,
+		limit:      limit,
+		lastAccess: time.Now(),
+	})
+	opened = true
+
+	impl.audit(podUUID, coreAPI.LogLevelInfo, "opened", request.Method, request.URL, map[string]string{
+		"status": strconv.Itoa(res.StatusCode),
+	})
+
+	return &netAPI.FetchResponse{
+		StreamID: streamID,
+		Status:   res.StatusCode,
+		Header:   res.Header,
+	}, nil
+}
+
+func (impl *fetchControllerImpl) Read(podUUID string, streamID string, size int) (*netAPI.ReadResponse, error) {
+	stream, err := impl.getStream(podUUID, streamID)
+	if err != nil {
+		return nil, err
+	}
+
+	if size <= 0 || size > netAPI.FETCH_READ_MAX_SIZE {
+		size = netAPI.FETCH_READ_MAX_SIZE
+	}
+
+	stream.mtx.Lock()
+	defer stream.mtx.Unlock()
+	stream.lastAccess = time.Now()
+
+	// read one more byte than the limit to detect exceeding
+	if rest := stream.limit - stream.read + 1; int64(size) > rest {
+		size = int(rest)
+	}
+	buf := make([]byte, size)
+	n, err := io.ReadFull(stream.body, buf)
+	stream.read += int64(n)
+
+	if stream.read > stream.limit {
+		impl.closeStream(streamID, stream, coreAPI.LogLevelWarn, "aborted", map[string]string{
+			"reason": "response exceeds the size limit",
+		})
+		return nil, fmt.Errorf("response exceeds the size limit (%d)", stream.limit)
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		impl.closeStream(streamID, stream, coreAPI.LogLevelInfo, "completed", nil)
+		return &netAPI.ReadResponse{
+			Data: buf[:n],
+			EOF:  true,
+		}, nil
+	}
+
+	if err != nil {
+		impl.closeStream(streamID, stream, coreAPI.LogLevelWarn, "aborted", map[string]string{
+			"error": err.Error(),
+		})
+		return nil, fmt.Errorf("failed to read the response: %w", err)
+	}
+
+	return &netAPI.ReadResponse{
+		Data: buf[:n],
+	}, nil
+}
+
+func (impl *fetchControllerImpl) Close(podUUID string, streamID string) error {
+	stream, err := impl.getStream(podUUID, streamID)
+	if err != nil {
+		return err
+	}
+
+	stream.mtx.Lock()
+	defer stream.mtx.Unlock()
+	impl.closeStream(streamID, stream, coreAPI.LogLevelInfo, "closed", nil)
+
+	return nil
+}
+
+func (impl *fetchControllerImpl) getStream(podUUID string, streamID string) (*fetchStream, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	stream, ok := impl.streams[streamID]
+	// streams can be accessed only from the pod opened it
+	if !ok || stream.podUUID != podUUID {
+		return nil, fmt.Errorf("stream not found (%s)", streamID)
+	}
+	return stream, nil
+}
+
+// reserveStream returns false if the streams of the pod including the ones being opened reach the limit,
+// the check and the reservation are done at once not to exceed the limit by the concurrent requests
+func (impl *fetchControllerImpl) reserveStream(podUUID string) bool {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	count := impl.sending[podUUID]
+	for _, stream := range impl.streams {
+		if stream.podUUID == podUUID {
+			count++
+		}
+	}
+	if count >= FETCH_MAX_STREAMS_PER_POD {
+		return false
+	}
+	impl.sending[podUUID]++
+	return true
+}
+
+// releaseStream releases the reservation of the pod and opens the stream instead if it is given
+func (impl *fetchControllerImpl) releaseStream(podUUID string, streamID string, stream *fetchStream) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	impl.sending[podUUID]--
+	if impl.sending[podUUID] == 0 {
+		delete(impl.sending, podUUID)
+	}
+	if stream != nil {
+		impl.streams[streamID] = stream
+	}
+}
+
+// close streams not accessed for a while
+func (impl *fetchControllerImpl) cleanup() {
+	impl.mtx.Lock()
+	idles := make(map[string]*fetchStream)
+	for streamID, stream := range impl.streams {
+		if stream.mtx.TryLock() {
+			if time.Since(stream.lastAccess) > FETCH_STREAM_IDLE_TIMEOUT {
+				idles[streamID] = stream
+			} else {
+				stream.mtx.Unlock()
+			}
+		}
+	}
+	impl.mtx.Unlock()
+
+	for streamID, stream := range idles {
+		impl.closeStream(streamID, stream, coreAPI.LogLevelWarn, "aborted", map[string]string{
+			"reason": "idle timeout",
+		})
+		stream.mtx.Unlock()
+	}
+}
+
+// should be called with locking mtx of the stream
+func (impl *fetchControllerImpl) closeStream(streamID string, stream *fetchStream, level coreAPI.LogLevel, result string, attrs map[string]string) {
+	impl.mtx.Lock()
+	_, ok := impl.streams[streamID]
+	delete(impl.streams, streamID)
+	impl.mtx.Unlock()
+
+	// already closed
+	if !ok {
+		return
+	}
+
+	stream.body.Close()
+	stream.cancel()
+
+	if attrs == nil {
+		attrs = make(map[string]string)
+	}
+	attrs["bytes"] = strconv.FormatInt(stream.read, 10)
+	impl.audit(stream.podUUID, level, result, stream.method, stream.url, attrs)
+}
+
+func (impl *fetchControllerImpl) audit(podUUID string, level coreAPI.LogLevel, result, method, url string, attrs map[string]string) {
+	if attrs == nil {
+		attrs = make(map[string]string)
+	}
+	attrs["result"] = result
+	attrs["method"] = method
+	attrs["url"] = url
+
+	err := impl.logCtrl.Append(podUUID, FETCH_AUDIT_LOG_CONTAINER, []coreAPI.LogEntry{
+		{
+			Timestamp: misc.GetTimestamp(),
+			Level:     level,
+			Message:   fmt.Sprintf("fetch %s %s: %s", method, url, result),
+			Attrs:     attrs,
+		},
+	})
+	if err != nil {
+		log.Printf("failed to write audit log of fetch: %s", err.Error())
+	}
+}
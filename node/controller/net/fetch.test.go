@@ -0,0 +1,433 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	coreAPI "github.com/llamerada-jp/oinari/api/core"
+	netAPI "github.com/llamerada-jp/oinari/api/net"
+	coreController "github.com/llamerada-jp/oinari/node/controller"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	TEST_POD_UUID  = "pod1"
+	TEST_OTHER_POD = "pod2"
+)
+
+// logControllerStub records the audit log entries
+type logControllerStub struct {
+	mtx sync.Mutex
+	// key: pod uuid
+	entries map[string][]coreAPI.LogEntry
+}
+
+var _ coreController.LogController = (*logControllerStub)(nil)
+
+func (stub *logControllerStub) Append(podUUID, containerName string, entries []coreAPI.LogEntry) error {
+	stub.mtx.Lock()
+	defer stub.mtx.Unlock()
+	for _, entry := range entries {
+		entry.Container = containerName
+		stub.entries[podUUID] = append(stub.entries[podUUID], entry)
+	}
+	return nil
+}
+
+func (stub *logControllerStub) GetLocalLog(podUUID, account string, after uint64, limit int) (*coreAPI.PodLog, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (stub *logControllerStub) GetLog(podUUID string, after uint64, limit int) (*coreAPI.PodLog, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// pop returns the recorded entries of the pod and clears them
+func (stub *logControllerStub) pop(podUUID string) []coreAPI.LogEntry {
+	stub.mtx.Lock()
+	defer stub.mtx.Unlock()
+	entries := stub.entries[podUUID]
+	delete(stub.entries, podUUID)
+	return entries
+}
+
+// podControllerStub returns the pods having the egress spec, other methods are not used by the fetch controller
+type podControllerStub struct {
+	coreController.PodController
+	egress map[string]*coreAPI.EgressSpec
+}
+
+func (stub *podControllerStub) GetPodData(uuid string) (*coreAPI.Pod, error) {
+	egress, ok := stub.egress[uuid]
+	if !ok {
+		return nil, fmt.Errorf("pod not found")
+	}
+	return &coreAPI.Pod{
+		Meta: &coreAPI.ObjectMeta{
+			Uuid: uuid,
+		},
+		Spec: &coreAPI.PodSpec{
+			Egress: egress,
+		},
+	}, nil
+}
+
+// roundTripperStub replies the responses instead of the servers
+type roundTripperStub struct {
+	mtx      sync.Mutex
+	requests []*http.Request
+	// the response of `/slow` is returned after closing it
+	gate chan struct{}
+}
+
+func (stub *roundTripperStub) RoundTrip(req *http.Request) (*http.Response, error) {
+	stub.mtx.Lock()
+	stub.requests = append(stub.requests, req)
+	stub.mtx.Unlock()
+
+	res := &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": []string{"text/plain"}},
+		ContentLength: -1,
+		Request:       req,
+	}
+	switch strings.TrimPrefix(req.URL.Path, "/public") {
+	case "/hello":
+		res.Body = io.NopCloser(strings.NewReader("hello world"))
+		res.ContentLength = int64(len("hello world"))
+	case "/large":
+		// the size is known before reading the body
+		res.Body = io.NopCloser(strings.NewReader(strings.Repeat("x", 32)))
+		res.ContentLength = 32
+	case "/chunked":
+		// the size is unknown until reading the body
+		res.Body = io.NopCloser(strings.NewReader(strings.Repeat("x", 32)))
+	case "/redirect":
+		res.StatusCode = http.StatusFound
+		res.Header.Set("Location", "https://other.example.com/")
+		res.Body = io.NopCloser(strings.NewReader(""))
+		res.ContentLength = 0
+	case "/slow":
+		<-stub.gate
+		res.Body = io.NopCloser(strings.NewReader(""))
+		res.ContentLength = 0
+	case "/error":
+		return nil, errors.New("connection refused")
+	default:
+		res.StatusCode = http.StatusNotFound
+		res.Body = io.NopCloser(strings.NewReader(""))
+		res.ContentLength = 0
+	}
+	return res, nil
+}
+
+func (stub *roundTripperStub) count() int {
+	stub.mtx.Lock()
+	defer stub.mtx.Unlock()
+	return len(stub.requests)
+}
+
+func (stub *roundTripperStub) last() *http.Request {
+	stub.mtx.Lock()
+	defer stub.mtx.Unlock()
+	return stub.requests[len(stub.requests)-1]
+}
+
+type fetchControllerTest struct {
+	suite.Suite
+	cancel       context.CancelFunc
+	impl         *fetchControllerImpl
+	logCtrl      *logControllerStub
+	roundTripper *roundTripperStub
+}
+
+func NewFetchControllerTest() suite.TestingSuite {
+	return &fetchControllerTest{}
+}
+
+func (test *fetchControllerTest) SetupTest() {
+	test.logCtrl = &logControllerStub{
+		entries: make(map[string][]coreAPI.LogEntry),
+	}
+	podCtrl := &podControllerStub{
+		egress: map[string]*coreAPI.EgressSpec{
+			TEST_POD_UUID: {
+				Allow: []coreAPI.EgressRule{
+					{
+						Host:    "api.example.com",
+						Methods: []string{"GET", "POST"},
+					},
+					{
+						Host:       "*.example.org",
+						PathPrefix: "/public/",
+						AllowHTTP:  true,
+					},
+				},
+				MaxResponseSize: 16,
+			},
+			// the pod without the egress spec can't access anywhere
+			TEST_OTHER_POD: nil,
+		},
+	}
+	var ctx context.Context
+	ctx, test.cancel = context.WithCancel(context.Background())
+	test.impl = NewFetchController(ctx, test.logCtrl, podCtrl).(*fetchControllerImpl)
+	test.roundTripper = &roundTripperStub{
+		gate: make(chan struct{}),
+	}
+	test.impl.client.Transport = test.roundTripper
+}
+
+func (test *fetchControllerTest) TearDownTest() {
+	test.cancel()
+}
+
+func (test *fetchControllerTest) fetch(podUUID, method, url string) (*netAPI.FetchResponse, error) {
+	return test.impl.Fetch(podUUID, &netAPI.FetchRequest{
+		Method: method,
+		URL:    url,
+	})
+}
+
+// checkAudit checks the last audit log entry of the pod
+func (test *fetchControllerTest) checkAudit(podUUID string, level coreAPI.LogLevel, result string) map[string]string {
+	entries := test.logCtrl.pop(podUUID)
+	if !test.NotEmpty(entries) {
+		return nil
+	}
+	entry := entries[len(entries)-1]
+	test.Equal(FETCH_AUDIT_LOG_CONTAINER, entry.Container)
+	test.Equal(level, entry.Level)
+	test.Equal(result, entry.Attrs["result"])
+	return entry.Attrs
+}
+
+func (test *fetchControllerTest) TestEgress() {
+	/// normal pattern: the requests allowed by the egress spec are sent
+	for _, request := range []struct {
+		method string
+		url    string
+	}{
+		{"GET", "https://api.example.com/hello"},
+		{"POST", "https://api.example.com/hello"},
+		{"PUT", "http://www.example.org/public/hello"},
+	} {
+		res, err := test.fetch(TEST_POD_UUID, request.method, request.url)
+		test.NoError(err, request.url)
+		test.Equal(http.StatusOK, res.Status)
+		attrs := test.checkAudit(TEST_POD_UUID, coreAPI.LogLevelInfo, "opened")
+		test.Equal(request.method, attrs["method"])
+		test.Equal(request.url, attrs["url"])
+		test.NoError(test.impl.Close(TEST_POD_UUID, res.StreamID))
+		test.checkAudit(TEST_POD_UUID, coreAPI.LogLevelInfo, "closed")
+	}
+	test.Equal(3, test.roundTripper.count())
+
+	// credentials of the node are not sent and redirects are not followed
+	req := test.roundTripper.last()
+	test.Equal("omit", req.Header.Get("js.fetch:credentials"))
+	test.Equal("manual", req.Header.Get("js.fetch:redirect"))
+	res, err := test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/redirect")
+	test.NoError(err)
+	test.Equal(http.StatusFound, res.Status)
+	test.Equal(4, test.roundTripper.count())
+	test.NoError(test.impl.Close(TEST_POD_UUID, res.StreamID))
+	test.logCtrl.pop(TEST_POD_UUID)
+
+	/// abnormal: the requests not allowed are not sent
+	for _, request := range []struct {
+		podUUID string
+		method  string
+		url     string
+	}{
+		// method not allowed
+		{TEST_POD_UUID, "DELETE", "https://api.example.com/hello"},
+		// host not allowed
+		{TEST_POD_UUID, "GET", "https://api.example.net/hello"},
+		{TEST_POD_UUID, "GET", "https://example.org/public/hello"},
+		// plain http is not allowed by default
+		{TEST_POD_UUID, "GET", "http://api.example.com/hello"},
+		// path prefix not matched
+		{TEST_POD_UUID, "GET", "https://www.example.org/private/hello"},
+		{TEST_POD_UUID, "GET", "https://www.example.org/public/../private/hello"},
+		{TEST_POD_UUID, "GET", "https://www.example.org/public/%2e%2e/private/hello"},
+		// the pod without the egress spec
+		{TEST_OTHER_POD, "GET", "https://api.example.com/hello"},
+	} {
+		_, err := test.fetch(request.podUUID, request.method, request.url)
+		test.Error(err, request.url)
+		attrs := test.checkAudit(request.podUUID, coreAPI.LogLevelWarn, "denied")
+		test.Equal(request.url, attrs["url"])
+	}
+	test.Equal(4, test.roundTripper.count())
+
+	/// abnormal: invalid requests are rejected without the audit log
+	_, err = test.fetch(TEST_POD_UUID, "GET", "ftp://api.example.com/hello")
+	test.Error(err)
+	_, err = test.impl.Fetch(TEST_POD_UUID, &netAPI.FetchRequest{
+		Method: "GET",
+		URL:    "https://api.example.com/hello",
+		Header: map[string][]string{"js.fetch:credentials": {"include"}},
+	})
+	test.Error(err)
+	test.Empty(test.logCtrl.pop(TEST_POD_UUID))
+	test.Equal(4, test.roundTripper.count())
+
+	/// abnormal: the failure of the request is written to the audit log
+	_, err = test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/error")
+	test.Error(err)
+	attrs := test.checkAudit(TEST_POD_UUID, coreAPI.LogLevelWarn, "failed")
+	test.Contains(attrs["error"], "connection refused")
+}
+
+func (test *fetchControllerTest) TestStream() {
+	/// normal pattern: the response body is read in parts
+	res, err := test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/hello")
+	test.NoError(err)
+	test.Equal("text/plain", res.Header["Content-Type"][0])
+	test.logCtrl.pop(TEST_POD_UUID)
+
+	read, err := test.impl.Read(TEST_POD_UUID, res.StreamID, 6)
+	test.NoError(err)
+	test.Equal("hello ", string(read.Data))
+	test.False(read.EOF)
+	read, err = test.impl.Read(TEST_POD_UUID, res.StreamID, 0)
+	test.NoError(err)
+	test.Equal("world", string(read.Data))
+	test.True(read.EOF)
+	attrs := test.checkAudit(TEST_POD_UUID, coreAPI.LogLevelInfo, "completed")
+	test.Equal("11", attrs["bytes"])
+
+	// the stream is closed after EOF
+	_, err = test.impl.Read(TEST_POD_UUID, res.StreamID, 0)
+	test.Error(err)
+	test.Error(test.impl.Close(TEST_POD_UUID, res.StreamID))
+
+	/// abnormal: the stream can't be accessed from other pods
+	res, err = test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/hello")
+	test.NoError(err)
+	_, err = test.impl.Read(TEST_OTHER_POD, res.StreamID, 0)
+	test.Error(err)
+	test.Error(test.impl.Close(TEST_OTHER_POD, res.StreamID))
+	test.NoError(test.impl.Close(TEST_POD_UUID, res.StreamID))
+	attrs = test.checkAudit(TEST_POD_UUID, coreAPI.LogLevelInfo, "closed")
+	test.Equal("0", attrs["bytes"])
+
+	/// abnormal: the response larger than the limit is aborted
+	_, err = test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/large")
+	test.Error(err)
+	test.checkAudit(TEST_POD_UUID, coreAPI.LogLevelWarn, "aborted")
+
+	res, err = test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/chunked")
+	test.NoError(err)
+	test.logCtrl.pop(TEST_POD_UUID)
+	_, err = test.impl.Read(TEST_POD_UUID, res.StreamID, 0)
+	test.Error(err)
+	attrs = test.checkAudit(TEST_POD_UUID, coreAPI.LogLevelWarn, "aborted")
+	test.Equal("response exceeds the size limit", attrs["reason"])
+	_, err = test.impl.Read(TEST_POD_UUID, res.StreamID, 0)
+	test.Error(err)
+
+	/// abnormal: the number of the streams of a pod is limited
+	streamIDs := make([]string, 0)
+	for i := 0; i < FETCH_MAX_STREAMS_PER_POD; i++ {
+		res, err := test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/hello")
+		test.NoError(err)
+		streamIDs = append(streamIDs, res.StreamID)
+	}
+	_, err = test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/hello")
+	test.Error(err)
+	attrs = test.checkAudit(TEST_POD_UUID, coreAPI.LogLevelWarn, "denied")
+	test.Equal("too many streams", attrs["reason"])
+
+	// a stream can be opened after closing one
+	test.NoError(test.impl.Close(TEST_POD_UUID, streamIDs[0]))
+	res, err = test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/hello")
+	test.NoError(err)
+	streamIDs = append(streamIDs[1:], res.StreamID)
+	for _, streamID := range streamIDs {
+		test.NoError(test.impl.Close(TEST_POD_UUID, streamID))
+	}
+}
+
+func (test *fetchControllerTest) TestStreamLimit() {
+	/// abnormal: the concurrent requests don't exceed the limit of the streams
+	const requests = FETCH_MAX_STREAMS_PER_POD + 4
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	streamIDs := make([]string, 0)
+	denied := 0
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/slow")
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				denied++
+				return
+			}
+			streamIDs = append(streamIDs, res.StreamID)
+		}()
+	}
+	// the requests over the limit are denied before sending
+	test.Eventually(func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return denied == requests-FETCH_MAX_STREAMS_PER_POD
+	}, time.Second, 10*time.Millisecond)
+	close(test.roundTripper.gate)
+	wg.Wait()
+	test.Len(streamIDs, FETCH_MAX_STREAMS_PER_POD)
+	test.Equal(FETCH_MAX_STREAMS_PER_POD, test.roundTripper.count())
+	test.Empty(test.impl.sending)
+
+	// the reservation is released when the request failed
+	test.NoError(test.impl.Close(TEST_POD_UUID, streamIDs[0]))
+	_, err := test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/error")
+	test.Error(err)
+	res, err := test.fetch(TEST_POD_UUID, "GET", "https://api.example.com/hello")
+	test.NoError(err)
+	streamIDs = append(streamIDs[1:], res.StreamID)
+	test.logCtrl.pop(TEST_POD_UUID)
+
+	/// abnormal: the streams not accessed for a while are closed
+	test.impl.mtx.Lock()
+	for _, stream := range test.impl.streams {
+		stream.lastAccess = time.Now().Add(-2 * FETCH_STREAM_IDLE_TIMEOUT)
+	}
+	test.impl.mtx.Unlock()
+	test.impl.cleanup()
+	test.Empty(test.impl.streams)
+	entries := test.logCtrl.pop(TEST_POD_UUID)
+	test.Len(entries, FETCH_MAX_STREAMS_PER_POD)
+	for _, entry := range entries {
+		test.Equal("aborted", entry.Attrs["result"])
+		test.Equal("idle timeout", entry.Attrs["reason"])
+	}
+	for _, streamID := range streamIDs {
+		test.Error(test.impl.Close(TEST_POD_UUID, streamID))
+	}
+}
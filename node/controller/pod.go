@@ -17,6 +17,7 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -40,21 +41,28 @@ type PodController interface {
 
 	Create(name, owner, creatorNode string, spec *core.PodSpec) (*ApplicationDigest, error)
 	GetPodData(uuid string) (*core.Pod, error)
+	Watch(uuid string) (<-chan *kvs.WatchEvent, func(), error)
 	GetContainerStateMessage(pod *core.Pod) string
 	Migrate(uuid string, targetNodeID string) error
+	ListRecordGenerations(uuid string) ([]core.RecordGeneration, error)
+	// Restart terminates the containers and starts them again from the generation of the record, they are kept
+	// terminated with the reason in the pod status if the generation could not be restored
+	Restart(uuid string, generation uint64) error
 	Delete(uuid string) error
 	Cleanup(uuid string) error
 }
 
 type podControllerImpl struct {
 	podKvs    kvs.PodKvs
+	recordKvs kvs.RecordKvs
 	messaging driver.MessagingDriver
 	localNid  string
 }
 
-func NewPodController(podKvs kvs.PodKvs, messaging driver.MessagingDriver, localNid string) PodController {
+func NewPodController(podKvs kvs.PodKvs, recordKvs kvs.RecordKvs, messaging driver.MessagingDriver, localNid string) PodController {
 	return &podControllerImpl{
 		podKvs:    podKvs,
+		recordKvs: recordKvs,
 		messaging: messaging,
 		localNid:  localNid,
 	}
@@ -84,6 +92,17 @@ func (impl *podControllerImpl) DealLocalResource(raw []byte) (bool, error) {
 		return false, impl.schedulePod(pod)
 	}
 
+	// start the containers again after the record is restored and they are terminated,
+	// they are kept terminated if the restore failed not to start from an unexpected record
+	if pod.Spec.RestoreGeneration != 0 && len(pod.Status.RestoreFailure) != 0 {
+		return false, nil
+	}
+	if pod.Spec.RestoreGeneration != 0 && impl.isContainerTerminated(pod) {
+		pod.Spec.RestoreGeneration = 0
+		impl.resetContainerStatuses(pod)
+		return false, impl.podKvs.Update(pod)
+	}
+
 	if pod.Status.RunningNode == pod.Spec.TargetNode {
 		if impl.isContainerTerminated(pod) || impl.isContainerUnknown(pod) {
 			// TODO restart pod by the restart policy
@@ -93,15 +112,7 @@ func (impl *podControllerImpl) DealLocalResource(raw []byte) (bool, error) {
 	} else {
 		if impl.isContainerTerminated(pod) {
 			pod.Status.RunningNode = pod.Spec.TargetNode
-			for idx := range pod.Status.ContainerStatuses {
-				containerStatus := &pod.Status.ContainerStatuses[idx]
-				containerStatus.ContainerID = ""
-				containerStatus.Image = ""
-				if containerStatus.State.Terminated != nil {
-					containerStatus.LastState = containerStatus.State.Terminated
-				}
-				containerStatus.State = core.ContainerState{}
-			}
+			impl.resetContainerStatuses(pod)
 			return false, impl.podKvs.Update(pod)
 
 		} else if impl.isContainerUnknown(pod) {
@@ -169,6 +180,9 @@ func (impl *podControllerImpl) setDefaultPodSpec(spec *core.PodSpec) *core.PodSp
 		}
 	}
 
+	// restore is only requested by Restart
+	spec.RestoreGeneration = 0
+
 	if spec.Scheduler == nil {
 		spec.Scheduler = &core.SchedulerSpec{
 			Type: "creator",
@@ -218,6 +232,9 @@ func (impl *podControllerImpl) GetContainerStateMessage(pod *core.Pod) string {
 	if len(unknownReasons) != 0 {
 		message = fmt.Sprintf("%s\n%s", message, strings.Join(unknownReasons, "\n"))
 	}
+	if len(pod.Status.RestoreFailure) != 0 {
+		message = fmt.Sprintf("%s\n%s", message, pod.Status.RestoreFailure)
+	}
 	return message
 }
 
@@ -225,23 +242,58 @@ func (impl *podControllerImpl) GetPodData(uuid string) (*core.Pod, error) {
 	return impl.podKvs.Get(uuid)
 }
 
+func (impl *podControllerImpl) Watch(uuid string) (<-chan *kvs.WatchEvent, func(), error) {
+	return impl.podKvs.Watch(uuid)
+}
+
 func (impl *podControllerImpl) Migrate(uuid string, targetNodeID string) error {
-	pod, err := impl.podKvs.Get(uuid)
-	if err != nil {
-		return err
-	}
+	return kvs.RetryOnConflict(func() error {
+		pod, err := impl.podKvs.Get(uuid)
+		if err != nil {
+			return err
+		}
 
-	if len(pod.Status.RunningNode) == 0 {
-		pod.Spec.TargetNode = targetNodeID
-		pod.Status.RunningNode = targetNodeID
+		if len(pod.Status.RunningNode) == 0 {
+			pod.Spec.TargetNode = targetNodeID
+			pod.Status.RunningNode = targetNodeID
 
-	} else {
-		// TODO check if migration is accepted
+		} else {
+			// TODO check if migration is accepted
+
+			pod.Spec.TargetNode = targetNodeID
+		}
+
+		return impl.podKvs.Update(pod)
+	})
+}
+
+func (impl *podControllerImpl) ListRecordGenerations(uuid string) ([]core.RecordGeneration, error) {
+	return impl.recordKvs.ListGenerations(uuid)
+}
 
-		pod.Spec.TargetNode = targetNodeID
+func (impl *podControllerImpl) Restart(uuid string, generation uint64) error {
+	// check the generation is readable before terminating the containers
+	if _, err := impl.recordKvs.GetGeneration(uuid, generation); err != nil {
+		return fmt.Errorf("failed to get the record to restart: %w", err)
 	}
 
-	return impl.podKvs.Update(pod)
+	return kvs.RetryOnConflict(func() error {
+		pod, err := impl.podKvs.Get(uuid)
+		if err != nil {
+			return err
+		}
+
+		if len(pod.Meta.DeletionTimestamp) != 0 {
+			return fmt.Errorf("the pod is being deleted")
+		}
+		if len(pod.Status.RunningNode) == 0 || pod.Spec.TargetNode != pod.Status.RunningNode {
+			return fmt.Errorf("the pod is not running or migrating")
+		}
+
+		pod.Spec.RestoreGeneration = generation
+		pod.Status.RestoreFailure = ""
+		return impl.podKvs.Update(pod)
+	})
 }
 
 func (impl *podControllerImpl) Delete(uuid string) error {
@@ -255,6 +307,10 @@ func (impl *podControllerImpl) Delete(uuid string) error {
 		if len(pod.Meta.DeletionTimestamp) == 0 {
 			pod.Meta.DeletionTimestamp = misc.GetTimestamp()
 			err = impl.podKvs.Update(pod)
+			// read the pod again if it is updated by another writer
+			if errors.Is(err, kvs.ErrConflict) {
+				continue
+			}
 			if err != nil {
 				return err
 			}
@@ -277,6 +333,20 @@ func (impl *podControllerImpl) Cleanup(uuid string) error {
 	return impl.podKvs.Delete(uuid)
 }
 
+// resetContainerStatuses makes the containers ready to start again, the record is used to setup them
+// because LastState is set.
+func (impl *podControllerImpl) resetContainerStatuses(pod *core.Pod) {
+	for idx := range pod.Status.ContainerStatuses {
+		containerStatus := &pod.Status.ContainerStatuses[idx]
+		containerStatus.ContainerID = ""
+		containerStatus.Image = ""
+		if containerStatus.State.Terminated != nil {
+			containerStatus.LastState = containerStatus.State.Terminated
+		}
+		containerStatus.State = core.ContainerState{}
+	}
+}
+
 func (impl *podControllerImpl) isContainerTerminated(pod *core.Pod) bool {
 	for _, containerStatus := range pod.Status.ContainerStatuses {
 		if containerStatus.State.Terminated == nil {
@@ -39,7 +39,7 @@ type podControllerTest struct {
 
 func NewPodControllerTest() suite.TestingSuite {
 	colMock := mock.NewColonioMock()
-	podKvs := kvs.NewPodKvs(colMock)
+	podKvs := kvs.NewPodKvs(colMock, nil, nil)
 	mdMock := mock.NewMessagingDriverMock()
 
 	return &podControllerTest{
@@ -48,6 +48,7 @@ func NewPodControllerTest() suite.TestingSuite {
 		mdMock: mdMock,
 		impl: &podControllerImpl{
 			podKvs:    podKvs,
+			recordKvs: kvs.NewRecordKvs(colMock, nil),
 			messaging: mdMock,
 			localNid:  TEST_NID,
 		},
@@ -337,3 +338,119 @@ func (test *podControllerTest) TestPodLifecycle() {
 	_, err = test.podKvs.Get(digest2.Uuid)
 	test.Error(err)
 }
+
+func (test *podControllerTest) TestRestart() {
+	nodeID := "012345678901234567890123456789ab"
+	digest, err := test.impl.Create("test-pod", "owner", nodeID, &core.PodSpec{
+		Containers: []core.ContainerSpec{
+			{
+				Name:    "test",
+				Image:   "http://localhost/dummy.wasm",
+				Runtime: []string{"go:1.20"},
+			},
+		},
+		// ignored on creation
+		RestoreGeneration: 1,
+	})
+	test.NoError(err)
+	pod, err := test.podKvs.Get(digest.Uuid)
+	test.NoError(err)
+	test.Zero(pod.Spec.RestoreGeneration)
+
+	// the generation of the record should exist
+	test.ErrorIs(test.impl.Restart(digest.Uuid, 1), kvs.ErrRecordGenerationNotFound)
+	for i := 0; i < 2; i++ {
+		test.NoError(test.impl.recordKvs.Set(&core.Record{
+			Meta: &core.ObjectMeta{
+				Type:        core.ResourceTypeRecord,
+				Name:        pod.Meta.Name,
+				Owner:       pod.Meta.Owner,
+				CreatorNode: pod.Meta.CreatorNode,
+				Uuid:        pod.Meta.Uuid,
+			},
+			Data: &core.RecordData{
+				Entries: map[string]core.RecordEntry{
+					"test": {
+						Timestamp: misc.GetTimestamp(),
+						Record:    []byte{byte(i)},
+					},
+				},
+			},
+		}))
+	}
+	generations, err := test.impl.ListRecordGenerations(digest.Uuid)
+	test.NoError(err)
+	test.Len(generations, 2)
+
+	// the pod should be running
+	test.Error(test.impl.Restart(digest.Uuid, 1))
+	test.NoError(test.impl.Migrate(digest.Uuid, nodeID))
+	test.NoError(test.impl.Restart(digest.Uuid, 1))
+	pod, err = test.podKvs.Get(digest.Uuid)
+	test.NoError(err)
+	test.Equal(uint64(1), pod.Spec.RestoreGeneration)
+
+	// wait for the containers to terminate
+	_, err = test.impl.DealLocalResource(test.getRaw(digest.Uuid))
+	test.NoError(err)
+	pod, err = test.podKvs.Get(digest.Uuid)
+	test.NoError(err)
+	test.Equal(uint64(1), pod.Spec.RestoreGeneration)
+
+	// reset the status to start the containers again
+	pod.Status.ContainerStatuses[0] = core.ContainerStatus{
+		ContainerID: "test",
+		Image:       "http://localhost/dummy.wasm",
+		State: core.ContainerState{
+			Running: &core.ContainerStateRunning{
+				StartedAt: misc.GetTimestamp(),
+			},
+			Terminated: &core.ContainerStateTerminated{
+				FinishedAt: misc.GetTimestamp(),
+			},
+		},
+	}
+	test.NoError(test.podKvs.Update(pod))
+	_, err = test.impl.DealLocalResource(test.getRaw(digest.Uuid))
+	test.NoError(err)
+	pod, err = test.podKvs.Get(digest.Uuid)
+	test.NoError(err)
+	test.Zero(pod.Spec.RestoreGeneration)
+	test.Equal(nodeID, pod.Status.RunningNode)
+	test.Empty(pod.Status.ContainerStatuses[0].ContainerID)
+	test.NotNil(pod.Status.ContainerStatuses[0].LastState)
+	test.Nil(pod.Status.ContainerStatuses[0].State.Terminated)
+
+	// the containers are not started again if the record could not be restored
+	test.NoError(test.impl.Restart(digest.Uuid, 1))
+	pod, err = test.podKvs.Get(digest.Uuid)
+	test.NoError(err)
+	pod.Status.ContainerStatuses[0] = core.ContainerStatus{
+		ContainerID: "test",
+		Image:       "http://localhost/dummy.wasm",
+		State: core.ContainerState{
+			Running: &core.ContainerStateRunning{
+				StartedAt: misc.GetTimestamp(),
+			},
+			Terminated: &core.ContainerStateTerminated{
+				FinishedAt: misc.GetTimestamp(),
+			},
+		},
+	}
+	pod.Status.RestoreFailure = "failed to restore the record"
+	test.NoError(test.podKvs.Update(pod))
+	_, err = test.impl.DealLocalResource(test.getRaw(digest.Uuid))
+	test.NoError(err)
+	pod, err = test.podKvs.Get(digest.Uuid)
+	test.NoError(err)
+	test.Equal(uint64(1), pod.Spec.RestoreGeneration)
+	test.NotNil(pod.Status.ContainerStatuses[0].State.Terminated)
+	test.Contains(test.impl.GetContainerStateMessage(pod), "failed to restore the record")
+
+	// restarting again clears the failure
+	test.NoError(test.impl.Restart(digest.Uuid, 2))
+	pod, err = test.podKvs.Get(digest.Uuid)
+	test.NoError(err)
+	test.Equal(uint64(2), pod.Spec.RestoreGeneration)
+	test.Empty(pod.Status.RestoreFailure)
+}
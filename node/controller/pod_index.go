@@ -0,0 +1,270 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/node/kvs"
+)
+
+const (
+	// entries not refreshed by the keepalive of the running node are removed after the lifetime
+	POD_INDEX_ENTRY_LIFETIME     = ACCOUNT_STATE_RESOURCE_LIFETIME
+	POD_INDEX_LIST_DEFAULT_LIMIT = 50
+	POD_INDEX_LIST_MAX_LIMIT     = 500
+)
+
+// PodIndexFilter selects the entries of the pod index, empty fields match any entry.
+type PodIndexFilter struct {
+	Phase       core.PodPhase
+	RunningNode string
+	NamePrefix  string
+}
+
+type PodIndexItem struct {
+	Uuid string
+	core.PodIndexEntry
+}
+
+type PodIndexPage struct {
+	Items []PodIndexItem
+	// Continue is passed to List to get the next page, it is empty for the last page
+	Continue string
+}
+
+type PodIndexController interface {
+	DealLocalResource(raw []byte) (bool, error)
+
+	UpdateEntries(account string, entries map[string]core.PodIndexEntry) error
+	List(account string, filter *PodIndexFilter, limit int, cont string) (*PodIndexPage, error)
+}
+
+type podIndexControllerImpl struct {
+	localNid    string
+	podIndexKvs kvs.PodIndexKvs
+}
+
+func NewPodIndexController(localNid string, podIndexKvs kvs.PodIndexKvs) PodIndexController {
+	return &podIndexControllerImpl{
+		localNid:    localNid,
+		podIndexKvs: podIndexKvs,
+	}
+}
+
+func (impl *podIndexControllerImpl) DealLocalResource(raw []byte) (bool, error) {
+	index := &core.PodIndex{}
+	if err := json.Unmarshal(raw, index); err != nil {
+		return true, fmt.Errorf("failed to unmarshal pod index record: %w", err)
+	}
+
+	if err := index.Validate(); err != nil {
+		return true, fmt.Errorf("failed to validate pod index record: %w", err)
+	}
+
+	if !removeExpiredEntries(index, time.Now()) {
+		return false, nil
+	}
+	if len(index.Entries) == 0 {
+		return true, nil
+	}
+
+	err := impl.podIndexKvs.Update(index)
+	// the shard was written by another node, check it at the next time
+	if errors.Is(err, kvs.ErrConflict) {
+		return false, nil
+	}
+	return false, err
+}
+
+// UpdateEntries writes the entries into the shards of the account, the shards are written one by one
+func (impl *podIndexControllerImpl) UpdateEntries(account string, entries map[string]core.PodIndexEntry) error {
+	shards := make(map[int]map[string]core.PodIndexEntry)
+	for podUuid, entry := range entries {
+		shard := core.GetPodIndexShard(podUuid)
+		if _, ok := shards[shard]; !ok {
+			shards[shard] = make(map[string]core.PodIndexEntry)
+		}
+		shards[shard][podUuid] = entry
+	}
+
+	for shard, shardEntries := range shards {
+		err := kvs.RetryOnConflict(func() error {
+			return impl.updateShard(account, shard, shardEntries)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update pod index of %s (shard %d): %w", account, shard, err)
+		}
+	}
+	return nil
+}
+
+func (impl *podIndexControllerImpl) updateShard(account string, shard int, entries map[string]core.PodIndexEntry) error {
+	index, err := impl.podIndexKvs.Get(account, shard)
+	// the broken shard is rebuilt by the keepalive of the nodes running the pods
+	broken := errors.Is(err, kvs.ErrInvalid)
+	if err != nil && !broken {
+		return err
+	}
+
+	if index == nil {
+		index = &core.PodIndex{
+			Meta: &core.ObjectMeta{
+				Type:        core.ResourceTypePodIndex,
+				Name:        account,
+				Owner:       account,
+				CreatorNode: impl.localNid,
+				Uuid:        core.GeneratePodIndexUuid(account, shard),
+			},
+			Shard:   shard,
+			Entries: entries,
+		}
+		if broken {
+			log.Printf("the pod index of %s (shard %d) is broken and written again: %s", account, shard, err.Error())
+			return impl.podIndexKvs.Set(index)
+		}
+		err := impl.podIndexKvs.Create(index)
+		// another node created the shard at the same time
+		if errors.Is(err, kvs.ErrAlreadyExists) {
+			return kvs.ErrConflict
+		}
+		return err
+	}
+
+	for podUuid, entry := range entries {
+		index.Entries[podUuid] = entry
+	}
+	removeExpiredEntries(index, time.Now())
+	return impl.podIndexKvs.Update(index)
+}
+
+// List returns the entries matching the filter in order of the shard and the uuid of the pods
+func (impl *podIndexControllerImpl) List(account string, filter *PodIndexFilter, limit int, cont string) (*PodIndexPage, error) {
+	if limit <= 0 {
+		limit = POD_INDEX_LIST_DEFAULT_LIMIT
+	}
+	if limit > POD_INDEX_LIST_MAX_LIMIT {
+		limit = POD_INDEX_LIST_MAX_LIMIT
+	}
+	if filter == nil {
+		filter = &PodIndexFilter{}
+	}
+
+	startShard, after, err := parseContinue(cont)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	page := &PodIndexPage{
+		Items: make([]PodIndexItem, 0),
+	}
+	for shard := startShard; shard < core.POD_INDEX_SHARD_COUNT; shard++ {
+		index, err := impl.podIndexKvs.Get(account, shard)
+		// the broken shard is skipped until it is rebuilt
+		if errors.Is(err, kvs.ErrInvalid) {
+			log.Printf("skip the broken pod index of %s (shard %d): %s", account, shard, err.Error())
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pod index of %s (shard %d): %w", account, shard, err)
+		}
+		if index == nil {
+			continue
+		}
+
+		uuids := make([]string, 0, len(index.Entries))
+		for podUuid := range index.Entries {
+			if shard == startShard && podUuid <= after {
+				continue
+			}
+			uuids = append(uuids, podUuid)
+		}
+		sort.Strings(uuids)
+
+		for _, podUuid := range uuids {
+			entry := index.Entries[podUuid]
+			if isEntryExpired(&entry, now) || !filter.Match(&entry) {
+				continue
+			}
+			page.Items = append(page.Items, PodIndexItem{
+				Uuid:          podUuid,
+				PodIndexEntry: entry,
+			})
+			if len(page.Items) == limit {
+				page.Continue = fmt.Sprintf("%d/%s", shard, podUuid)
+				return page, nil
+			}
+		}
+	}
+
+	return page, nil
+}
+
+func (filter *PodIndexFilter) Match(entry *core.PodIndexEntry) bool {
+	if len(filter.Phase) != 0 && entry.Phase != filter.Phase {
+		return false
+	}
+	if len(filter.RunningNode) != 0 && entry.RunningNode != filter.RunningNode {
+		return false
+	}
+	return strings.HasPrefix(entry.Name, filter.NamePrefix)
+}
+
+// the format of the continue token is "<shard>/<uuid of the last pod in the previous page>"
+func parseContinue(cont string) (int, string, error) {
+	if len(cont) == 0 {
+		return 0, "", nil
+	}
+
+	shardStr, after, ok := strings.Cut(cont, "/")
+	if !ok {
+		return 0, "", fmt.Errorf("invalid continue token: %s", cont)
+	}
+	shard, err := strconv.Atoi(shardStr)
+	if err != nil || shard < 0 || shard >= core.POD_INDEX_SHARD_COUNT {
+		return 0, "", fmt.Errorf("invalid shard in continue token: %s", cont)
+	}
+	return shard, after, nil
+}
+
+func isEntryExpired(entry *core.PodIndexEntry, now time.Time) bool {
+	timestamp, err := time.Parse(time.RFC3339, entry.Timestamp)
+	if err != nil {
+		return true
+	}
+	return now.After(timestamp.Add(POD_INDEX_ENTRY_LIFETIME))
+}
+
+// removeExpiredEntries returns true if some entries are removed
+func removeExpiredEntries(index *core.PodIndex, now time.Time) bool {
+	removed := false
+	for podUuid, entry := range index.Entries {
+		if isEntryExpired(&entry, now) {
+			delete(index.Entries, podUuid)
+			removed = true
+		}
+	}
+	return removed
+}
@@ -0,0 +1,242 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/node/kvs"
+	"github.com/llamerada-jp/oinari/node/misc"
+	"github.com/llamerada-jp/oinari/node/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type podIndexControllerTest struct {
+	suite.Suite
+	col         *mock.Colonio
+	podIndexKvs kvs.PodIndexKvs
+	impl        *podIndexControllerImpl
+}
+
+func NewPodIndexControllerTest() suite.TestingSuite {
+	colMock := mock.NewColonioMock()
+	podIndexKvs := kvs.NewPodIndexKvs(colMock)
+
+	return &podIndexControllerTest{
+		col:         colMock,
+		podIndexKvs: podIndexKvs,
+		impl:        NewPodIndexController(colMock.LocalNid, podIndexKvs).(*podIndexControllerImpl),
+	}
+}
+
+func (test *podIndexControllerTest) SetupTest() {
+	test.col.DeleteKVSAll()
+}
+
+func (test *podIndexControllerTest) makeEntries(count int, name string, phase core.PodPhase, nid string) map[string]core.PodIndexEntry {
+	entries := make(map[string]core.PodIndexEntry)
+	for i := 0; i < count; i++ {
+		entries[core.GeneratePodUuid()] = core.PodIndexEntry{
+			Name:        fmt.Sprintf("%s-%d", name, i),
+			RunningNode: nid,
+			Phase:       phase,
+			Timestamp:   misc.GetTimestamp(),
+		}
+	}
+	return entries
+}
+
+func (test *podIndexControllerTest) TestUpdateEntries() {
+	account := "account"
+	entries := test.makeEntries(40, "pod", core.PodPhaseRunning, test.col.LocalNid)
+	test.NoError(test.impl.UpdateEntries(account, entries))
+
+	// entries are spread over the shards
+	count := 0
+	shards := 0
+	for shard := 0; shard < core.POD_INDEX_SHARD_COUNT; shard++ {
+		index, err := test.podIndexKvs.Get(account, shard)
+		test.NoError(err)
+		if index == nil {
+			continue
+		}
+		shards++
+		count += len(index.Entries)
+		for podUuid, entry := range index.Entries {
+			test.Equal(shard, core.GetPodIndexShard(podUuid))
+			test.Equal(entries[podUuid], entry)
+		}
+	}
+	test.Equal(40, count)
+	test.Greater(shards, 1)
+
+	// update exists and remove expired entries
+	var updated, expired string
+	for podUuid := range entries {
+		if len(updated) == 0 {
+			updated = podUuid
+		} else if core.GetPodIndexShard(podUuid) != core.GetPodIndexShard(updated) {
+			expired = podUuid
+			break
+		}
+	}
+	shard := core.GetPodIndexShard(expired)
+	index, err := test.podIndexKvs.Get(account, shard)
+	test.NoError(err)
+	entry := index.Entries[expired]
+	entry.Timestamp = time.Now().Add(-2 * POD_INDEX_ENTRY_LIFETIME).Format(time.RFC3339)
+	index.Entries[expired] = entry
+	test.NoError(test.podIndexKvs.Update(index))
+
+	page, err := test.impl.List(account, nil, 0, "")
+	test.NoError(err)
+	test.Len(page.Items, 39)
+
+	test.NoError(test.impl.UpdateEntries(account, map[string]core.PodIndexEntry{
+		updated: {
+			Name:      "updated",
+			Phase:     core.PodPhaseTerminated,
+			Timestamp: misc.GetTimestamp(),
+		},
+	}))
+	index, err = test.podIndexKvs.Get(account, core.GetPodIndexShard(updated))
+	test.NoError(err)
+	test.Equal("updated", index.Entries[updated].Name)
+	test.Equal(core.PodPhaseTerminated, index.Entries[updated].Phase)
+
+	// DealLocalResource removes expired entries
+	index, err = test.podIndexKvs.Get(account, shard)
+	test.NoError(err)
+	test.Contains(index.Entries, expired)
+	raw, err := json.Marshal(index)
+	test.NoError(err)
+	deleteFlg, err := test.impl.DealLocalResource(raw)
+	test.NoError(err)
+	test.Equal(len(index.Entries) == 1, deleteFlg)
+	// the shard having only the expired entry is left to be deleted by the caller
+	if !deleteFlg {
+		index, err = test.podIndexKvs.Get(account, shard)
+		test.NoError(err)
+		test.NotContains(index.Entries, expired)
+	}
+
+	// require deletion if the record is not valid
+	deleteFlg, err = test.impl.DealLocalResource([]byte(""))
+	test.Error(err)
+	test.True(deleteFlg)
+}
+
+func (test *podIndexControllerTest) TestBrokenShard() {
+	account := "account"
+	entries := test.makeEntries(10, "pod", core.PodPhaseRunning, test.col.LocalNid)
+	test.NoError(test.impl.UpdateEntries(account, entries))
+
+	var broken string
+	for podUuid := range entries {
+		broken = podUuid
+		break
+	}
+	shard := core.GetPodIndexShard(broken)
+	key := string(core.ResourceTypePodIndex) + "/" + core.GeneratePodIndexUuid(account, shard)
+	test.NoError(test.col.KvsSet(key, []byte("broken"), 0))
+
+	/// abnormal: the broken shard is kept and skipped by List
+	_, err := test.podIndexKvs.Get(account, shard)
+	test.ErrorIs(err, kvs.ErrInvalid)
+	page, err := test.impl.List(account, nil, 0, "")
+	test.NoError(err)
+	test.Less(len(page.Items), 10)
+	for _, item := range page.Items {
+		test.NotEqual(shard, core.GetPodIndexShard(item.Uuid))
+	}
+
+	/// normal pattern: the broken shard is rebuilt by the keepalive
+	test.NoError(test.impl.UpdateEntries(account, map[string]core.PodIndexEntry{
+		broken: entries[broken],
+	}))
+	index, err := test.podIndexKvs.Get(account, shard)
+	test.NoError(err)
+	test.Contains(index.Entries, broken)
+	page, err = test.impl.List(account, nil, 0, "")
+	test.NoError(err)
+	uuids := make([]string, 0)
+	for _, item := range page.Items {
+		uuids = append(uuids, item.Uuid)
+	}
+	test.Contains(uuids, broken)
+}
+
+func (test *podIndexControllerTest) TestList() {
+	account := "account"
+	nid := "012345678901234567890123456789ab"
+	test.NoError(test.impl.UpdateEntries(account, test.makeEntries(30, "fox", core.PodPhaseRunning, test.col.LocalNid)))
+	test.NoError(test.impl.UpdateEntries(account, test.makeEntries(20, "sleep", core.PodPhaseRunning, nid)))
+	test.NoError(test.impl.UpdateEntries(account, test.makeEntries(10, "fox-pending", core.PodPhasePending, "")))
+	test.NoError(test.impl.UpdateEntries("other", test.makeEntries(10, "fox", core.PodPhaseRunning, nid)))
+
+	// pagination
+	uuids := make(map[string]bool)
+	cont := ""
+	pages := 0
+	for {
+		page, err := test.impl.List(account, nil, 7, cont)
+		test.NoError(err)
+		test.LessOrEqual(len(page.Items), 7)
+		for _, item := range page.Items {
+			test.False(uuids[item.Uuid])
+			uuids[item.Uuid] = true
+		}
+		pages++
+		if len(page.Continue) == 0 {
+			break
+		}
+		cont = page.Continue
+	}
+	test.Len(uuids, 60)
+	test.GreaterOrEqual(pages, 9)
+
+	// filters
+	for _, tc := range []struct {
+		filter   PodIndexFilter
+		expected int
+	}{
+		{PodIndexFilter{}, 60},
+		{PodIndexFilter{Phase: core.PodPhaseRunning}, 50},
+		{PodIndexFilter{Phase: core.PodPhasePending}, 10},
+		{PodIndexFilter{Phase: core.PodPhaseTerminated}, 0},
+		{PodIndexFilter{RunningNode: nid}, 20},
+		{PodIndexFilter{NamePrefix: "fox"}, 40},
+		{PodIndexFilter{NamePrefix: "fox", Phase: core.PodPhaseRunning}, 30},
+		{PodIndexFilter{NamePrefix: "fox-1", RunningNode: test.col.LocalNid}, 11},
+	} {
+		page, err := test.impl.List(account, &tc.filter, POD_INDEX_LIST_MAX_LIMIT, "")
+		test.NoError(err)
+		test.Len(page.Items, tc.expected, "%+v", tc.filter)
+		test.Empty(page.Continue)
+		for _, item := range page.Items {
+			test.True(tc.filter.Match(&item.PodIndexEntry))
+		}
+	}
+
+	// invalid continue token
+	_, err := test.impl.List(account, nil, 0, "invalid")
+	test.Error(err)
+	_, err = test.impl.List(account, nil, 0, fmt.Sprintf("%d/", core.POD_INDEX_SHARD_COUNT))
+	test.Error(err)
+}
@@ -17,6 +17,8 @@ package controller
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"fmt"
 
 	"github.com/llamerada-jp/colonio/go/colonio"
@@ -29,12 +31,28 @@ type EventHandler interface {
 	OnConnect(nodeName string, nodeType core.NodeType) error
 }
 
+// AccountCredential is given by the seed, the fields are base64 encoded and can be empty
+type AccountCredential struct {
+	// Key is shared by the nodes of the account to encrypt the records and to derive the signing key
+	Key string
+	// Certificate is made by the seed for the signing key derived from Key
+	Certificate string
+	// Authority is the public key of the seed to verify the certificates
+	Authority string
+}
+
 type SystemController interface {
 	Start(ctx context.Context) error
-	Connect(url, account, token, nodeName, nodeType string) error
+	Connect(url, account string, credential *AccountCredential, token, nodeName, nodeType string) error
 	Disconnect() error
 
 	GetAccount() string
+	// GetAccountKey returns nil if the key of the account is not given
+	GetAccountKey() []byte
+	// GetKeyCertificate returns nil if the certificate of the signing key is not given
+	GetKeyCertificate() []byte
+	// GetAuthorityKey returns nil if the key of the seed is not given
+	GetAuthorityKey() ed25519.PublicKey
 	GetNode() string
 }
 
@@ -43,6 +61,9 @@ type systemControllerImpl struct {
 	evh            EventHandler
 	frontendDriver driver.FrontendDriver
 	account        string
+	accountKey     []byte
+	certificate    []byte
+	authorityKey   ed25519.PublicKey
 }
 
 func NewSystemController(col colonio.Colonio, evh EventHandler, frontendDriver driver.FrontendDriver) SystemController {
@@ -62,21 +83,55 @@ func (impl *systemControllerImpl) GetAccount() string {
 	return impl.account
 }
 
+func (impl *systemControllerImpl) GetAccountKey() []byte {
+	return impl.accountKey
+}
+
+func (impl *systemControllerImpl) GetKeyCertificate() []byte {
+	return impl.certificate
+}
+
+func (impl *systemControllerImpl) GetAuthorityKey() ed25519.PublicKey {
+	return impl.authorityKey
+}
+
 func (impl *systemControllerImpl) GetNode() string {
 	return impl.colonio.GetLocalNid()
 }
 
-func (impl *systemControllerImpl) Connect(url, account, token, nodeName, nodeType string) error {
+func (impl *systemControllerImpl) Connect(url, account string, credential *AccountCredential, token, nodeName, nodeType string) error {
 	if !slices.Contains(core.NodeTypeAccepted, core.NodeType(nodeType)) {
 		return fmt.Errorf("unsupported node type specified")
 	}
 
-	err := impl.colonio.Connect(url, token)
+	if credential == nil {
+		credential = &AccountCredential{}
+	}
+	key, err := decodeCredential(credential.Key)
+	if err != nil {
+		return fmt.Errorf("failed to decode the account key: %w", err)
+	}
+	certificate, err := decodeCredential(credential.Certificate)
+	if err != nil {
+		return fmt.Errorf("failed to decode the certificate: %w", err)
+	}
+	authorityKey, err := decodeCredential(credential.Authority)
+	if err != nil {
+		return fmt.Errorf("failed to decode the key of the authority: %w", err)
+	}
+	if len(authorityKey) != 0 && len(authorityKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("size of the key of the authority is wrong")
+	}
+
+	err = impl.colonio.Connect(url, token)
 	if err != nil {
 		return fmt.Errorf("failed to colonio.Connect: %w", err)
 	}
 
 	impl.account = account
+	impl.accountKey = key
+	impl.certificate = certificate
+	impl.authorityKey = authorityKey
 
 	err = impl.evh.OnConnect(nodeName, core.NodeType(nodeType))
 	if err != nil {
@@ -92,5 +147,15 @@ func (impl *systemControllerImpl) Disconnect() error {
 		return err
 	}
 	impl.account = ""
+	impl.accountKey = nil
+	impl.certificate = nil
+	impl.authorityKey = nil
 	return nil
 }
+
+func decodeCredential(encoded string) ([]byte, error) {
+	if len(encoded) == 0 {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
@@ -16,49 +16,105 @@
 package three
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 
 	coreAPI "github.com/llamerada-jp/oinari/api/core"
 	threeAPI "github.com/llamerada-jp/oinari/api/three"
+	apiDriver "github.com/llamerada-jp/oinari/node/apis/three"
 	coreController "github.com/llamerada-jp/oinari/node/controller"
 	fd "github.com/llamerada-jp/oinari/node/frontend/driver"
+	coreKVS "github.com/llamerada-jp/oinari/node/kvs"
 	kvs "github.com/llamerada-jp/oinari/node/kvs/three"
-	messaging "github.com/llamerada-jp/oinari/node/messaging/three/driver"
+	messaging "github.com/llamerada-jp/oinari/node/messaging/three"
+	md "github.com/llamerada-jp/oinari/node/messaging/three/driver"
+)
+
+const (
+	// radius in meters to populate the scene around the node
+	OBJECT_POPULATE_RADIUS = 1000
+	// transient updates are written to the KVS if the accumulated patch exceeds this number of operations
+	TRANSIENT_MAX_PATCH_OPERATIONS = 64
 )
 
 type ObjectController interface {
-	Create(name string, podUUID string, spec *threeAPI.ObjectSpec) (string, error)
+	Create(name string, podUUID string, spec *threeAPI.ObjectSpec, acl *threeAPI.ObjectACL) (string, error)
 	Update(uuid string, podUUID string, spec *threeAPI.ObjectSpec) error
+	Patch(uuid string, podUUID string, patch []threeAPI.PatchOperation, transient bool) error
+	UpdateTransform(uuid string, podUUID string, transform *threeAPI.ObjectTransform, transient bool) error
 	Get(uuid string, podUUID string) (*threeAPI.Object, error)
+	SetACL(uuid string, podUUID string, acl *threeAPI.ObjectACL) error
+	List(center *threeAPI.Vector3, radius float64, podUUID string) ([]*threeAPI.Object, error)
 	Delete(uuid string, podUUID string) error
 
-	ReceiveSpreadEvent(uuid string) error
+	// DealLocalResource collects the object if its parent pod is removed.
+	DealLocalResource(raw []byte) (bool, error)
+	// DealLocalIndex removes the stale entries from the index of the objects stored in the local node.
+	DealLocalIndex(raw []byte) (bool, error)
+	// Populate shows objects around the position on the frontend, it is called when the node is moved.
+	Populate(center *threeAPI.Vector3, radius float64) error
+	ReceiveSpreadEvent(event *messaging.SpreadObject) error
+
+	// Interact routes the event from the local frontend to the pod owning the object.
+	Interact(event *threeAPI.InteractionEvent) error
+	// DeliverInteraction sends the event to the containers of the pod running on this node if the account of
+	// the event has the permission. The account should be proved by the caller, not taken from the message.
+	DeliverInteraction(podUUID string, event *threeAPI.InteractionEvent) error
 }
 
 type objectControllerImpl struct {
 	// KVSs
 	objectKVS kvs.ObjectKVS
-	// frontend driver
-	frontendDriver fd.FrontendDriver
+	// cache of the objects shown on the frontend
+	scene *scene
 	// messaging drivers
-	messagingDriver messaging.ThreeMessagingDriver
+	messagingDriver md.ThreeMessagingDriver
+	// api driver to call applications
+	threeDriver apiDriver.ThreeDriver
 	// controllers
 	nodeCtrl coreController.NodeController
 	podCtrl  coreController.PodController
+
+	// latest objects known by this node to apply transient updates, the objects embedded in the spread messages
+	// are cached only if they follow the cached generation, key: uuid
+	spreadMtx   sync.Mutex
+	spreadCache map[string]*spreadCacheEntry
+
+	// transient updates accumulated on this node since the generation written to the KVS, key: uuid
+	transientMtx sync.Mutex
+	transients   map[string]*transientState
+}
+
+type spreadCacheEntry struct {
+	object *threeAPI.Object
+	// the object is read from the KVS, not from the message of other nodes
+	verified bool
+}
+
+type transientState struct {
+	generation uint64
+	update     *threeAPI.TransientUpdate
 }
 
-func NewObjectController(objectKVS kvs.ObjectKVS, frontendDriver fd.FrontendDriver, messagingDriver messaging.ThreeMessagingDriver, nodeCtrl coreController.NodeController, podCtrl coreController.PodController) ObjectController {
+func NewObjectController(ctx context.Context, objectKVS kvs.ObjectKVS, frontendDriver fd.FrontendDriver, messagingDriver md.ThreeMessagingDriver, threeDriver apiDriver.ThreeDriver, nodeCtrl coreController.NodeController, podCtrl coreController.PodController) ObjectController {
 	return &objectControllerImpl{
 		objectKVS:       objectKVS,
-		frontendDriver:  frontendDriver,
+		scene:           newScene(ctx, frontendDriver, nodeCtrl),
 		messagingDriver: messagingDriver,
+		threeDriver:     threeDriver,
 		nodeCtrl:        nodeCtrl,
 		podCtrl:         podCtrl,
+		spreadCache:     make(map[string]*spreadCacheEntry),
+		transients:      make(map[string]*transientState),
 	}
 }
 
-func (impl *objectControllerImpl) Create(name string, podUUID string, spec *threeAPI.ObjectSpec) (string, error) {
+func (impl *objectControllerImpl) Create(name string, podUUID string, spec *threeAPI.ObjectSpec, acl *threeAPI.ObjectACL) (string, error) {
 	pod, err := impl.podCtrl.GetPodData(podUUID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get pod data: %w", err)
@@ -71,53 +127,150 @@ func (impl *objectControllerImpl) Create(name string, podUUID string, spec *thre
 			Owner:       pod.Meta.Owner,
 			CreatorNode: impl.nodeCtrl.GetNid(),
 			Uuid:        threeAPI.GenerateObjectUUID(),
+			// the object is removed after the pod is removed
+			Parent: podUUID,
 		},
-		Spec: spec,
+		Spec:       spec,
+		ACL:        acl,
+		Generation: 1,
 	}
 
 	if err := impl.objectKVS.Create(obj); err != nil {
 		return "", fmt.Errorf("failed to create object: %w", err)
 	}
 
-	if err := impl.messagingDriver.SpreadObject(obj.Meta.Uuid, spec.Position, 1000); err != nil {
-		log.Printf("failed to spread object: name=%s, uuid=%s: %s\n", name, obj.Meta.Uuid, err.Error())
-	}
-	// colonio spread post is not send event to myself currently, so call ReceiveSpreadEvent directly.
-	go impl.ReceiveSpreadEvent(obj.Meta.Uuid)
+	impl.spread(obj, obj, nil)
 
 	return obj.Meta.Uuid, nil
 }
 
 func (impl *objectControllerImpl) Update(uuid string, podUUID string, spec *threeAPI.ObjectSpec) error {
-	pod, err := impl.podCtrl.GetPodData(podUUID)
+	obj, err := impl.getPermitted(uuid, podUUID, threeAPI.PermissionWrite)
 	if err != nil {
-		return fmt.Errorf("failed to get pod data: %w", err)
+		return err
 	}
 
-	obj, err := impl.objectKVS.Get(uuid)
-	if err != nil || obj == nil {
-		return fmt.Errorf("failed to get object data: %w", err)
+	obj.Spec = spec
+	obj.Generation++
+	if err := impl.objectKVS.Update(obj); err != nil {
+		return fmt.Errorf("failed to update object: %w", err)
+	}
+
+	impl.spread(obj, obj, nil)
+
+	return nil
+}
+
+func (impl *objectControllerImpl) Patch(uuid string, podUUID string, patch []threeAPI.PatchOperation, transient bool) error {
+	return impl.applyUpdate(uuid, podUUID, &threeAPI.TransientUpdate{
+		Patch: patch,
+	}, transient)
+}
+
+func (impl *objectControllerImpl) UpdateTransform(uuid string, podUUID string, transform *threeAPI.ObjectTransform, transient bool) error {
+	if transform == nil {
+		return fmt.Errorf("transform should be specified")
 	}
+	return impl.applyUpdate(uuid, podUUID, &threeAPI.TransientUpdate{
+		Transform: transform,
+	}, transient)
+}
+
+// apply the update to the object, write it to the KVS or spread it without writing if transient. Transient updates
+// are accumulated on this node and spread as one update based on the generation in the KVS, so that receivers
+// get the same result as this node. They are written to the KVS if they can not be accumulated.
+func (impl *objectControllerImpl) applyUpdate(uuid string, podUUID string, update *threeAPI.TransientUpdate, transient bool) error {
+	impl.transientMtx.Lock()
+	defer impl.transientMtx.Unlock()
 
-	if obj.Meta.Owner != pod.Meta.Owner {
-		return fmt.Errorf("object is not owned by pod owner")
+	obj, err := impl.getPermitted(uuid, podUUID, threeAPI.PermissionWrite)
+	if err != nil {
+		return err
 	}
 
-	obj.Spec = spec
-	if err := impl.objectKVS.Update(obj); err != nil {
-		return fmt.Errorf("failed to update object: %w", err)
+	if state, ok := impl.transients[uuid]; ok && state.generation == obj.Generation {
+		merged := state.update.Merge(update)
+		if transient && merged != nil && len(merged.Patch) <= TRANSIENT_MAX_PATCH_OPERATIONS {
+			update = merged
+		} else if !transient {
+			// the persistent update overwrites the transient updates as receivers do
+			delete(impl.transients, uuid)
+		} else {
+			// write the accumulated updates to the KVS
+			obj.Spec, err = state.update.Apply(obj.Spec)
+			if err != nil {
+				return fmt.Errorf("failed to apply transient update: %w", err)
+			}
+			transient = false
+		}
 	}
 
-	if err := impl.messagingDriver.SpreadObject(obj.Meta.Uuid, spec.Position, 1000); err != nil {
-		log.Printf("failed to spread object: name=%s, uuid=%s: %s\n", obj.Meta.Name, obj.Meta.Uuid, err.Error())
+	spec, err := update.Apply(obj.Spec)
+	if err != nil {
+		return fmt.Errorf("failed to apply update: %w", err)
+	}
+	updated := *obj
+	updated.Spec = spec
+	// check the updated object even if it is not stored
+	if err := updated.Validate(); err != nil {
+		return fmt.Errorf("updated object is invalid: %w", err)
+	}
+
+	// receivers apply the transient update to the object stored in the KVS
+	if transient {
+		impl.transients[uuid] = &transientState{
+			generation: obj.Generation,
+			update:     update,
+		}
+		impl.spread(obj, &updated, update)
+		return nil
 	}
-	// colonio spread post is not send event to myself currently, so call ReceiveSpreadEvent directly.
-	go impl.ReceiveSpreadEvent(obj.Meta.Uuid)
+
+	updated.Generation++
+	if err := impl.objectKVS.Update(&updated); err != nil {
+		return fmt.Errorf("failed to update object: %w", err)
+	}
+	delete(impl.transients, uuid)
+	impl.spread(&updated, &updated, nil)
 
 	return nil
 }
 
+// Get returns the object applied the transient updates made on this node
 func (impl *objectControllerImpl) Get(uuid string, podUUID string) (*threeAPI.Object, error) {
+	obj, err := impl.getPermitted(uuid, podUUID, threeAPI.PermissionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	impl.transientMtx.Lock()
+	defer impl.transientMtx.Unlock()
+	if state, ok := impl.transients[uuid]; ok && state.generation == obj.Generation {
+		obj.Spec, err = state.update.Apply(obj.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply transient update: %w", err)
+		}
+	}
+
+	return obj, nil
+}
+
+func (impl *objectControllerImpl) SetACL(uuid string, podUUID string, acl *threeAPI.ObjectACL) error {
+	obj, err := impl.getPermitted(uuid, podUUID, threeAPI.PermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	obj.ACL = acl
+	obj.Generation++
+	if err := impl.objectKVS.Update(obj); err != nil {
+		return fmt.Errorf("failed to update object: %w", err)
+	}
+	return nil
+}
+
+// getPermitted returns the object if the pod has the permission for it
+func (impl *objectControllerImpl) getPermitted(uuid string, podUUID string, permission threeAPI.Permission) (*threeAPI.Object, error) {
 	pod, err := impl.podCtrl.GetPodData(podUUID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pod data: %w", err)
@@ -125,57 +278,340 @@ func (impl *objectControllerImpl) Get(uuid string, podUUID string) (*threeAPI.Ob
 
 	obj, err := impl.objectKVS.Get(uuid)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get object: %w", err)
+		return nil, fmt.Errorf("failed to get object data: %w", err)
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("object not found")
 	}
 
-	if obj.Meta.Owner != pod.Meta.Owner {
-		return nil, fmt.Errorf("object is not owned by pod owner")
+	if !obj.IsAllowed(permission, pod.Meta.Owner, podUUID) {
+		return nil, fmt.Errorf("the pod does not have %s permission for the object", permission)
 	}
 
 	return obj, nil
 }
 
 func (impl *objectControllerImpl) Delete(uuid string, podUUID string) error {
+	obj, err := impl.getPermitted(uuid, podUUID, threeAPI.PermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	if err := impl.objectKVS.Delete(uuid); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	impl.transientMtx.Lock()
+	delete(impl.transients, uuid)
+	impl.transientMtx.Unlock()
+
+	impl.spreadDeletion(obj)
+
+	return nil
+}
+
+func (impl *objectControllerImpl) List(center *threeAPI.Vector3, radius float64, podUUID string) ([]*threeAPI.Object, error) {
 	pod, err := impl.podCtrl.GetPodData(podUUID)
 	if err != nil {
-		return fmt.Errorf("failed to get pod data: %w", err)
+		return nil, fmt.Errorf("failed to get pod data: %w", err)
 	}
 
-	obj, err := impl.objectKVS.Get(uuid)
+	if radius > threeAPI.OBJECT_LIST_MAX_RADIUS {
+		return nil, fmt.Errorf("radius should be %d meters or less", threeAPI.OBJECT_LIST_MAX_RADIUS)
+	}
+
+	objects, err := impl.objectKVS.List(center, radius)
 	if err != nil {
-		return fmt.Errorf("failed to get object data: %w", err)
+		return nil, fmt.Errorf("failed to list objects: %w", err)
 	}
 
-	if obj.Meta.Owner != pod.Meta.Owner {
-		return fmt.Errorf("object is not owned by pod owner")
+	readable := make([]*threeAPI.Object, 0, len(objects))
+	for _, obj := range objects {
+		if obj.IsAllowed(threeAPI.PermissionRead, pod.Meta.Owner, podUUID) {
+			readable = append(readable, obj)
+		}
 	}
+	return readable, nil
+}
 
-	if err := impl.objectKVS.Delete(uuid); err != nil {
-		return fmt.Errorf("failed to delete object: %w", err)
+func (impl *objectControllerImpl) DealLocalResource(raw []byte) (bool, error) {
+	obj := &threeAPI.Object{}
+	if err := json.Unmarshal(raw, obj); err != nil {
+		return true, fmt.Errorf("failed to unmarshal object record: %w", err)
+	}
+
+	if err := obj.Validate(); err != nil {
+		return true, fmt.Errorf("failed to validate object record: %w", err)
+	}
+
+	// objects created before the parent parameter was introduced are kept
+	if len(obj.Meta.Parent) == 0 {
+		return false, nil
 	}
 
-	if err := impl.messagingDriver.SpreadObject(obj.Meta.Uuid, obj.Spec.Position, 1000*2); err != nil {
-		log.Printf("failed to spread object: name=%s, uuid=%s: %s\n", obj.Meta.Name, obj.Meta.Uuid, err.Error())
+	pod, err := impl.podCtrl.GetPodData(obj.Meta.Parent)
+	if err != nil && !errors.Is(err, coreKVS.ErrPodNotFound) {
+		return false, fmt.Errorf("failed to get parent pod data: %w", err)
+	}
+	if err == nil && len(pod.Meta.DeletionTimestamp) == 0 {
+		return false, nil
+	}
+
+	// delete via the KVS to remove the index entry too
+	if err := impl.objectKVS.Delete(obj.Meta.Uuid); err != nil {
+		return false, fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	impl.spreadDeletion(obj)
+
+	return false, nil
+}
+
+func (impl *objectControllerImpl) DealLocalIndex(raw []byte) (bool, error) {
+	if err := impl.objectKVS.RepairIndex(raw); err != nil {
+		return false, fmt.Errorf("failed to repair the index of objects: %w", err)
+	}
+	return false, nil
+}
+
+func (impl *objectControllerImpl) Populate(center *threeAPI.Vector3, radius float64) error {
+	objects, err := impl.objectKVS.List(center, radius)
+	if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	for _, obj := range objects {
+		if err := impl.scene.apply(obj, true); err != nil {
+			return fmt.Errorf("failed to put object: %w", err)
+		}
+	}
+	return nil
+}
+
+// spread notifies the update to the nodes around the object and the local frontend,
+// base is the object stored in the KVS and updated is the object after applying the transient update.
+func (impl *objectControllerImpl) spread(base, updated *threeAPI.Object, transient *threeAPI.TransientUpdate) {
+	// the object without position is not spread to other nodes
+	if updated.Spec.Position != nil {
+		if err := impl.messagingDriver.SpreadObject(base, updated.Spec.Position, updated.Spec.GetSpreadRadius(), transient); err != nil {
+			log.Printf("failed to spread object: name=%s, uuid=%s: %s\n", base.Meta.Name, base.Meta.Uuid, err.Error())
+		}
 	}
 	// colonio spread post is not send event to myself currently, so call ReceiveSpreadEvent directly.
-	go impl.ReceiveSpreadEvent(obj.Meta.Uuid)
+	go impl.ReceiveSpreadEvent(&messaging.SpreadObject{
+		UUID:       base.Meta.Uuid,
+		Object:     base,
+		Generation: base.Generation,
+		Transient:  transient,
+	})
+}
+
+func (impl *objectControllerImpl) spreadDeletion(obj *threeAPI.Object) {
+	// spread wider than updates to reach the nodes moved from the object
+	if obj.Spec.Position != nil {
+		if err := impl.messagingDriver.SpreadDeletion(obj.Meta.Uuid, obj.Spec.Position, obj.Spec.GetSpreadRadius()*2); err != nil {
+			log.Printf("failed to spread object: name=%s, uuid=%s: %s\n", obj.Meta.Name, obj.Meta.Uuid, err.Error())
+		}
+	}
+	go impl.ReceiveSpreadEvent(&messaging.SpreadObject{
+		UUID:    obj.Meta.Uuid,
+		Deleted: true,
+	})
+}
+
+func (impl *objectControllerImpl) ReceiveSpreadEvent(event *messaging.SpreadObject) error {
+	if event.Deleted {
+		impl.spreadMtx.Lock()
+		delete(impl.spreadCache, event.UUID)
+		impl.spreadMtx.Unlock()
+
+		impl.scene.remove(event.UUID)
+		return nil
+	}
+
+	obj, verified, err := impl.getSpreadBase(event)
+	if err != nil {
+		return err
+	}
+
+	if obj == nil {
+		impl.scene.remove(event.UUID)
+		return nil
+	}
+
+	// the transient update is overwritten if the object was updated after it
+	if event.Transient != nil && obj.Generation == event.Generation {
+		updated := *obj
+		updated.Spec, err = event.Transient.Apply(obj.Spec)
+		if err != nil {
+			return fmt.Errorf("failed to apply transient update: %s", err.Error())
+		}
+		obj = &updated
+	}
+
+	if err := impl.scene.apply(obj, verified); err != nil {
+		return fmt.Errorf("failed to put object: %s", err.Error())
+	}
 
 	return nil
 }
 
-func (impl *objectControllerImpl) ReceiveSpreadEvent(uuid string) error {
-	obj, err := impl.objectKVS.Get(uuid)
+// getSpreadBase returns the object the event is based on and true if it is read from the KVS. The KVS is read only
+// if the object is not embedded in the event or the generation is skipped, because the embedded object might be
+// forged. The embedded object is used without reading the KVS in these cases:
+//   - this node does not know the object, it is not cached not to hide the object read from the KVS later.
+//   - it is the next generation of the cached object and the owner is not changed, it is cached not to read
+//     the KVS for the next update, and the cache is checked with the KVS if another object of the same
+//     generation is embedded.
+func (impl *objectControllerImpl) getSpreadBase(event *messaging.SpreadObject) (*threeAPI.Object, bool, error) {
+	impl.spreadMtx.Lock()
+	cached := impl.spreadCache[event.UUID]
+	impl.spreadMtx.Unlock()
+
+	generation := event.Generation
+	embedded := event.Object
+	if embedded != nil {
+		if err := embedded.Validate(); err != nil || embedded.Meta.Uuid != event.UUID {
+			log.Printf("invalid object embedded in spreadObject message: uuid=%s", event.UUID)
+			embedded = nil
+		} else {
+			generation = embedded.Generation
+		}
+	}
+
+	switch {
+	case embedded != nil && cached == nil:
+		return embedded, false, nil
+
+	case embedded != nil && generation == cached.object.Generation+1 && isSameOwnership(cached.object, embedded):
+		impl.spreadMtx.Lock()
+		defer impl.spreadMtx.Unlock()
+		// the cache might be updated while checking
+		if latest := impl.spreadCache[event.UUID]; latest == cached {
+			impl.spreadCache[event.UUID] = &spreadCacheEntry{
+				object: embedded,
+			}
+		}
+		return embedded, false, nil
+
+	// the event based on the older or the same generation does not need the KVS
+	case cached != nil && cached.object.Generation > generation:
+		return cached.object, cached.verified, nil
+
+	case cached != nil && cached.object.Generation == generation:
+		if cached.verified || embedded == nil || isSameObject(cached.object, embedded) {
+			return cached.object, cached.verified, nil
+		}
+	}
+
+	obj, err := impl.objectKVS.Get(event.UUID)
 	if err != nil {
-		return fmt.Errorf("failed to get object in spreadObject: %s", err.Error())
+		return nil, false, fmt.Errorf("failed to get object in spreadObject: %s", err.Error())
+	}
+
+	impl.spreadMtx.Lock()
+	defer impl.spreadMtx.Unlock()
+	if obj == nil {
+		delete(impl.spreadCache, event.UUID)
+		return nil, true, nil
+	}
+	impl.spreadCache[event.UUID] = &spreadCacheEntry{
+		object:   obj,
+		verified: true,
+	}
+	return obj, true, nil
+}
+
+// isSameOwnership returns true if the fields deciding the permissions except the ACL are not changed
+func isSameOwnership(prev, next *threeAPI.Object) bool {
+	return prev.Meta.Owner == next.Meta.Owner &&
+		prev.Meta.Parent == next.Meta.Parent &&
+		prev.Meta.CreatorNode == next.Meta.CreatorNode
+}
+
+func isSameObject(a, b *threeAPI.Object) bool {
+	rawA, errA := json.Marshal(a)
+	rawB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(rawA, rawB)
+}
+
+func (impl *objectControllerImpl) Interact(event *threeAPI.InteractionEvent) error {
+	if err := event.Validate(); err != nil {
+		return fmt.Errorf("invalid interaction event: %w", err)
+	}
+
+	obj, err := impl.objectKVS.Get(event.ObjectUUID)
+	if err != nil {
+		return fmt.Errorf("failed to get object data: %w", err)
+	}
+	if obj == nil {
+		return fmt.Errorf("object not found")
+	}
+
+	// users are not pods, so pod level permission is not allowed
+	if !obj.IsAllowed(threeAPI.PermissionInteract, event.Account, "") {
+		return fmt.Errorf("the user does not have interact permission for the object")
+	}
+
+	if len(obj.Meta.Parent) == 0 {
+		return fmt.Errorf("the object does not have the parent pod")
+	}
+
+	pod, err := impl.podCtrl.GetPodData(obj.Meta.Parent)
+	if err != nil {
+		return fmt.Errorf("failed to get pod data: %w", err)
+	}
+
+	if len(pod.Status.RunningNode) == 0 {
+		return fmt.Errorf("the pod is not running")
+	}
+
+	if pod.Status.RunningNode == impl.nodeCtrl.GetNid() {
+		return impl.DeliverInteraction(pod.Meta.Uuid, event)
+	}
+
+	return impl.messagingDriver.Interact(pod.Status.RunningNode, pod.Meta.Uuid, event)
+}
+
+func (impl *objectControllerImpl) DeliverInteraction(podUUID string, event *threeAPI.InteractionEvent) error {
+	if event == nil {
+		return fmt.Errorf("event should be specified")
+	}
+	if err := event.Validate(); err != nil {
+		return fmt.Errorf("invalid interaction event: %w", err)
+	}
+
+	// the permission is checked again on the node running the pod because the sender might not check it
+	obj, err := impl.objectKVS.Get(event.ObjectUUID)
+	if err != nil {
+		return fmt.Errorf("failed to get object data: %w", err)
+	}
+	if obj == nil {
+		return fmt.Errorf("object not found")
+	}
+	if obj.Meta.Parent != podUUID {
+		return fmt.Errorf("the object is not owned by the pod")
+	}
+	if !obj.IsAllowed(threeAPI.PermissionInteract, event.Account, "") {
+		return fmt.Errorf("the user does not have interact permission for the object")
+	}
+
+	pod, err := impl.podCtrl.GetPodData(podUUID)
+	if err != nil {
+		return fmt.Errorf("failed to get pod data: %w", err)
+	}
+
+	// the pod might be migrated after the event was sent
+	if pod.Status.RunningNode != impl.nodeCtrl.GetNid() {
+		return fmt.Errorf("the pod is not running on this node")
 	}
 
-	if obj != nil {
-		if err := impl.frontendDriver.ApplyObjects([]threeAPI.Object{*obj}); err != nil {
-			return fmt.Errorf("failed to put object: %s", err.Error())
+	for _, status := range pod.Status.ContainerStatuses {
+		if len(status.ContainerID) == 0 || status.State.Running == nil {
+			continue
 		}
-	} else {
-		if err := impl.frontendDriver.DeleteObjects([]string{uuid}); err != nil {
-			return fmt.Errorf("failed to delete object: %s", err.Error())
+		if err := impl.threeDriver.Interact(status.ContainerID, event); err != nil {
+			log.Printf("failed to send interaction event to the container (%s): %s", status.ContainerID, err.Error())
 		}
 	}
 
@@ -0,0 +1,396 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package three
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	coreAPI "github.com/llamerada-jp/oinari/api/core"
+	threeAPI "github.com/llamerada-jp/oinari/api/three"
+	coreController "github.com/llamerada-jp/oinari/node/controller"
+	fd "github.com/llamerada-jp/oinari/node/frontend/driver"
+	coreKVS "github.com/llamerada-jp/oinari/node/kvs"
+	kvs "github.com/llamerada-jp/oinari/node/kvs/three"
+	messaging "github.com/llamerada-jp/oinari/node/messaging/three"
+	md "github.com/llamerada-jp/oinari/node/messaging/three/driver"
+	"github.com/llamerada-jp/oinari/node/misc"
+	"github.com/llamerada-jp/oinari/node/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// frontendDriverStub records the changes sent to the frontend
+type frontendDriverStub struct {
+	fd.FrontendDriver
+	mtx     sync.Mutex
+	applied [][]threeAPI.Object
+	deleted [][]string
+	// returned by the methods sending the changes if it is not nil
+	err error
+}
+
+func (stub *frontendDriverStub) ApplyObjects(objects []threeAPI.Object) error {
+	stub.mtx.Lock()
+	defer stub.mtx.Unlock()
+	if stub.err != nil {
+		return stub.err
+	}
+	stub.applied = append(stub.applied, objects)
+	return nil
+}
+
+func (stub *frontendDriverStub) DeleteObjects(uuids []string) error {
+	stub.mtx.Lock()
+	defer stub.mtx.Unlock()
+	if stub.err != nil {
+		return stub.err
+	}
+	stub.deleted = append(stub.deleted, uuids)
+	return nil
+}
+
+func (stub *frontendDriverStub) setError(err error) {
+	stub.mtx.Lock()
+	defer stub.mtx.Unlock()
+	stub.err = err
+}
+
+// pop returns the recorded changes and clears them
+func (stub *frontendDriverStub) pop() ([][]threeAPI.Object, [][]string) {
+	stub.mtx.Lock()
+	defer stub.mtx.Unlock()
+	applied := stub.applied
+	deleted := stub.deleted
+	stub.applied = nil
+	stub.deleted = nil
+	return applied, deleted
+}
+
+// nodeControllerStub returns the position of the local node, other methods are not used
+type nodeControllerStub struct {
+	coreController.NodeController
+	nid      string
+	position *coreAPI.Vector3
+}
+
+func (stub *nodeControllerStub) GetNid() string {
+	return stub.nid
+}
+
+func (stub *nodeControllerStub) GetPosition() *coreAPI.Vector3 {
+	return stub.position
+}
+
+// podControllerStub returns the pods, other methods are not used
+type podControllerStub struct {
+	coreController.PodController
+	pods map[string]*coreAPI.Pod
+	// returned by GetPodData if it is not nil
+	err error
+}
+
+func (stub *podControllerStub) GetPodData(uuid string) (*coreAPI.Pod, error) {
+	if stub.err != nil {
+		return nil, stub.err
+	}
+	pod, ok := stub.pods[uuid]
+	if !ok {
+		return nil, fmt.Errorf("failed to get pod data: %w", coreKVS.ErrPodNotFound)
+	}
+	return pod, nil
+}
+
+// messagingDriverStub records the spread deletions, the updates are not recorded
+type messagingDriverStub struct {
+	md.ThreeMessagingDriver
+	mtx     sync.Mutex
+	deleted []string
+}
+
+func (stub *messagingDriverStub) SpreadObject(obj *threeAPI.Object, position *threeAPI.Vector3, r float64, transient *threeAPI.TransientUpdate) error {
+	return nil
+}
+
+func (stub *messagingDriverStub) SpreadDeletion(uuid string, position *threeAPI.Vector3, r float64) error {
+	stub.mtx.Lock()
+	defer stub.mtx.Unlock()
+	stub.deleted = append(stub.deleted, uuid)
+	return nil
+}
+
+// objectKVSCounter counts the objects read from the KVS
+type objectKVSCounter struct {
+	kvs.ObjectKVS
+	mtx   sync.Mutex
+	count int
+}
+
+func (counter *objectKVSCounter) Get(uuid string) (*threeAPI.Object, error) {
+	counter.mtx.Lock()
+	counter.count++
+	counter.mtx.Unlock()
+	return counter.ObjectKVS.Get(uuid)
+}
+
+func (counter *objectKVSCounter) pop() int {
+	counter.mtx.Lock()
+	defer counter.mtx.Unlock()
+	count := counter.count
+	counter.count = 0
+	return count
+}
+
+type objectControllerTest struct {
+	suite.Suite
+	cancel    context.CancelFunc
+	objectKVS kvs.ObjectKVS
+	podCtrl   *podControllerStub
+	messaging *messagingDriverStub
+	impl      *objectControllerImpl
+}
+
+func NewObjectControllerTest() suite.TestingSuite {
+	return &objectControllerTest{}
+}
+
+func (test *objectControllerTest) SetupTest() {
+	colMock := mock.NewColonioMock()
+	test.objectKVS = kvs.NewObjectKVS(colMock)
+	test.podCtrl = &podControllerStub{
+		pods: make(map[string]*coreAPI.Pod),
+	}
+	test.messaging = &messagingDriverStub{}
+
+	var ctx context.Context
+	ctx, test.cancel = context.WithCancel(context.Background())
+	test.impl = NewObjectController(ctx, test.objectKVS, &frontendDriverStub{}, test.messaging, nil,
+		&nodeControllerStub{nid: colMock.LocalNid}, test.podCtrl).(*objectControllerImpl)
+}
+
+func (test *objectControllerTest) TearDownTest() {
+	test.cancel()
+}
+
+func (test *objectControllerTest) addPod(deleting bool) string {
+	pod := &coreAPI.Pod{
+		Meta: &coreAPI.ObjectMeta{
+			Type:  coreAPI.ResourceTypePod,
+			Name:  "pod",
+			Owner: "owner",
+			Uuid:  coreAPI.GeneratePodUuid(),
+		},
+	}
+	if deleting {
+		pod.Meta.DeletionTimestamp = misc.GetTimestamp()
+	}
+	test.podCtrl.pods[pod.Meta.Uuid] = pod
+	return pod.Meta.Uuid
+}
+
+// createObject writes the object having the parent to the KVS and returns the raw record of it
+func (test *objectControllerTest) createObject(name, parent string) (*threeAPI.Object, []byte) {
+	obj := &threeAPI.Object{
+		Meta: &coreAPI.ObjectMeta{
+			Type:        threeAPI.ResourceTypeThreeObject,
+			Name:        name,
+			Owner:       "owner",
+			CreatorNode: "012345678901234567890123456789ab",
+			Uuid:        threeAPI.GenerateObjectUUID(),
+			Parent:      parent,
+		},
+		Spec: &threeAPI.ObjectSpec{
+			Parts:     []*threeAPI.PartSpec{},
+			Materials: []*threeAPI.MaterialSpec{},
+			Maps:      []*threeAPI.TextureSpec{},
+			Position:  &threeAPI.Vector3{X: 139.7671, Y: 35.6812},
+		},
+	}
+	test.NoError(test.objectKVS.Create(obj))
+	raw, err := json.Marshal(obj)
+	test.NoError(err)
+	return obj, raw
+}
+
+func (test *objectControllerTest) TestDealLocalResource() {
+	/// normal pattern: the objects of the alive pod are kept
+	alive, raw := test.createObject("alive", test.addPod(false))
+	deleteFlg, err := test.impl.DealLocalResource(raw)
+	test.NoError(err)
+	test.False(deleteFlg)
+
+	// the objects created before the parent was introduced are kept
+	legacy, raw := test.createObject("legacy", "")
+	deleteFlg, err = test.impl.DealLocalResource(raw)
+	test.NoError(err)
+	test.False(deleteFlg)
+
+	/// normal pattern: the orphans are deleted via the KVS and the deletion is spread
+	orphan, raw := test.createObject("orphan", coreAPI.GeneratePodUuid())
+	deleteFlg, err = test.impl.DealLocalResource(raw)
+	test.NoError(err)
+	test.False(deleteFlg)
+
+	deleting, raw := test.createObject("deleting", test.addPod(true))
+	deleteFlg, err = test.impl.DealLocalResource(raw)
+	test.NoError(err)
+	test.False(deleteFlg)
+
+	for _, obj := range []*threeAPI.Object{alive, legacy} {
+		got, err := test.objectKVS.Get(obj.Meta.Uuid)
+		test.NoError(err)
+		test.NotNil(got, obj.Meta.Name)
+	}
+	for _, obj := range []*threeAPI.Object{orphan, deleting} {
+		got, err := test.objectKVS.Get(obj.Meta.Uuid)
+		test.NoError(err)
+		test.Nil(got, obj.Meta.Name)
+	}
+	test.ElementsMatch([]string{orphan.Meta.Uuid, deleting.Meta.Uuid}, test.messaging.deleted)
+
+	// the deleted objects are removed from the index
+	objects, err := test.objectKVS.List(alive.Spec.Position, 100)
+	test.NoError(err)
+	names := make([]string, 0)
+	for _, obj := range objects {
+		names = append(names, obj.Meta.Name)
+	}
+	test.ElementsMatch([]string{"alive", "legacy"}, names)
+
+	/// abnormal: the object is kept if the parent can't be checked
+	unknown, raw := test.createObject("unknown", test.addPod(true))
+	test.podCtrl.err = fmt.Errorf("network error")
+	deleteFlg, err = test.impl.DealLocalResource(raw)
+	test.Error(err)
+	test.False(deleteFlg)
+	got, err := test.objectKVS.Get(unknown.Meta.Uuid)
+	test.NoError(err)
+	test.NotNil(got)
+
+	/// abnormal: require deletion if the object is broken
+	deleteFlg, err = test.impl.DealLocalResource([]byte(""))
+	test.Error(err)
+	test.True(deleteFlg)
+	deleteFlg, err = test.impl.DealLocalResource([]byte("{}"))
+	test.Error(err)
+	test.True(deleteFlg)
+}
+
+func (test *objectControllerTest) TestReceiveSpreadEvent() {
+	counter := &objectKVSCounter{ObjectKVS: test.objectKVS}
+	test.impl.objectKVS = counter
+	stored, _ := test.createObject("object", "")
+	uuid := stored.Meta.Uuid
+	// next returns the object of the next generation embedded in the spread message
+	next := func(prev *threeAPI.Object, x float64) *threeAPI.Object {
+		obj := *prev
+		obj.Spec = &threeAPI.ObjectSpec{
+			Parts:     []*threeAPI.PartSpec{},
+			Materials: []*threeAPI.MaterialSpec{},
+			Maps:      []*threeAPI.TextureSpec{},
+			Position:  &threeAPI.Vector3{X: x, Y: prev.Spec.Position.Y},
+		}
+		obj.Generation = prev.Generation + 1
+		return &obj
+	}
+
+	/// normal pattern: the embedded object of unknown object is used without reading the KVS
+	test.NoError(test.impl.ReceiveSpreadEvent(&messaging.SpreadObject{
+		UUID:       uuid,
+		Object:     stored,
+		Generation: stored.Generation,
+	}))
+	test.Equal(0, counter.pop())
+	test.Empty(test.impl.spreadCache)
+
+	// the KVS is read if the object is not embedded, it is cached
+	test.NoError(test.impl.ReceiveSpreadEvent(&messaging.SpreadObject{
+		UUID:       uuid,
+		Generation: stored.Generation,
+	}))
+	test.Equal(1, counter.pop())
+	test.True(test.impl.spreadCache[uuid].verified)
+
+	/// normal pattern: the updates of the next generations are used without reading the KVS
+	obj := stored
+	for i := 1; i <= 3; i++ {
+		obj = next(obj, stored.Spec.Position.X+float64(i)*0.0001)
+		test.NoError(test.impl.ReceiveSpreadEvent(&messaging.SpreadObject{
+			UUID:       uuid,
+			Object:     obj,
+			Generation: obj.Generation,
+		}))
+		base, verified, err := test.impl.getSpreadBase(&messaging.SpreadObject{
+			UUID:       uuid,
+			Generation: obj.Generation,
+		})
+		test.NoError(err)
+		test.False(verified)
+		test.Equal(obj, base)
+	}
+	test.Equal(0, counter.pop())
+
+	/// abnormal: the KVS is read if the generation is skipped
+	test.NoError(test.impl.ReceiveSpreadEvent(&messaging.SpreadObject{
+		UUID:       uuid,
+		Object:     next(next(obj, 0), 0),
+		Generation: obj.Generation + 2,
+	}))
+	test.Equal(1, counter.pop())
+	test.True(test.impl.spreadCache[uuid].verified)
+	test.Equal(stored.Generation, test.impl.spreadCache[uuid].object.Generation)
+
+	/// abnormal: the KVS is read if the owner is changed
+	forged := next(stored, 0)
+	forged.Meta = &coreAPI.ObjectMeta{}
+	*forged.Meta = *stored.Meta
+	forged.Meta.Owner = "other"
+	test.NoError(test.impl.ReceiveSpreadEvent(&messaging.SpreadObject{
+		UUID:       uuid,
+		Object:     forged,
+		Generation: forged.Generation,
+	}))
+	test.Equal(1, counter.pop())
+
+	/// abnormal: the KVS is read if another object of the cached generation is embedded
+	obj = next(stored, 1)
+	test.NoError(test.impl.ReceiveSpreadEvent(&messaging.SpreadObject{
+		UUID:       uuid,
+		Object:     obj,
+		Generation: obj.Generation,
+	}))
+	test.NoError(test.impl.ReceiveSpreadEvent(&messaging.SpreadObject{
+		UUID:       uuid,
+		Object:     obj,
+		Generation: obj.Generation,
+	}))
+	test.Equal(0, counter.pop())
+	test.NoError(test.impl.ReceiveSpreadEvent(&messaging.SpreadObject{
+		UUID:       uuid,
+		Object:     next(stored, 2),
+		Generation: obj.Generation,
+	}))
+	test.Equal(1, counter.pop())
+	test.True(test.impl.spreadCache[uuid].verified)
+
+	// the cache is cleared by the deletion
+	test.NoError(test.impl.ReceiveSpreadEvent(&messaging.SpreadObject{
+		UUID:    uuid,
+		Deleted: true,
+	}))
+	test.Empty(test.impl.spreadCache)
+}
@@ -0,0 +1,251 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package three
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	threeAPI "github.com/llamerada-jp/oinari/api/three"
+	coreController "github.com/llamerada-jp/oinari/node/controller"
+	fd "github.com/llamerada-jp/oinari/node/frontend/driver"
+)
+
+const (
+	// interval to send the changes of the scene to the frontend, it is about a frame of the view
+	SCENE_FLUSH_INTERVAL = 50 * time.Millisecond
+	// deleted objects are remembered to drop stale updates delivered after the deletion
+	SCENE_DELETED_LIFETIME = 30 * time.Second
+)
+
+// scene is the cache of the objects shown on the frontend of this node. Changes are batched and sent to
+// the frontend every SCENE_FLUSH_INTERVAL.
+type scene struct {
+	mtx            sync.Mutex
+	frontendDriver fd.FrontendDriver
+	nodeCtrl       coreController.NodeController
+	// objects shown on the frontend, key: uuid
+	entries map[string]*sceneEntry
+	// time the object was deleted, key: uuid
+	deleted map[string]time.Time
+	// changes not sent to the frontend yet, key: uuid
+	applying map[string]*threeAPI.Object
+	deleting map[string]struct{}
+}
+
+type sceneEntry struct {
+	generation uint64
+	// the object is read from the KVS, not from the message of other nodes
+	verified bool
+	// hash of the object to drop duplicate updates
+	digest   [sha256.Size]byte
+	position *threeAPI.Vector3
+	radius   float64
+}
+
+func newScene(ctx context.Context, frontendDriver fd.FrontendDriver, nodeCtrl coreController.NodeController) *scene {
+	s := &scene{
+		frontendDriver: frontendDriver,
+		nodeCtrl:       nodeCtrl,
+		entries:        make(map[string]*sceneEntry),
+		deleted:        make(map[string]time.Time),
+		applying:       make(map[string]*threeAPI.Object),
+		deleting:       make(map[string]struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(SCENE_FLUSH_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.flush(); err != nil {
+					log.Printf("failed to flush the scene: %s", err.Error())
+				}
+			}
+		}
+	}()
+
+	return s
+}
+
+// apply queues the object to show on the frontend, it is dropped if it is stale, duplicate or out of the view.
+// verified is true if the object is read from the KVS, the object not verified does not replace the verified one
+// of the same or newer generation, and the verified object replaces the one not verified of any generation.
+func (s *scene) apply(obj *threeAPI.Object, verified bool) error {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to marshal object: %w", err)
+	}
+	uuid := obj.Meta.Uuid
+	digest := sha256.Sum256(raw)
+	radius := obj.Spec.GetSpreadRadius()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.deleted[uuid]; ok {
+		return nil
+	}
+
+	if !isInView(s.center(), obj.Spec.Position, radius) {
+		s.evict(uuid)
+		return nil
+	}
+
+	if entry, ok := s.entries[uuid]; ok {
+		if entry.digest == digest {
+			return nil
+		}
+		if entry.verified && !verified && obj.Generation <= entry.generation {
+			return nil
+		}
+		if entry.verified == verified && obj.Generation < entry.generation {
+			return nil
+		}
+	}
+
+	s.entries[uuid] = &sceneEntry{
+		generation: obj.Generation,
+		verified:   verified,
+		digest:     digest,
+		position:   obj.Spec.Position,
+		radius:     radius,
+	}
+	s.applying[uuid] = obj
+	delete(s.deleting, uuid)
+
+	return nil
+}
+
+// remove queues the deletion of the object, updates of the object delivered later are dropped.
+func (s *scene) remove(uuid string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.deleted[uuid] = time.Now()
+	s.evict(uuid)
+}
+
+// evict removes the object from the scene, it should be called with the lock
+func (s *scene) evict(uuid string) {
+	delete(s.applying, uuid)
+	if _, ok := s.entries[uuid]; !ok {
+		return
+	}
+	delete(s.entries, uuid)
+	s.deleting[uuid] = struct{}{}
+}
+
+// center returns the position of the node, nil if it is not set yet
+func (s *scene) center() *threeAPI.Vector3 {
+	return (*threeAPI.Vector3)(s.nodeCtrl.GetPosition())
+}
+
+// isInView returns true if the position is in the radius from the center
+func isInView(center, position *threeAPI.Vector3, radius float64) bool {
+	if center == nil || position == nil {
+		return true
+	}
+	return threeAPI.Distance(center, position) <= radius
+}
+
+func (s *scene) flush() error {
+	now := time.Now()
+
+	center := s.center()
+
+	s.mtx.Lock()
+	// evict objects drifted out of the view by moving the node
+	for uuid, entry := range s.entries {
+		if !isInView(center, entry.position, entry.radius) {
+			s.evict(uuid)
+		}
+	}
+
+	for uuid, deletedAt := range s.deleted {
+		if now.Sub(deletedAt) > SCENE_DELETED_LIFETIME {
+			delete(s.deleted, uuid)
+		}
+	}
+
+	applying := make([]threeAPI.Object, 0, len(s.applying))
+	for _, obj := range s.applying {
+		applying = append(applying, *obj)
+	}
+	deleting := make([]string, 0, len(s.deleting))
+	for uuid := range s.deleting {
+		deleting = append(deleting, uuid)
+	}
+	s.applying = make(map[string]*threeAPI.Object)
+	s.deleting = make(map[string]struct{})
+	s.mtx.Unlock()
+
+	var errs []error
+	if len(deleting) != 0 {
+		if err := s.frontendDriver.DeleteObjects(deleting); err != nil {
+			s.requeueDeleting(deleting)
+			errs = append(errs, fmt.Errorf("failed to delete objects: %w", err))
+		}
+	}
+	if len(applying) != 0 {
+		if err := s.frontendDriver.ApplyObjects(applying); err != nil {
+			s.requeueApplying(applying)
+			errs = append(errs, fmt.Errorf("failed to put objects: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// requeueDeleting queues the deletions failed to send again unless the objects are shown again after them
+func (s *scene) requeueDeleting(deleting []string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, uuid := range deleting {
+		if _, ok := s.entries[uuid]; ok {
+			continue
+		}
+		s.deleting[uuid] = struct{}{}
+	}
+}
+
+// requeueApplying queues the objects failed to send again unless they are updated or removed after them,
+// the entries keep the digest of the objects, so the same objects are not queued by apply.
+func (s *scene) requeueApplying(applying []threeAPI.Object) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for idx := range applying {
+		obj := &applying[idx]
+		uuid := obj.Meta.Uuid
+		if _, ok := s.entries[uuid]; !ok {
+			continue
+		}
+		if _, ok := s.applying[uuid]; ok {
+			continue
+		}
+		s.applying[uuid] = obj
+	}
+}
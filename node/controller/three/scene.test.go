@@ -0,0 +1,212 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package three
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	coreAPI "github.com/llamerada-jp/oinari/api/core"
+	threeAPI "github.com/llamerada-jp/oinari/api/three"
+	"github.com/stretchr/testify/suite"
+)
+
+type sceneTest struct {
+	suite.Suite
+	frontend *frontendDriverStub
+	nodeCtrl *nodeControllerStub
+}
+
+func NewSceneTest() suite.TestingSuite {
+	return &sceneTest{}
+}
+
+func (test *sceneTest) SetupTest() {
+	test.frontend = &frontendDriverStub{}
+	test.nodeCtrl = &nodeControllerStub{
+		position: &coreAPI.Vector3{X: 139.7671, Y: 35.6812},
+	}
+}
+
+// newScene returns the scene flushed only by calling flush
+func (test *sceneTest) newScene() *scene {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return newScene(ctx, test.frontend, test.nodeCtrl)
+}
+
+func (test *sceneTest) makeObject(name string, generation uint64, x float64) *threeAPI.Object {
+	return &threeAPI.Object{
+		Meta: &coreAPI.ObjectMeta{
+			Type:  threeAPI.ResourceTypeThreeObject,
+			Name:  name,
+			Owner: "owner",
+			Uuid:  name,
+		},
+		Generation: generation,
+		Spec: &threeAPI.ObjectSpec{
+			Parts:     []*threeAPI.PartSpec{},
+			Materials: []*threeAPI.MaterialSpec{},
+			Maps:      []*threeAPI.TextureSpec{},
+			Position:  &threeAPI.Vector3{X: x, Y: 35.6812},
+		},
+	}
+}
+
+// sent returns the names and generations of the objects sent in a batch and the deleted uuids
+func (test *sceneTest) sent() (map[string]uint64, []string) {
+	applied, deleted := test.frontend.pop()
+	objects := make(map[string]uint64)
+	test.LessOrEqual(len(applied), 1)
+	for _, batch := range applied {
+		for _, obj := range batch {
+			objects[obj.Meta.Name] = obj.Generation
+		}
+	}
+	uuids := make([]string, 0)
+	test.LessOrEqual(len(deleted), 1)
+	for _, batch := range deleted {
+		uuids = append(uuids, batch...)
+	}
+	return objects, uuids
+}
+
+func (test *sceneTest) TestBatch() {
+	s := test.newScene()
+
+	/// normal pattern: the changes are sent in a batch and the latest one of each object is sent
+	test.NoError(s.apply(test.makeObject("a", 1, 139.7671), true))
+	test.NoError(s.apply(test.makeObject("b", 1, 139.7672), false))
+	test.NoError(s.apply(test.makeObject("a", 2, 139.7673), true))
+	test.NoError(s.flush())
+	objects, deleted := test.sent()
+	test.Equal(map[string]uint64{"a": 2, "b": 1}, objects)
+	test.Empty(deleted)
+
+	// nothing is sent without changes
+	test.NoError(s.flush())
+	objects, deleted = test.sent()
+	test.Empty(objects)
+	test.Empty(deleted)
+
+	/// abnormal: duplicate and stale updates are dropped
+	test.NoError(s.apply(test.makeObject("a", 2, 139.7673), true))
+	test.NoError(s.apply(test.makeObject("a", 1, 139.7671), true))
+	// the object not verified does not replace the verified one of the same generation
+	test.NoError(s.apply(test.makeObject("a", 2, 139.7674), false))
+	test.NoError(s.flush())
+	objects, _ = test.sent()
+	test.Empty(objects)
+
+	/// normal pattern: the deletion is sent and updates delivered after it are dropped
+	test.NoError(s.apply(test.makeObject("c", 1, 139.7671), true))
+	s.remove("b")
+	s.remove("c")
+	test.NoError(s.apply(test.makeObject("b", 2, 139.7671), true))
+	test.NoError(s.flush())
+	objects, deleted = test.sent()
+	test.Empty(objects)
+	test.ElementsMatch([]string{"b", "c"}, deleted)
+
+	/// normal pattern: the objects out of the view are evicted
+	test.NoError(s.apply(test.makeObject("a", 3, 139.9671), true))
+	test.NoError(s.flush())
+	objects, deleted = test.sent()
+	test.Empty(objects)
+	test.ElementsMatch([]string{"a"}, deleted)
+
+	test.NoError(s.apply(test.makeObject("d", 1, 139.7671), true))
+	test.NoError(s.flush())
+	test.sent()
+	test.nodeCtrl.position = &coreAPI.Vector3{X: 0, Y: 0}
+	test.NoError(s.flush())
+	_, deleted = test.sent()
+	test.ElementsMatch([]string{"d"}, deleted)
+}
+
+func (test *sceneTest) TestRateLimit() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := newScene(ctx, test.frontend, test.nodeCtrl)
+
+	/// normal pattern: the changes are sent once in each interval and the latest one is sent
+	start := time.Now()
+	for generation := uint64(1); generation <= 100; generation++ {
+		test.NoError(s.apply(test.makeObject("a", generation, 139.7671), true))
+	}
+	test.Eventually(func() bool {
+		test.frontend.mtx.Lock()
+		defer test.frontend.mtx.Unlock()
+		if len(test.frontend.applied) == 0 {
+			return false
+		}
+		last := test.frontend.applied[len(test.frontend.applied)-1]
+		return last[0].Generation == 100
+	}, time.Second, SCENE_FLUSH_INTERVAL)
+	elapsed := time.Since(start)
+
+	applied, _ := test.frontend.pop()
+	test.LessOrEqual(len(applied), int(elapsed/SCENE_FLUSH_INTERVAL)+1)
+	for _, batch := range applied {
+		test.Len(batch, 1)
+	}
+
+	// nothing is sent without changes
+	time.Sleep(3 * SCENE_FLUSH_INTERVAL)
+	applied, deleted := test.frontend.pop()
+	test.Empty(applied)
+	test.Empty(deleted)
+}
+
+func (test *sceneTest) TestRequeue() {
+	s := test.newScene()
+	test.NoError(s.apply(test.makeObject("a", 1, 139.7671), true))
+	test.NoError(s.apply(test.makeObject("b", 1, 139.7671), true))
+	test.NoError(s.apply(test.makeObject("c", 1, 139.7671), true))
+	test.NoError(s.flush())
+	test.sent()
+
+	/// abnormal: the changes failed to send are sent again by the next flush
+	test.NoError(s.apply(test.makeObject("a", 2, 139.7671), true))
+	test.NoError(s.apply(test.makeObject("b", 2, 139.7671), true))
+	s.remove("c")
+	test.frontend.setError(errors.New("frontend is busy"))
+	test.Error(s.flush())
+	test.frontend.setError(nil)
+
+	// the same object is not queued again by apply because the digest is kept
+	test.NoError(s.apply(test.makeObject("a", 2, 139.7671), true))
+	test.NoError(s.flush())
+	objects, deleted := test.sent()
+	test.Equal(map[string]uint64{"a": 2, "b": 2}, objects)
+	test.ElementsMatch([]string{"c"}, deleted)
+
+	/// abnormal: the newer changes made after the failure are sent instead
+	test.NoError(s.apply(test.makeObject("a", 3, 139.7671), true))
+	test.NoError(s.apply(test.makeObject("b", 3, 139.7671), true))
+	test.NoError(s.apply(test.makeObject("c", 2, 139.7671), true))
+	test.frontend.setError(errors.New("frontend is busy"))
+	test.Error(s.flush())
+	test.frontend.setError(nil)
+	test.NoError(s.apply(test.makeObject("a", 4, 139.7671), true))
+	s.remove("b")
+	test.NoError(s.flush())
+	objects, deleted = test.sent()
+	// c was deleted before, so the update is dropped
+	test.Equal(map[string]uint64{"a": 4}, objects)
+	test.ElementsMatch([]string{"b"}, deleted)
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controller
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	coreAPI "github.com/llamerada-jp/oinari/node/apis/core"
+	"github.com/llamerada-jp/oinari/node/misc"
+)
+
+const (
+	// timers have one second resolution
+	TIMER_CHECK_INTERVAL    = 1 * time.Second
+	TIMER_MAX_PER_CONTAINER = 64
+)
+
+type TimerController interface {
+	// set a new timer for the container, return the timer filled id and next time
+	Set(containerID string, timer *core.Timer) (*core.Timer, error)
+	Cancel(containerID, timerID string) error
+	// restore timers stored in the record when the container is set up
+	Restore(containerID string, timers []core.Timer) error
+	// remove timers of the container and return them to store into the record
+	Export(containerID string) []core.Timer
+	// fire timers those time has come
+	Fire(now time.Time)
+}
+
+type timerControllerImpl struct {
+	mtx                  sync.Mutex
+	apiCoreDriverManager *coreAPI.Manager
+	// key: container ID, timer ID
+	timers map[string]map[string]*core.Timer
+	// key: container ID, true while firing timers of the container
+	firing map[string]bool
+}
+
+func NewTimerController(apiCoreDriverManager *coreAPI.Manager) TimerController {
+	return &timerControllerImpl{
+		apiCoreDriverManager: apiCoreDriverManager,
+		timers:               make(map[string]map[string]*core.Timer),
+		firing:               make(map[string]bool),
+	}
+}
+
+func (impl *timerControllerImpl) Set(containerID string, timer *core.Timer) (*core.Timer, error) {
+	if err := timer.Validate(false); err != nil {
+		return nil, err
+	}
+
+	next, ok, err := timer.Next(time.Now(), false)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("the timer will never fire")
+	}
+
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	timers, ok := impl.timers[containerID]
+	if !ok {
+		timers = make(map[string]*core.Timer)
+		impl.timers[containerID] = timers
+	}
+
+	if len(timers) >= TIMER_MAX_PER_CONTAINER {
+		return nil, fmt.Errorf("timers of the container exceed the limit (%d)", TIMER_MAX_PER_CONTAINER)
+	}
+
+	t := *timer
+	t.ID = core.GenerateTimerID()
+	t.NextAt = misc.TimeToTimestamp(ceilSecond(next))
+	timers[t.ID] = &t
+
+	res := t
+	return &res, nil
+}
+
+func (impl *timerControllerImpl) Cancel(containerID, timerID string) error {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	timers, ok := impl.timers[containerID]
+	if !ok {
+		return fmt.Errorf("timer not found (%s)", timerID)
+	}
+	if _, ok := timers[timerID]; !ok {
+		return fmt.Errorf("timer not found (%s)", timerID)
+	}
+
+	delete(timers, timerID)
+	if len(timers) == 0 {
+		delete(impl.timers, containerID)
+	}
+
+	return nil
+}
+
+func (impl *timerControllerImpl) Restore(containerID string, timers []core.Timer) error {
+	for _, timer := range timers {
+		if err := timer.Validate(true); err != nil {
+			return fmt.Errorf("invalid timer in the record: %w", err)
+		}
+	}
+
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	if len(timers) == 0 {
+		return nil
+	}
+
+	entries, ok := impl.timers[containerID]
+	if !ok {
+		entries = make(map[string]*core.Timer)
+		impl.timers[containerID] = entries
+	}
+
+	for _, timer := range timers {
+		t := timer
+		entries[t.ID] = &t
+	}
+
+	return nil
+}
+
+func (impl *timerControllerImpl) Export(containerID string) []core.Timer {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	delete(impl.firing, containerID)
+
+	timers, ok := impl.timers[containerID]
+	if !ok {
+		return nil
+	}
+	delete(impl.timers, containerID)
+
+	res := make([]core.Timer, 0, len(timers))
+	for _, timer := range timers {
+		res = append(res, *timer)
+	}
+	// keep the order to make records stable
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	return res
+}
+
+type firedTimer struct {
+	timer       core.Timer
+	scheduledAt string
+}
+
+func (impl *timerControllerImpl) Fire(now time.Time) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	for containerID, timers := range impl.timers {
+		// skip the container until the previous firing finishes
+		if impl.firing[containerID] {
+			continue
+		}
+
+		fired := make([]firedTimer, 0)
+		for id, timer := range timers {
+			scheduled, err := time.Parse(time.RFC3339, timer.NextAt)
+			if err != nil {
+				log.Printf("drop a timer having invalid time (%s): %s", id, err.Error())
+				delete(timers, id)
+				continue
+			}
+			if scheduled.After(now) {
+				continue
+			}
+
+			fired = append(fired, firedTimer{
+				timer:       *timer,
+				scheduledAt: timer.NextAt,
+			})
+
+			// fire only once for the missed time and schedule the next time after now
+			next, ok, err := timer.Next(scheduled, true)
+			if err == nil && ok && !next.After(now) {
+				next, ok, err = timer.Next(now, true)
+			}
+			if err != nil || !ok {
+				delete(timers, id)
+				continue
+			}
+			timer.NextAt = misc.TimeToTimestamp(ceilSecond(next))
+		}
+
+		if len(timers) == 0 {
+			delete(impl.timers, containerID)
+		}
+
+		if len(fired) == 0 {
+			continue
+		}
+
+		sort.Slice(fired, func(i, j int) bool {
+			return fired[i].scheduledAt < fired[j].scheduledAt
+		})
+
+		impl.firing[containerID] = true
+		go impl.fireContainer(containerID, fired)
+	}
+}
+
+func (impl *timerControllerImpl) fireContainer(containerID string, fired []firedTimer) {
+	defer func() {
+		impl.mtx.Lock()
+		defer impl.mtx.Unlock()
+		delete(impl.firing, containerID)
+	}()
+
+	driver := impl.apiCoreDriverManager.GetDriver(containerID)
+	if driver == nil {
+		log.Printf("driver not found to fire timers of the container (%s)", containerID)
+		return
+	}
+
+	for _, f := range fired {
+		if err := driver.FireTimer(&f.timer, f.scheduledAt); err != nil {
+			log.Printf("failed to fire the timer (%s): %s", f.timer.ID, err.Error())
+		}
+	}
+}
+
+func ceilSecond(t time.Time) time.Time {
+	truncated := t.Truncate(time.Second)
+	if truncated.Equal(t) {
+		return t
+	}
+	return truncated.Add(time.Second)
+}
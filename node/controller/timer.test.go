@@ -0,0 +1,193 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controller
+
+import (
+	"time"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	coreAPI "github.com/llamerada-jp/oinari/node/apis/core"
+	"github.com/llamerada-jp/oinari/node/misc"
+	"github.com/stretchr/testify/suite"
+)
+
+type timerControllerTest struct {
+	suite.Suite
+	impl *timerControllerImpl
+}
+
+func NewTimerControllerTest() suite.TestingSuite {
+	return &timerControllerTest{}
+}
+
+func (test *timerControllerTest) SetupTest() {
+	test.impl = NewTimerController(coreAPI.NewCoreDriverManager(nil)).(*timerControllerImpl)
+}
+
+func (test *timerControllerTest) TestSet() {
+	// invalid timer
+	_, err := test.impl.Set("container1", &core.Timer{
+		Name:     "timer",
+		Type:     core.TimerTypeEvery,
+		Interval: 10,
+	})
+	test.Error(err)
+
+	// cron never matches
+	_, err = test.impl.Set("container1", &core.Timer{
+		Name: "timer",
+		Type: core.TimerTypeCron,
+		Cron: "0 0 30 2 *",
+	})
+	test.Error(err)
+
+	now := time.Now()
+	timer, err := test.impl.Set("container1", &core.Timer{
+		Name:     "timer",
+		Type:     core.TimerTypeAfter,
+		Interval: 3000,
+		Payload:  []byte("payload"),
+	})
+	test.NoError(err)
+	test.NoError(timer.Validate(true))
+	nextAt, err := time.Parse(time.RFC3339, timer.NextAt)
+	test.NoError(err)
+	test.False(nextAt.Before(now.Add(3 * time.Second).Truncate(time.Second)))
+	test.True(nextAt.Before(now.Add(5 * time.Second)))
+
+	// limit of the timers
+	for i := 1; i < TIMER_MAX_PER_CONTAINER; i++ {
+		_, err = test.impl.Set("container1", &core.Timer{
+			Name: "timer",
+			Type: core.TimerTypeAfter,
+		})
+		test.NoError(err)
+	}
+	_, err = test.impl.Set("container1", &core.Timer{
+		Name: "timer",
+		Type: core.TimerTypeAfter,
+	})
+	test.Error(err)
+	_, err = test.impl.Set("container2", &core.Timer{
+		Name: "timer",
+		Type: core.TimerTypeAfter,
+	})
+	test.NoError(err)
+}
+
+func (test *timerControllerTest) TestCancel() {
+	timer, err := test.impl.Set("container1", &core.Timer{
+		Name:     "timer",
+		Type:     core.TimerTypeEvery,
+		Interval: 1000,
+	})
+	test.NoError(err)
+
+	// can not cancel the timer of other container
+	test.Error(test.impl.Cancel("container2", timer.ID))
+	test.NoError(test.impl.Cancel("container1", timer.ID))
+	test.Error(test.impl.Cancel("container1", timer.ID))
+	test.Len(test.impl.Export("container1"), 0)
+}
+
+func (test *timerControllerTest) TestExportAndRestore() {
+	t1, err := test.impl.Set("container1", &core.Timer{
+		Name:     "timer1",
+		Type:     core.TimerTypeAfter,
+		Interval: 60000,
+	})
+	test.NoError(err)
+	t2, err := test.impl.Set("container1", &core.Timer{
+		Name: "timer2",
+		Type: core.TimerTypeCron,
+		Cron: "0 * * * *",
+	})
+	test.NoError(err)
+
+	timers := test.impl.Export("container1")
+	test.Len(timers, 2)
+	test.ElementsMatch([]core.Timer{*t1, *t2}, timers)
+	// timers are removed by export
+	test.Len(test.impl.Export("container1"), 0)
+
+	// restore timers to the other container
+	test.NoError(test.impl.Restore("container2", timers))
+	test.ElementsMatch(timers, test.impl.Export("container2"))
+
+	// invalid timers can not be restored
+	test.Error(test.impl.Restore("container3", []core.Timer{
+		{
+			Name: "timer",
+			Type: core.TimerTypeAfter,
+		},
+	}))
+}
+
+func (test *timerControllerTest) TestFire() {
+	now := time.Now()
+	past := misc.TimeToTimestamp(now.Add(-90 * time.Second))
+	future := misc.TimeToTimestamp(now.Add(60 * time.Second))
+
+	test.NoError(test.impl.Restore("container1", []core.Timer{
+		{
+			ID:       core.GenerateTimerID(),
+			Name:     "after",
+			Type:     core.TimerTypeAfter,
+			Interval: 1000,
+			NextAt:   past,
+		},
+		{
+			ID:       "5a2b5a14-0f2a-4bfa-9c57-28b0b7c2ad3f",
+			Name:     "every",
+			Type:     core.TimerTypeEvery,
+			Interval: 60000,
+			NextAt:   past,
+		},
+		{
+			ID:       "8f0d8e54-7a8c-4c3f-a0a8-d13a9d5c9e0e",
+			Name:     "future",
+			Type:     core.TimerTypeAfter,
+			Interval: 1000,
+			NextAt:   future,
+		},
+	}))
+
+	// the driver does not exist, but timers are rescheduled
+	test.impl.Fire(now)
+	test.Eventually(func() bool {
+		test.impl.mtx.Lock()
+		defer test.impl.mtx.Unlock()
+		return !test.impl.firing["container1"]
+	}, 3*time.Second, 10*time.Millisecond)
+
+	timers := test.impl.Export("container1")
+	test.Len(timers, 2)
+	for _, timer := range timers {
+		switch timer.Name {
+		case "every":
+			// missed intervals are skipped
+			nextAt, err := time.Parse(time.RFC3339, timer.NextAt)
+			test.NoError(err)
+			test.True(nextAt.After(now))
+
+		case "future":
+			test.Equal(future, timer.NextAt)
+
+		default:
+			test.Fail("unexpected timer", timer.Name)
+		}
+	}
+}
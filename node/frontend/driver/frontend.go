@@ -30,11 +30,20 @@ type DeleteObjectsRequest struct {
 	UUIDs []string `json:"uuids"`
 }
 
+type WatchEventRequest struct {
+	// id of the subscription returned by resource/watchPod or resource/watchAccount
+	ID              string `json:"id"`
+	ResourceVersion uint64 `json:"resourceVersion"`
+	Deleted         bool   `json:"deleted"`
+}
+
 type FrontendDriver interface {
 	// send a message that tell initialization complete
 	TellInitComplete() error
 	ApplyObjects(objects []threeAPI.Object) error
 	DeleteObjects(uuids []string) error
+	// tell the event of the watched resource
+	TellWatchEvent(id string, resourceVersion uint64, deleted bool) error
 }
 
 type frontendDriverImpl struct {
@@ -82,3 +91,19 @@ func (impl *frontendDriverImpl) DeleteObjects(uuids []string) error {
 		})
 	return nil
 }
+
+func (impl *frontendDriverImpl) TellWatchEvent(id string, resourceVersion uint64, deleted bool) error {
+	impl.cl.Call("frontend/watchEvent",
+		WatchEventRequest{
+			ID:              id,
+			ResourceVersion: resourceVersion,
+			Deleted:         deleted,
+		},
+		nil, func(b []byte, err error) {
+			// the page might have been closed the subscription
+			if err != nil {
+				log.Printf("frontend/watchEvent has an error: %s", err.Error())
+			}
+		})
+	return nil
+}
@@ -17,10 +17,16 @@ package command
 
 import (
 	"log"
+	"sync"
 
+	"github.com/google/uuid"
 	"github.com/llamerada-jp/oinari/api/core"
+	threeAPI "github.com/llamerada-jp/oinari/api/three"
 	"github.com/llamerada-jp/oinari/lib/crosslink"
 	"github.com/llamerada-jp/oinari/node/controller"
+	threeController "github.com/llamerada-jp/oinari/node/controller/three"
+	"github.com/llamerada-jp/oinari/node/frontend/driver"
+	"github.com/llamerada-jp/oinari/node/kvs"
 	"github.com/llamerada-jp/oinari/node/misc"
 )
 
@@ -45,8 +51,17 @@ type createPodResponse struct {
 	Digest *controller.ApplicationDigest `json:"digest"`
 }
 
+type listPodRequest struct {
+	Phase       core.PodPhase `json:"phase,omitempty"`
+	RunningNode string        `json:"runningNode,omitempty"`
+	NamePrefix  string        `json:"namePrefix,omitempty"`
+	Limit       int           `json:"limit,omitempty"`
+	Continue    string        `json:"continue,omitempty"`
+}
+
 type listPodResponse struct {
-	Digests []controller.ApplicationDigest `json:"digests"`
+	Digests  []controller.ApplicationDigest `json:"digests"`
+	Continue string                         `json:"continue,omitempty"`
 }
 
 type migratePodRequest struct {
@@ -58,14 +73,93 @@ type deletePodRequest struct {
 	Uuid string `json:"uuid"`
 }
 
+type listRecordGenerationRequest struct {
+	Uuid string `json:"uuid"`
+}
+
+type listRecordGenerationResponse struct {
+	Generations []core.RecordGeneration `json:"generations"`
+}
+
+type restartPodRequest struct {
+	Uuid       string `json:"uuid"`
+	Generation uint64 `json:"generation"`
+}
+
+type getPodLogRequest struct {
+	Uuid  string `json:"uuid"`
+	After uint64 `json:"after"`
+	Limit int    `json:"limit"`
+}
+
+type getPodLogResponse struct {
+	Log *core.PodLog `json:"log"`
+}
+
+type watchPodRequest struct {
+	Uuid string `json:"uuid"`
+}
+
+type watchResponse struct {
+	ID string `json:"id"`
+}
+
+type unwatchRequest struct {
+	ID string `json:"id"`
+}
+
+type interactObjectRequest struct {
+	UUID string                   `json:"uuid"`
+	Part string                   `json:"part"`
+	Type threeAPI.InteractionType `json:"type"`
+}
+
 type configRequest struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
 }
 
-func InitResourceHandler(nodeMpx crosslink.MultiPlexer, accCtrl controller.AccountController, containerCtrl controller.ContainerController, nodeCtrl controller.NodeController, podCtrl controller.PodController) {
+// watchSubscriptions keeps the functions to stop watching for each subscription id
+type watchSubscriptions struct {
+	mtx     sync.Mutex
+	cancels map[string]func()
+}
+
+// start forwards the events to the frontend until the subscription is canceled
+func (ws *watchSubscriptions) start(fd driver.FrontendDriver, ch <-chan *kvs.WatchEvent, cancel func()) string {
+	id := uuid.NewString()
+	ws.mtx.Lock()
+	ws.cancels[id] = cancel
+	ws.mtx.Unlock()
+
+	go func() {
+		for event := range ch {
+			if err := fd.TellWatchEvent(id, event.ResourceVersion, event.Deleted); err != nil {
+				log.Printf("failed to tell the watch event: %s", err.Error())
+			}
+		}
+	}()
+	return id
+}
+
+func (ws *watchSubscriptions) stop(id string) bool {
+	ws.mtx.Lock()
+	cancel, ok := ws.cancels[id]
+	delete(ws.cancels, id)
+	ws.mtx.Unlock()
+
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+func InitResourceHandler(nodeMpx crosslink.MultiPlexer, fd driver.FrontendDriver, accCtrl controller.AccountController, containerCtrl controller.ContainerController, logCtrl controller.LogController, nodeCtrl controller.NodeController, podCtrl controller.PodController, podIndexCtrl controller.PodIndexController, objectCtrl threeController.ObjectController) {
 	mpx := crosslink.NewMultiPlexer()
 	nodeMpx.SetHandler("resource", mpx)
+	subscriptions := &watchSubscriptions{
+		cancels: make(map[string]func()),
+	}
 
 	// node resource
 	mpx.SetHandler("setNodePosition", crosslink.NewFuncHandler(func(request *setPositionRequest, tags map[string]string, writer crosslink.ResponseWriter) {
@@ -75,6 +169,30 @@ func InitResourceHandler(nodeMpx crosslink.MultiPlexer, accCtrl controller.Accou
 			return
 		}
 		writer.ReplySuccess(nil)
+
+		// show objects already existing around the new position
+		go func() {
+			center := threeAPI.Vector3(request.Position)
+			if err := objectCtrl.Populate(&center, threeController.OBJECT_POPULATE_RADIUS); err != nil {
+				log.Printf("failed to populate objects: %s", err.Error())
+			}
+		}()
+	}))
+
+	mpx.SetHandler("interactObject", crosslink.NewFuncHandler(func(request *interactObjectRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+		err := objectCtrl.Interact(&threeAPI.InteractionEvent{
+			Type:       request.Type,
+			ObjectUUID: request.UUID,
+			Part:       request.Part,
+			Account:    accCtrl.GetAccountName(),
+			Node:       nodeCtrl.GetNid(),
+			Timestamp:  misc.GetTimestamp(),
+		})
+		if err != nil {
+			writer.ReplyError(err.Error())
+			return
+		}
+		writer.ReplySuccess(nil)
 	}))
 
 	mpx.SetHandler("setNodePublicity", crosslink.NewFuncHandler(func(request *SetPublicityRequest, tags map[string]string, writer crosslink.ResponseWriter) {
@@ -144,47 +262,82 @@ func InitResourceHandler(nodeMpx crosslink.MultiPlexer, accCtrl controller.Accou
 				return
 			}
 
+			err = podIndexCtrl.UpdateEntries(owner, map[string]core.PodIndexEntry{
+				digest.Uuid: {
+					Name:      digest.Name,
+					Phase:     core.PodPhasePending,
+					Timestamp: misc.GetTimestamp(),
+				},
+			})
+			if err != nil {
+				writer.ReplyError(err.Error())
+				return
+			}
+
 			writer.ReplySuccess(createPodResponse{
 				Digest: digest,
 			})
 		}))
 
 	mpx.SetHandler("listPod", crosslink.NewFuncHandler(
-		func(request *interface{}, tags map[string]string, writer crosslink.ResponseWriter) {
-			res := listPodResponse{
-				Digests: make([]controller.ApplicationDigest, 0),
+		func(request *listPodRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+			account := accCtrl.GetAccountName()
+			filter := &controller.PodIndexFilter{
+				Phase:       request.Phase,
+				RunningNode: request.RunningNode,
+				NamePrefix:  request.NamePrefix,
 			}
 
-			uuids := make(map[string]any, 0)
-
-			// get pod uuids bound for the account
-			podState, err := accCtrl.GetPodState()
+			// get pods bound for the account from the pod index
+			page, err := podIndexCtrl.List(account, filter, request.Limit, request.Continue)
 			if err != nil {
 				writer.ReplyError(err.Error())
 				return
 			}
-			for uuid := range podState {
-				uuids[uuid] = true
-			}
 
-			// get pod uuids running on local node
-			for _, info := range containerCtrl.GetContainerInfos() {
-				uuids[info.PodUUID] = true
+			res := listPodResponse{
+				Digests:  make([]controller.ApplicationDigest, 0, len(page.Items)),
+				Continue: page.Continue,
+			}
+			for _, item := range page.Items {
+				res.Digests = append(res.Digests, controller.ApplicationDigest{
+					Name:          item.Name,
+					Uuid:          item.Uuid,
+					RunningNodeID: item.RunningNode,
+					Owner:         account,
+					State:         string(item.Phase),
+				})
 			}
 
-			// make pod digest
-			for uuid := range uuids {
-				pod, err := podCtrl.GetPodData(uuid)
+			// pods of other accounts running on local node are not in the index of the account,
+			// they are added to the first page
+			if len(request.Continue) != 0 {
+				writer.ReplySuccess(res)
+				return
+			}
+			for _, info := range containerCtrl.GetContainerInfos() {
+				if info.Owner == account {
+					continue
+				}
+				pod, err := podCtrl.GetPodData(info.PodUUID)
 				if err != nil {
 					log.Printf("error on get pod info: %s", err.Error())
 					continue
 				}
+				entry := &core.PodIndexEntry{
+					Name:        pod.Meta.Name,
+					RunningNode: nodeCtrl.GetNid(),
+					Phase:       pod.GetPhase(),
+				}
+				if !filter.Match(entry) {
+					continue
+				}
 				res.Digests = append(res.Digests, controller.ApplicationDigest{
-					Name:          pod.Meta.Name,
-					Uuid:          uuid,
-					RunningNodeID: pod.Status.RunningNode,
+					Name:          entry.Name,
+					Uuid:          info.PodUUID,
+					RunningNodeID: entry.RunningNode,
 					Owner:         pod.Meta.Owner,
-					State:         podCtrl.GetContainerStateMessage(pod),
+					State:         string(entry.Phase),
 				})
 			}
 
@@ -210,4 +363,72 @@ func InitResourceHandler(nodeMpx crosslink.MultiPlexer, accCtrl controller.Accou
 			}
 			writer.ReplySuccess(nil)
 		}))
+
+	mpx.SetHandler("listRecordGeneration", crosslink.NewFuncHandler(
+		func(param *listRecordGenerationRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+			generations, err := podCtrl.ListRecordGenerations(param.Uuid)
+			if err != nil {
+				writer.ReplyError(err.Error())
+				return
+			}
+			writer.ReplySuccess(listRecordGenerationResponse{
+				Generations: generations,
+			})
+		}))
+
+	mpx.SetHandler("restartPod", crosslink.NewFuncHandler(
+		func(param *restartPodRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+			err := podCtrl.Restart(param.Uuid, param.Generation)
+			if err != nil {
+				writer.ReplyError(err.Error())
+				return
+			}
+			writer.ReplySuccess(nil)
+		}))
+
+	mpx.SetHandler("getPodLog", crosslink.NewFuncHandler(
+		func(param *getPodLogRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+			podLog, err := logCtrl.GetLog(param.Uuid, param.After, param.Limit)
+			if err != nil {
+				writer.ReplyError(err.Error())
+				return
+			}
+			writer.ReplySuccess(getPodLogResponse{
+				Log: podLog,
+			})
+		}))
+
+	// watch
+	mpx.SetHandler("watchPod", crosslink.NewFuncHandler(
+		func(param *watchPodRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+			ch, cancel, err := podCtrl.Watch(param.Uuid)
+			if err != nil {
+				writer.ReplyError(err.Error())
+				return
+			}
+			writer.ReplySuccess(watchResponse{
+				ID: subscriptions.start(fd, ch, cancel),
+			})
+		}))
+
+	mpx.SetHandler("watchAccount", crosslink.NewFuncHandler(
+		func(param *interface{}, tags map[string]string, writer crosslink.ResponseWriter) {
+			ch, cancel, err := accCtrl.Watch()
+			if err != nil {
+				writer.ReplyError(err.Error())
+				return
+			}
+			writer.ReplySuccess(watchResponse{
+				ID: subscriptions.start(fd, ch, cancel),
+			})
+		}))
+
+	mpx.SetHandler("unwatch", crosslink.NewFuncHandler(
+		func(param *unwatchRequest, tags map[string]string, writer crosslink.ResponseWriter) {
+			if !subscriptions.stop(param.ID) {
+				writer.ReplyError("the subscription is not found")
+				return
+			}
+			writer.ReplySuccess(nil)
+		}))
 }
@@ -28,6 +28,12 @@ type connectRequest struct {
 	Token    string `json:"token"`
 	NodeName string `json:"nodeName"`
 	NodeType string `json:"nodeType"`
+	// base64 encoded key shared by the nodes of the account, it is empty if the records are not encrypted
+	AccountKey string `json:"accountKey,omitempty"`
+	// base64 encoded certificate of the signing key and the public key of the seed,
+	// they are empty if the resources are not signed
+	AccountCertificate string `json:"accountCertificate,omitempty"`
+	AuthorityKey       string `json:"authorityKey,omitempty"`
 }
 
 type connectResponse struct {
@@ -51,7 +57,11 @@ func InitSystemHandler(nodeMpx crosslink.MultiPlexer, appFilter controller.Appli
 	}))
 
 	mpx.SetHandler("connect", crosslink.NewFuncHandler(func(request *connectRequest, tags map[string]string, writer crosslink.ResponseWriter) {
-		err := sysCtrl.Connect(request.Url, request.Account, request.Token, request.NodeName, request.NodeType)
+		err := sysCtrl.Connect(request.Url, request.Account, &controller.AccountCredential{
+			Key:         request.AccountKey,
+			Certificate: request.AccountCertificate,
+			Authority:   request.AuthorityKey,
+		}, request.Token, request.NodeName, request.NodeType)
 		if err != nil {
 			writer.ReplyError(err.Error())
 			return
@@ -16,103 +16,64 @@
 package kvs
 
 import (
-	"encoding/json"
-
 	"errors"
 
 	"github.com/llamerada-jp/colonio/go/colonio"
 	"github.com/llamerada-jp/oinari/api/core"
-	"github.com/llamerada-jp/oinari/node/misc"
 )
 
 type AccountKvs interface {
 	Get(name string) (*core.Account, error)
 	Set(account *core.Account) error
 	Delete(name string) error
+	// Watch returns the channel of the events for the account and the function to stop watching
+	Watch(name string) (<-chan *WatchEvent, func(), error)
 }
 
 type accountKvsImpl struct {
-	col         colonio.Colonio
-	progressing *misc.UniqueSet
+	store Store[*core.Account]
 }
 
-func NewAccountKvs(col colonio.Colonio) AccountKvs {
+// NewAccountKvs returns AccountKvs, Watch is not supported if watcher is nil and signatures are not checked if signer is nil
+func NewAccountKvs(col colonio.Colonio, watcher WatchHub, signer ResourceSigner) AccountKvs {
 	return &accountKvsImpl{
-		col:         col,
-		progressing: misc.NewUniqueSet(),
+		store: NewStore(col, StoreConfig[*core.Account]{
+			Type:     core.ResourceTypeAccount,
+			Validate: (*core.Account).Validate,
+			ID: func(account *core.Account) string {
+				return account.Meta.Name
+			},
+			// use sha256 hash as account's uuid
+			Key: func(name string) string {
+				return string(core.ResourceTypeAccount) + "/" + core.GenerateAccountUuid(name)
+			},
+			Watcher: watcher,
+			Signer:  signer,
+		}),
 	}
 }
 
 func (impl *accountKvsImpl) Get(name string) (*core.Account, error) {
-	key := impl.getKey(name)
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
-
-	val, err := impl.col.KvsGet(key)
-	if err != nil {
-		if errors.Is(err, colonio.ErrKvsNotFound) {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	if val.IsNil() {
-		return nil, nil
-	}
-
-	raw, err := val.GetBinary()
-	if err != nil {
-		return nil, err
-	}
-
-	account := core.Account{}
-	err = json.Unmarshal(raw, &account)
-	if err != nil {
-		return nil, err
-	}
-
-	// delete data if it is invalid
-	if err := account.Validate(); err != nil {
-		// colonio does not have delete method on KVS, set nil instead of that
-		impl.col.KvsSet(key, nil, 0)
+	account, err := impl.store.Get(name)
+	if errors.Is(err, ErrNotFound) {
 		return nil, nil
 	}
-
-	return &account, nil
+	return account, err
 }
 
 func (impl *accountKvsImpl) Set(account *core.Account) error {
-	if err := account.Validate(); err != nil {
-		return err
-	}
-
-	raw, err := json.Marshal(account)
-	if err != nil {
-		return err
-	}
-
-	key := impl.getKey(account.Meta.Name)
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
-
-	err = impl.col.KvsSet(key, raw, 0)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return impl.store.Set(account)
 }
 
 func (impl *accountKvsImpl) Delete(name string) error {
-	key := impl.getKey(name)
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
+	_, err := impl.store.Delete(name)
+	return err
+}
 
-	// colonio does not have delete method on KVS, set nil instead of that
-	return impl.col.KvsSet(key, nil, 0)
+func (impl *accountKvsImpl) Watch(name string) (<-chan *WatchEvent, func(), error) {
+	return impl.store.Watch(name)
 }
 
-// use sha256 hash as account's uuid
 func (impl *accountKvsImpl) getKey(name string) string {
-	return string(core.ResourceTypeAccount) + "/" + core.GenerateAccountUuid(name)
+	return impl.store.Key(name)
 }
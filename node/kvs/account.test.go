@@ -19,7 +19,6 @@ import (
 	"encoding/json"
 
 	"github.com/llamerada-jp/oinari/api/core"
-	"github.com/llamerada-jp/oinari/node/misc"
 	"github.com/llamerada-jp/oinari/node/mock"
 	"github.com/stretchr/testify/suite"
 )
@@ -33,11 +32,8 @@ type accountKvsTest struct {
 func NewAccountKvsTest() suite.TestingSuite {
 	colonioMock := mock.NewColonioMock()
 	return &accountKvsTest{
-		col: colonioMock,
-		impl: &accountKvsImpl{
-			col:         colonioMock,
-			progressing: misc.NewUniqueSet(),
-		},
+		col:  colonioMock,
+		impl: NewAccountKvs(colonioMock, nil, nil).(*accountKvsImpl),
 	}
 }
 
@@ -116,9 +112,7 @@ func (test *accountKvsTest) TestGet() {
 	test.Equal(core.NodeTypeMobile, accountGet.State.Nodes["012345678901234567890123456789ab"].NodeType)
 	test.Equal("2023-04-15T17:30:40+09:00", accountGet.State.Nodes["012345678901234567890123456789ab"].Timestamp)
 
-	// should be remove invalid record and return nil
-	accountName = "get-account-invalid"
-	key = test.impl.getKey(accountName)
+	// legacy records are upgraded instead of removed
 	accountSet.State.Nodes = nil
 	raw, err = json.Marshal(accountSet)
 	test.NoError(err)
@@ -126,10 +120,26 @@ func (test *accountKvsTest) TestGet() {
 	test.NoError(err)
 	accountGet, err = test.impl.Get(accountName)
 	test.NoError(err)
+	test.NotNil(accountGet)
+	test.Equal(core.API_VERSION_CORE_V1, accountGet.Meta.APIVersion)
+	test.NotNil(accountGet.State.Nodes)
+	test.Len(accountGet.State.Nodes, 0)
+	test.Len(accountGet.State.Pods, 1)
+
+	// should return the error and keep the invalid record
+	accountName = "get-account-invalid"
+	key = test.impl.getKey(accountName)
+	accountSet.Meta.APIVersion = core.API_VERSION_CORE_V1
+	raw, err = json.Marshal(accountSet)
+	test.NoError(err)
+	err = test.col.KvsSet(key, raw, 0)
+	test.NoError(err)
+	accountGet, err = test.impl.Get(accountName)
+	test.ErrorIs(err, ErrInvalid)
 	test.Nil(accountGet)
 	record, err = test.col.KvsGet(key)
 	test.NoError(err)
-	test.True(record.IsNil())
+	test.False(isDeleted(record))
 }
 
 func (test *accountKvsTest) TestSet() {
@@ -177,5 +187,5 @@ func (test *accountKvsTest) TestDelete() {
 	test.impl.Delete(KEY)
 	raw, err := test.col.KvsGet(KEY_RAW)
 	test.NoError(err)
-	test.True(raw.IsNil())
+	test.True(isDeleted(raw))
 }
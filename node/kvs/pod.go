@@ -16,129 +16,64 @@
 package kvs
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-
 	"github.com/llamerada-jp/colonio/go/colonio"
 	"github.com/llamerada-jp/oinari/api/core"
-	"github.com/llamerada-jp/oinari/node/misc"
 )
 
+// ErrPodNotFound is kept for the callers checking the error of PodKvs.Get
+var ErrPodNotFound = ErrNotFound
+
 type PodKvs interface {
 	Create(pod *core.Pod) error
+	// Update writes the pod if its resource version is the same as the stored one and increments the version,
+	// it returns ErrConflict if the pod was updated after it was read. The check covers only the writes before it,
+	// see Store.Update.
 	Update(pod *core.Pod) error
 	Get(uuid string) (*core.Pod, error)
 	Delete(uuid string) error
+	// Watch returns the channel of the events for the pod and the function to stop watching
+	Watch(uuid string) (<-chan *WatchEvent, func(), error)
 }
 
 type podKvsImpl struct {
-	col         colonio.Colonio
-	progressing *misc.UniqueSet
+	store Store[*core.Pod]
 }
 
-func NewPodKvs(col colonio.Colonio) PodKvs {
+// NewPodKvs returns PodKvs, Watch is not supported if watcher is nil and signatures are not checked if signer is nil
+func NewPodKvs(col colonio.Colonio, watcher WatchHub, signer ResourceSigner) PodKvs {
 	return &podKvsImpl{
-		col:         col,
-		progressing: misc.NewUniqueSet(),
+		store: NewStore(col, StoreConfig[*core.Pod]{
+			Type: core.ResourceTypePod,
+			Validate: func(pod *core.Pod) error {
+				return pod.Validate(true)
+			},
+			Watcher: watcher,
+			Signer:  signer,
+		}),
 	}
 }
 
 func (impl *podKvsImpl) Create(pod *core.Pod) error {
-	if err := pod.Validate(true); err != nil {
-		return fmt.Errorf("failed to create pod data: %w", err)
-	}
-
-	key := string(core.ResourceTypePod) + "/" + pod.Meta.Uuid
-	raw, err := json.Marshal(pod)
-	if err != nil {
-		return fmt.Errorf("failed to create pod data: %w", err)
-	}
-
-	val, err := impl.col.KvsGet(key)
-	if errors.Is(err, colonio.ErrKvsNotFound) {
-		err = impl.col.KvsSet(key, raw, colonio.KvsProhibitOverwrite)
-		if err != nil {
-			return fmt.Errorf("failed to set pod data with prohibit overwrite option: %w", err)
-		}
-		return nil
-	}
-
-	// TODO: this implement might occur collision, fix this after delete feature is implemented in colonio
-	if val.IsNil() {
-		err = impl.col.KvsSet(key, raw, 0)
-		if err != nil {
-			return fmt.Errorf("failed to set pod data: %w", err)
-		}
-		return nil
-	}
-
-	return fmt.Errorf("there is an duplicate uuid pod data")
+	return impl.store.Create(pod)
 }
 
 func (impl *podKvsImpl) Update(pod *core.Pod) error {
-	if err := pod.Validate(true); err != nil {
-		return fmt.Errorf("failed to update pod data: %w", err)
-	}
-
-	key := string(core.ResourceTypePod) + "/" + pod.Meta.Uuid
-	raw, err := json.Marshal(pod)
-	if err != nil {
-		return fmt.Errorf("failed to update pod data: %w", err)
-	}
-
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
-
-	val, err := impl.col.KvsGet(key)
-	if err != nil {
-		return fmt.Errorf("failed to check for the existence of the data: %w", err)
-	}
-	if val.IsNil() {
-		return fmt.Errorf("the data might be deleted")
-	}
-
-	return impl.col.KvsSet(key, raw, 0)
+	_, err := impl.store.Update(pod)
+	return err
 }
 
 // return pod
 func (impl *podKvsImpl) Get(uuid string) (*core.Pod, error) {
-	key := string(core.ResourceTypePod) + "/" + uuid
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
-
-	val, err := impl.col.KvsGet(key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get raw data: %w", err)
-	}
-
-	if val.IsNil() {
-		return nil, fmt.Errorf("the record is not exists")
-	}
-
-	raw, err := val.GetBinary()
-	if err != nil {
-		return nil, fmt.Errorf("invalid raw data format: %w", err)
-	}
-
-	pod := &core.Pod{}
-	err = json.Unmarshal(raw, pod)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal raw data: %w", err)
-	}
-
-	return pod, nil
+	return impl.store.Get(uuid)
 }
 
 // set nil instead of remove record
 func (impl *podKvsImpl) Delete(uuid string) error {
-	key := string(core.ResourceTypePod) + "/" + uuid
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
-
 	// TODO check record before set nil for the record
-	if err := impl.col.KvsSet(key, nil, 0); err != nil {
-		return fmt.Errorf("failed to delete the record: %w", err)
-	}
-	return nil
+	_, err := impl.store.Delete(uuid)
+	return err
+}
+
+func (impl *podKvsImpl) Watch(uuid string) (<-chan *WatchEvent, func(), error) {
+	return impl.store.Watch(uuid)
 }
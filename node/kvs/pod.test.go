@@ -63,7 +63,7 @@ func NewPodKvsTest() suite.TestingSuite {
 	colonioMock := mock.NewColonioMock()
 	return &podKvsTest{
 		col:  colonioMock,
-		impl: NewPodKvs(colonioMock),
+		impl: NewPodKvs(colonioMock, nil, nil),
 	}
 }
 
@@ -269,7 +269,7 @@ func (test *podKvsTest) TestDelete() {
 	test.NoError(err)
 	val, err := test.col.KvsGet(string(core.ResourceTypePod) + "/" + uuid)
 	test.NoError(err)
-	test.True(val.IsNil())
+	test.True(isDeleted(val))
 
 	/// normal pattern: double delete
 	err = test.impl.Delete(uuid)
@@ -302,3 +302,52 @@ func (test *podKvsTest) existsByUUID(uuid string) bool {
 	}
 	return true
 }
+
+func (test *podKvsTest) TestUpdateConflict() {
+	uuid := core.GeneratePodUuid()
+	pod := &core.Pod{
+		Meta: &core.ObjectMeta{
+			Type:        core.ResourceTypePod,
+			Name:        "cat",
+			Owner:       "owner",
+			CreatorNode: "01234567890123456789012345678901",
+			Uuid:        uuid,
+		},
+		Spec:   validSpec,
+		Status: validStatus,
+	}
+	test.NoError(test.impl.Create(pod))
+
+	reader1 := test.getByUUID(uuid)
+	reader2 := test.getByUUID(uuid)
+	test.Equal(uint64(1), reader1.Meta.ResourceVersion)
+
+	/// normal pattern: the version is incremented
+	reader1.Meta.Name = "dog"
+	test.NoError(test.impl.Update(reader1))
+	test.Equal(uint64(2), reader1.Meta.ResourceVersion)
+	test.Equal(uint64(2), test.getByUUID(uuid).Meta.ResourceVersion)
+
+	/// abnormal: the pod was updated after read
+	reader2.Meta.Name = "naked mole rat"
+	err := test.impl.Update(reader2)
+	test.ErrorIs(err, ErrConflict)
+	test.Equal(uint64(1), reader2.Meta.ResourceVersion)
+	test.Equal("dog", test.getByUUID(uuid).Meta.Name)
+
+	/// normal pattern: retry with a fresh read
+	retried := 0
+	err = RetryOnConflict(func() error {
+		retried++
+		if retried == 1 {
+			return test.impl.Update(reader2)
+		}
+		latest := test.getByUUID(uuid)
+		latest.Meta.Name = "naked mole rat"
+		return test.impl.Update(latest)
+	})
+	test.NoError(err)
+	test.Equal(2, retried)
+	test.Equal("naked mole rat", test.getByUUID(uuid).Meta.Name)
+	test.Equal(uint64(3), test.getByUUID(uuid).Meta.ResourceVersion)
+}
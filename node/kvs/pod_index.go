@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package kvs
+
+import (
+	"errors"
+
+	"github.com/llamerada-jp/colonio/go/colonio"
+	"github.com/llamerada-jp/oinari/api/core"
+)
+
+type PodIndexKvs interface {
+	// Get returns nil if the shard does not exist and ErrInvalid if the shard is broken
+	Get(account string, shard int) (*core.PodIndex, error)
+	// Create returns ErrAlreadyExists if the shard exists
+	Create(index *core.PodIndex) error
+	// Update returns ErrConflict if the shard was updated after it was read,
+	// the check covers only the writes before it, see Store.Update.
+	Update(index *core.PodIndex) error
+	// Set overwrites the shard, it is used to rebuild the broken shard
+	Set(index *core.PodIndex) error
+	Delete(account string, shard int) error
+}
+
+type podIndexKvsImpl struct {
+	store Store[*core.PodIndex]
+}
+
+func NewPodIndexKvs(col colonio.Colonio) PodIndexKvs {
+	return &podIndexKvsImpl{
+		store: NewStore(col, StoreConfig[*core.PodIndex]{
+			Type:     core.ResourceTypePodIndex,
+			Validate: (*core.PodIndex).Validate,
+		}),
+	}
+}
+
+func (impl *podIndexKvsImpl) Get(account string, shard int) (*core.PodIndex, error) {
+	index, err := impl.store.Get(core.GeneratePodIndexUuid(account, shard))
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return index, err
+}
+
+func (impl *podIndexKvsImpl) Create(index *core.PodIndex) error {
+	return impl.store.Create(index)
+}
+
+func (impl *podIndexKvsImpl) Update(index *core.PodIndex) error {
+	_, err := impl.store.Update(index)
+	return err
+}
+
+func (impl *podIndexKvsImpl) Set(index *core.PodIndex) error {
+	return impl.store.Set(index)
+}
+
+func (impl *podIndexKvsImpl) Delete(account string, shard int) error {
+	_, err := impl.store.Delete(core.GeneratePodIndexUuid(account, shard))
+	return err
+}
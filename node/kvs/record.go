@@ -16,92 +16,671 @@
 package kvs
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"slices"
 
 	"github.com/llamerada-jp/colonio/go/colonio"
 	"github.com/llamerada-jp/oinari/api/core"
 	"github.com/llamerada-jp/oinari/node/misc"
 )
 
+const (
+	// records of the containers are split into chunks of the size, each chunk is compressed separately
+	// so that the unchanged chunks keep their keys
+	RECORD_CHUNK_SIZE = 32 * 1024
+	// records not larger than the size are stored in the manifest without chunks
+	RECORD_INLINE_MAX_SIZE = 1024
+	// number of the generations kept for each pod, the older generations are removed
+	RECORD_HISTORY_SIZE = 5
+
+	recordChunkType = "recordChunk"
+	// the first byte of the chunk tells the encoding, 0 is not used not to be confused with tombstones
+	recordChunkRaw  byte = 1
+	recordChunkGzip byte = 2
+	// the chunk encrypted with the data key, the encrypted data has the header of raw or gzip
+	recordChunkSealed byte = 3
+)
+
+var (
+	ErrBrokenRecordChunk        = errors.New("the record chunk is broken")
+	ErrRecordGenerationNotFound = errors.New("the generation of the record is not found")
+)
+
 type RecordKvs interface {
+	// Get returns the latest generation of the record, it returns nil if the record does not exist.
 	Get(podUuid string) (*core.Record, error)
+	// GetGeneration returns the generation of the record kept in the history.
+	GetGeneration(podUuid string, generation uint64) (*core.Record, error)
+	// ListGenerations returns the generations kept in the history from the oldest one.
+	ListGenerations(podUuid string) ([]core.RecordGeneration, error)
+	// Set writes the record as the new generation, it does nothing if the record is the same as the latest one.
 	Set(record *core.Record) error
+	// Restore writes a copy of the generation as the new generation.
+	Restore(podUuid string, generation uint64) error
 	Delete(podUuid string) error
 }
 
+// recordManifest is stored in record/<pod uuid> instead of core.Record, the records of the containers
+// are stored in recordChunk/<pod uuid>.<sha256 digest of the chunk>. The generations are stored in
+// Envelope instead of Generations if the record is encrypted.
+type recordManifest struct {
+	Meta *core.ObjectMeta `json:"meta"`
+	// Data is the inline record written by older nodes, it is replaced with the generations by the next Set
+	Data *core.RecordData `json:"data,omitempty"`
+	// Manifest is the record written by older nodes without the history, it is treated as the first generation
+	// key: container name
+	Manifest map[string]recordManifestEntry `json:"manifest,omitempty"`
+	// the last one is the latest generation
+	Generations []recordGeneration `json:"generations,omitempty"`
+	Envelope    *recordEnvelope    `json:"envelope,omitempty"`
+}
+
+type recordGeneration struct {
+	Generation   uint64 `json:"generation"`
+	Timestamp    string `json:"timestamp"`
+	SourceNode   string `json:"sourceNode"`
+	RestoredFrom uint64 `json:"restoredFrom,omitempty"`
+	// key: container name
+	Manifest map[string]recordManifestEntry `json:"manifest"`
+}
+
+type recordManifestEntry struct {
+	Timestamp string       `json:"timestamp"`
+	Timers    []core.Timer `json:"timers,omitempty"`
+	// size of the record before compression
+	Size   int      `json:"size"`
+	Inline []byte   `json:"inline,omitempty"`
+	Chunks []string `json:"chunks,omitempty"`
+}
+
+func (manifest *recordManifest) GetMeta() *core.ObjectMeta {
+	return manifest.Meta
+}
+
+func (manifest *recordManifest) validate() error {
+	if manifest.Meta == nil {
+		return fmt.Errorf("meta field should be filled")
+	}
+	if err := manifest.Meta.Validate(core.ResourceTypeRecord); err != nil {
+		return fmt.Errorf("invalid meta field: %w", err)
+	}
+	if err := core.ValidatePodUuid(manifest.Meta.Uuid); err != nil {
+		return fmt.Errorf("invalid uuid field: %w", err)
+	}
+
+	if manifest.Envelope != nil {
+		// generations are validated after decrypting
+		if manifest.Data != nil || manifest.Manifest != nil || len(manifest.Generations) != 0 {
+			return fmt.Errorf("encrypted record should not have plain generations")
+		}
+		if len(manifest.Envelope.KeyID) == 0 || len(manifest.Envelope.DataKey) == 0 || len(manifest.Envelope.Generations) == 0 {
+			return fmt.Errorf("envelope field should be filled")
+		}
+		return nil
+	}
+	if manifest.Data != nil {
+		return manifest.Data.Validate()
+	}
+	if manifest.Manifest != nil {
+		return validateRecordManifestEntries(manifest.Manifest)
+	}
+	if len(manifest.Generations) == 0 {
+		return fmt.Errorf("generations field should not be empty")
+	}
+
+	var prev uint64
+	for _, generation := range manifest.Generations {
+		if generation.Generation <= prev {
+			return fmt.Errorf("generations should be sorted: %d", generation.Generation)
+		}
+		prev = generation.Generation
+		if err := core.ValidateTimestamp(generation.Timestamp); err != nil {
+			return fmt.Errorf("invalid timestamp for generation %d: %w", generation.Generation, err)
+		}
+		if generation.Manifest == nil {
+			return fmt.Errorf("manifest field of generation %d should not nil", generation.Generation)
+		}
+		if err := validateRecordManifestEntries(generation.Manifest); err != nil {
+			return fmt.Errorf("invalid generation %d: %w", generation.Generation, err)
+		}
+	}
+	return nil
+}
+
+func validateRecordManifestEntries(entries map[string]recordManifestEntry) error {
+	for name, entry := range entries {
+		if err := core.ValidateTimestamp(entry.Timestamp); err != nil {
+			return fmt.Errorf("invalid timestamp for %s: %w", name, err)
+		}
+		for _, timer := range entry.Timers {
+			if err := timer.Validate(true); err != nil {
+				return fmt.Errorf("invalid timer for %s: %w", name, err)
+			}
+		}
+		if len(entry.Chunks) == 0 && len(entry.Inline) != entry.Size {
+			return fmt.Errorf("size of the inline record for %s is wrong", name)
+		}
+		if len(entry.Chunks) != 0 && len(entry.Inline) != 0 {
+			return fmt.Errorf("record for %s should not have both of inline and chunks", name)
+		}
+		for _, digest := range entry.Chunks {
+			if raw, err := hex.DecodeString(digest); err != nil || len(raw) != sha256.Size {
+				return fmt.Errorf("invalid chunk digest for %s: %s", name, digest)
+			}
+		}
+	}
+	return nil
+}
+
+// getGenerations returns the generations, the records written by older nodes are converted to the first generation
+func (manifest *recordManifest) getGenerations() []recordGeneration {
+	if manifest == nil {
+		return nil
+	}
+	if len(manifest.Generations) != 0 {
+		return manifest.Generations
+	}
+
+	entries := manifest.Manifest
+	if manifest.Data != nil {
+		entries = make(map[string]recordManifestEntry)
+		for name, entry := range manifest.Data.Entries {
+			entries[name] = recordManifestEntry{
+				Timestamp: entry.Timestamp,
+				Timers:    entry.Timers,
+				Size:      len(entry.Record),
+				Inline:    entry.Record,
+			}
+		}
+	}
+	if entries == nil {
+		return nil
+	}
+
+	// use the latest timestamp of the containers as the timestamp of the generation
+	timestamp := ""
+	for _, entry := range entries {
+		if entry.Timestamp > timestamp {
+			timestamp = entry.Timestamp
+		}
+	}
+	return []recordGeneration{
+		{
+			Generation: 1,
+			Timestamp:  timestamp,
+			Manifest:   entries,
+		},
+	}
+}
+
 type recordKVSImpl struct {
-	col         colonio.Colonio
-	progressing *misc.UniqueSet
+	col     colonio.Colonio
+	keyring RecordKeyring
+	store   Store[*recordManifest]
 }
 
-func NewRecordKvs(col colonio.Colonio) RecordKvs {
+// NewRecordKvs returns the store encrypting the records of the accounts having the key in the keyring,
+// keyring can be nil not to encrypt the records. Only the records are encrypted, other resources like pods and
+// three.Object are stored in plaintext.
+func NewRecordKvs(col colonio.Colonio, keyring RecordKeyring) RecordKvs {
 	return &recordKVSImpl{
-		col:         col,
-		progressing: misc.NewUniqueSet(),
+		col:     col,
+		keyring: keyring,
+		store: NewStore(col, StoreConfig[*recordManifest]{
+			Type:     core.ResourceTypeRecord,
+			Validate: (*recordManifest).validate,
+		}),
 	}
 }
 
-func (impl *recordKVSImpl) Get(podUuid string) (*core.Record, error) {
-	key := string(core.ResourceTypeRecord) + "/" + podUuid
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
+func getRecordChunkKey(podUuid, digest string) string {
+	return recordChunkType + "/" + podUuid + "." + digest
+}
 
-	val, err := impl.col.KvsGet(key)
+func (impl *recordKVSImpl) getKey(account string) []byte {
+	if impl.keyring == nil {
+		return nil
+	}
+	return impl.keyring.GetKey(account)
+}
+
+// load returns the generations and the data key of the record, the data key is nil if the record is not encrypted.
+func (impl *recordKVSImpl) load(podUuid string) (*recordManifest, []recordGeneration, []byte, error) {
+	manifest, err := impl.store.Get(podUuid)
 	if err != nil {
-		if errors.Is(err, colonio.ErrKvsNotFound) {
-			return nil, nil
+		return nil, nil, nil, err
+	}
+
+	key := impl.getKey(manifest.Meta.Owner)
+	envelope := manifest.Envelope
+	if envelope == nil {
+		// the records of the accounts having the key are always encrypted, the plain record is written by other than
+		// the nodes of the account to replace the encrypted one
+		if key != nil {
+			return nil, nil, nil, fmt.Errorf("the record of %s is not encrypted: %w", podUuid, ErrTamperedRecord)
 		}
-		return nil, err
+		return manifest, manifest.getGenerations(), nil, nil
 	}
 
-	if val.IsNil() {
+	if key == nil || getRecordKeyID(key) != envelope.KeyID {
+		return nil, nil, nil, fmt.Errorf("the record of %s is encrypted with the key %s: %w", podUuid, envelope.KeyID, ErrRecordKeyUnavailable)
+	}
+	dataKey, err := openRecord(key, envelope.DataKey, []byte(podUuid))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decrypt the data key of %s: %w", podUuid, err)
+	}
+	raw, err := openRecord(dataKey, envelope.Generations, getRecordAdditionalData(manifest.Meta))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decrypt the generations of %s: %w", podUuid, err)
+	}
+
+	opened := &recordManifest{
+		Meta: manifest.Meta,
+	}
+	if err := json.Unmarshal(raw, &opened.Generations); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode the generations of %s: %w", podUuid, ErrTamperedRecord)
+	}
+	if err := opened.validate(); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid generations of %s: %w", podUuid, err)
+	}
+	return manifest, opened.Generations, dataKey, nil
+}
+
+// getRecordAdditionalData binds the encrypted generations to the pod and the owner
+func getRecordAdditionalData(meta *core.ObjectMeta) []byte {
+	return []byte(meta.Uuid + "/" + meta.Owner)
+}
+
+func findRecordGeneration(generations []recordGeneration, generation uint64) *recordGeneration {
+	for idx := range generations {
+		if generations[idx].Generation == generation {
+			return &generations[idx]
+		}
+	}
+	return nil
+}
+
+func (impl *recordKVSImpl) Get(podUuid string) (*core.Record, error) {
+	manifest, generations, dataKey, err := impl.load(podUuid)
+	if errors.Is(err, ErrNotFound) {
 		return nil, nil
 	}
+	if err != nil {
+		return nil, err
+	}
 
-	raw, err := val.GetBinary()
+	return impl.readGeneration(manifest.Meta, &generations[len(generations)-1], dataKey)
+}
+
+func (impl *recordKVSImpl) GetGeneration(podUuid string, generation uint64) (*core.Record, error) {
+	manifest, generations, dataKey, err := impl.load(podUuid)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("the record of %s does not exist: %w", podUuid, ErrRecordGenerationNotFound)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	record := &core.Record{}
-	err = json.Unmarshal(raw, record)
+	target := findRecordGeneration(generations, generation)
+	if target == nil {
+		return nil, fmt.Errorf("generation %d of %s: %w", generation, podUuid, ErrRecordGenerationNotFound)
+	}
+	return impl.readGeneration(manifest.Meta, target, dataKey)
+}
+
+func (impl *recordKVSImpl) ListGenerations(podUuid string) ([]core.RecordGeneration, error) {
+	_, generations, _, err := impl.load(podUuid)
+	if errors.Is(err, ErrNotFound) {
+		return []core.RecordGeneration{}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if err := record.Validate(); err != nil {
-		// colonio does not have delete method on KVS, set nil instead of that
-		impl.col.KvsSet(key, nil, 0)
-		return nil, nil
+	list := make([]core.RecordGeneration, 0, len(generations))
+	for _, generation := range generations {
+		containers := make(map[string]core.RecordGenerationContainer)
+		for name, entry := range generation.Manifest {
+			containers[name] = core.RecordGenerationContainer{
+				Timestamp: entry.Timestamp,
+				Size:      entry.Size,
+			}
+		}
+		list = append(list, core.RecordGeneration{
+			Generation:   generation.Generation,
+			Timestamp:    generation.Timestamp,
+			SourceNode:   generation.SourceNode,
+			RestoredFrom: generation.RestoredFrom,
+			Containers:   containers,
+		})
 	}
+	return list, nil
+}
 
-	return record, nil
+func (impl *recordKVSImpl) readGeneration(meta *core.ObjectMeta, generation *recordGeneration, dataKey []byte) (*core.Record, error) {
+	data := &core.RecordData{
+		Entries: make(map[string]core.RecordEntry),
+	}
+	for name, entry := range generation.Manifest {
+		raw, err := impl.readRecord(meta.Uuid, &entry, dataKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the record of %s: %w", name, err)
+		}
+		data.Entries[name] = core.RecordEntry{
+			Timestamp: entry.Timestamp,
+			Record:    raw,
+			Timers:    entry.Timers,
+		}
+	}
+
+	return &core.Record{
+		Meta: meta,
+		Data: data,
+	}, nil
+}
+
+func (impl *recordKVSImpl) readRecord(podUuid string, entry *recordManifestEntry, dataKey []byte) ([]byte, error) {
+	if len(entry.Chunks) == 0 {
+		return entry.Inline, nil
+	}
+
+	raw := make([]byte, 0, entry.Size)
+	for _, digest := range entry.Chunks {
+		val, err := impl.col.KvsGet(getRecordChunkKey(podUuid, digest))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the record chunk %s: %w", digest, err)
+		}
+		if val.IsNil() {
+			return nil, fmt.Errorf("the record chunk %s is deleted: %w", digest, ErrBrokenRecordChunk)
+		}
+		value, err := val.GetBinary()
+		if err != nil {
+			return nil, fmt.Errorf("invalid record chunk format: %w", err)
+		}
+		chunk, err := decodeRecordChunk(podUuid, digest, value, dataKey)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, chunk...)
+	}
+
+	if len(raw) != entry.Size {
+		return nil, fmt.Errorf("size of the record is wrong: %w", ErrBrokenRecordChunk)
+	}
+	return raw, nil
 }
 
 func (impl *recordKVSImpl) Set(record *core.Record) error {
+	if record == nil || record.Meta == nil || record.Data == nil {
+		return fmt.Errorf("failed to set record data: record should be filled")
+	}
 	if err := record.Validate(); err != nil {
-		return err
+		return fmt.Errorf("failed to set record data: %w", err)
+	}
+
+	podUuid := record.Meta.Uuid
+	_, prevGenerations, dataKey, err := impl.load(podUuid)
+	// the encrypted record should not be overwritten with the plain record by the nodes not having the key, and
+	// the tampered record should not be overwritten to keep the history and the evidence
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to set record data: %w", err)
+	}
+	if dataKey == nil && impl.getKey(record.Meta.Owner) != nil {
+		if dataKey, err = generateRecordDataKey(); err != nil {
+			return err
+		}
 	}
 
-	raw, err := json.Marshal(record)
+	prevChunks := getRecordChunks(prevGenerations)
+	written := make(map[string]bool)
+	entries := make(map[string]recordManifestEntry)
+
+	for name, entry := range record.Data.Entries {
+		manifestEntry := recordManifestEntry{
+			Timestamp: entry.Timestamp,
+			Timers:    entry.Timers,
+			Size:      len(entry.Record),
+		}
+
+		if len(entry.Record) <= RECORD_INLINE_MAX_SIZE {
+			if len(entry.Record) != 0 {
+				manifestEntry.Inline = entry.Record
+			}
+			entries[name] = manifestEntry
+			continue
+		}
+
+		for offset := 0; offset < len(entry.Record); offset += RECORD_CHUNK_SIZE {
+			end := min(offset+RECORD_CHUNK_SIZE, len(entry.Record))
+			value, err := encodeRecordChunk(podUuid, entry.Record[offset:end], dataKey)
+			if err != nil {
+				return err
+			}
+			hash := sha256.Sum256(value)
+			digest := hex.EncodeToString(hash[:])
+			manifestEntry.Chunks = append(manifestEntry.Chunks, digest)
+
+			// write only the chunks not stored yet
+			if written[digest] || prevChunks[digest] {
+				continue
+			}
+			written[digest] = true
+			if err := impl.col.KvsSet(getRecordChunkKey(podUuid, digest), value, 0); err != nil {
+				return fmt.Errorf("failed to set the record chunk: %w", err)
+			}
+		}
+		entries[name] = manifestEntry
+	}
+
+	return impl.appendGeneration(record.Meta, prevGenerations, entries, 0, dataKey)
+}
+
+func (impl *recordKVSImpl) Restore(podUuid string, generation uint64) error {
+	manifest, generations, dataKey, err := impl.load(podUuid)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("the record of %s does not exist: %w", podUuid, ErrRecordGenerationNotFound)
+	}
 	if err != nil {
 		return err
 	}
 
-	key := string(core.ResourceTypeRecord) + "/" + record.Meta.Uuid
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
+	target := findRecordGeneration(generations, generation)
+	if target == nil {
+		return fmt.Errorf("generation %d of %s: %w", generation, podUuid, ErrRecordGenerationNotFound)
+	}
+	// check the chunks not to restore the broken generation
+	if _, err := impl.readGeneration(manifest.Meta, target, dataKey); err != nil {
+		return fmt.Errorf("failed to restore generation %d: %w", generation, err)
+	}
 
-	err = impl.col.KvsSet(key, raw, 0)
-	return err
+	return impl.appendGeneration(manifest.Meta, generations, target.Manifest, generation, dataKey)
 }
 
+// appendGeneration writes the manifest having the entries as the latest generation and removes the chunks
+// referred only by the generations dropped from the history. The generations are encrypted if dataKey is given.
+func (impl *recordKVSImpl) appendGeneration(meta *core.ObjectMeta, generations []recordGeneration, entries map[string]recordManifestEntry, restoredFrom uint64, dataKey []byte) error {
+	var next uint64 = 1
+	if len(generations) != 0 {
+		latest := &generations[len(generations)-1]
+		// teardown of the exited containers writes the same record again, it is not a new generation
+		if reflect.DeepEqual(latest.Manifest, entries) {
+			return nil
+		}
+		next = latest.Generation + 1
+	}
+
+	prevChunks := getRecordChunks(generations)
+	generations = append(slices.Clone(generations), recordGeneration{
+		Generation:   next,
+		Timestamp:    misc.GetTimestamp(),
+		SourceNode:   impl.col.GetLocalNid(),
+		RestoredFrom: restoredFrom,
+		Manifest:     entries,
+	})
+	if len(generations) > RECORD_HISTORY_SIZE {
+		generations = generations[len(generations)-RECORD_HISTORY_SIZE:]
+	}
+
+	manifest := &recordManifest{
+		Meta: meta,
+	}
+	if dataKey == nil {
+		manifest.Generations = generations
+	} else {
+		envelope, err := impl.seal(meta, generations, dataKey)
+		if err != nil {
+			return err
+		}
+		manifest.Envelope = envelope
+	}
+	if err := impl.store.Set(manifest); err != nil {
+		return err
+	}
+
+	// TODO: readers having the previous manifest might fail to read the removed chunks
+	chunks := getRecordChunks(generations)
+	for digest := range prevChunks {
+		if !chunks[digest] {
+			impl.deleteChunk(meta.Uuid, digest)
+		}
+	}
+	return nil
+}
+
+func (impl *recordKVSImpl) seal(meta *core.ObjectMeta, generations []recordGeneration, dataKey []byte) (*recordEnvelope, error) {
+	key := impl.getKey(meta.Owner)
+	if key == nil {
+		return nil, fmt.Errorf("failed to encrypt the record of %s: %w", meta.Uuid, ErrRecordKeyUnavailable)
+	}
+
+	raw, err := json.Marshal(generations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the generations: %w", err)
+	}
+	sealedGenerations, err := sealRecord(dataKey, raw, getRecordAdditionalData(meta))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt the generations: %w", err)
+	}
+	sealedKey, err := sealRecord(key, dataKey, []byte(meta.Uuid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt the data key: %w", err)
+	}
+
+	return &recordEnvelope{
+		KeyID:       getRecordKeyID(key),
+		DataKey:     sealedKey,
+		Generations: sealedGenerations,
+	}, nil
+}
+
+// Delete removes the chunks too, they are left as the garbage if the record can not be read
 func (impl *recordKVSImpl) Delete(podUuid string) error {
-	key := string(core.ResourceTypeRecord) + "/" + podUuid
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
+	_, prevGenerations, _, _ := impl.load(podUuid)
+	prevChunks := getRecordChunks(prevGenerations)
+	if _, err := impl.store.Delete(podUuid); err != nil {
+		return err
+	}
+	for digest := range prevChunks {
+		impl.deleteChunk(podUuid, digest)
+	}
+	return nil
+}
+
+// getRecordChunks returns the chunks referred by the generations
+func getRecordChunks(generations []recordGeneration) map[string]bool {
+	chunks := make(map[string]bool)
+	for _, generation := range generations {
+		for _, entry := range generation.Manifest {
+			for _, digest := range entry.Chunks {
+				chunks[digest] = true
+			}
+		}
+	}
+	return chunks
+}
+
+func (impl *recordKVSImpl) deleteChunk(podUuid, digest string) {
+	raw, err := NewTombstone(impl.col.GetLocalNid(), 0).Encode()
+	if err == nil {
+		err = impl.col.KvsSet(getRecordChunkKey(podUuid, digest), raw, 0)
+	}
+	// the chunk is not referred anymore, it is only a garbage
+	if err != nil {
+		log.Printf("failed to delete the record chunk %s: %s", digest, err.Error())
+	}
+}
+
+// encodeRecordChunk compresses the chunk if it gets smaller, and encrypts it if dataKey is given
+func encodeRecordChunk(podUuid string, chunk, dataKey []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(recordChunkGzip)
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(chunk); err != nil {
+		return nil, fmt.Errorf("failed to compress the record chunk: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress the record chunk: %w", err)
+	}
+	value := buf.Bytes()
+	if buf.Len() >= len(chunk)+1 {
+		value = append([]byte{recordChunkRaw}, chunk...)
+	}
+
+	if dataKey == nil {
+		return value, nil
+	}
+	sealed, err := sealRecordDeterministic(dataKey, value, []byte(podUuid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt the record chunk: %w", err)
+	}
+	return append([]byte{recordChunkSealed}, sealed...), nil
+}
+
+func decodeRecordChunk(podUuid, digest string, value, dataKey []byte) ([]byte, error) {
+	hash := sha256.Sum256(value)
+	if hex.EncodeToString(hash[:]) != digest || len(value) == 0 {
+		return nil, fmt.Errorf("digest of the record chunk %s is wrong: %w", digest, ErrBrokenRecordChunk)
+	}
+
+	if value[0] == recordChunkSealed {
+		if dataKey == nil {
+			return nil, fmt.Errorf("the record chunk %s is encrypted: %w", digest, ErrRecordKeyUnavailable)
+		}
+		var err error
+		value, err = openRecord(dataKey, value[1:], []byte(podUuid))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt the record chunk %s: %w", digest, err)
+		}
+		if len(value) == 0 {
+			return nil, fmt.Errorf("the record chunk %s is empty: %w", digest, ErrBrokenRecordChunk)
+		}
+	}
+
+	switch value[0] {
+	case recordChunkRaw:
+		return value[1:], nil
+
+	case recordChunkGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(value[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress the record chunk %s: %w", digest, err)
+		}
+		defer reader.Close()
+		chunk, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress the record chunk %s: %w", digest, err)
+		}
+		return chunk, nil
+	}
 
-	// colonio does not have delete method on KVS, set nil instead of that
-	return impl.col.KvsSet(key, nil, 0)
+	return nil, fmt.Errorf("unknown encoding of the record chunk %s: %w", digest, ErrBrokenRecordChunk)
 }
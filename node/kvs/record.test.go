@@ -0,0 +1,438 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package kvs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/rand"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/node/misc"
+	"github.com/llamerada-jp/oinari/node/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type recordKvsTest struct {
+	suite.Suite
+	col  *mock.Colonio
+	impl *recordKVSImpl
+}
+
+func NewRecordKvsTest() suite.TestingSuite {
+	colonioMock := mock.NewColonioMock()
+	return &recordKvsTest{
+		col:  colonioMock,
+		impl: NewRecordKvs(colonioMock, nil).(*recordKVSImpl),
+	}
+}
+
+func (test *recordKvsTest) makeRecord(uuid string, records map[string][]byte) *core.Record {
+	record := &core.Record{
+		Meta: &core.ObjectMeta{
+			Type:        core.ResourceTypeRecord,
+			Name:        "record",
+			Owner:       "owner",
+			CreatorNode: "01234567890123456789012345678901",
+			Uuid:        uuid,
+		},
+		Data: &core.RecordData{
+			Entries: make(map[string]core.RecordEntry),
+		},
+	}
+	for name, raw := range records {
+		record.Data.Entries[name] = core.RecordEntry{
+			Timestamp: misc.GetTimestamp(),
+			Record:    raw,
+		}
+	}
+	return record
+}
+
+// getChunkKeys returns the keys of the chunks not deleted
+func (test *recordKvsTest) getChunkKeys(uuid string) []string {
+	keys := make([]string, 0)
+	for _, key := range test.col.KvsKeys(recordChunkType + "/" + uuid + ".") {
+		val, err := test.col.KvsGet(key)
+		test.NoError(err)
+		if !isDeleted(val) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (test *recordKvsTest) TestChunks() {
+	uuid := core.GeneratePodUuid()
+	random := make([]byte, RECORD_CHUNK_SIZE*3+10)
+	rand.New(rand.NewSource(1)).Read(random)
+	// the chunks of the pattern are the same
+	compressible := bytes.Repeat([]byte("oinari!\n"), RECORD_CHUNK_SIZE*6/8)
+	small := []byte("small record")
+
+	record := test.makeRecord(uuid, map[string][]byte{
+		"random":       random,
+		"compressible": compressible,
+		"small":        small,
+		"empty":        {},
+	})
+	test.NoError(test.impl.Set(record))
+
+	// manifest does not contain large records
+	val, err := test.col.KvsGet(test.impl.store.Key(uuid))
+	test.NoError(err)
+	raw, err := val.GetBinary()
+	test.NoError(err)
+	test.Less(len(raw), RECORD_CHUNK_SIZE/4)
+	manifest := &recordManifest{}
+	test.NoError(json.Unmarshal(raw, manifest))
+	test.Nil(manifest.Data)
+	test.Len(manifest.Generations, 1)
+	latest := manifest.Generations[0].Manifest
+	test.Len(latest["random"].Chunks, 4)
+	test.Len(latest["compressible"].Chunks, 6)
+	test.Equal(small, latest["small"].Inline)
+
+	// same chunks are stored once, compressed chunks are smaller
+	chunkKeys := test.getChunkKeys(uuid)
+	test.Len(chunkKeys, 5)
+	for _, digest := range latest["compressible"].Chunks {
+		val, err := test.col.KvsGet(getRecordChunkKey(uuid, digest))
+		test.NoError(err)
+		chunk, err := val.GetBinary()
+		test.NoError(err)
+		test.Equal(recordChunkGzip, chunk[0])
+		test.Less(len(chunk), RECORD_CHUNK_SIZE/10)
+	}
+
+	got, err := test.impl.Get(uuid)
+	test.NoError(err)
+	test.Len(got.Data.Entries, 4)
+	for name, entry := range record.Data.Entries {
+		test.Equal(entry.Timestamp, got.Data.Entries[name].Timestamp)
+		test.Equal(len(entry.Record), len(got.Data.Entries[name].Record))
+		test.True(bytes.Equal(entry.Record, got.Data.Entries[name].Record), name)
+	}
+
+	// only changed chunks are rewritten
+	counts := make(map[string]int)
+	for _, key := range chunkKeys {
+		counts[key] = test.col.KvsSetCount(key)
+	}
+	modified := bytes.Clone(random)
+	modified[RECORD_CHUNK_SIZE+1] ^= 0xff
+	record = test.makeRecord(uuid, map[string][]byte{
+		"random":       modified,
+		"compressible": compressible,
+	})
+	test.NoError(test.impl.Set(record))
+	// the chunk of the previous generation is kept for the history
+	newChunkKeys := test.getChunkKeys(uuid)
+	test.Len(newChunkKeys, 6)
+	rewritten := 0
+	for _, key := range newChunkKeys {
+		count, ok := counts[key]
+		if !ok {
+			rewritten++
+			continue
+		}
+		test.Equal(count, test.col.KvsSetCount(key))
+	}
+	test.Equal(1, rewritten)
+
+	got, err = test.impl.Get(uuid)
+	test.NoError(err)
+	test.Len(got.Data.Entries, 2)
+	test.Equal(modified, got.Data.Entries["random"].Record)
+
+	// broken chunks are detected
+	manifest, err = test.impl.store.Get(uuid)
+	test.NoError(err)
+	generations := manifest.Generations
+	key := getRecordChunkKey(uuid, generations[len(generations)-1].Manifest["random"].Chunks[0])
+	val, err = test.col.KvsGet(key)
+	test.NoError(err)
+	chunk, err := val.GetBinary()
+	test.NoError(err)
+	chunk = bytes.Clone(chunk)
+	chunk[len(chunk)-1] ^= 0xff
+	test.NoError(test.col.KvsSet(key, chunk, 0))
+	_, err = test.impl.Get(uuid)
+	test.ErrorIs(err, ErrBrokenRecordChunk)
+
+	// delete removes the chunks
+	test.NoError(test.impl.Delete(uuid))
+	got, err = test.impl.Get(uuid)
+	test.NoError(err)
+	test.Nil(got)
+	test.Len(test.getChunkKeys(uuid), 0)
+}
+
+func (test *recordKvsTest) TestLegacyRecord() {
+	uuid := core.GeneratePodUuid()
+	record := test.makeRecord(uuid, map[string][]byte{
+		"legacy": bytes.Repeat([]byte("x"), RECORD_CHUNK_SIZE),
+	})
+
+	// records written by older nodes are stored inline
+	raw, err := json.Marshal(record)
+	test.NoError(err)
+	test.NoError(test.col.KvsSet(test.impl.store.Key(uuid), raw, 0))
+	got, err := test.impl.Get(uuid)
+	test.NoError(err)
+	test.Equal(record.Data.Entries["legacy"].Record, got.Data.Entries["legacy"].Record)
+
+	// they are kept as the first generation and rewritten with the chunks by the next set
+	got.Data.Entries["legacy"] = core.RecordEntry{
+		Timestamp: misc.GetTimestamp(),
+		Record:    record.Data.Entries["legacy"].Record,
+	}
+	test.NoError(test.impl.Set(got))
+	manifest, err := test.impl.store.Get(uuid)
+	test.NoError(err)
+	test.Nil(manifest.Data)
+	test.Len(manifest.Generations, 2)
+	test.Equal(RECORD_CHUNK_SIZE, len(manifest.Generations[0].Manifest["legacy"].Inline))
+	test.Len(manifest.Generations[1].Manifest["legacy"].Chunks, 1)
+	got, err = test.impl.Get(uuid)
+	test.NoError(err)
+	test.Equal(record.Data.Entries["legacy"].Record, got.Data.Entries["legacy"].Record)
+
+	// invalid records
+	test.Error(test.impl.Set(nil))
+	record.Data.Entries["legacy"] = core.RecordEntry{Timestamp: "invalid"}
+	test.Error(test.impl.Set(record))
+}
+
+func (test *recordKvsTest) TestHistory() {
+	uuid := core.GeneratePodUuid()
+	makeRaw := func(i int) []byte {
+		return bytes.Repeat([]byte{byte(i)}, RECORD_CHUNK_SIZE)
+	}
+
+	// the same record is not a new generation
+	for i := 1; i <= RECORD_HISTORY_SIZE+2; i++ {
+		record := test.makeRecord(uuid, map[string][]byte{"container": makeRaw(i)})
+		test.NoError(test.impl.Set(record))
+		test.NoError(test.impl.Set(record))
+	}
+
+	generations, err := test.impl.ListGenerations(uuid)
+	test.NoError(err)
+	test.Len(generations, RECORD_HISTORY_SIZE)
+	for idx, generation := range generations {
+		test.Equal(uint64(idx+3), generation.Generation)
+		test.Equal(test.col.LocalNid, generation.SourceNode)
+		test.NoError(core.ValidateTimestamp(generation.Timestamp))
+		test.Zero(generation.RestoredFrom)
+		test.Equal(RECORD_CHUNK_SIZE, generation.Containers["container"].Size)
+	}
+	// chunks of the removed generations are deleted
+	test.Len(test.getChunkKeys(uuid), RECORD_HISTORY_SIZE)
+
+	for generation := uint64(3); generation <= RECORD_HISTORY_SIZE+2; generation++ {
+		record, err := test.impl.GetGeneration(uuid, generation)
+		test.NoError(err)
+		test.Equal(makeRaw(int(generation)), record.Data.Entries["container"].Record)
+	}
+	_, err = test.impl.GetGeneration(uuid, 2)
+	test.ErrorIs(err, ErrRecordGenerationNotFound)
+
+	// restore writes a copy of the generation as the latest one
+	test.NoError(test.impl.Restore(uuid, 4))
+	test.NoError(test.impl.Restore(uuid, 4))
+	generations, err = test.impl.ListGenerations(uuid)
+	test.NoError(err)
+	test.Len(generations, RECORD_HISTORY_SIZE)
+	latest := generations[len(generations)-1]
+	test.Equal(uint64(RECORD_HISTORY_SIZE+3), latest.Generation)
+	test.Equal(uint64(4), latest.RestoredFrom)
+	record, err := test.impl.Get(uuid)
+	test.NoError(err)
+	test.Equal(makeRaw(4), record.Data.Entries["container"].Record)
+	test.ErrorIs(test.impl.Restore(uuid, 3), ErrRecordGenerationNotFound)
+	test.ErrorIs(test.impl.Restore(core.GeneratePodUuid(), 1), ErrRecordGenerationNotFound)
+
+	// broken generations are not restored
+	key := getRecordChunkKey(uuid, test.getDigest(uuid, 5))
+	test.NoError(test.col.KvsSet(key, []byte{recordChunkRaw}, 0))
+	test.ErrorIs(test.impl.Restore(uuid, 5), ErrBrokenRecordChunk)
+
+	generations, err = test.impl.ListGenerations(core.GeneratePodUuid())
+	test.NoError(err)
+	test.Len(generations, 0)
+}
+
+func (test *recordKvsTest) getDigest(uuid string, generation uint64) string {
+	manifest, err := test.impl.store.Get(uuid)
+	test.NoError(err)
+	return findRecordGeneration(manifest.Generations, generation).Manifest["container"].Chunks[0]
+}
+
+func (test *recordKvsTest) TestEncryption() {
+	uuid := core.GeneratePodUuid()
+	keyring := NewAccountKeyring("owner", []byte("account key"))
+	impl := NewRecordKvs(test.col, keyring).(*recordKVSImpl)
+	random := make([]byte, RECORD_CHUNK_SIZE*2)
+	rand.New(rand.NewSource(1)).Read(random)
+	secret := []byte("secret record")
+
+	record := test.makeRecord(uuid, map[string][]byte{
+		"random": random,
+		"small":  secret,
+	})
+	test.NoError(impl.Set(record))
+
+	// plain data is not stored
+	val, err := test.col.KvsGet(impl.store.Key(uuid))
+	test.NoError(err)
+	raw, err := val.GetBinary()
+	test.NoError(err)
+	test.False(bytes.Contains(raw, secret))
+	manifest, err := impl.store.Get(uuid)
+	test.NoError(err)
+	test.Empty(manifest.Generations)
+	test.NotNil(manifest.Envelope)
+	chunkKeys := test.getChunkKeys(uuid)
+	test.Len(chunkKeys, 2)
+	for _, key := range chunkKeys {
+		val, err := test.col.KvsGet(key)
+		test.NoError(err)
+		chunk, err := val.GetBinary()
+		test.NoError(err)
+		test.Equal(recordChunkSealed, chunk[0])
+	}
+
+	// nodes of the account can read the record, and unchanged chunks are not rewritten
+	other := NewRecordKvs(test.col, NewAccountKeyring("owner", []byte("account key")))
+	got, err := other.Get(uuid)
+	test.NoError(err)
+	test.Equal(random, got.Data.Entries["random"].Record)
+	test.Equal(secret, got.Data.Entries["small"].Record)
+	record.Data.Entries["small"] = core.RecordEntry{
+		Timestamp: misc.GetTimestamp(),
+		Record:    []byte("updated"),
+	}
+	test.NoError(other.Set(record))
+	test.Len(test.getChunkKeys(uuid), 2)
+	generations, err := other.ListGenerations(uuid)
+	test.NoError(err)
+	test.Len(generations, 2)
+	test.NoError(other.Restore(uuid, 1))
+	got, err = impl.Get(uuid)
+	test.NoError(err)
+	test.Equal(secret, got.Data.Entries["small"].Record)
+
+	// nodes not having the key can not read and overwrite the record
+	for _, keyring := range []RecordKeyring{nil, NewAccountKeyring("owner", nil), NewAccountKeyring("owner", []byte("wrong")), NewAccountKeyring("other", []byte("account key"))} {
+		kvs := NewRecordKvs(test.col, keyring)
+		_, err = kvs.Get(uuid)
+		test.ErrorIs(err, ErrRecordKeyUnavailable)
+		test.ErrorIs(kvs.Set(record), ErrRecordKeyUnavailable)
+	}
+
+	// tampered records are rejected
+	manifest, err = impl.store.Get(uuid)
+	test.NoError(err)
+	for _, tc := range []struct {
+		tamper   func(manifest *recordManifest)
+		expected error
+	}{
+		{
+			tamper: func(manifest *recordManifest) {
+				manifest.Envelope.Generations[len(manifest.Envelope.Generations)-1] ^= 0xff
+			},
+			expected: ErrTamperedRecord,
+		},
+		{
+			tamper: func(manifest *recordManifest) {
+				manifest.Envelope.DataKey[len(manifest.Envelope.DataKey)-1] ^= 0xff
+			},
+			expected: ErrTamperedRecord,
+		},
+		{
+			// the key of another account does not match
+			tamper: func(manifest *recordManifest) {
+				manifest.Meta.Owner = "owner2"
+			},
+			expected: ErrRecordKeyUnavailable,
+		},
+	} {
+		tampered := &recordManifest{}
+		raw, err := json.Marshal(manifest)
+		test.NoError(err)
+		test.NoError(json.Unmarshal(raw, tampered))
+		tc.tamper(tampered)
+		raw, err = json.Marshal(tampered)
+		test.NoError(err)
+		test.NoError(test.col.KvsSet(impl.store.Key(uuid), raw, 0))
+
+		kvs := NewRecordKvs(test.col, NewAccountKeyring(tampered.Meta.Owner, []byte("account key")))
+		_, err = kvs.Get(uuid)
+		test.ErrorIs(err, tc.expected)
+	}
+
+	// the envelope can not be moved to another pod
+	moved := &recordManifest{}
+	raw, err = json.Marshal(manifest)
+	test.NoError(err)
+	test.NoError(json.Unmarshal(raw, moved))
+	movedUuid := core.GeneratePodUuid()
+	moved.Meta.Uuid = movedUuid
+	raw, err = json.Marshal(moved)
+	test.NoError(err)
+	test.NoError(test.col.KvsSet(impl.store.Key(movedUuid), raw, 0))
+	_, err = impl.Get(movedUuid)
+	test.ErrorIs(err, ErrTamperedRecord)
+
+	// chunks encrypted with another data key are rejected
+	raw, err = json.Marshal(manifest)
+	test.NoError(err)
+	test.NoError(test.col.KvsSet(impl.store.Key(uuid), raw, 0))
+	value, err := encodeRecordChunk(uuid, make([]byte, RECORD_CHUNK_SIZE), make([]byte, RECORD_DATA_KEY_SIZE))
+	test.NoError(err)
+	hash := sha256.Sum256(value)
+	_, err = decodeRecordChunk(uuid, hex.EncodeToString(hash[:]), value, bytes.Repeat([]byte{1}, RECORD_DATA_KEY_SIZE))
+	test.ErrorIs(err, ErrTamperedRecord)
+	got, err = impl.Get(uuid)
+	test.NoError(err)
+	test.Equal(random, got.Data.Entries["random"].Record)
+
+	// the plain record replacing the encrypted one is rejected and the tampered record is not overwritten
+	plain := NewRecordKvs(test.col, nil)
+	plainUuid := core.GeneratePodUuid()
+	test.NoError(plain.Set(test.makeRecord(plainUuid, map[string][]byte{"small": []byte("forged")})))
+	val, err = test.col.KvsGet(impl.store.Key(plainUuid))
+	test.NoError(err)
+	raw, err = val.GetBinary()
+	test.NoError(err)
+	forgedManifest := &recordManifest{}
+	test.NoError(json.Unmarshal(raw, forgedManifest))
+	forgedManifest.Meta.Uuid = uuid
+	forged, err := json.Marshal(forgedManifest)
+	test.NoError(err)
+	test.NoError(test.col.KvsSet(impl.store.Key(uuid), forged, 0))
+	_, err = impl.Get(uuid)
+	test.ErrorIs(err, ErrTamperedRecord)
+	test.ErrorIs(impl.Set(record), ErrTamperedRecord)
+	val, err = test.col.KvsGet(impl.store.Key(uuid))
+	test.NoError(err)
+	raw, err = val.GetBinary()
+	test.NoError(err)
+	test.Equal(forged, raw)
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package kvs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	// size of the key generated for the record of each pod
+	RECORD_DATA_KEY_SIZE = 32
+
+	recordKeyInfo = "oinari/record/key-encryption-key"
+)
+
+var (
+	ErrRecordKeyUnavailable = errors.New("the key to decrypt the record is not available")
+	ErrTamperedRecord       = errors.New("the record is tampered")
+)
+
+// RecordKeyring provides the keys to encrypt the records of the accounts.
+type RecordKeyring interface {
+	// GetKey returns the key encryption key of the account, it returns nil if the node does not have the key.
+	GetKey(account string) []byte
+}
+
+// recordEnvelope is stored in the manifest instead of the generations when the record is encrypted.
+// The generations are encrypted with the data key of the pod, and the data key is encrypted with the key
+// of the account.
+type recordEnvelope struct {
+	// id of the key encryption key to tell the record is encrypted with another key
+	KeyID       string `json:"keyID"`
+	DataKey     []byte `json:"dataKey"`
+	Generations []byte `json:"generations"`
+}
+
+type accountKeyringImpl struct {
+	account string
+	key     []byte
+}
+
+// NewAccountKeyring returns the keyring having the key of the local account only, because the account key
+// is shared only among the nodes of the account. The records are not encrypted if accountKey is empty.
+func NewAccountKeyring(account string, accountKey []byte) RecordKeyring {
+	impl := &accountKeyringImpl{
+		account: account,
+	}
+	if len(accountKey) != 0 {
+		mac := hmac.New(sha256.New, accountKey)
+		mac.Write([]byte(recordKeyInfo + "/" + account))
+		impl.key = mac.Sum(nil)
+	}
+	return impl
+}
+
+func (impl *accountKeyringImpl) GetKey(account string) []byte {
+	if account != impl.account {
+		return nil
+	}
+	return impl.key
+}
+
+func getRecordKeyID(key []byte) string {
+	hash := sha256.Sum256(key)
+	return hex.EncodeToString(hash[:8])
+}
+
+func generateRecordDataKey() ([]byte, error) {
+	key := make([]byte, RECORD_DATA_KEY_SIZE)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate the data key: %w", err)
+	}
+	return key, nil
+}
+
+// sealRecord encrypts the plaintext with a random nonce, the nonce is put before the ciphertext
+func sealRecord(key, plaintext, additional []byte) ([]byte, error) {
+	aead, err := newRecordAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate the nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// sealRecordDeterministic makes the same ciphertext for the same plaintext to keep the keys of the
+// unchanged chunks, the nonce is derived from the plaintext.
+func sealRecordDeterministic(key, plaintext, additional []byte) ([]byte, error) {
+	aead, err := newRecordAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(plaintext)
+	nonce := mac.Sum(nil)[:aead.NonceSize()]
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func openRecord(key, sealed, additional []byte) ([]byte, error) {
+	aead, err := newRecordAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("the sealed data is too short: %w", ErrTamperedRecord)
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", ErrTamperedRecord)
+	}
+	return plaintext, nil
+}
+
+func newRecordAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make the cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make the cipher: %w", err)
+	}
+	return aead, nil
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package kvs
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/node/misc"
+)
+
+const (
+	// the credential of the request is accepted only for this duration after it is made
+	CREDENTIAL_LIFETIME = 60 * time.Second
+)
+
+// ResourceSigner signs the resources owned by the local account and verifies the resources are signed with the keys
+// certified for the owners by the authority (the seed). The keys published on the account records are not trusted
+// by themselves, so rewriting or removing them does not make forged resources valid.
+type ResourceSigner interface {
+	// GetPublicKey returns the key to publish on the account record
+	GetPublicKey() ed25519.PublicKey
+	// GetCertificate returns the certificate of the key made by the authority
+	GetCertificate() []byte
+	// Sign signs the resource owned by the local account. The resources of other accounts keep the signature,
+	// it returns core.ErrInvalidSignature if the content signed by the owner is changed.
+	Sign(resource core.SignedResource) error
+	// Verify returns core.ErrInvalidSignature if the resource is not signed by a node of the owner,
+	// the resources without the signature are rejected.
+	Verify(resource core.SignedResource) error
+	// MakeCredential returns the credential of the request for the content sent to the target node
+	MakeCredential(target string, content []byte) *core.Credential
+	// VerifyCredential returns core.ErrInvalidSignature if the credential is not made by a node of the account
+	// for the content, it is expired or it is for another node.
+	VerifyCredential(credential *core.Credential, target string, content []byte) error
+	// SignTombstone sets the credential proving the local account deleted the resource of the uuid
+	SignTombstone(tombstone *Tombstone, uuid string)
+	// VerifyTombstone returns core.ErrInvalidSignature if the tombstone does not have the credential made by a node
+	// of the account for the resource of the uuid, the tombstones are not expired unlike the credentials of requests.
+	VerifyTombstone(tombstone *Tombstone, uuid string) error
+}
+
+type resourceSignerImpl struct {
+	account     string
+	privateKey  ed25519.PrivateKey
+	certificate []byte
+	authority   ed25519.PublicKey
+}
+
+func NewResourceSigner(account string, privateKey ed25519.PrivateKey, certificate []byte, authority ed25519.PublicKey) ResourceSigner {
+	return &resourceSignerImpl{
+		account:     account,
+		privateKey:  privateKey,
+		certificate: certificate,
+		authority:   authority,
+	}
+}
+
+func (impl *resourceSignerImpl) GetPublicKey() ed25519.PublicKey {
+	return impl.privateKey.Public().(ed25519.PublicKey)
+}
+
+func (impl *resourceSignerImpl) GetCertificate() []byte {
+	return impl.certificate
+}
+
+func (impl *resourceSignerImpl) Sign(resource core.SignedResource) error {
+	if resource.GetMeta().Owner != impl.account {
+		if err := impl.Verify(resource); err != nil {
+			return fmt.Errorf("the resource of other account can not be signed: %w", err)
+		}
+		return nil
+	}
+	return core.Sign(resource, impl.privateKey, impl.certificate)
+}
+
+func (impl *resourceSignerImpl) Verify(resource core.SignedResource) error {
+	return core.VerifySignature(resource, impl.authority)
+}
+
+func (impl *resourceSignerImpl) MakeCredential(target string, content []byte) *core.Credential {
+	credential := &core.Credential{
+		Account:   impl.account,
+		Target:    target,
+		Timestamp: misc.GetTimestamp(),
+	}
+	core.SignCredential(credential, content, impl.privateKey, impl.certificate)
+	return credential
+}
+
+func (impl *resourceSignerImpl) VerifyCredential(credential *core.Credential, target string, content []byte) error {
+	if err := core.VerifyCredential(credential, content, impl.authority); err != nil {
+		return err
+	}
+	if credential.Target != target {
+		return fmt.Errorf("the credential is made for other node: %w", core.ErrInvalidSignature)
+	}
+	timestamp, err := time.Parse(time.RFC3339, credential.Timestamp)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp of the credential: %w", core.ErrInvalidSignature)
+	}
+	if elapsed := time.Since(timestamp); elapsed > CREDENTIAL_LIFETIME || elapsed < -CREDENTIAL_LIFETIME {
+		return fmt.Errorf("the credential is expired: %w", core.ErrInvalidSignature)
+	}
+	return nil
+}
+
+func (impl *resourceSignerImpl) SignTombstone(tombstone *Tombstone, uuid string) {
+	credential := &core.Credential{
+		Account:   impl.account,
+		Target:    uuid,
+		Timestamp: tombstone.DeletedAt,
+	}
+	core.SignCredential(credential, tombstone.getSignedContent(), impl.privateKey, impl.certificate)
+	tombstone.Credential = credential
+}
+
+func (impl *resourceSignerImpl) VerifyTombstone(tombstone *Tombstone, uuid string) error {
+	credential := tombstone.Credential
+	if err := core.VerifyCredential(credential, tombstone.getSignedContent(), impl.authority); err != nil {
+		return err
+	}
+	if credential.Target != uuid {
+		return fmt.Errorf("the tombstone is made for other resource: %w", core.ErrInvalidSignature)
+	}
+	if credential.Timestamp != tombstone.DeletedAt {
+		return fmt.Errorf("the time of the deletion is not signed: %w", core.ErrInvalidSignature)
+	}
+	return nil
+}
+
+// GetAuthenticatedAccount returns the account proved by the credential of the request sent to the target node.
+// The request is treated as anonymous and an empty string is returned if the credential is not given or invalid,
+// or the signer is not available to verify it, because the account written in the message can be forged.
+func GetAuthenticatedAccount(signer ResourceSigner, credential *core.Credential, target string, content []byte) string {
+	if signer == nil || credential == nil {
+		return ""
+	}
+	if err := signer.VerifyCredential(credential, target, content); err != nil {
+		log.Printf("the request is treated as anonymous: %s", err.Error())
+		return ""
+	}
+	return credential.Account
+}
@@ -0,0 +1,342 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package kvs
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"time"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/node/misc"
+	"github.com/llamerada-jp/oinari/node/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type resourceSignerTest struct {
+	suite.Suite
+	col          *mock.Colonio
+	authorityKey ed25519.PrivateKey
+}
+
+func NewResourceSignerTest() suite.TestingSuite {
+	_, authorityKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		panic(err)
+	}
+	return &resourceSignerTest{
+		col:          mock.NewColonioMock(),
+		authorityKey: authorityKey,
+	}
+}
+
+func (test *resourceSignerTest) makeSigner(account string, accountKey []byte) ResourceSigner {
+	privateKey := core.DeriveNodeKey(accountKey)
+	certificate := core.CertifyKey(test.authorityKey, account, privateKey.Public().(ed25519.PublicKey))
+	return NewResourceSigner(account, privateKey, certificate, test.authorityKey.Public().(ed25519.PublicKey))
+}
+
+func (test *resourceSignerTest) publishKey(account string, signer ResourceSigner) {
+	publicKey := signer.GetPublicKey()
+	err := NewAccountKvs(test.col, nil, signer).Set(&core.Account{
+		Meta: &core.ObjectMeta{
+			Type:        core.ResourceTypeAccount,
+			Name:        account,
+			Owner:       account,
+			CreatorNode: "01234567890123456789012345678901",
+			Uuid:        core.GenerateAccountUuid(account),
+		},
+		State: &core.AccountState{
+			Pods:  map[string]core.AccountPodState{},
+			Nodes: map[string]core.AccountNodeState{},
+			Keys: map[string]core.AccountKey{
+				core.GetKeyID(publicKey): {
+					PublicKey:   publicKey,
+					Timestamp:   misc.GetTimestamp(),
+					Certificate: signer.GetCertificate(),
+				},
+			},
+		},
+	})
+	test.NoError(err)
+}
+
+func (test *resourceSignerTest) makePod(owner string) *core.Pod {
+	return &core.Pod{
+		Meta: &core.ObjectMeta{
+			Type:        core.ResourceTypePod,
+			Name:        "pod",
+			Owner:       owner,
+			CreatorNode: "01234567890123456789012345678901",
+			Uuid:        core.GeneratePodUuid(),
+		},
+		Spec: &core.PodSpec{
+			Containers: []core.ContainerSpec{
+				{
+					Name:          "test",
+					Image:         "http://localhost/dummy.wasm",
+					Runtime:       []string{"go:1.20"},
+					RestartPolicy: core.RestartPolicyAlways,
+				},
+			},
+		},
+		Status: &core.PodStatus{
+			RunningNode: "01234567890123456789012345678901",
+			ContainerStatuses: []core.ContainerStatus{
+				{
+					ContainerID: "dummy",
+					Image:       "http://localhost/dummy.wasm",
+					State: core.ContainerState{
+						Running: &core.ContainerStateRunning{
+							StartedAt: misc.GetTimestamp(),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (test *resourceSignerTest) TestSignedPod() {
+	test.col.DeleteKVSAll()
+	ownerSigner := test.makeSigner("owner", []byte("owner key"))
+	otherSigner := test.makeSigner("other", []byte("other key"))
+	ownerKvs := NewPodKvs(test.col, nil, ownerSigner)
+	otherKvs := NewPodKvs(test.col, nil, otherSigner)
+	// the store without the signer writes the records like a malicious node
+	rawKvs := NewPodKvs(test.col, nil, nil)
+
+	/// normal pattern: the pod signed by the owner is read by other accounts without the account record
+	pod := test.makePod("owner")
+	test.NoError(ownerKvs.Create(pod))
+	test.NotNil(pod.Meta.Signature)
+	read, err := otherKvs.Get(pod.Meta.Uuid)
+	test.NoError(err)
+	test.Equal(pod.Meta.Signature, read.Meta.Signature)
+
+	/// normal pattern: the status and the scheduled creator node are written by other accounts
+	read.Spec.TargetNode = read.Meta.CreatorNode
+	read.Status.RunningNode = "0123456789abcdef0123456789abcdef"
+	test.NoError(otherKvs.Update(read))
+	read, err = ownerKvs.Get(pod.Meta.Uuid)
+	test.NoError(err)
+	test.Equal("0123456789abcdef0123456789abcdef", read.Status.RunningNode)
+
+	/// abnormal: other accounts can not write the content signed by the owner
+	read.Spec.TargetNode = "0123456789abcdef0123456789abcdef"
+	test.ErrorIs(otherKvs.Update(read), core.ErrInvalidSignature)
+	read.Spec.TargetNode = read.Meta.CreatorNode
+	read.Spec.Containers[0].Image = "http://localhost/other.wasm"
+	test.ErrorIs(otherKvs.Update(read), core.ErrInvalidSignature)
+
+	/// abnormal: the spec is modified by a malicious node
+	test.NoError(rawKvs.Update(read))
+	_, err = otherKvs.Get(pod.Meta.Uuid)
+	test.ErrorIs(err, core.ErrInvalidSignature)
+	_, err = ownerKvs.Get(pod.Meta.Uuid)
+	test.ErrorIs(err, core.ErrInvalidSignature)
+
+	/// abnormal: the pod of the owner is created without the signature
+	forged := test.makePod("owner")
+	test.ErrorIs(otherKvs.Create(forged), core.ErrInvalidSignature)
+	test.NoError(rawKvs.Create(forged))
+	_, err = otherKvs.Get(forged.Meta.Uuid)
+	test.ErrorIs(err, core.ErrInvalidSignature)
+
+	/// abnormal: the pod is signed with the key not certified by the authority
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	test.NoError(err)
+	forged = test.makePod("owner")
+	test.NoError(core.Sign(forged, privateKey, core.CertifyKey(privateKey, "owner", privateKey.Public().(ed25519.PublicKey))))
+	test.NoError(rawKvs.Create(forged))
+	_, err = otherKvs.Get(forged.Meta.Uuid)
+	test.ErrorIs(err, core.ErrInvalidSignature)
+
+	/// abnormal: the pod is signed with the key certified for other account
+	forged = test.makePod("owner")
+	test.NoError(core.Sign(forged, core.DeriveNodeKey([]byte("other key")), otherSigner.GetCertificate()))
+	test.NoError(rawKvs.Create(forged))
+	_, err = ownerKvs.Get(forged.Meta.Uuid)
+	test.ErrorIs(err, core.ErrInvalidSignature)
+}
+
+func (test *resourceSignerTest) getTombstone(key string) *Tombstone {
+	val, err := test.col.KvsGet(key)
+	test.NoError(err)
+	raw, err := val.GetBinary()
+	test.NoError(err)
+	tombstone, err := DecodeTombstone(raw)
+	test.NoError(err)
+	return tombstone
+}
+
+func (test *resourceSignerTest) TestSignedTombstone() {
+	test.col.DeleteKVSAll()
+	ownerSigner := test.makeSigner("owner", []byte("owner key"))
+	otherSigner := test.makeSigner("other", []byte("other key"))
+	ownerKvs := NewPodKvs(test.col, nil, ownerSigner)
+	otherKvs := NewPodKvs(test.col, nil, otherSigner)
+	rawKvs := NewPodKvs(test.col, nil, nil)
+
+	/// normal pattern: the tombstone is signed by the account deleted the pod
+	pod := test.makePod("owner")
+	key := string(core.ResourceTypePod) + "/" + pod.Meta.Uuid
+	test.NoError(ownerKvs.Create(pod))
+	test.NoError(otherKvs.Delete(pod.Meta.Uuid))
+	_, err := ownerKvs.Get(pod.Meta.Uuid)
+	test.ErrorIs(err, ErrNotFound)
+	signed := test.getTombstone(key)
+	test.Equal("other", signed.Credential.Account)
+	test.Equal(pod.Meta.Uuid, signed.Credential.Target)
+
+	// the pod is created again continuing the version
+	test.NoError(ownerKvs.Create(pod))
+	read, err := otherKvs.Get(pod.Meta.Uuid)
+	test.NoError(err)
+	test.Equal(uint64(3), read.Meta.ResourceVersion)
+
+	/// abnormal: the tombstone without the credential is not treated as deleted
+	test.NoError(rawKvs.Delete(pod.Meta.Uuid))
+	_, err = otherKvs.Get(pod.Meta.Uuid)
+	test.ErrorIs(err, ErrInvalid)
+	test.ErrorIs(err, core.ErrInvalidSignature)
+	// the store without the signer does not check it
+	_, err = rawKvs.Get(pod.Meta.Uuid)
+	test.ErrorIs(err, ErrNotFound)
+
+	/// abnormal: the tombstone of other resource is copied
+	other := test.makePod("owner")
+	test.NoError(ownerKvs.Create(other))
+	test.NoError(ownerKvs.Delete(other.Meta.Uuid))
+	raw, err := test.getTombstone(string(core.ResourceTypePod) + "/" + other.Meta.Uuid).Encode()
+	test.NoError(err)
+	test.NoError(test.col.KvsSet(key, raw, 0))
+	_, err = ownerKvs.Get(pod.Meta.Uuid)
+	test.ErrorIs(err, ErrInvalid)
+
+	/// abnormal: the time of the deletion is rewritten
+	tombstone := test.getTombstone(string(core.ResourceTypePod) + "/" + other.Meta.Uuid)
+	tombstone.DeletedAt = time.Now().Add(-2 * TOMBSTONE_TTL).Format(time.RFC3339)
+	raw, err = tombstone.Encode()
+	test.NoError(err)
+	test.NoError(test.col.KvsSet(string(core.ResourceTypePod)+"/"+other.Meta.Uuid, raw, 0))
+	_, err = ownerKvs.Get(other.Meta.Uuid)
+	test.ErrorIs(err, ErrInvalid)
+
+	/// abnormal: the nil value and the broken tombstone are not treated as deleted
+	test.NoError(test.col.KvsSet(key, nil, 0))
+	_, err = ownerKvs.Get(pod.Meta.Uuid)
+	test.ErrorIs(err, ErrInvalid)
+	_, err = rawKvs.Get(pod.Meta.Uuid)
+	test.ErrorIs(err, ErrNotFound)
+	test.NoError(test.col.KvsSet(key, append(bytes.Clone(tombstonePrefix), []byte("broken")...), 0))
+	_, err = ownerKvs.Get(pod.Meta.Uuid)
+	test.ErrorIs(err, ErrInvalid)
+	test.NotErrorIs(err, ErrNotFound)
+
+	/// normal pattern: the invalid tombstone is replaced by deleting the pod again
+	test.NoError(ownerKvs.Delete(pod.Meta.Uuid))
+	_, err = otherKvs.Get(pod.Meta.Uuid)
+	test.ErrorIs(err, ErrNotFound)
+}
+
+func (test *resourceSignerTest) TestSignedAccount() {
+	test.col.DeleteKVSAll()
+	ownerSigner := test.makeSigner("owner", []byte("owner key"))
+	otherSigner := test.makeSigner("other", []byte("other key"))
+	test.publishKey("owner", ownerSigner)
+	ownerKvs := NewAccountKvs(test.col, nil, ownerSigner)
+	otherKvs := NewAccountKvs(test.col, nil, otherSigner)
+	rawKvs := NewAccountKvs(test.col, nil, nil)
+
+	/// normal pattern: the states are written by other accounts
+	account, err := otherKvs.Get("owner")
+	test.NoError(err)
+	test.NotNil(account.Meta.Signature)
+	account.State.Pods[core.GeneratePodUuid()] = core.AccountPodState{
+		RunningNode: "0123456789abcdef0123456789abcdef",
+		Timestamp:   misc.GetTimestamp(),
+	}
+	test.NoError(otherKvs.Set(account))
+	_, err = ownerKvs.Get("owner")
+	test.NoError(err)
+
+	/// normal pattern: adding or removing the keys does not break the record, the keys are not trusted by themselves
+	publicKey := otherSigner.GetPublicKey()
+	account.State.Keys[core.GetKeyID(publicKey)] = core.AccountKey{
+		PublicKey:   publicKey,
+		Timestamp:   misc.GetTimestamp(),
+		Certificate: otherSigner.GetCertificate(),
+	}
+	test.NoError(otherKvs.Set(account))
+	_, err = ownerKvs.Get("owner")
+	test.NoError(err)
+	account.State.Keys = nil
+	test.NoError(otherKvs.Set(account))
+	_, err = ownerKvs.Get("owner")
+	test.NoError(err)
+
+	/// abnormal: the record is rewritten with the key of other account, it is invalid but kept
+	test.NoError(core.Sign(account, core.DeriveNodeKey([]byte("other key")), otherSigner.GetCertificate()))
+	test.NoError(rawKvs.Set(account))
+	_, err = ownerKvs.Get("owner")
+	test.ErrorIs(err, ErrInvalid)
+	test.ErrorIs(err, core.ErrInvalidSignature)
+	val, err := test.col.KvsGet(string(core.ResourceTypeAccount) + "/" + core.GenerateAccountUuid("owner"))
+	test.NoError(err)
+	test.False(isDeleted(val))
+
+	/// normal pattern: the owner writes the record again
+	test.publishKey("owner", ownerSigner)
+	account, err = otherKvs.Get("owner")
+	test.NoError(err)
+	test.NotNil(account)
+	test.NoError(ownerSigner.Verify(account))
+}
+
+func (test *resourceSignerTest) TestCredential() {
+	ownerSigner := test.makeSigner("owner", []byte("owner key"))
+	otherSigner := test.makeSigner("other", []byte("other key"))
+	target := "0123456789abcdef0123456789abcdef"
+
+	/// normal pattern: the account is proved by the credential
+	credential := ownerSigner.MakeCredential(target, []byte("content"))
+	test.NoError(otherSigner.VerifyCredential(credential, target, []byte("content")))
+	test.Equal("owner", GetAuthenticatedAccount(otherSigner, credential, target, []byte("content")))
+
+	/// abnormal: the credential for other content or other node
+	test.ErrorIs(otherSigner.VerifyCredential(credential, target, []byte("other")), core.ErrInvalidSignature)
+	test.ErrorIs(otherSigner.VerifyCredential(credential, "fedcba9876543210fedcba9876543210", []byte("content")), core.ErrInvalidSignature)
+
+	/// abnormal: the account of the credential is rewritten
+	forged := *credential
+	forged.Account = "other"
+	test.ErrorIs(otherSigner.VerifyCredential(&forged, target, []byte("content")), core.ErrInvalidSignature)
+	test.Equal("", GetAuthenticatedAccount(otherSigner, &forged, target, []byte("content")))
+
+	/// abnormal: the credential is expired
+	expired := &core.Credential{
+		Account:   "owner",
+		Target:    target,
+		Timestamp: misc.TimeToTimestamp(time.Now().Add(-2 * CREDENTIAL_LIFETIME)),
+	}
+	core.SignCredential(expired, []byte("content"), core.DeriveNodeKey([]byte("owner key")), ownerSigner.GetCertificate())
+	test.ErrorIs(otherSigner.VerifyCredential(expired, target, []byte("content")), core.ErrInvalidSignature)
+
+	/// abnormal: the request is anonymous without the credential or the signer
+	test.Equal("", GetAuthenticatedAccount(otherSigner, nil, target, []byte("content")))
+	test.Equal("", GetAuthenticatedAccount(nil, credential, target, []byte("content")))
+}
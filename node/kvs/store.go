@@ -0,0 +1,487 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package kvs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/llamerada-jp/colonio/go/colonio"
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/node/misc"
+)
+
+var (
+	ErrNotFound      = errors.New("the record is not exists")
+	ErrAlreadyExists = errors.New("there is an duplicate record")
+	// ErrInvalid is returned when the record failed to decode, validate or verify, the record is kept as it is
+	ErrInvalid = errors.New("the record is invalid")
+	// ErrConflict is returned when the record is updated by another writer after it was read,
+	// the writes from other nodes are not always detected because colonio KVS does not support CAS
+	ErrConflict = errors.New("the record is updated by another writer")
+)
+
+const (
+	// max number of attempts in RetryOnConflict
+	CONFLICT_RETRY_MAX      = 5
+	CONFLICT_RETRY_INTERVAL = 100 * time.Millisecond
+)
+
+// RetryOnConflict calls f again while it returns ErrConflict, f should read the resource each time.
+func RetryOnConflict(f func() error) error {
+	var err error
+	for i := 0; i < CONFLICT_RETRY_MAX; i++ {
+		err = f()
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+		time.Sleep(CONFLICT_RETRY_INTERVAL * time.Duration(i+1))
+	}
+	return err
+}
+
+// Resource is a type stored in the KVS with the common meta data.
+type Resource interface {
+	comparable
+	GetMeta() *core.ObjectMeta
+}
+
+// Codec converts the resource to the value stored in the KVS and back.
+type Codec[T any] interface {
+	Encode(resource T) ([]byte, error)
+	Decode(raw []byte) (T, error)
+}
+
+type jsonCodec[T any] struct{}
+
+func NewJSONCodec[T any]() Codec[T] {
+	return &jsonCodec[T]{}
+}
+
+func (c *jsonCodec[T]) Encode(resource T) ([]byte, error) {
+	return json.Marshal(resource)
+}
+
+func (c *jsonCodec[T]) Decode(raw []byte) (T, error) {
+	var resource T
+	err := json.Unmarshal(raw, &resource)
+	return resource, err
+}
+
+type versionedJSONCodec[T Resource] struct {
+	registry     *core.ConverterRegistry
+	resourceType core.ResourceType
+}
+
+// NewVersionedJSONCodec returns the JSON codec writing the current apiVersion of the type,
+// records of older versions are upgraded by the registry when they are decoded.
+func NewVersionedJSONCodec[T Resource](registry *core.ConverterRegistry, resourceType core.ResourceType) Codec[T] {
+	return &versionedJSONCodec[T]{
+		registry:     registry,
+		resourceType: resourceType,
+	}
+}
+
+func (c *versionedJSONCodec[T]) Encode(resource T) ([]byte, error) {
+	if meta := resource.GetMeta(); meta != nil {
+		meta.APIVersion = c.registry.GetCurrentVersion(c.resourceType)
+	}
+	return json.Marshal(resource)
+}
+
+func (c *versionedJSONCodec[T]) Decode(raw []byte) (T, error) {
+	var resource T
+	upgraded, err := c.registry.Upgrade(c.resourceType, raw)
+	if err != nil {
+		return resource, err
+	}
+	err = json.Unmarshal(upgraded, &resource)
+	return resource, err
+}
+
+type StoreConfig[T Resource] struct {
+	Type core.ResourceType
+	// Codec is the versioned JSON codec with core.DefaultConverters if nil
+	Codec Codec[T]
+	// Validate is called before writing and after reading the resource
+	Validate func(resource T) error
+	// ID returns the id of the resource, the uuid in the meta is used if nil
+	ID func(resource T) string
+	// Key returns the key in the KVS for the id, "<type>/<id>" is used if nil
+	Key func(id string) string
+	// Watcher notifies the writes of the resources to the watchers, Watch is not supported if nil
+	Watcher WatchHub
+	// Signer signs the resources of the local account before writing and verifies the resources after reading,
+	// the resource should implement core.SignedResource. The tombstones are signed by the deleter and verified too,
+	// and nil values are treated as invalid because anyone can write them. The signatures are not checked if nil.
+	Signer ResourceSigner
+}
+
+// Store reads and writes the resources of a type in the KVS. colonio KVS does not have a delete method,
+// so deleted records are replaced with tombstones, records set nil are also treated as deleted.
+// colonio KVS does not have compare-and-swap either, the version check and the write are serialized only on
+// this node and the last write wins if other nodes write the same record between them.
+type Store[T Resource] interface {
+	// Create writes the resource with version 1 or the next version of the tombstone,
+	// it returns ErrAlreadyExists if the record exists.
+	Create(resource T) error
+	// Update writes the resource if its resource version is the same as the stored one and increments the version,
+	// it returns ErrConflict if the record was updated after it was read and the previous resource on success.
+	// The conflict check covers only the writes before the check, a write from another node between the check
+	// and the write of this node is overwritten without ErrConflict.
+	Update(resource T) (T, error)
+	// Set writes the resource whether the record exists or not, the resource version is written as it is
+	// and not checked, so it overwrites the writes from other nodes.
+	Set(resource T) error
+	// Get returns ErrNotFound if the record does not exist and ErrInvalid if the record is broken or forged,
+	// the invalid record is not removed by reading it, the writers should overwrite or delete it.
+	Get(id string) (T, error)
+	// Delete replaces the record with a tombstone and returns the deleted resource,
+	// it is the zero value if the record did not exist.
+	Delete(id string) (T, error)
+	// Watch returns the channel of the events for the resource and the function to stop watching,
+	// it returns ErrWatchNotSupported if the store does not have the watcher.
+	Watch(id string) (<-chan *WatchEvent, func(), error)
+	Key(id string) string
+}
+
+type storeImpl[T Resource] struct {
+	col         colonio.Colonio
+	config      StoreConfig[T]
+	progressing *misc.UniqueSet
+}
+
+func NewStore[T Resource](col colonio.Colonio, config StoreConfig[T]) Store[T] {
+	if config.Codec == nil {
+		config.Codec = NewVersionedJSONCodec[T](core.DefaultConverters, config.Type)
+	}
+	if config.ID == nil {
+		config.ID = func(resource T) string {
+			return resource.GetMeta().Uuid
+		}
+	}
+	if config.Key == nil {
+		prefix := string(config.Type) + "/"
+		config.Key = func(id string) string {
+			return prefix + id
+		}
+	}
+
+	return &storeImpl[T]{
+		col:         col,
+		config:      config,
+		progressing: misc.NewUniqueSet(),
+	}
+}
+
+func (impl *storeImpl[T]) Key(id string) string {
+	return impl.config.Key(id)
+}
+
+func (impl *storeImpl[T]) Watch(id string) (<-chan *WatchEvent, func(), error) {
+	if impl.config.Watcher == nil {
+		return nil, nil, fmt.Errorf("failed to watch %s data: %w", impl.config.Type, ErrWatchNotSupported)
+	}
+	ch, cancel := impl.config.Watcher.Watch(impl.Key(id))
+	return ch, cancel, nil
+}
+
+func (impl *storeImpl[T]) notify(key string, version uint64, deleted bool) {
+	if impl.config.Watcher == nil {
+		return
+	}
+	impl.config.Watcher.Notify(&WatchEvent{
+		Key:             key,
+		ResourceVersion: version,
+		Deleted:         deleted,
+	})
+}
+
+func (impl *storeImpl[T]) validate(resource T) error {
+	var zero T
+	if resource == zero {
+		return fmt.Errorf("%s resource should be specified", impl.config.Type)
+	}
+	if impl.config.Validate == nil {
+		return nil
+	}
+	return impl.config.Validate(resource)
+}
+
+func (impl *storeImpl[T]) sign(resource T) error {
+	if impl.config.Signer == nil {
+		return nil
+	}
+	signed, ok := any(resource).(core.SignedResource)
+	if !ok {
+		return fmt.Errorf("%s resource does not support the signature", impl.config.Type)
+	}
+	return impl.config.Signer.Sign(signed)
+}
+
+func (impl *storeImpl[T]) verify(resource T) error {
+	if impl.config.Signer == nil {
+		return nil
+	}
+	signed, ok := any(resource).(core.SignedResource)
+	if !ok {
+		return fmt.Errorf("%s resource does not support the signature", impl.config.Type)
+	}
+	return impl.config.Signer.Verify(signed)
+}
+
+func (impl *storeImpl[T]) Create(resource T) error {
+	if err := impl.validate(resource); err != nil {
+		return fmt.Errorf("failed to create %s data: %w", impl.config.Type, err)
+	}
+	if err := impl.sign(resource); err != nil {
+		return fmt.Errorf("failed to sign %s data: %w", impl.config.Type, err)
+	}
+
+	key := impl.Key(impl.config.ID(resource))
+	impl.progressing.Insert(key)
+	defer impl.progressing.Remove(key)
+
+	val, err := impl.col.KvsGet(key)
+	if errors.Is(err, colonio.ErrKvsNotFound) {
+		raw, err := impl.encodeWithVersion(resource, 1)
+		if err != nil {
+			return err
+		}
+		err = impl.col.KvsSet(key, raw, colonio.KvsProhibitOverwrite)
+		if err != nil {
+			return fmt.Errorf("failed to set %s data with prohibit overwrite option: %w", impl.config.Type, err)
+		}
+		impl.notify(key, 1, false)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check for the existence of the %s data: %w", impl.config.Type, err)
+	}
+
+	_, tombstone, err := impl.decode(key, val)
+	if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to create %s data: %w", impl.config.Type, ErrAlreadyExists)
+	}
+
+	// TODO: this implement might occur collision, fix this after delete feature is implemented in colonio
+	version := uint64(1)
+	if tombstone != nil {
+		version = tombstone.ResourceVersion + 1
+	}
+	raw, err := impl.encodeWithVersion(resource, version)
+	if err != nil {
+		return err
+	}
+	err = impl.col.KvsSet(key, raw, 0)
+	if err != nil {
+		return fmt.Errorf("failed to set %s data: %w", impl.config.Type, err)
+	}
+	impl.notify(key, version, false)
+	return nil
+}
+
+func (impl *storeImpl[T]) encodeWithVersion(resource T, version uint64) ([]byte, error) {
+	resource.GetMeta().ResourceVersion = version
+	raw, err := impl.config.Codec.Encode(resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s data: %w", impl.config.Type, err)
+	}
+	return raw, nil
+}
+
+func (impl *storeImpl[T]) Update(resource T) (T, error) {
+	var zero T
+	if err := impl.validate(resource); err != nil {
+		return zero, fmt.Errorf("failed to update %s data: %w", impl.config.Type, err)
+	}
+	if err := impl.sign(resource); err != nil {
+		return zero, fmt.Errorf("failed to sign %s data: %w", impl.config.Type, err)
+	}
+
+	key := impl.Key(impl.config.ID(resource))
+
+	// progressing serializes the check and the write only on this node as written in the doc of Store.
+	// TODO: writes from other nodes between them are not detected, fix this after colonio supports CAS
+	impl.progressing.Insert(key)
+	defer impl.progressing.Remove(key)
+
+	current, err := impl.get(key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return zero, fmt.Errorf("the %s data might be deleted: %w", impl.config.Type, err)
+		}
+		return zero, fmt.Errorf("failed to check for the existence of the %s data: %w", impl.config.Type, err)
+	}
+
+	meta := resource.GetMeta()
+	currentVersion := current.GetMeta().ResourceVersion
+	if meta.ResourceVersion != 0 && meta.ResourceVersion != currentVersion {
+		return zero, fmt.Errorf("failed to update %s data (version %d, stored %d): %w",
+			impl.config.Type, meta.ResourceVersion, currentVersion, ErrConflict)
+	}
+
+	version := meta.ResourceVersion
+	meta.ResourceVersion = currentVersion + 1
+	raw, err := impl.config.Codec.Encode(resource)
+	if err != nil {
+		meta.ResourceVersion = version
+		return zero, fmt.Errorf("failed to update %s data: %w", impl.config.Type, err)
+	}
+
+	if err := impl.col.KvsSet(key, raw, 0); err != nil {
+		meta.ResourceVersion = version
+		return zero, fmt.Errorf("failed to update %s data: %w", impl.config.Type, err)
+	}
+	impl.notify(key, meta.ResourceVersion, false)
+	return current, nil
+}
+
+func (impl *storeImpl[T]) Set(resource T) error {
+	if err := impl.validate(resource); err != nil {
+		return fmt.Errorf("failed to set %s data: %w", impl.config.Type, err)
+	}
+	if err := impl.sign(resource); err != nil {
+		return fmt.Errorf("failed to sign %s data: %w", impl.config.Type, err)
+	}
+
+	raw, err := impl.config.Codec.Encode(resource)
+	if err != nil {
+		return fmt.Errorf("failed to set %s data: %w", impl.config.Type, err)
+	}
+
+	key := impl.Key(impl.config.ID(resource))
+	impl.progressing.Insert(key)
+	defer impl.progressing.Remove(key)
+
+	if err := impl.col.KvsSet(key, raw, 0); err != nil {
+		return fmt.Errorf("failed to set %s data: %w", impl.config.Type, err)
+	}
+	impl.notify(key, resource.GetMeta().ResourceVersion, false)
+	return nil
+}
+
+func (impl *storeImpl[T]) Get(id string) (T, error) {
+	key := impl.Key(id)
+	impl.progressing.Insert(key)
+	defer impl.progressing.Remove(key)
+
+	return impl.get(key)
+}
+
+// get should be called in progressing of the key
+func (impl *storeImpl[T]) get(key string) (T, error) {
+	var zero T
+	val, err := impl.col.KvsGet(key)
+	if err != nil {
+		if errors.Is(err, colonio.ErrKvsNotFound) {
+			return zero, ErrNotFound
+		}
+		return zero, fmt.Errorf("failed to get raw data: %w", err)
+	}
+
+	resource, _, err := impl.decode(key, val)
+	return resource, err
+}
+
+// decode returns ErrNotFound with the tombstone if the record is deleted, the tombstone is nil for nil records.
+// The store with the signer returns ErrInvalid for nil records and tombstones not signed for the key.
+func (impl *storeImpl[T]) decode(key string, val colonio.Value) (T, *Tombstone, error) {
+	var zero T
+	if val.IsNil() {
+		if impl.config.Signer != nil {
+			return zero, nil, fmt.Errorf("the nil %s data is not signed: %w", impl.config.Type, ErrInvalid)
+		}
+		return zero, nil, ErrNotFound
+	}
+
+	raw, err := val.GetBinary()
+	if err != nil {
+		return zero, nil, fmt.Errorf("invalid raw data format: %w", err)
+	}
+
+	tombstone, err := DecodeTombstone(raw)
+	if err != nil {
+		if impl.config.Signer != nil {
+			return zero, nil, fmt.Errorf("failed to decode the tombstone of %s data: %w: %w", impl.config.Type, ErrInvalid, err)
+		}
+		// the version of the broken tombstone is unknown
+		return zero, &Tombstone{}, ErrNotFound
+	}
+	if tombstone != nil {
+		if impl.config.Signer != nil {
+			if err := impl.config.Signer.VerifyTombstone(tombstone, GetTombstoneUuid(key)); err != nil {
+				return zero, nil, fmt.Errorf("failed to verify the tombstone of %s data: %w: %w", impl.config.Type, ErrInvalid, err)
+			}
+		}
+		return zero, tombstone, ErrNotFound
+	}
+
+	resource, err := impl.config.Codec.Decode(raw)
+	// the record might be written by a newer node, it is not invalid
+	if errors.Is(err, core.ErrUnknownAPIVersion) {
+		return zero, nil, fmt.Errorf("failed to decode raw data: %w", err)
+	}
+	if err != nil {
+		return zero, nil, fmt.Errorf("failed to decode raw data: %w: %w", ErrInvalid, err)
+	}
+
+	if err := impl.validate(resource); err != nil {
+		return zero, nil, fmt.Errorf("failed to validate %s data: %w: %w", impl.config.Type, ErrInvalid, err)
+	}
+	if err := impl.verify(resource); err != nil {
+		return zero, nil, fmt.Errorf("failed to verify %s data: %w: %w", impl.config.Type, ErrInvalid, err)
+	}
+	return resource, nil, nil
+}
+
+func (impl *storeImpl[T]) Delete(id string) (T, error) {
+	var prev T
+	key := impl.Key(id)
+	impl.progressing.Insert(key)
+	defer impl.progressing.Remove(key)
+
+	// write the tombstone even if the record does not exist, is invalid or failed to read,
+	// the version is unknown in that case
+	version := uint64(0)
+	if val, err := impl.col.KvsGet(key); err == nil {
+		resource, tombstone, err := impl.decode(key, val)
+		if tombstone != nil {
+			// already deleted, keep the time of the deletion
+			return prev, nil
+		}
+		if err == nil {
+			prev = resource
+			version = resource.GetMeta().ResourceVersion + 1
+		}
+	}
+
+	tombstone := NewTombstone(impl.col.GetLocalNid(), version)
+	if impl.config.Signer != nil {
+		impl.config.Signer.SignTombstone(tombstone, GetTombstoneUuid(key))
+	}
+	raw, err := tombstone.Encode()
+	if err != nil {
+		return prev, err
+	}
+	if err := impl.col.KvsSet(key, raw, 0); err != nil {
+		return prev, fmt.Errorf("failed to delete the %s record: %w", impl.config.Type, err)
+	}
+	impl.notify(key, version, true)
+	return prev, nil
+}
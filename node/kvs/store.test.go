@@ -0,0 +1,411 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package kvs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/llamerada-jp/colonio/go/colonio"
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/node/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeTest struct {
+	suite.Suite
+	col *mock.Colonio
+}
+
+// reversedCodec is a codec other than JSON to check the codec is pluggable
+type reversedCodec struct {
+	json Codec[*core.Record]
+}
+
+func (c *reversedCodec) Encode(record *core.Record) ([]byte, error) {
+	raw, err := c.json.Encode(record)
+	if err != nil {
+		return nil, err
+	}
+	return reverse(raw), nil
+}
+
+func (c *reversedCodec) Decode(raw []byte) (*core.Record, error) {
+	return c.json.Decode(reverse(raw))
+}
+
+func reverse(raw []byte) []byte {
+	reversed := bytes.Clone(raw)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	return reversed
+}
+
+func NewStoreTest() suite.TestingSuite {
+	return &storeTest{
+		col: mock.NewColonioMock(),
+	}
+}
+
+func (test *storeTest) makeRecord(uuid string) *core.Record {
+	return &core.Record{
+		Meta: &core.ObjectMeta{
+			Type:        core.ResourceTypeRecord,
+			Name:        "record",
+			Owner:       "owner",
+			CreatorNode: "01234567890123456789012345678901",
+			Uuid:        uuid,
+		},
+		Data: &core.RecordData{
+			Entries: map[string]core.RecordEntry{},
+		},
+	}
+}
+
+func (test *storeTest) TestCodecAndKey() {
+	store := NewStore(test.col, StoreConfig[*core.Record]{
+		Type:     core.ResourceTypeRecord,
+		Codec:    &reversedCodec{json: NewJSONCodec[*core.Record]()},
+		Validate: (*core.Record).Validate,
+		Key: func(id string) string {
+			return "custom/" + id
+		},
+	})
+
+	uuid := core.GeneratePodUuid()
+	test.NoError(store.Create(test.makeRecord(uuid)))
+	test.ErrorIs(store.Create(test.makeRecord(uuid)), ErrAlreadyExists)
+
+	val, err := test.col.KvsGet("custom/" + uuid)
+	test.NoError(err)
+	raw, err := val.GetBinary()
+	test.NoError(err)
+	test.Equal(byte('{'), raw[len(raw)-1])
+
+	record, err := store.Get(uuid)
+	test.NoError(err)
+	test.Equal(uuid, record.Meta.Uuid)
+	test.Equal(uint64(1), record.Meta.ResourceVersion)
+}
+
+func (test *storeTest) TestUpdateAndDelete() {
+	store := NewStore(test.col, StoreConfig[*core.Record]{
+		Type:     core.ResourceTypeRecord,
+		Validate: (*core.Record).Validate,
+	})
+
+	uuid := core.GeneratePodUuid()
+	_, err := store.Update(test.makeRecord(uuid))
+	test.ErrorIs(err, ErrNotFound)
+
+	test.NoError(store.Create(test.makeRecord(uuid)))
+	record := test.makeRecord(uuid)
+	record.Meta.Name = "updated"
+	prev, err := store.Update(record)
+	test.NoError(err)
+	test.Equal("record", prev.Meta.Name)
+	test.Equal(uint64(2), record.Meta.ResourceVersion)
+
+	// set does not check the version
+	record.Meta.ResourceVersion = 1
+	test.NoError(store.Set(record))
+
+	prev, err = store.Delete(uuid)
+	test.NoError(err)
+	test.Equal("updated", prev.Meta.Name)
+	_, err = store.Get(uuid)
+	test.ErrorIs(err, ErrNotFound)
+
+	// delete the record not exists
+	prev, err = store.Delete(uuid)
+	test.NoError(err)
+	test.Nil(prev)
+
+	// nil and invalid resources are not written
+	test.Error(store.Set(nil))
+	invalid := test.makeRecord(uuid)
+	invalid.Meta.Owner = ""
+	test.Error(store.Set(invalid))
+}
+
+func (test *storeTest) TestInvalidRecord() {
+	store := NewStore(test.col, StoreConfig[*core.Record]{
+		Type:     core.ResourceTypeRecord,
+		Validate: (*core.Record).Validate,
+	})
+
+	uuid := core.GeneratePodUuid()
+	key := store.Key(uuid)
+	raw := []byte(fmt.Sprintf(`{"meta":{"type":"record","uuid":"%s"}}`, uuid))
+	test.NoError(test.col.KvsSet(key, raw, 0))
+
+	// the invalid record is kept and the error is returned
+	_, err := store.Get(uuid)
+	test.ErrorIs(err, ErrInvalid)
+	test.NotErrorIs(err, ErrNotFound)
+	val, err := test.col.KvsGet(key)
+	test.NoError(err)
+	stored, err := val.GetBinary()
+	test.NoError(err)
+	test.Equal(raw, stored)
+
+	// the broken record is kept too
+	test.NoError(test.col.KvsSet(key, []byte("broken"), 0))
+	_, err = store.Get(uuid)
+	test.ErrorIs(err, ErrInvalid)
+	test.False(isDeleted(test.getValue(key)))
+
+	// it can be overwritten or deleted by the writers
+	test.NoError(store.Set(test.makeRecord(uuid)))
+	_, err = store.Get(uuid)
+	test.NoError(err)
+	test.NoError(test.col.KvsSet(key, []byte("broken"), 0))
+	_, err = store.Delete(uuid)
+	test.NoError(err)
+	_, err = store.Get(uuid)
+	test.ErrorIs(err, ErrNotFound)
+}
+
+// isDeleted returns true if the value is nil or a tombstone
+func isDeleted(val colonio.Value) bool {
+	if val.IsNil() {
+		return true
+	}
+	raw, err := val.GetBinary()
+	if err != nil {
+		return false
+	}
+	tombstone, err := DecodeTombstone(raw)
+	return err == nil && tombstone != nil
+}
+
+func (test *storeTest) getValue(key string) colonio.Value {
+	val, err := test.col.KvsGet(key)
+	test.NoError(err)
+	return val
+}
+
+func (test *storeTest) getTombstone(key string) *Tombstone {
+	val, err := test.col.KvsGet(key)
+	test.NoError(err)
+	raw, err := val.GetBinary()
+	test.NoError(err)
+	tombstone, err := DecodeTombstone(raw)
+	test.NoError(err)
+	return tombstone
+}
+
+func (test *storeTest) TestTombstone() {
+	store := NewStore(test.col, StoreConfig[*core.Record]{
+		Type:     core.ResourceTypeRecord,
+		Validate: (*core.Record).Validate,
+	})
+
+	uuid := core.GeneratePodUuid()
+	record := test.makeRecord(uuid)
+	test.NoError(store.Create(record))
+	_, err := store.Update(record)
+	test.NoError(err)
+	stale := test.makeRecord(uuid)
+	stale.Meta.ResourceVersion = record.Meta.ResourceVersion
+
+	_, err = store.Delete(uuid)
+	test.NoError(err)
+	tombstone := test.getTombstone(store.Key(uuid))
+	test.Equal(uint64(3), tombstone.ResourceVersion)
+	test.Equal(test.col.LocalNid, tombstone.Deleter)
+	test.False(tombstone.IsExpired(time.Now()))
+	test.True(tombstone.IsExpired(time.Now().Add(TOMBSTONE_TTL + time.Second)))
+
+	_, err = store.Get(uuid)
+	test.ErrorIs(err, ErrNotFound)
+	_, err = store.Update(stale)
+	test.ErrorIs(err, ErrNotFound)
+
+	// deleting again keeps the tombstone
+	prev, err := store.Delete(uuid)
+	test.NoError(err)
+	test.Nil(prev)
+	test.Equal(tombstone, test.getTombstone(store.Key(uuid)))
+
+	// create continues the version from the tombstone
+	test.NoError(store.Create(test.makeRecord(uuid)))
+	record, err = store.Get(uuid)
+	test.NoError(err)
+	test.Equal(uint64(4), record.Meta.ResourceVersion)
+	test.ErrorIs(store.Create(test.makeRecord(uuid)), ErrAlreadyExists)
+
+	// the writer having the resource before the deletion is rejected
+	_, err = store.Update(stale)
+	test.ErrorIs(err, ErrConflict)
+
+	// nil records written by the old implementation are also treated as deleted
+	test.NoError(test.col.KvsSet(store.Key(uuid), nil, 0))
+	_, err = store.Get(uuid)
+	test.ErrorIs(err, ErrNotFound)
+	test.NoError(store.Create(test.makeRecord(uuid)))
+}
+
+func (test *storeTest) receiveEvent(ch <-chan *WatchEvent) *WatchEvent {
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(3 * time.Second):
+		test.FailNow("watch event was not received")
+		return nil
+	}
+}
+
+func (test *storeTest) hasWatcher(hub *watchHubImpl, key, nid string) bool {
+	registry, err := hub.getRegistry(getWatchRegistryKey(key))
+	test.NoError(err)
+	_, ok := registry.Watchers[nid]
+	return ok
+}
+
+func (test *storeTest) TestWatch() {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+	mdMock := mock.NewMessagingDriverMock()
+	hub := NewWatchHub(ctx, test.col, mdMock).(*watchHubImpl)
+	store := NewStore(test.col, StoreConfig[*core.Record]{
+		Type:     core.ResourceTypeRecord,
+		Validate: (*core.Record).Validate,
+		Watcher:  hub,
+	})
+
+	// store without the watcher
+	_, _, err := NewStore(test.col, StoreConfig[*core.Record]{
+		Type: core.ResourceTypeRecord,
+	}).Watch("uuid")
+	test.ErrorIs(err, ErrWatchNotSupported)
+
+	uuid := core.GeneratePodUuid()
+	key := store.Key(uuid)
+	ch, cancel, err := store.Watch(uuid)
+	test.NoError(err)
+	test.Eventually(func() bool {
+		return test.hasWatcher(hub, key, test.col.LocalNid)
+	}, 3*time.Second, 10*time.Millisecond)
+	test.True(strings.HasPrefix(getWatchRegistryKey(key), "watch/"))
+	test.Len(strings.Split(getWatchRegistryKey(key), "/"), 2)
+
+	// local changes
+	test.NoError(store.Create(test.makeRecord(uuid)))
+	event := test.receiveEvent(ch)
+	test.Equal(key, event.Key)
+	test.Equal(uint64(1), event.ResourceVersion)
+	test.False(event.Deleted)
+
+	// another node watching the resource is notified by messaging
+	remoteNid := "fedcba9876543210fedcba9876543210"
+	expiration := time.Now().Add(WATCH_LEASE).Format(time.RFC3339)
+	test.NoError(hub.modifyRegistry(key, func(registry *watchRegistry) {
+		registry.Watchers[remoteNid] = expiration
+		registry.Watchers["expired"] = time.Now().Add(-time.Second).Format(time.RFC3339)
+	}))
+	test.False(test.hasWatcher(hub, key, "expired"))
+	record, err := store.Get(uuid)
+	test.NoError(err)
+	_, err = store.Update(record)
+	test.NoError(err)
+	event = test.receiveEvent(ch)
+	test.Equal(uint64(2), event.ResourceVersion)
+	test.Eventually(func() bool {
+		for _, r := range mdMock.Records {
+			if r.DestNodeID == remoteNid && r.WatchResource != nil && r.WatchResource.ResourceVersion == 2 {
+				return true
+			}
+		}
+		return false
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// events notified by other nodes
+	hub.Receive(&WatchEvent{
+		Key:             key,
+		ResourceVersion: 3,
+	})
+	event = test.receiveEvent(ch)
+	test.Equal(uint64(3), event.ResourceVersion)
+
+	_, err = store.Delete(uuid)
+	test.NoError(err)
+	event = test.receiveEvent(ch)
+	test.True(event.Deleted)
+
+	// old events are dropped instead of blocking the writer when the watcher does not receive them,
+	// and the latest event is kept
+	for i := 0; i < WATCH_CHANNEL_SIZE*2; i++ {
+		hub.Receive(&WatchEvent{Key: key, ResourceVersion: uint64(10 + i)})
+	}
+	test.Len(ch, WATCH_CHANNEL_SIZE)
+	for i := 0; i < WATCH_CHANNEL_SIZE; i++ {
+		event = test.receiveEvent(ch)
+		test.Equal(uint64(10+WATCH_CHANNEL_SIZE+i), event.ResourceVersion)
+	}
+
+	cancel()
+	cancel()
+	for range ch {
+	}
+	test.Eventually(func() bool {
+		return !test.hasWatcher(hub, key, test.col.LocalNid)
+	}, 3*time.Second, 10*time.Millisecond)
+	test.True(test.hasWatcher(hub, key, remoteNid))
+}
+
+func (test *storeTest) TestAPIVersion() {
+	store := NewStore(test.col, StoreConfig[*core.Record]{
+		Type:     core.ResourceTypeRecord,
+		Validate: (*core.Record).Validate,
+	})
+
+	// written records have the current version
+	uuid := core.GeneratePodUuid()
+	test.NoError(store.Create(test.makeRecord(uuid)))
+	record, err := store.Get(uuid)
+	test.NoError(err)
+	test.Equal(core.API_VERSION_CORE_V1, record.Meta.APIVersion)
+
+	// legacy records are upgraded instead of removed
+	legacy := test.makeRecord(uuid)
+	legacy.Data.Entries = nil
+	raw, err := NewJSONCodec[*core.Record]().Encode(legacy)
+	test.NoError(err)
+	test.NoError(test.col.KvsSet(store.Key(uuid), raw, 0))
+	record, err = store.Get(uuid)
+	test.NoError(err)
+	test.Equal(core.API_VERSION_CORE_V1, record.Meta.APIVersion)
+	test.NotNil(record.Data.Entries)
+
+	// records of unknown version are kept
+	future := test.makeRecord(uuid)
+	future.Meta.APIVersion = "core/v999"
+	raw, err = NewJSONCodec[*core.Record]().Encode(future)
+	test.NoError(err)
+	test.NoError(test.col.KvsSet(store.Key(uuid), raw, 0))
+	_, err = store.Get(uuid)
+	test.ErrorIs(err, core.ErrUnknownAPIVersion)
+	val, err := test.col.KvsGet(store.Key(uuid))
+	test.NoError(err)
+	stored, err := val.GetBinary()
+	test.NoError(err)
+	test.Equal(raw, stored)
+}
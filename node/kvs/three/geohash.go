@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package three
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	api "github.com/llamerada-jp/oinari/api/three"
+)
+
+const (
+	// 5 characters of geohash is about 4.9km x 4.9km near the equator
+	GEOHASH_PRECISION = 5
+	// max number of buckets read by a list request
+	GEOHASH_MAX_BUCKETS = 256
+	// approximate length of 1 degree of latitude
+	METERS_PER_DEGREE = 111320.0
+)
+
+const geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// encodeGeohash returns the geohash of the position, x is longitude and y is latitude.
+func encodeGeohash(x, y float64, precision int) string {
+	lonRange := [2]float64{-180, 180}
+	latRange := [2]float64{-90, 90}
+
+	var sb strings.Builder
+	bit := 0
+	ch := 0
+	even := true
+	for sb.Len() < precision {
+		if even {
+			mid := (lonRange[0] + lonRange[1]) / 2
+			if x >= mid {
+				ch |= 1 << (4 - bit)
+				lonRange[0] = mid
+			} else {
+				lonRange[1] = mid
+			}
+		} else {
+			mid := (latRange[0] + latRange[1]) / 2
+			if y >= mid {
+				ch |= 1 << (4 - bit)
+				latRange[0] = mid
+			} else {
+				latRange[1] = mid
+			}
+		}
+		even = !even
+
+		if bit < 4 {
+			bit++
+		} else {
+			sb.WriteByte(geohashBase32[ch])
+			bit = 0
+			ch = 0
+		}
+	}
+
+	return sb.String()
+}
+
+// geohashCellSize returns the size of a cell in degrees of longitude and latitude.
+func geohashCellSize(precision int) (float64, float64) {
+	bits := precision * 5
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 360 / math.Pow(2, float64(lonBits)), 180 / math.Pow(2, float64(latBits))
+}
+
+// coverGeohash returns geohashes of the cells overlapping the bounding box of the circle.
+func coverGeohash(center *api.Vector3, radius float64, precision int) ([]string, error) {
+	dLat := radius / METERS_PER_DEGREE
+	// clamp near the poles not to make the box too wide
+	dLon := dLat / math.Max(math.Cos(center.Y*math.Pi/180), 0.01)
+	if dLon > 180 {
+		dLon = 180
+	}
+	cellLon, cellLat := geohashCellSize(precision)
+
+	minLat := math.Max(center.Y-dLat, -90)
+	maxLat := math.Min(center.Y+dLat, 90)
+
+	hashes := make([]string, 0)
+	exists := make(map[string]bool)
+	for lat := minLat; ; lat += cellLat {
+		if lat > maxLat {
+			lat = maxLat
+		}
+		for lon := center.X - dLon; ; lon += cellLon {
+			if lon > center.X+dLon {
+				lon = center.X + dLon
+			}
+			hash := encodeGeohash(normalizeLongitude(lon), lat, precision)
+			if !exists[hash] {
+				exists[hash] = true
+				hashes = append(hashes, hash)
+				if len(hashes) > GEOHASH_MAX_BUCKETS {
+					return nil, fmt.Errorf("radius is too large")
+				}
+			}
+			if lon >= center.X+dLon {
+				break
+			}
+		}
+		if lat >= maxLat {
+			break
+		}
+	}
+
+	return hashes, nil
+}
+
+func normalizeLongitude(lon float64) float64 {
+	for lon < -180 {
+		lon += 360
+	}
+	for lon >= 180 {
+		lon -= 360
+	}
+	return lon
+}
@@ -19,10 +19,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"math"
+	"sync"
 
 	"github.com/llamerada-jp/colonio/go/colonio"
 	api "github.com/llamerada-jp/oinari/api/three"
+	"github.com/llamerada-jp/oinari/node/kvs"
 	"github.com/llamerada-jp/oinari/node/misc"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// max number of the objects read from the KVS at the same time
+	OBJECT_GET_CONCURRENCY = 8
 )
 
 type ObjectKVS interface {
@@ -30,119 +40,265 @@ type ObjectKVS interface {
 	Update(object *api.Object) error
 	Get(uuid string) (*api.Object, error)
 	Delete(uuid string) error
+	// List returns objects within radius meters from the center using the geohash index.
+	// It does not write the KVS, the stale entries of the index are skipped.
+	List(center *api.Vector3, radius float64) ([]*api.Object, error)
+	// RepairIndex removes the entries of the objects moved or deleted from the index,
+	// it is called by the node storing the index.
+	RepairIndex(raw []byte) error
+}
+
+// objectIndex is stored for each geohash bucket to enumerate objects in the bucket,
+// because colonio KVS does not have a way to scan keys.
+type objectIndex struct {
+	// empty for the index written before the bucket was recorded
+	Bucket string   `json:"bucket,omitempty"`
+	UUIDs  []string `json:"uuids"`
 }
 
 type objectKVSImpl struct {
-	col         colonio.Colonio
+	col   colonio.Colonio
+	store kvs.Store[*api.Object]
+	// progressing for the index keys
 	progressing *misc.UniqueSet
 }
 
+// NewObjectKVS returns the store of the objects, the objects are written in plaintext unlike the records of the pods.
+// TODO: encrypt the objects not readable by the world with the key of the account
 func NewObjectKVS(col colonio.Colonio) ObjectKVS {
 	return &objectKVSImpl{
-		col:         col,
+		col: col,
+		store: kvs.NewStore(col, kvs.StoreConfig[*api.Object]{
+			Type:     api.ResourceTypeThreeObject,
+			Validate: (*api.Object).Validate,
+		}),
 		progressing: misc.NewUniqueSet(),
 	}
 }
 
 func (impl *objectKVSImpl) Create(object *api.Object) error {
-	if err := object.Validate(); err != nil {
-		return fmt.Errorf("object record invalid: %w", err)
+	if err := impl.store.Create(object); err != nil {
+		return err
 	}
+	return impl.addIndex(object)
+}
 
-	key := string(api.ResourceTypeThreeObject) + "/" + object.Meta.Uuid
-	raw, err := json.Marshal(object)
+func (impl *objectKVSImpl) Update(object *api.Object) error {
+	prev, err := impl.store.Update(object)
 	if err != nil {
-		return fmt.Errorf("failed to marshal: %w", err)
+		return err
 	}
 
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
-
-	val, err := impl.col.KvsGet(key)
-	if errors.Is(err, colonio.ErrKvsNotFound) {
-		err = impl.col.KvsSet(key, raw, colonio.KvsProhibitOverwrite)
-		if err != nil {
-			return fmt.Errorf("failed to get existing data: %w", err)
-		}
+	// move the index only when the bucket is changed
+	prevBucket := getBucket(prev)
+	if prevBucket == getBucket(object) {
 		return nil
 	}
+	if err := impl.removeIndex(prevBucket, object.Meta.Uuid); err != nil {
+		return err
+	}
+	return impl.addIndex(object)
+}
 
-	// TODO: this implement might occur collision, fix this after delete feature is implemented in colonio
-	if val.IsNil() {
-		err = impl.col.KvsSet(key, raw, 0)
-		if err != nil {
-			return fmt.Errorf("failed to set raw data: %w", err)
-		}
-		return nil
+func (impl *objectKVSImpl) Get(uuid string) (*api.Object, error) {
+	object, err := impl.store.Get(uuid)
+	if errors.Is(err, kvs.ErrNotFound) {
+		return nil, nil
+	}
+	return object, err
+}
+
+func (impl *objectKVSImpl) Delete(uuid string) error {
+	prev, err := impl.store.Delete(uuid)
+	if err != nil {
+		return err
 	}
 
-	return fmt.Errorf("there is an duplicate uuid object data")
+	if prev != nil {
+		return impl.removeIndex(getBucket(prev), uuid)
+	}
+	return nil
 }
 
-func (impl *objectKVSImpl) Update(object *api.Object) error {
-	if err := object.Validate(); err != nil {
-		return fmt.Errorf("object record invalid: %w", err)
+func (impl *objectKVSImpl) List(center *api.Vector3, radius float64) ([]*api.Object, error) {
+	if center == nil {
+		return nil, fmt.Errorf("center should be specified")
+	}
+	if math.IsNaN(radius) || radius <= 0 {
+		return nil, fmt.Errorf("radius should be positive")
 	}
 
-	key := string(api.ResourceTypeThreeObject) + "/" + object.Meta.Uuid
-	raw, err := json.Marshal(object)
+	buckets, err := coverGeohash(center, radius, GEOHASH_PRECISION)
 	if err != nil {
-		return fmt.Errorf("failed to marshal: %w", err)
+		return nil, err
 	}
 
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
+	objects := make([]*api.Object, 0)
+	for _, bucket := range buckets {
+		index, err := impl.getIndex(bucket)
+		if err != nil {
+			return nil, err
+		}
 
-	val, err := impl.col.KvsGet(key)
-	if err != nil {
-		return fmt.Errorf("failed to check for the existence of the data: %w", err)
+		found, errs := impl.getObjects(index.UUIDs)
+		for i, object := range found {
+			if errs[i] != nil {
+				log.Printf("failed to get an object in the index: %s", errs[i].Error())
+				continue
+			}
+			// the index might be stale when the object was moved or deleted by a node failed to update the index,
+			// it is repaired by the node storing the index
+			if object == nil || getBucket(object) != bucket {
+				continue
+			}
+			if api.Distance(center, object.Spec.Position) <= radius {
+				objects = append(objects, object)
+			}
+		}
 	}
-	if val.IsNil() {
-		return fmt.Errorf("the data might be deleted")
+
+	return objects, nil
+}
+
+func (impl *objectKVSImpl) RepairIndex(raw []byte) error {
+	index := &objectIndex{}
+	if err := json.Unmarshal(raw, index); err != nil {
+		return fmt.Errorf("failed to unmarshal the index: %w", err)
+	}
+	// the index without the bucket is repaired after it is modified next time
+	if len(index.Bucket) == 0 {
+		return nil
+	}
+
+	stale := make([]string, 0)
+	found, errs := impl.getObjects(index.UUIDs)
+	for i, object := range found {
+		// keep the entry if it can't be checked
+		if errs[i] != nil {
+			continue
+		}
+		if object == nil || getBucket(object) != index.Bucket {
+			stale = append(stale, index.UUIDs[i])
+		}
+	}
+	if len(stale) == 0 {
+		return nil
 	}
 
-	return impl.col.KvsSet(key, raw, 0)
+	return impl.modifyIndex(index.Bucket, func(index *objectIndex) bool {
+		count := len(index.UUIDs)
+		index.UUIDs = slices.DeleteFunc(index.UUIDs, func(uuid string) bool {
+			return slices.Contains(stale, uuid)
+		})
+		return len(index.UUIDs) != count
+	})
 }
 
-func (impl *objectKVSImpl) Get(uuid string) (*api.Object, error) {
-	key := string(api.ResourceTypeThreeObject) + "/" + uuid
-	impl.progressing.Insert(key)
-	defer impl.progressing.Remove(key)
+// getObjects reads the objects concurrently, the object is nil if it is not found
+func (impl *objectKVSImpl) getObjects(uuids []string) ([]*api.Object, []error) {
+	objects := make([]*api.Object, len(uuids))
+	errs := make([]error, len(uuids))
+	semaphore := make(chan struct{}, OBJECT_GET_CONCURRENCY)
+	var wg sync.WaitGroup
+	for i, uuid := range uuids {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, uuid string) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			objects[i], errs[i] = impl.Get(uuid)
+		}(i, uuid)
+	}
+	wg.Wait()
+	return objects, errs
+}
 
-	val, err := impl.col.KvsGet(key)
+// getBucket returns empty string for objects without position, they are not indexed
+func getBucket(object *api.Object) string {
+	if object == nil || object.Spec == nil || object.Spec.Position == nil {
+		return ""
+	}
+	return encodeGeohash(object.Spec.Position.X, object.Spec.Position.Y, GEOHASH_PRECISION)
+}
+
+func getIndexKey(bucket string) string {
+	return string(api.ResourceTypeThreeObjectIndex) + "/" + bucket
+}
+
+func (impl *objectKVSImpl) getIndex(bucket string) (*objectIndex, error) {
+	val, err := impl.col.KvsGet(getIndexKey(bucket))
 	if err != nil {
 		if errors.Is(err, colonio.ErrKvsNotFound) {
-			return nil, nil
+			return &objectIndex{UUIDs: []string{}}, nil
 		}
-		return nil, fmt.Errorf("failed to get raw data: %w", err)
+		return nil, fmt.Errorf("failed to get the index: %w", err)
 	}
 
+	index := &objectIndex{UUIDs: []string{}}
 	if val.IsNil() {
-		return nil, nil
+		return index, nil
 	}
-
 	raw, err := val.GetBinary()
 	if err != nil {
-		return nil, fmt.Errorf("invalid raw data format: %w", err)
+		return nil, fmt.Errorf("invalid index format: %w", err)
+	}
+	if err := json.Unmarshal(raw, index); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the index: %w", err)
 	}
+	return index, nil
+}
 
-	object := &api.Object{}
-	err = json.Unmarshal(raw, object)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal raw data: %w", err)
+func (impl *objectKVSImpl) addIndex(object *api.Object) error {
+	bucket := getBucket(object)
+	if len(bucket) == 0 {
+		return nil
 	}
+	return impl.modifyIndex(bucket, func(index *objectIndex) bool {
+		if slices.Contains(index.UUIDs, object.Meta.Uuid) {
+			return false
+		}
+		index.UUIDs = append(index.UUIDs, object.Meta.Uuid)
+		return true
+	})
+}
 
-	return object, nil
+func (impl *objectKVSImpl) removeIndex(bucket string, uuid string) error {
+	if len(bucket) == 0 {
+		return nil
+	}
+	return impl.modifyIndex(bucket, func(index *objectIndex) bool {
+		i := slices.Index(index.UUIDs, uuid)
+		if i < 0 {
+			return false
+		}
+		index.UUIDs = slices.Delete(index.UUIDs, i, i+1)
+		return true
+	})
 }
 
-func (impl *objectKVSImpl) Delete(uuid string) error {
-	key := string(api.ResourceTypeThreeObject) + "/" + uuid
+// TODO: the index might be overwritten by other nodes at the same time, fix this after colonio supports atomic update
+func (impl *objectKVSImpl) modifyIndex(bucket string, modify func(index *objectIndex) bool) error {
+	key := getIndexKey(bucket)
 	impl.progressing.Insert(key)
 	defer impl.progressing.Remove(key)
 
-	// TODO check record before set nil for the record
-	if err := impl.col.KvsSet(key, nil, 0); err != nil {
-		return fmt.Errorf("failed to delete the record: %w", err)
+	index, err := impl.getIndex(bucket)
+	if err != nil {
+		return err
+	}
+	if !modify(index) {
+		return nil
+	}
+	index.Bucket = bucket
+
+	raw, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the index: %w", err)
+	}
+	if err := impl.col.KvsSet(key, raw, 0); err != nil {
+		return fmt.Errorf("failed to update the index: %w", err)
 	}
 	return nil
 }
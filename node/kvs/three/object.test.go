@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package three
+
+import (
+	"encoding/json"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	api "github.com/llamerada-jp/oinari/api/three"
+	"github.com/llamerada-jp/oinari/node/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type objectKVSTest struct {
+	suite.Suite
+	col  *mock.Colonio
+	impl *objectKVSImpl
+}
+
+func NewObjectKVSTest() suite.TestingSuite {
+	colonioMock := mock.NewColonioMock()
+	return &objectKVSTest{
+		col:  colonioMock,
+		impl: NewObjectKVS(colonioMock).(*objectKVSImpl),
+	}
+}
+
+func (test *objectKVSTest) makeObject(name string, x, y float64) *api.Object {
+	return &api.Object{
+		Meta: &core.ObjectMeta{
+			Type:        api.ResourceTypeThreeObject,
+			Name:        name,
+			Owner:       "owner",
+			CreatorNode: "012345678901234567890123456789ab",
+			Uuid:        api.GenerateObjectUUID(),
+		},
+		Spec: &api.ObjectSpec{
+			Parts:     []*api.PartSpec{},
+			Materials: []*api.MaterialSpec{},
+			Maps:      []*api.TextureSpec{},
+			Position:  &api.Vector3{X: x, Y: y},
+		},
+	}
+}
+
+func (test *objectKVSTest) listNames(center *api.Vector3, radius float64) []string {
+	objects, err := test.impl.List(center, radius)
+	test.NoError(err)
+	names := make([]string, 0)
+	for _, object := range objects {
+		names = append(names, object.Meta.Name)
+	}
+	return names
+}
+
+func (test *objectKVSTest) TestGeohash() {
+	// well known values
+	test.Equal("xn76u", encodeGeohash(139.7671, 35.6812, 5))
+	test.Equal("u4pruydqqvj", encodeGeohash(10.40744, 57.64911, 11))
+
+	lon, lat := geohashCellSize(5)
+	test.InDelta(0.0439, lon, 0.0001)
+	test.InDelta(0.0439, lat, 0.0001)
+
+	// the bucket of the center should be contained
+	hashes, err := coverGeohash(&api.Vector3{X: 139.7671, Y: 35.6812}, 1000, 5)
+	test.NoError(err)
+	test.Contains(hashes, "xn76u")
+	// cover both sides of the antimeridian
+	hashes, err = coverGeohash(&api.Vector3{X: 179.999, Y: 0}, 1000, 5)
+	test.NoError(err)
+	test.Contains(hashes, encodeGeohash(179.999, 0, 5))
+	test.Contains(hashes, encodeGeohash(-179.999, 0, 5))
+
+	_, err = coverGeohash(&api.Vector3{X: 0, Y: 0}, 1000000, 5)
+	test.Error(err)
+
+	test.InDelta(111195, api.Distance(&api.Vector3{X: 0, Y: 0}, &api.Vector3{X: 1, Y: 0}), 1)
+}
+
+func (test *objectKVSTest) TestList() {
+	center := &api.Vector3{X: 139.7671, Y: 35.6812}
+	near := test.makeObject("near", 139.7672, 35.6813)
+	// in the next bucket but in the radius
+	border := test.makeObject("border", 139.7671, 35.6812+0.045)
+	far := test.makeObject("far", 139.8671, 35.6812)
+	for _, object := range []*api.Object{near, border, far} {
+		test.NoError(test.impl.Create(object))
+	}
+
+	test.ElementsMatch([]string{"near"}, test.listNames(center, 100))
+	test.ElementsMatch([]string{"near", "border"}, test.listNames(center, 6000))
+	test.ElementsMatch([]string{"near", "border", "far"}, test.listNames(center, 10000))
+
+	// move the object to the other bucket
+	far.Spec.Position = &api.Vector3{X: 139.7670, Y: 35.6811}
+	test.NoError(test.impl.Update(far))
+	test.ElementsMatch([]string{"near", "far"}, test.listNames(center, 100))
+	index, err := test.impl.getIndex(encodeGeohash(139.8671, 35.6812, GEOHASH_PRECISION))
+	test.NoError(err)
+	test.NotContains(index.UUIDs, far.Meta.Uuid)
+
+	test.NoError(test.impl.Delete(near.Meta.Uuid))
+	test.ElementsMatch([]string{"far"}, test.listNames(center, 100))
+
+	// stale index is skipped without writing when listing
+	test.NoError(test.col.KvsSet(string(api.ResourceTypeThreeObject)+"/"+far.Meta.Uuid, nil, 0))
+	test.Empty(test.listNames(center, 100))
+	index, err = test.impl.getIndex(getBucket(far))
+	test.NoError(err)
+	test.Contains(index.UUIDs, far.Meta.Uuid)
+
+	// stale index is removed by the node storing it
+	raw, err := json.Marshal(index)
+	test.NoError(err)
+	test.NoError(test.impl.RepairIndex(raw))
+	index, err = test.impl.getIndex(getBucket(far))
+	test.NoError(err)
+	test.NotContains(index.UUIDs, far.Meta.Uuid)
+	test.Equal(getBucket(far), index.Bucket)
+
+	// invalid parameters
+	_, err = test.impl.List(nil, 100)
+	test.Error(err)
+	_, err = test.impl.List(center, 0)
+	test.Error(err)
+	_, err = test.impl.List(center, 1000000)
+	test.Error(err)
+}
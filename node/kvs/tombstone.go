@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package kvs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/node/misc"
+)
+
+// tombstones are kept for TOMBSTONE_TTL after the deletion and then compacted by node.Manager
+const TOMBSTONE_TTL = 10 * time.Minute
+
+// prefix to distinguish tombstones from resources encoded by any codec
+var tombstonePrefix = []byte("\x00tombstone:")
+
+// Tombstone is stored instead of the deleted resource, because colonio KVS does not have a delete method.
+// Create continues the resource version from the tombstone so that writers having the deleted resource
+// are rejected, and Get treats it as not found.
+type Tombstone struct {
+	DeletedAt string `json:"deletedAt"`
+	// node id deleted the resource
+	Deleter         string `json:"deleter"`
+	ResourceVersion uint64 `json:"resourceVersion"`
+	// Credential proves the account deleted the resource, it is made for the uuid of the resource as the target.
+	// The stores with the signer accept only the tombstones having the valid credential.
+	Credential *core.Credential `json:"credential,omitempty"`
+}
+
+func NewTombstone(deleter string, resourceVersion uint64) *Tombstone {
+	return &Tombstone{
+		DeletedAt:       misc.GetTimestamp(),
+		Deleter:         deleter,
+		ResourceVersion: resourceVersion,
+	}
+}
+
+func (tombstone *Tombstone) Encode() ([]byte, error) {
+	raw, err := json.Marshal(tombstone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tombstone: %w", err)
+	}
+	return append(bytes.Clone(tombstonePrefix), raw...), nil
+}
+
+// getSignedContent returns the content of the tombstone proved by the credential
+func (tombstone *Tombstone) getSignedContent() []byte {
+	return []byte(tombstone.Deleter + "/" + strconv.FormatUint(tombstone.ResourceVersion, 10))
+}
+
+// GetTombstoneUuid returns the uuid of the resource the credential of the tombstone is made for, it is the last
+// segment of the key.
+func GetTombstoneUuid(key string) string {
+	return key[strings.LastIndex(key, "/")+1:]
+}
+
+// IsExpired returns true if the tombstone has been kept for TOMBSTONE_TTL
+func (tombstone *Tombstone) IsExpired(now time.Time) bool {
+	deletedAt, err := time.Parse(time.RFC3339, tombstone.DeletedAt)
+	// broken tombstones are not useful
+	if err != nil {
+		return true
+	}
+	return now.Sub(deletedAt) > TOMBSTONE_TTL
+}
+
+// DecodeTombstone returns nil if the raw data is not a tombstone
+func DecodeTombstone(raw []byte) (*Tombstone, error) {
+	if !bytes.HasPrefix(raw, tombstonePrefix) {
+		return nil, nil
+	}
+	tombstone := &Tombstone{}
+	if err := json.Unmarshal(raw[len(tombstonePrefix):], tombstone); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tombstone: %w", err)
+	}
+	return tombstone, nil
+}
@@ -0,0 +1,322 @@
+/*
+ * Copyright 2018 Yuji Ito <llamerada.jp@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package kvs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/llamerada-jp/colonio/go/colonio"
+	"github.com/llamerada-jp/oinari/node/misc"
+)
+
+const (
+	// the registration of the node in the watch registry expires after WATCH_LEASE unless it is refreshed
+	WATCH_LEASE = 60 * time.Second
+	// the oldest event is dropped when the channel is full, so the latest event of the key is always delivered.
+	// watchers should get the latest resource for each event
+	WATCH_CHANNEL_SIZE = 8
+	// the type of the watch registry records, it is not dealt by node.Manager
+	watchRegistryType = "watch"
+)
+
+var ErrWatchNotSupported = errors.New("watch is not supported by the store")
+
+// WatchEvent tells that the resource of the key was written, it does not contain the resource.
+type WatchEvent struct {
+	Key             string `json:"key"`
+	ResourceVersion uint64 `json:"resourceVersion"`
+	Deleted         bool   `json:"deleted"`
+}
+
+// WatchNotifier sends the event to the watchers on another node, it is implemented by the messaging driver.
+type WatchNotifier interface {
+	NotifyWatch(nid, key string, resourceVersion uint64, deleted bool) error
+}
+
+// WatchHub delivers events of the resources to the watchers. The nodes watching a key are registered
+// in the watch registry record, and the writer of the resource notifies them by messaging.
+type WatchHub interface {
+	// Watch returns the channel of the events for the key and the function to stop watching.
+	Watch(key string) (<-chan *WatchEvent, func())
+	// Notify delivers the event of the resource written by this node to the watchers on all nodes.
+	Notify(event *WatchEvent)
+	// Receive delivers the event notified by another node to the watchers on this node.
+	Receive(event *WatchEvent)
+}
+
+// watchRegistry is stored for each watched key
+type watchRegistry struct {
+	// key: node id, value: expiration timestamp of the registration
+	Watchers map[string]string `json:"watchers"`
+}
+
+type watchHubImpl struct {
+	col         colonio.Colonio
+	notifier    WatchNotifier
+	progressing *misc.UniqueSet
+
+	mtx    sync.Mutex
+	nextID uint64
+	// key: key of the resource, key of the inner map: id of the watcher
+	watchers map[string]map[uint64]chan *WatchEvent
+}
+
+func NewWatchHub(ctx context.Context, col colonio.Colonio, notifier WatchNotifier) WatchHub {
+	impl := &watchHubImpl{
+		col:         col,
+		notifier:    notifier,
+		progressing: misc.NewUniqueSet(),
+		watchers:    make(map[string]map[uint64]chan *WatchEvent),
+	}
+
+	go func() {
+		ticker := time.NewTicker(WATCH_LEASE / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				impl.refresh()
+			}
+		}
+	}()
+
+	return impl
+}
+
+func getWatchRegistryKey(key string) string {
+	// the key of the registry should have only 2 segments to be treated by the local datastore
+	return watchRegistryType + "/" + strings.ReplaceAll(key, "/", ":")
+}
+
+func (impl *watchHubImpl) Watch(key string) (<-chan *WatchEvent, func()) {
+	ch := make(chan *WatchEvent, WATCH_CHANNEL_SIZE)
+
+	impl.mtx.Lock()
+	id := impl.nextID
+	impl.nextID++
+	watchers, ok := impl.watchers[key]
+	if !ok {
+		watchers = make(map[uint64]chan *WatchEvent)
+		impl.watchers[key] = watchers
+	}
+	watchers[id] = ch
+	impl.mtx.Unlock()
+
+	if !ok {
+		go func() {
+			if err := impl.register(key); err != nil {
+				log.Printf("failed to register the watcher of %s: %s", key, err.Error())
+			}
+		}()
+	}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			impl.mtx.Lock()
+			watchers := impl.watchers[key]
+			delete(watchers, id)
+			close(ch)
+			last := len(watchers) == 0
+			if last {
+				delete(impl.watchers, key)
+			}
+			impl.mtx.Unlock()
+
+			if last {
+				go func() {
+					if err := impl.unregister(key); err != nil {
+						log.Printf("failed to unregister the watcher of %s: %s", key, err.Error())
+					}
+				}()
+			}
+		})
+	}
+
+	return ch, cancel
+}
+
+func (impl *watchHubImpl) Notify(event *WatchEvent) {
+	impl.deliver(event)
+
+	go func() {
+		if err := impl.notifyRemote(event); err != nil {
+			log.Printf("failed to notify the event of %s: %s", event.Key, err.Error())
+		}
+	}()
+}
+
+func (impl *watchHubImpl) Receive(event *WatchEvent) {
+	impl.deliver(event)
+}
+
+func (impl *watchHubImpl) deliver(event *WatchEvent) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	for _, ch := range impl.watchers[event.Key] {
+		// the channels are closed with the lock, so sending to them here is safe
+		for sent := false; !sent; {
+			select {
+			case ch <- event:
+				sent = true
+			default:
+				// all events in the channel are of the same key, drop the oldest one to coalesce them into the latest
+				select {
+				case <-ch:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (impl *watchHubImpl) notifyRemote(event *WatchEvent) error {
+	registry, err := impl.getRegistry(getWatchRegistryKey(event.Key))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	localNid := impl.col.GetLocalNid()
+	for nid, expiration := range registry.Watchers {
+		if nid == localNid || isWatchExpired(expiration, now) {
+			continue
+		}
+		if err := impl.notifier.NotifyWatch(nid, event.Key, event.ResourceVersion, event.Deleted); err != nil {
+			log.Printf("failed to notify the event of %s to %s: %s", event.Key, nid, err.Error())
+		}
+	}
+	return nil
+}
+
+// refresh extends the registrations of the keys watched on this node
+func (impl *watchHubImpl) refresh() {
+	impl.mtx.Lock()
+	keys := make([]string, 0, len(impl.watchers))
+	for key := range impl.watchers {
+		keys = append(keys, key)
+	}
+	impl.mtx.Unlock()
+
+	for _, key := range keys {
+		if err := impl.register(key); err != nil {
+			log.Printf("failed to refresh the watcher of %s: %s", key, err.Error())
+		}
+	}
+}
+
+func (impl *watchHubImpl) register(key string) error {
+	expiration := time.Now().Add(WATCH_LEASE).Format(time.RFC3339)
+	return impl.modifyRegistry(key, func(registry *watchRegistry) {
+		registry.Watchers[impl.col.GetLocalNid()] = expiration
+	})
+}
+
+func (impl *watchHubImpl) unregister(key string) error {
+	return impl.modifyRegistry(key, func(registry *watchRegistry) {
+		delete(registry.Watchers, impl.col.GetLocalNid())
+	})
+}
+
+func (impl *watchHubImpl) modifyRegistry(key string, f func(registry *watchRegistry)) error {
+	registryKey := getWatchRegistryKey(key)
+	impl.progressing.Insert(registryKey)
+	defer impl.progressing.Remove(registryKey)
+
+	registry, err := impl.getRegistry(registryKey)
+	if err != nil {
+		return err
+	}
+	f(registry)
+
+	now := time.Now()
+	for nid, expiration := range registry.Watchers {
+		if isWatchExpired(expiration, now) {
+			delete(registry.Watchers, nid)
+		}
+	}
+
+	var raw []byte
+	if len(registry.Watchers) == 0 {
+		// the tombstone is compacted by node.Manager after TOMBSTONE_TTL
+		raw, err = NewTombstone(impl.col.GetLocalNid(), 0).Encode()
+	} else {
+		raw, err = json.Marshal(registry)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode the watch registry: %w", err)
+	}
+
+	// TODO: registrations from other nodes at the same time might be lost, fix this after colonio supports CAS
+	if err := impl.col.KvsSet(registryKey, raw, 0); err != nil {
+		return fmt.Errorf("failed to set the watch registry: %w", err)
+	}
+	return nil
+}
+
+// getRegistry returns an empty registry if the record does not exist
+func (impl *watchHubImpl) getRegistry(registryKey string) (*watchRegistry, error) {
+	registry := &watchRegistry{
+		Watchers: make(map[string]string),
+	}
+
+	val, err := impl.col.KvsGet(registryKey)
+	if errors.Is(err, colonio.ErrKvsNotFound) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the watch registry: %w", err)
+	}
+	if val.IsNil() {
+		return registry, nil
+	}
+
+	raw, err := val.GetBinary()
+	if err != nil {
+		return nil, fmt.Errorf("invalid watch registry format: %w", err)
+	}
+	if tombstone, _ := DecodeTombstone(raw); tombstone != nil {
+		return registry, nil
+	}
+	if err := json.Unmarshal(raw, registry); err != nil {
+		// broken registry is overwritten by the next registration
+		log.Printf("failed to unmarshal the watch registry %s: %s", registryKey, err.Error())
+		return &watchRegistry{Watchers: make(map[string]string)}, nil
+	}
+	if registry.Watchers == nil {
+		registry.Watchers = make(map[string]string)
+	}
+	return registry, nil
+}
+
+func isWatchExpired(expiration string, now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, expiration)
+	if err != nil {
+		return true
+	}
+	return now.After(t)
+}
@@ -16,11 +16,15 @@
 package node
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/llamerada-jp/colonio/go/colonio"
 	"github.com/llamerada-jp/oinari/api/core"
+	"github.com/llamerada-jp/oinari/node/kvs"
+	"golang.org/x/exp/slices"
 )
 
 type LocalResource struct {
@@ -29,25 +33,130 @@ type LocalResource struct {
 	recordRaw    []byte
 }
 
+type LocalTombstone struct {
+	key       string
+	tombstone *kvs.Tombstone
+}
+
 type LocalDatastore interface {
+	// DeleteResource replaces the resource with a tombstone
 	DeleteResource(key string) error
 	GetResources() ([]LocalResource, error)
+	GetTombstones() ([]LocalTombstone, error)
+	// CompactTombstone clears the tombstone if it is not overwritten by a new resource,
+	// the signed tombstones are kept because the nil value is not accepted as deleted by the signed stores
+	CompactTombstone(key string) error
+}
+
+// the types of the resources stored with the signer, their tombstones should be signed
+var signedResourceTypes = []core.ResourceType{
+	core.ResourceTypePod,
+	core.ResourceTypeAccount,
 }
 
 type localDatastore struct {
-	col colonio.Colonio
+	col    colonio.Colonio
+	signer kvs.ResourceSigner
 }
 
-func NewLocalDatastore(col colonio.Colonio) LocalDatastore {
+// NewLocalDatastore returns LocalDatastore, the tombstones of the signed resources are signed if signer is not nil
+func NewLocalDatastore(col colonio.Colonio, signer kvs.ResourceSigner) LocalDatastore {
 	return &localDatastore{
-		col: col,
+		col:    col,
+		signer: signer,
 	}
 }
 
 func (ld *localDatastore) DeleteResource(key string) error {
+	// take over the resource version to reject writers having the deleted resource
+	version := uint64(0)
+	if val, err := ld.col.KvsGet(key); err == nil && !val.IsNil() {
+		if raw, err := val.GetBinary(); err == nil {
+			resource := struct {
+				Meta *core.ObjectMeta `json:"meta"`
+			}{}
+			if err := json.Unmarshal(raw, &resource); err == nil && resource.Meta != nil {
+				version = resource.Meta.ResourceVersion + 1
+			}
+		}
+	}
+
+	tombstone := kvs.NewTombstone(ld.col.GetLocalNid(), version)
+	resourceType := core.ResourceType(strings.Split(key, "/")[0])
+	if ld.signer != nil && slices.Contains(signedResourceTypes, resourceType) {
+		ld.signer.SignTombstone(tombstone, kvs.GetTombstoneUuid(key))
+	}
+	raw, err := tombstone.Encode()
+	if err != nil {
+		return err
+	}
+	return ld.col.KvsSet(key, raw, 0)
+}
+
+func (ld *localDatastore) CompactTombstone(key string) error {
+	val, err := ld.col.KvsGet(key)
+	if err != nil {
+		if errors.Is(err, colonio.ErrKvsNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get the tombstone: %w", err)
+	}
+	if val.IsNil() {
+		return nil
+	}
+	raw, err := val.GetBinary()
+	if err != nil {
+		return fmt.Errorf("failed to get the tombstone binary: %w", err)
+	}
+	tombstone, _ := kvs.DecodeTombstone(raw)
+	if tombstone == nil {
+		// the resource is created again after the tombstone was listed
+		return nil
+	}
+	// TODO: remove the signed tombstones after colonio supports deleting values from KVS
+	if tombstone.Credential != nil {
+		return nil
+	}
+
+	// TODO: delete the entry after colonio supports deleting values from KVS, set nil instead of that
 	return ld.col.KvsSet(key, nil, 0)
 }
 
+func (ld *localDatastore) GetTombstones() ([]LocalTombstone, error) {
+	tombstones := make([]LocalTombstone, 0)
+
+	localData := ld.col.KvsGetLocalData()
+	defer localData.Free()
+
+	for _, key := range localData.GetKeys() {
+		v, err := localData.GetValue(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get local resource value: %w", err)
+		}
+		if v.IsNil() {
+			continue
+		}
+		raw, err := v.GetBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get local resource binary: %w", err)
+		}
+		tombstone, err := kvs.DecodeTombstone(raw)
+		if err != nil {
+			// broken tombstones are compacted immediately
+			tombstone = &kvs.Tombstone{}
+		}
+		if tombstone == nil {
+			continue
+		}
+		tombstones = append(tombstones, LocalTombstone{
+			key:       key,
+			tombstone: tombstone,
+		})
+	}
+
+	return tombstones, nil
+}
+
 func (ld *localDatastore) GetResources() ([]LocalResource, error) {
 	resources := make([]LocalResource, 0)
 
@@ -68,6 +177,10 @@ func (ld *localDatastore) GetResources() ([]LocalResource, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to get local resource binary: %w", err)
 		}
+		// skip tombstones, they are compacted by GetTombstones and CompactTombstone
+		if tombstone, err := kvs.DecodeTombstone(raw); tombstone != nil || err != nil {
+			continue
+		}
 		resourceEntry := strings.Split(key, "/")
 		if len(resourceEntry) != 2 {
 			return nil, fmt.Errorf("local value key is not supported format:%s", key)
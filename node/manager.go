@@ -17,12 +17,16 @@ package node
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/llamerada-jp/oinari/api/core"
+	threeAPI "github.com/llamerada-jp/oinari/api/three"
 	"github.com/llamerada-jp/oinari/node/controller"
+	threeController "github.com/llamerada-jp/oinari/node/controller/three"
+	"github.com/llamerada-jp/oinari/node/kvs"
 	"github.com/llamerada-jp/oinari/node/misc"
 )
 
@@ -36,17 +40,24 @@ type manager struct {
 	containerCtrl controller.ContainerController
 	nodeCtrl      controller.NodeController
 	podCtrl       controller.PodController
+	podIndexCtrl  controller.PodIndexController
+	timerCtrl     controller.TimerController
+	objectCtrl    threeController.ObjectController
 }
 
 func NewManager(ld LocalDatastore, accountCtrl controller.AccountController,
 	containerCtrl controller.ContainerController, nodeCtrl controller.NodeController,
-	podCtrl controller.PodController) Manager {
+	podCtrl controller.PodController, podIndexCtrl controller.PodIndexController, timerCtrl controller.TimerController,
+	objectCtrl threeController.ObjectController) Manager {
 	return &manager{
 		localDs:       ld,
 		accountCtrl:   accountCtrl,
 		containerCtrl: containerCtrl,
 		nodeCtrl:      nodeCtrl,
 		podCtrl:       podCtrl,
+		podIndexCtrl:  podIndexCtrl,
+		timerCtrl:     timerCtrl,
+		objectCtrl:    objectCtrl,
 	}
 }
 
@@ -55,6 +66,10 @@ func (mgr *manager) Start(ctx context.Context) error {
 	defer tickerDealLR.Stop()
 	tickerKeepAlive := time.NewTicker(30 * time.Second)
 	defer tickerKeepAlive.Stop()
+	tickerTimer := time.NewTicker(controller.TIMER_CHECK_INTERVAL)
+	defer tickerTimer.Stop()
+	tickerCompaction := time.NewTicker(kvs.TOMBSTONE_TTL / 2)
+	defer tickerCompaction.Stop()
 
 	// first keepalive
 	if err := mgr.keepAlive(); err != nil {
@@ -76,6 +91,13 @@ func (mgr *manager) Start(ctx context.Context) error {
 				log.Printf("keepAlive of node manager failed: %s", err.Error())
 			}
 
+		case now := <-tickerTimer.C:
+			mgr.timerCtrl.Fire(now)
+
+		case now := <-tickerCompaction.C:
+			if err := mgr.compactTombstones(now); err != nil {
+				log.Printf("compactTombstones of node manager failed: %s", err.Error())
+			}
 		}
 	}
 }
@@ -87,12 +109,28 @@ func (mgr *manager) dealLocalResource() error {
 	}
 
 	for _, resource := range resources {
+		raw, err := core.DefaultConverters.Upgrade(resource.resourceType, resource.recordRaw)
+		if err != nil {
+			// records of unknown version might be written by a newer node, keep them
+			if errors.Is(err, core.ErrUnknownAPIVersion) {
+				continue
+			}
+			// broken records are removed by the controllers
+			raw = resource.recordRaw
+		}
+
 		willDelete := false
 		switch resource.resourceType {
 		case core.ResourceTypePod:
-			willDelete, err = mgr.podCtrl.DealLocalResource(resource.recordRaw)
+			willDelete, err = mgr.podCtrl.DealLocalResource(raw)
 		case core.ResourceTypeAccount:
-			willDelete, err = mgr.accountCtrl.DealLocalResource(resource.recordRaw)
+			willDelete, err = mgr.accountCtrl.DealLocalResource(raw)
+		case core.ResourceTypePodIndex:
+			willDelete, err = mgr.podIndexCtrl.DealLocalResource(raw)
+		case threeAPI.ResourceTypeThreeObject:
+			willDelete, err = mgr.objectCtrl.DealLocalResource(raw)
+		case threeAPI.ResourceTypeThreeObjectIndex:
+			willDelete, err = mgr.objectCtrl.DealLocalIndex(raw)
 		}
 		if willDelete {
 			err := mgr.localDs.DeleteResource(resource.key)
@@ -108,37 +146,86 @@ func (mgr *manager) dealLocalResource() error {
 	return nil
 }
 
+// compactTombstones clears tombstones stored in this node after kvs.TOMBSTONE_TTL
+func (mgr *manager) compactTombstones(now time.Time) error {
+	tombstones, err := mgr.localDs.GetTombstones()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range tombstones {
+		if !entry.tombstone.IsExpired(now) {
+			continue
+		}
+		if err := mgr.localDs.CompactTombstone(entry.key); err != nil {
+			return fmt.Errorf("failed to compact tombstone (%s): %w", entry.key, err)
+		}
+	}
+
+	return nil
+}
+
 func (mgr *manager) keepAlive() error {
 	localAccount := mgr.accountCtrl.GetAccountName()
 	localNodeID := mgr.nodeCtrl.GetNid()
 
 	accPodStates := make(map[string]map[string]core.AccountPodState)
 	accPodStates[localAccount] = make(map[string]core.AccountPodState)
+	accIndexEntries := make(map[string]map[string]core.PodIndexEntry)
 	for _, info := range mgr.containerCtrl.GetContainerInfos() {
 		podStates, ok := accPodStates[info.Owner]
 		if !ok {
 			podStates = make(map[string]core.AccountPodState)
 			accPodStates[info.Owner] = podStates
 		}
+		timestamp := misc.GetTimestamp()
 		podStates[info.PodUUID] = core.AccountPodState{
 			RunningNode: localNodeID,
-			Timestamp:   misc.GetTimestamp(),
+			Timestamp:   timestamp,
+		}
+
+		pod, err := mgr.podCtrl.GetPodData(info.PodUUID)
+		if err != nil {
+			// the pod might be deleted, the entry of the index will expire
+			log.Printf("failed to get pod data for the pod index (%s): %s", info.PodUUID, err.Error())
+			continue
+		}
+		entries, ok := accIndexEntries[info.Owner]
+		if !ok {
+			entries = make(map[string]core.PodIndexEntry)
+			accIndexEntries[info.Owner] = entries
+		}
+		entries[info.PodUUID] = core.PodIndexEntry{
+			Name:        pod.Meta.Name,
+			RunningNode: localNodeID,
+			Phase:       pod.GetPhase(),
+			Timestamp:   timestamp,
 		}
 	}
 
+	// an error for an account should not stop the updates for other accounts
+	errs := make([]error, 0)
+	for account, entries := range accIndexEntries {
+		if err := mgr.podIndexCtrl.UpdateEntries(account, entries); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update pod index (%s): %w", account, err))
+		}
+	}
+
+	// TODO: stop writing the pod states into the account record after all nodes use the pod index
+
 	for account, podStates := range accPodStates {
 		if account == localAccount {
 			nodeState := mgr.nodeCtrl.GetNodeState()
 			nodeState.Timestamp = misc.GetTimestamp()
 			if err := mgr.accountCtrl.UpdatePodAndNodeState(account, podStates, localNodeID, nodeState); err != nil {
-				return fmt.Errorf("failed to update account state (%s): %w", account, err)
+				errs = append(errs, fmt.Errorf("failed to update account state (%s): %w", account, err))
 			}
 		} else {
 			if err := mgr.accountCtrl.UpdatePodAndNodeState(account, podStates, "", nil); err != nil {
-				return fmt.Errorf("failed to update account state (%s): %w", account, err)
+				errs = append(errs, fmt.Errorf("failed to update account state (%s): %w", account, err))
 			}
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
@@ -28,15 +28,26 @@ import (
 type MessagingDriver interface {
 	PublishNode(r float64, nid, name, account string, nodeType core.NodeType, position *core.Vector3) error
 	ReconcileContainer(nid, uuid string) error
+	// FetchLog fetches the log with the credential of the local account if the signer is configured
+	FetchLog(nid, podUuid, account string, after uint64, limit int) (*core.PodLog, error)
+	NotifyWatch(nid, key string, resourceVersion uint64, deleted bool) error
+}
+
+// CredentialMaker makes the credential proving the account of the request, it is implemented by kvs.ResourceSigner
+type CredentialMaker interface {
+	MakeCredential(target string, content []byte) *core.Credential
 }
 
 type messagingDriverImpl struct {
 	colonio colonio.Colonio
+	// nil if the signing key of the account is not available
+	signer CredentialMaker
 }
 
-func NewMessagingDriver(col colonio.Colonio) MessagingDriver {
+func NewMessagingDriver(col colonio.Colonio, signer CredentialMaker) MessagingDriver {
 	return &messagingDriverImpl{
 		colonio: col,
+		signer:  signer,
 	}
 }
 
@@ -56,6 +67,69 @@ func (d *messagingDriverImpl) ReconcileContainer(nid, podUuid string) error {
 	return nil
 }
 
+func (d *messagingDriverImpl) FetchLog(nid, podUuid, account string, after uint64, limit int) (*core.PodLog, error) {
+	msg := messaging.FetchLog{
+		PodUuid: podUuid,
+		Account: account,
+		After:   after,
+		Limit:   limit,
+	}
+	if d.signer != nil {
+		content, err := msg.GetCredentialContent()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal fetchLog message: %w", err)
+		}
+		msg.Credential = d.signer.MakeCredential(nid, content)
+	}
+
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fetchLog message: %w", err)
+	}
+
+	val, err := d.colonio.MessagingPost(nid, messaging.MessageNameFetchLog, raw, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to post fetchLog message: %w", err)
+	}
+
+	resRaw, err := val.GetBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response of fetchLog message: %w", err)
+	}
+
+	var res messaging.FetchLogResponse
+	if err := json.Unmarshal(resRaw, &res); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response of fetchLog message: %w", err)
+	}
+
+	if len(res.Error) != 0 {
+		return nil, fmt.Errorf("fetchLog failed on the remote node: %s", res.Error)
+	}
+	if res.Log == nil {
+		return nil, fmt.Errorf("response of fetchLog message is empty")
+	}
+
+	return res.Log, nil
+}
+
+func (d *messagingDriverImpl) NotifyWatch(nid, key string, resourceVersion uint64, deleted bool) error {
+	raw, err := json.Marshal(messaging.WatchResource{
+		Key:             key,
+		ResourceVersion: resourceVersion,
+		Deleted:         deleted,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal watchResource message: %w", err)
+	}
+
+	_, err = d.colonio.MessagingPost(nid, messaging.MessageNameWatchResource, raw, 0)
+	if err != nil {
+		return fmt.Errorf("failed to post watchResource message: %w", err)
+	}
+
+	return nil
+}
+
 func (d *messagingDriverImpl) PublishNode(r float64, nid, name, account string, nodeType core.NodeType, position *core.Vector3) error {
 	raw, err := json.Marshal(messaging.PublishNode{
 		Name:     name,
@@ -18,14 +18,16 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 
 	"github.com/llamerada-jp/colonio/go/colonio"
 	"github.com/llamerada-jp/oinari/node/controller"
+	"github.com/llamerada-jp/oinari/node/kvs"
 	"github.com/llamerada-jp/oinari/node/messaging"
 )
 
-func InitMessagingHandler(col colonio.Colonio, containerCtrl controller.ContainerController, nodeCtrl controller.NodeController) error {
+func InitMessagingHandler(col colonio.Colonio, signer kvs.ResourceSigner, containerCtrl controller.ContainerController, logCtrl controller.LogController, nodeCtrl controller.NodeController, watchHub kvs.WatchHub) error {
 	// reconcile container
 	col.MessagingSetHandler(messaging.MessageNameReconcileContainer, func(mr *colonio.MessagingRequest, mrw colonio.MessagingResponseWriter) {
 		raw, err := mr.Message.GetBinary()
@@ -51,6 +53,80 @@ func InitMessagingHandler(col colonio.Colonio, containerCtrl controller.Containe
 		}(raw)
 	})
 
+	// fetch log
+	col.MessagingSetHandler(messaging.MessageNameFetchLog, func(mr *colonio.MessagingRequest, mrw colonio.MessagingResponseWriter) {
+		res := messaging.FetchLogResponse{}
+		defer func() {
+			raw, err := json.Marshal(res)
+			if err != nil {
+				log.Printf("failed to marshal response of fetchLog message: %s", err.Error())
+				mrw.Write(nil)
+				return
+			}
+			mrw.Write(raw)
+		}()
+
+		raw, err := mr.Message.GetBinary()
+		if err != nil {
+			res.Error = fmt.Sprintf("failed to read fetchLog message: %s", err.Error())
+			return
+		}
+
+		var msg messaging.FetchLog
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			res.Error = fmt.Sprintf("failed to unmarshal fetchLog message: %s", err.Error())
+			return
+		}
+
+		// the account written in the message can be forged, so it should be proved by the credential
+		// if the signer is configured to verify it
+		account := msg.Account
+		if signer != nil {
+			content, err := msg.GetCredentialContent()
+			if err != nil {
+				res.Error = fmt.Sprintf("failed to marshal fetchLog message: %s", err.Error())
+				return
+			}
+			account = kvs.GetAuthenticatedAccount(signer, msg.Credential, col.GetLocalNid(), content)
+			if len(account) == 0 {
+				res.Error = "the account reading the log is not proved"
+				return
+			}
+		}
+
+		podLog, err := logCtrl.GetLocalLog(msg.PodUuid, account, msg.After, msg.Limit)
+		if err != nil {
+			res.Error = err.Error()
+			return
+		}
+		res.Log = podLog
+	})
+
+	// watch resource
+	col.MessagingSetHandler(messaging.MessageNameWatchResource, func(mr *colonio.MessagingRequest, mrw colonio.MessagingResponseWriter) {
+		raw, err := mr.Message.GetBinary()
+		defer mrw.Write(nil)
+		if err != nil {
+			log.Printf("failed to read watchResource message: %s", err.Error())
+			return
+		}
+
+		go func(raw []byte) {
+			var msg messaging.WatchResource
+			err := json.Unmarshal(raw, &msg)
+			if err != nil {
+				log.Printf("failed to unmarshal watchResource message: %s", err.Error())
+				return
+			}
+
+			watchHub.Receive(&kvs.WatchEvent{
+				Key:             msg.Key,
+				ResourceVersion: msg.ResourceVersion,
+				Deleted:         msg.Deleted,
+			})
+		}(raw)
+	})
+
 	// publish node
 	col.SpreadSetHandler(messaging.MessageNamePublishNode, func(sr *colonio.SpreadRequest) {
 		raw, err := sr.Message.GetBinary()
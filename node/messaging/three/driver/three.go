@@ -18,38 +18,243 @@ package driver
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"math"
+	"sync"
+	"time"
 
 	"github.com/llamerada-jp/colonio/go/colonio"
 	threeAPI "github.com/llamerada-jp/oinari/api/three"
+	"github.com/llamerada-jp/oinari/node/kvs"
 	messaging "github.com/llamerada-jp/oinari/node/messaging/three"
 )
 
+const (
+	// min interval to spread updates of the same object, updates in the interval are coalesced
+	SPREAD_MIN_INTERVAL = 200 * time.Millisecond
+	// interval to clean up the rate limiter states of the objects not updated recently
+	SPREAD_SWEEP_INTERVAL = 10 * time.Second
+	// max size in bytes of the object embedded in the spread message
+	SPREAD_INLINE_MAX_SIZE = 16 * 1024
+)
+
 type ThreeMessagingDriver interface {
-	SpreadObject(uuid string, position *threeAPI.Vector3, r float64) error
+	// SpreadObject spreads the object written to the KVS and the transient update based on it if it is not nil.
+	// The transient update should contain all the transient updates made since the generation of the object.
+	// It is delayed and coalesced with the next updates if the same object is spread in SPREAD_MIN_INTERVAL.
+	SpreadObject(obj *threeAPI.Object, position *threeAPI.Vector3, r float64, transient *threeAPI.TransientUpdate) error
+	SpreadDeletion(uuid string, position *threeAPI.Vector3, r float64) error
+	// Interact sends the interaction event with the credential of the local account if the signer is available
+	Interact(nid string, podUUID string, event *threeAPI.InteractionEvent) error
 }
 
 type threeMessagingDriverImpl struct {
 	col colonio.Colonio
+	// nil if the signing key of the account is not available
+	signer kvs.ResourceSigner
+
+	mtx sync.Mutex
+	// key: uuid of the object
+	limiters  map[string]*spreadLimiter
+	lastSweep time.Time
+}
+
+type spreadLimiter struct {
+	lastSent time.Time
+	// updates waiting for the interval in order, one of them is sent in each interval,
+	// an update is coalesced into the last one if they can be merged
+	pending []*spreadRequest
 }
 
-func NewThreeMessagingDriver(col colonio.Colonio) ThreeMessagingDriver {
+type spreadRequest struct {
+	position *threeAPI.Vector3
+	r        float64
+	msg      *messaging.SpreadObject
+}
+
+func NewThreeMessagingDriver(col colonio.Colonio, signer kvs.ResourceSigner) ThreeMessagingDriver {
 	return &threeMessagingDriverImpl{
-		col: col,
+		col:       col,
+		signer:    signer,
+		limiters:  make(map[string]*spreadLimiter),
+		lastSweep: time.Now(),
 	}
 }
 
-func (impl *threeMessagingDriverImpl) SpreadObject(uuid string, position *threeAPI.Vector3, r float64) error {
-	raw, err := json.Marshal(messaging.SpreadObject{
-		UUID: uuid,
+func (impl *threeMessagingDriverImpl) SpreadObject(obj *threeAPI.Object, position *threeAPI.Vector3, r float64, transient *threeAPI.TransientUpdate) error {
+	msg := &messaging.SpreadObject{
+		UUID:       obj.Meta.Uuid,
+		Generation: obj.Generation,
+		Transient:  transient,
+	}
+
+	// receivers knowing the generation can apply the transient update without the object
+	if transient == nil {
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("failed to marshal: %w", err)
+		}
+		if len(raw) <= SPREAD_INLINE_MAX_SIZE {
+			msg.Object = obj
+		}
+	}
+
+	return impl.spread(&spreadRequest{
+		position: position,
+		r:        r,
+		msg:      msg,
+	})
+}
+
+func (impl *threeMessagingDriverImpl) SpreadDeletion(uuid string, position *threeAPI.Vector3, r float64) error {
+	return impl.spread(&spreadRequest{
+		position: position,
+		r:        r,
+		msg: &messaging.SpreadObject{
+			UUID:    uuid,
+			Deleted: true,
+		},
 	})
+}
+
+func (impl *threeMessagingDriverImpl) spread(req *spreadRequest) error {
+	if req.position == nil {
+		return fmt.Errorf("position is required to spread the object")
+	}
+
+	uuid := req.msg.UUID
+	now := time.Now()
+
+	impl.mtx.Lock()
+	impl.sweep(now)
+
+	limiter, ok := impl.limiters[uuid]
+	if !ok {
+		limiter = &spreadLimiter{}
+		impl.limiters[uuid] = limiter
+	}
+
+	if len(limiter.pending) == 0 && now.Sub(limiter.lastSent) >= SPREAD_MIN_INTERVAL {
+		limiter.lastSent = now
+		impl.mtx.Unlock()
+		return impl.post(req)
+	}
+
+	if len(limiter.pending) == 0 {
+		limiter.pending = append(limiter.pending, req)
+		time.AfterFunc(limiter.lastSent.Add(SPREAD_MIN_INTERVAL).Sub(now), func() {
+			impl.flush(uuid)
+		})
+		impl.mtx.Unlock()
+		return nil
+	}
+
+	last := len(limiter.pending) - 1
+	if merged, mergeable := mergeSpreadRequest(limiter.pending[last], req); mergeable {
+		limiter.pending[last] = merged
+	} else {
+		// queue the update after the pending one to keep the order, it is sent in the next interval
+		limiter.pending = append(limiter.pending, req)
+	}
+	impl.mtx.Unlock()
+	return nil
+}
+
+func (impl *threeMessagingDriverImpl) flush(uuid string) {
+	impl.mtx.Lock()
+	limiter, ok := impl.limiters[uuid]
+	if !ok || len(limiter.pending) == 0 {
+		impl.mtx.Unlock()
+		return
+	}
+	req := limiter.pending[0]
+	limiter.pending = limiter.pending[1:]
+	limiter.lastSent = time.Now()
+	if len(limiter.pending) != 0 {
+		time.AfterFunc(SPREAD_MIN_INTERVAL, func() {
+			impl.flush(uuid)
+		})
+	}
+	impl.mtx.Unlock()
+
+	if err := impl.post(req); err != nil {
+		log.Printf("failed to spread coalesced update of the object: uuid=%s: %s\n", uuid, err.Error())
+	}
+}
+
+// remove the limiters not used recently, it should be called with the lock
+func (impl *threeMessagingDriverImpl) sweep(now time.Time) {
+	if now.Sub(impl.lastSweep) < SPREAD_SWEEP_INTERVAL {
+		return
+	}
+	impl.lastSweep = now
+
+	for uuid, limiter := range impl.limiters {
+		if len(limiter.pending) == 0 && now.Sub(limiter.lastSent) >= SPREAD_MIN_INTERVAL {
+			delete(impl.limiters, uuid)
+		}
+	}
+}
+
+// merge the requests to the request equivalent to spread them in order, return false if they can't be merged
+func mergeSpreadRequest(pending, next *spreadRequest) (*spreadRequest, bool) {
+	merged := &spreadRequest{
+		position: next.position,
+		r:        math.Max(pending.r, next.r),
+	}
+
+	// the object written to the KVS or the deletion overwrites the pending update
+	if next.msg.Deleted || next.msg.Transient == nil {
+		merged.msg = next.msg
+		return merged, true
+	}
+
+	if pending.msg.Deleted || pending.msg.Generation != next.msg.Generation {
+		return nil, false
+	}
+
+	// the transient update contains all the updates since the generation, so the next one replaces the pending one
+	msg := *pending.msg
+	msg.Transient = next.msg.Transient
+	merged.msg = &msg
+
+	return merged, true
+}
+
+func (impl *threeMessagingDriverImpl) post(req *spreadRequest) error {
+	raw, err := json.Marshal(req.msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal: %w", err)
 	}
 
-	err = impl.col.SpreadPost(math.Pi*position.X/180.0, math.Pi*position.Y/180.0, r, messaging.MessageNameSpreadObject, raw, 0)
+	err = impl.col.SpreadPost(math.Pi*req.position.X/180.0, math.Pi*req.position.Y/180.0, req.r, messaging.MessageNameSpreadObject, raw, 0)
 	if err != nil {
 		return fmt.Errorf("failed to spread %s message: %w", messaging.MessageNameSpreadObject, err)
 	}
 	return nil
 }
+
+func (impl *threeMessagingDriverImpl) Interact(nid string, podUUID string, event *threeAPI.InteractionEvent) error {
+	msg := messaging.Interact{
+		PodUUID: podUUID,
+		Event:   event,
+	}
+	content, err := msg.GetCredentialContent()
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	if impl.signer != nil {
+		msg.Credential = impl.signer.MakeCredential(nid, content)
+	}
+
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	_, err = impl.col.MessagingPost(nid, messaging.MessageNameInteract, raw, 0)
+	if err != nil {
+		return fmt.Errorf("failed to post %s message: %w", messaging.MessageNameInteract, err)
+	}
+	return nil
+}
@@ -21,10 +21,11 @@ import (
 
 	"github.com/llamerada-jp/colonio/go/colonio"
 	controller "github.com/llamerada-jp/oinari/node/controller/three"
+	"github.com/llamerada-jp/oinari/node/kvs"
 	messaging "github.com/llamerada-jp/oinari/node/messaging/three"
 )
 
-func InitMessagingHandler(col colonio.Colonio, threeCtrl controller.ObjectController) error {
+func InitMessagingHandler(col colonio.Colonio, signer kvs.ResourceSigner, threeCtrl controller.ObjectController) error {
 	col.SpreadSetHandler(messaging.MessageNameSpreadObject, func(sr *colonio.SpreadRequest) {
 		raw, err := sr.Message.GetBinary()
 		if err != nil {
@@ -39,12 +40,51 @@ func InitMessagingHandler(col colonio.Colonio, threeCtrl controller.ObjectContro
 				return
 			}
 
-			if err := threeCtrl.ReceiveSpreadEvent(msg.UUID); err != nil {
+			if err := threeCtrl.ReceiveSpreadEvent(&msg); err != nil {
 				log.Printf("failed to receive spreadObject message: %s", err.Error())
 				return
 			}
 		}(raw)
 	})
 
+	col.MessagingSetHandler(messaging.MessageNameInteract, func(mr *colonio.MessagingRequest, mrw colonio.MessagingResponseWriter) {
+		raw, err := mr.Message.GetBinary()
+		defer mrw.Write(nil)
+		if err != nil {
+			log.Printf("failed to read interact message: %s", err.Error())
+			return
+		}
+
+		go func(raw []byte, sourceNid string) {
+			var msg messaging.Interact
+			if err := json.Unmarshal(raw, &msg); err != nil {
+				log.Printf("failed to unmarshal interact message: %s", err.Error())
+				return
+			}
+			if msg.Event == nil {
+				log.Println("interact message does not have the event")
+				return
+			}
+
+			// the account written in the event is replaced by the one proved by the credential
+			content, err := msg.GetCredentialContent()
+			if err != nil {
+				log.Printf("failed to marshal interact message: %s", err.Error())
+				return
+			}
+			msg.Event.Account = kvs.GetAuthenticatedAccount(signer, msg.Credential, col.GetLocalNid(), content)
+			if len(msg.Event.Account) == 0 {
+				log.Printf("interaction from %s is dropped because the account is not proved", sourceNid)
+				return
+			}
+			msg.Event.Node = sourceNid
+
+			if err := threeCtrl.DeliverInteraction(msg.PodUUID, msg.Event); err != nil {
+				log.Printf("failed to deliver interaction: %s", err.Error())
+				return
+			}
+		}(raw, mr.SourceNid)
+	})
+
 	return nil
 }
@@ -15,10 +15,44 @@
  */
 package messaging
 
+import (
+	"encoding/json"
+
+	"github.com/llamerada-jp/oinari/api/core"
+	threeAPI "github.com/llamerada-jp/oinari/api/three"
+)
+
 const (
 	MessageNameSpreadObject = "spreadObject"
+	MessageNameInteract     = "interact"
 )
 
+// SpreadObject notifies the update of the object to the nodes around it.
+// Receivers read the object from the KVS only if the payload is not embedded or they don't know the base generation.
 type SpreadObject struct {
 	UUID string `json:"uuid"`
+	// the object written to the KVS, it is not embedded if the size exceeds SPREAD_INLINE_MAX_SIZE
+	Object *threeAPI.Object `json:"object,omitempty"`
+	// generation of the object that the transient update is based on
+	Generation uint64 `json:"generation,omitempty"`
+	// update applied to the object stored in the KVS by receivers
+	Transient *threeAPI.TransientUpdate `json:"transient,omitempty"`
+	// the object is deleted
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// Interact is sent to the node running the pod owning the object
+type Interact struct {
+	PodUUID string                     `json:"podUUID"`
+	Event   *threeAPI.InteractionEvent `json:"event"`
+	// proves the account of the user, the account in the event is not trusted by the receiver
+	Credential *core.Credential `json:"credential,omitempty"`
+}
+
+// GetCredentialContent returns the part of the message covered by the credential
+func (msg *Interact) GetCredentialContent() ([]byte, error) {
+	return json.Marshal(Interact{
+		PodUUID: msg.PodUUID,
+		Event:   msg.Event,
+	})
 }
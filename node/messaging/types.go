@@ -15,11 +15,17 @@
  */
 package messaging
 
-import "github.com/llamerada-jp/oinari/api/core"
+import (
+	"encoding/json"
+
+	"github.com/llamerada-jp/oinari/api/core"
+)
 
 const (
 	MessageNameReconcileContainer = "reconcileContainer"
 	MessageNamePublishNode        = "publishNode"
+	MessageNameFetchLog           = "fetchLog"
+	MessageNameWatchResource      = "watchResource"
 )
 
 type ReconcileContainer struct {
@@ -33,3 +39,34 @@ type PublishNode struct {
 	NodeType core.NodeType `json:"nodeType"`
 	Position *core.Vector3 `json:"position"`
 }
+
+type FetchLog struct {
+	PodUuid string `json:"podUuid"`
+	Account string `json:"account"`
+	After   uint64 `json:"after"`
+	Limit   int    `json:"limit"`
+	// proves the account reading the log, nil if the signer of the sender is not configured
+	Credential *core.Credential `json:"credential,omitempty"`
+}
+
+// GetCredentialContent returns the part of the message covered by the credential
+func (msg *FetchLog) GetCredentialContent() ([]byte, error) {
+	return json.Marshal(FetchLog{
+		PodUuid: msg.PodUuid,
+		Account: msg.Account,
+		After:   msg.After,
+		Limit:   msg.Limit,
+	})
+}
+
+type FetchLogResponse struct {
+	Log   *core.PodLog `json:"log,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+// WatchResource tells the watching node that the resource of the key was written
+type WatchResource struct {
+	Key             string `json:"key"`
+	ResourceVersion uint64 `json:"resourceVersion"`
+	Deleted         bool   `json:"deleted"`
+}
@@ -17,6 +17,7 @@ package mock
 
 import (
 	"log"
+	"strings"
 	"sync"
 
 	"github.com/llamerada-jp/colonio/go/colonio"
@@ -29,10 +30,13 @@ type colonioValue struct {
 var _ colonio.Value = &colonioValue{}
 
 type Colonio struct {
-	mutex     sync.Mutex
-	kvs       map[string]*colonioValue
-	PositionX float64
-	PositionY float64
+	mutex sync.Mutex
+	kvs   map[string]*colonioValue
+	// number of writes for each key of KVS
+	kvsSetCount map[string]int
+	LocalNid    string
+	PositionX   float64
+	PositionY   float64
 }
 
 var _ colonio.Colonio = &Colonio{}
@@ -40,7 +44,9 @@ var _ colonio.Colonio = &Colonio{}
 // mimic Colonio for testings
 func NewColonioMock() *Colonio {
 	return &Colonio{
-		kvs: make(map[string]*colonioValue),
+		kvs:         make(map[string]*colonioValue),
+		kvsSetCount: make(map[string]int),
+		LocalNid:    "0123456789abcdef0123456789abcdef",
 	}
 }
 
@@ -49,6 +55,29 @@ func (impl *Colonio) DeleteKVSAll() {
 	defer impl.mutex.Unlock()
 
 	impl.kvs = make(map[string]*colonioValue)
+	impl.kvsSetCount = make(map[string]int)
+}
+
+// KvsKeys returns the keys of KVS having the prefix
+func (impl *Colonio) KvsKeys(prefix string) []string {
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+
+	keys := make([]string, 0)
+	for key := range impl.kvs {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// KvsSetCount returns the number of writes for the key
+func (impl *Colonio) KvsSetCount(key string) int {
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+
+	return impl.kvsSetCount[key]
 }
 
 func (impl *Colonio) Connect(url, token string) error {
@@ -67,8 +96,7 @@ func (impl *Colonio) IsConnected() bool {
 }
 
 func (impl *Colonio) GetLocalNid() string {
-	log.Fatal("ColonioMock::GetLocalNid is not implemented")
-	return ""
+	return impl.LocalNid
 }
 
 func (impl *Colonio) SetPosition(x, y float64) (float64, float64, error) {
@@ -120,6 +148,7 @@ func (impl *Colonio) KvsSet(key string, val interface{}, opt uint32) error {
 		}
 	}
 
+	impl.kvsSetCount[key]++
 	if val == nil {
 		impl.kvs[key] = &colonioValue{}
 		return nil
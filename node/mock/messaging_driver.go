@@ -29,6 +29,8 @@ type MessagingRecord struct {
 	DestPosition       core.Vector3
 	PublishNode        *messaging.PublishNode
 	ReconcileContainer *messaging.ReconcileContainer
+	FetchLog           *messaging.FetchLog
+	WatchResource      *messaging.WatchResource
 }
 
 type MessagingDriver struct {
@@ -83,3 +85,39 @@ func (md *MessagingDriver) ReconcileContainer(nid, podUuid string) error {
 
 	return nil
 }
+
+func (md *MessagingDriver) FetchLog(nid, podUuid, account string, after uint64, limit int) (*core.PodLog, error) {
+	md.mutex.Lock()
+	defer md.mutex.Unlock()
+
+	md.Records = append(md.Records, &MessagingRecord{
+		DestNodeID: nid,
+		FetchLog: &messaging.FetchLog{
+			PodUuid: podUuid,
+			Account: account,
+			After:   after,
+			Limit:   limit,
+		},
+	})
+
+	return &core.PodLog{
+		Entries: make([]core.LogEntry, 0),
+		Last:    after,
+	}, nil
+}
+
+func (md *MessagingDriver) NotifyWatch(nid, key string, resourceVersion uint64, deleted bool) error {
+	md.mutex.Lock()
+	defer md.mutex.Unlock()
+
+	md.Records = append(md.Records, &MessagingRecord{
+		DestNodeID: nid,
+		WatchResource: &messaging.WatchResource{
+			Key:             key,
+			ResourceVersion: resourceVersion,
+			Deleted:         deleted,
+		},
+	})
+
+	return nil
+}
@@ -17,7 +17,10 @@ package seed
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -31,6 +34,7 @@ import (
 
 	"github.com/google/go-github/github"
 	"github.com/gorilla/sessions"
+	"github.com/llamerada-jp/oinari/api/core"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/endpoints"
 )
@@ -38,6 +42,9 @@ import (
 const (
 	SESSION_KEY                = "oinari"
 	SECRET_KEY_COOKIE_KEY_PAIR = "cookie_key_pair"
+	// optional, the records of the pods are encrypted with the keys derived from it for each account,
+	// the resources are signed with the keys certified by the key derived from it
+	SECRET_KEY_ACCOUNT_KEY_SEED = "account_key_seed"
 
 	SECRET_KEY_GITHUB_CLIENT_ID     = "github_client_id"
 	SECRET_KEY_GITHUB_CLIENT_SECRET = "github_client_secret"
@@ -67,6 +74,14 @@ func Init(mux *http.ServeMux, secret map[string]string, rootURL, homeURL,
 	store := sessions.NewCookieStore(cookieKeyPair)
 	store.Options.HttpOnly = true
 
+	var accountKeySeed []byte
+	if encoded, ok := secret[SECRET_KEY_ACCOUNT_KEY_SEED]; ok {
+		accountKeySeed, err = base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("failed to decode account key seed: %w", err)
+		}
+	}
+
 	redirectURL, err := url.JoinPath(rootURL, "callback_github")
 	if err != nil {
 		return fmt.Errorf("failed to generate oauth callback url: %w", err)
@@ -118,10 +133,14 @@ func Init(mux *http.ServeMux, secret map[string]string, rootURL, homeURL,
 			session.Save(r, w)
 		}
 
+		credential := deriveAccountCredential(accountKeySeed, account)
 		writePage(w, "main.html", map[string]any{
-			"google_api_key": secret["google_api_key"],
-			"account":        account,
-			"account_id":     accountID,
+			"google_api_key":      secret["google_api_key"],
+			"account":             account,
+			"account_id":          accountID,
+			"account_key":         credential.key,
+			"account_certificate": credential.certificate,
+			"authority_key":       credential.authority,
 		})
 	})
 
@@ -228,6 +247,40 @@ func Init(mux *http.ServeMux, secret map[string]string, rootURL, homeURL,
 	return nil
 }
 
+// accountCredential has the base64 encoded values given to the nodes of the account
+type accountCredential struct {
+	key         string
+	certificate string
+	authority   string
+}
+
+// deriveAccountCredential returns the key shared by the nodes of the account and the certificate of the signing key
+// derived from it, the fields are empty if the seed of the keys is not configured.
+func deriveAccountCredential(seed []byte, account string) *accountCredential {
+	if len(seed) == 0 {
+		return &accountCredential{}
+	}
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte(account))
+	accountKey := mac.Sum(nil)
+
+	authority := deriveAuthorityKey(seed)
+	publicKey := core.DeriveNodeKey(accountKey).Public().(ed25519.PublicKey)
+	return &accountCredential{
+		key:         base64.StdEncoding.EncodeToString(accountKey),
+		certificate: base64.StdEncoding.EncodeToString(core.CertifyKey(authority, account, publicKey)),
+		authority:   base64.StdEncoding.EncodeToString(authority.Public().(ed25519.PublicKey)),
+	}
+}
+
+// deriveAuthorityKey returns the key to certify the signing keys of the accounts, it is not shared with the nodes.
+// the info starts with NUL which is not used in the account names to separate it from the account keys.
+func deriveAuthorityKey(seed []byte) ed25519.PrivateKey {
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte("\x00oinari/seed/authority-key"))
+	return ed25519.NewKeyFromSeed(mac.Sum(nil)[:ed25519.SeedSize])
+}
+
 func writeErrorPage(w http.ResponseWriter, code int) {
 	w.WriteHeader(http.StatusInternalServerError)
 	tpl, err := template.ParseFiles(filepath.Join(templateRoot, "error.html"))
@@ -15,6 +15,13 @@ export interface ConnectInfo {
   nodeID: string
 }
 
+// base64 encoded values given by the seed, they are empty if the records are not encrypted or signed
+export interface AccountCredential {
+  accountKey: string
+  accountCertificate: string
+  authorityKey: string
+}
+
 export interface ApplicationDigest {
   name: string
   uuid: string
@@ -53,8 +60,22 @@ interface CreatePodResponse {
   digest: ApplicationDigest
 }
 
+// empty fields match any process
+export interface ProcessFilter {
+  // Pending, Running, Terminating, Terminated or Unknown
+  phase?: string
+  runningNode?: string
+  namePrefix?: string
+}
+
+interface ListPodRequest extends ProcessFilter {
+  limit?: number
+  continue?: string
+}
+
 interface ListPodResponse {
   digests: Array<ApplicationDigest>
+  continue?: string
 }
 
 interface MigratePodRequest {
@@ -66,6 +87,75 @@ interface DeletePodRequest {
   uuid: string
 }
 
+// generation of the process record kept in the history
+export interface RecordGeneration {
+  generation: number
+  timestamp: string
+  sourceNode: string
+  // generation copied to this generation by the restart
+  restoredFrom?: number
+  containers: Record<string, RecordGenerationContainer>
+}
+
+export interface RecordGenerationContainer {
+  timestamp: string
+  size: number
+}
+
+interface ListRecordGenerationRequest {
+  uuid: string
+}
+
+interface ListRecordGenerationResponse {
+  generations: Array<RecordGeneration>
+}
+
+interface RestartPodRequest {
+  uuid: string
+  generation: number
+}
+
+interface InteractObjectRequest {
+  uuid: string
+  part: string
+  type: string
+}
+
+interface GetPodLogRequest {
+  uuid: string
+  after: number
+  limit: number
+}
+
+interface GetPodLogResponse {
+  log: PodLog
+}
+
+export interface LogEntry {
+  sequence: number
+  container: string
+  timestamp: string
+  level: string
+  message: string
+  attrs: Record<string, string> | undefined
+}
+
+export interface PodLog {
+  entries: Array<LogEntry>
+  last: number
+}
+
+// event of the watched resource, get the resource again to know the change
+export interface WatchEvent {
+  id: string
+  resourceVersion: number
+  deleted: boolean
+}
+
+interface WatchResponse {
+  id: string
+}
+
 interface ObjectMeta {
   name: string
   namespace: string | undefined
@@ -80,6 +170,19 @@ interface PodSpec {
   containers: Array<Container>
   // TODO scheduler
   enableMigrate: boolean
+  egress?: EgressSpec
+}
+
+interface EgressSpec {
+  allow: Array<EgressRule>
+  maxResponseSize?: number
+}
+
+interface EgressRule {
+  host: string
+  methods?: Array<string>
+  pathPrefix?: string
+  allowHTTP?: boolean
 }
 
 interface Container {
@@ -110,18 +213,24 @@ interface Vector3 {
 
 export class Commands {
   private cl: CL.Crosslink;
+  // key: subscription id
+  private watchListeners: Map<string, (event: WatchEvent) => void>;
   constructor(cl: CL.Crosslink) {
     this.cl = cl;
+    this.watchListeners = new Map();
   }
 
   getNodeInfo(): Promise<NodeInfo> {
     return this.cl.call(CL_SYSTEM_PATH + "/info", {}) as Promise<NodeInfo>;
   }
 
-  connect(url: string, account: string, token: string, nodeName: string, nodeType: string): Promise<ConnectInfo> {
+  connect(url: string, account: string, credential: AccountCredential, token: string, nodeName: string, nodeType: string): Promise<ConnectInfo> {
     return this.cl.call(CL_SYSTEM_PATH + "/connect", {
       url: url,
       account: account,
+      accountKey: credential.accountKey,
+      accountCertificate: credential.accountCertificate,
+      authorityKey: credential.authorityKey,
       token: token,
       nodeName: nodeName,
       nodeType: nodeType,
@@ -154,6 +263,15 @@ export class Commands {
     });
   }
 
+  // send the user's interaction (click, hoverStart, hoverEnd or select) with the object to its application
+  interactObject(uuid: string, part: string, type: string): Promise<any> {
+    return this.cl.call(CL_RESOURCE_PATH + "/interactObject", {
+      uuid: uuid,
+      part: part,
+      type: type,
+    } as InteractObjectRequest);
+  }
+
   listNode(): Promise<Array<NodeState>> {
     return this.cl.call(CL_RESOURCE_PATH + "/listNode", {}).then((r) => {
       let response = r as ListNodeResponse;
@@ -172,7 +290,10 @@ export class Commands {
     }).then((a) => {
       app = a as ApplicationDefinition
 
-      return this.cl.call(CL_RESOURCE_PATH + "/listPod", {});
+      return this.cl.call(CL_RESOURCE_PATH + "/listPod", {
+        namePrefix: app.metadata.name,
+        limit: 500,
+      } as ListPodRequest);
 
     }).then((l) => {
       let pods = l as ListPodResponse;
@@ -203,11 +324,19 @@ export class Commands {
     });
   }
 
-  listProcess(): Promise<Array<ApplicationDigest>> {
-    return this.cl.call(CL_RESOURCE_PATH + "/listPod", {}).then((r) => {
-      let response = r as ListPodResponse;
-      return response.digests;
-    });
+  // get all processes matching the filter by following the pages
+  async listProcess(filter: ProcessFilter = {}): Promise<Array<ApplicationDigest>> {
+    let digests = new Array<ApplicationDigest>();
+    let cont: string | undefined = undefined;
+    do {
+      let response = await this.cl.call(CL_RESOURCE_PATH + "/listPod", {
+        ...filter,
+        continue: cont,
+      } as ListPodRequest) as ListPodResponse;
+      digests.push(...response.digests);
+      cont = response.continue;
+    } while (cont);
+    return digests;
   }
 
   migrateProcess(uuid: string, targetNode: string): Promise<any> {
@@ -217,9 +346,104 @@ export class Commands {
     } as MigratePodRequest)
   }
 
+  // get the generations of the process record from the oldest one
+  listRecordGeneration(uuid: string): Promise<Array<RecordGeneration>> {
+    return this.cl.call(CL_RESOURCE_PATH + "/listRecordGeneration", {
+      uuid: uuid,
+    } as ListRecordGenerationRequest).then((r) => {
+      let response = r as ListRecordGenerationResponse;
+      return response.generations;
+    });
+  }
+
+  // restart the process from the generation of the record
+  restartProcess(uuid: string, generation: number): Promise<any> {
+    return this.cl.call(CL_RESOURCE_PATH + "/restartPod", {
+      uuid: uuid,
+      generation: generation,
+    } as RestartPodRequest);
+  }
+
   terminateProcess(uuid: string): Promise<any> {
     return this.cl.call(CL_RESOURCE_PATH + "/deletePod", {
       uuid: uuid,
     } as DeletePodRequest);
   }
-}
\ No newline at end of file
+
+  // get log entries of the process newer than `after` sequence number
+  getProcessLog(uuid: string, after: number = 0, limit: number = 0): Promise<PodLog> {
+    return this.cl.call(CL_RESOURCE_PATH + "/getPodLog", {
+      uuid: uuid,
+      after: after,
+      limit: limit,
+    } as GetPodLogRequest).then((r) => {
+      let response = r as GetPodLogResponse;
+      return response.log;
+    });
+  }
+
+  // call `listener` with new log entries of the process every `interval` msec, return function to stop it
+  streamProcessLog(uuid: string, listener: (entries: Array<LogEntry>) => void, interval: number = 1000): () => void {
+    let after = 0;
+    let stopped = false;
+    let timer = 0;
+
+    let fetchLog = (): void => {
+      this.getProcessLog(uuid, after).then((podLog) => {
+        if (stopped) {
+          return;
+        }
+        after = podLog.last;
+        if (podLog.entries.length !== 0) {
+          listener(podLog.entries);
+        }
+      }).catch((e) => {
+        console.error(e);
+      }).finally(() => {
+        if (!stopped) {
+          timer = window.setTimeout(fetchLog, interval);
+        }
+      });
+    };
+    fetchLog();
+
+    return () => {
+      stopped = true;
+      window.clearTimeout(timer);
+    };
+  }
+
+  // call `listener` when the process is updated or deleted, return function to stop it
+  watchProcess(uuid: string, listener: (event: WatchEvent) => void): Promise<() => Promise<any>> {
+    return this.cl.call(CL_RESOURCE_PATH + "/watchPod", {
+      uuid: uuid,
+    }).then((r) => {
+      return this.addWatchListener((r as WatchResponse).id, listener);
+    });
+  }
+
+  // call `listener` when the account record of this node is updated, return function to stop it
+  watchAccount(listener: (event: WatchEvent) => void): Promise<() => Promise<any>> {
+    return this.cl.call(CL_RESOURCE_PATH + "/watchAccount", {}).then((r) => {
+      return this.addWatchListener((r as WatchResponse).id, listener);
+    });
+  }
+
+  // called by the handler of frontend/watchEvent
+  dispatchWatchEvent(event: WatchEvent): void {
+    let listener = this.watchListeners.get(event.id);
+    if (listener !== undefined) {
+      listener(event);
+    }
+  }
+
+  private addWatchListener(id: string, listener: (event: WatchEvent) => void): () => Promise<any> {
+    this.watchListeners.set(id, listener);
+    return () => {
+      this.watchListeners.delete(id);
+      return this.cl.call(CL_RESOURCE_PATH + "/unwatch", {
+        id: id,
+      });
+    };
+  }
+}
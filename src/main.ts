@@ -29,6 +29,7 @@ import * as UI_PL from "./ui/proc_list";
 import * as UI_SE from "./ui/settings";
 import * as UI_XR from "./ui/xr";
 import * as Util from "./ui/util";
+import * as V from "./ui/view";
 
 declare function ColonioModule(): Promise<any>;
 
@@ -43,11 +44,23 @@ let localSettings: LS.LocalSettings;
 let position: POS.Position;
 
 let intervalForCheckSeed: number = 0;
-
-export function main(account: string): void {
+// key shared by the nodes of the account, it is empty if the records are not encrypted
+let accountKey: string = "";
+// certificate of the signing key and the public key of the seed, they are empty if the resources are not signed
+let accountCertificate: string = "";
+let authorityKey: string = "";
+
+export function main(account: string, key: string = "", certificate: string = "", authority: string = ""): void {
+  accountKey = key;
+  accountCertificate = certificate;
+  authorityKey = authority;
   // start controller
   initController().then(() => {
     command = new CM.Commands(crosslink);
+    frontendMpx.setHandlerFunc("watchEvent", (data: any, _: Map<string, string>, writer: CL.ResponseWriter) => {
+      command.dispatchWatchEvent(data as CM.WatchEvent);
+      writer.replySuccess("");
+    });
 
     localSettings = new LS.LocalSettings(command, account);
     position = new POS.Position(command);
@@ -146,6 +159,11 @@ async function connect(): Promise<void> {
     let connectInfo = await command.connect(
       location.protocol + "//" + location.host + "/seed",
       localSettings.account,
+      {
+        accountKey: accountKey,
+        accountCertificate: accountCertificate,
+        authorityKey: authorityKey,
+      },
       "",
       localSettings.deviceName,
       "PC");
@@ -193,6 +211,13 @@ function start(connectInfo: CM.ConnectInfo): Promise<void> {
   }
 }
 
+function interact(target: V.InteractionTarget, type: string): void {
+  command.interactObject(target.uuid, target.part, type).catch((e) => {
+    // the object might not accept interactions
+    console.debug(e);
+  });
+}
+
 function startLandscape(connectInfo: CM.ConnectInfo): Promise<void> {
   UI_AL.init(command);
   UI_MI.init(command);
@@ -212,7 +237,7 @@ function startLandscape(connectInfo: CM.ConnectInfo): Promise<void> {
   UI_INFO.init(localSettings, position);
 
   return new Promise<void>((resolve) => {
-    UI_MAP.init(frontendMpx, position, () => {
+    UI_MAP.init(frontendMpx, position, interact, () => {
       // show
       UI_INFO.show();
       UI_MAP.show();
@@ -224,7 +249,7 @@ function startLandscape(connectInfo: CM.ConnectInfo): Promise<void> {
 }
 
 function startXR(connectInfo: CM.ConnectInfo): void {
-  UI_XR.init(frontendMpx, position);
+  UI_XR.init(frontendMpx, position, interact);
 
   UI_AL.init(command);
   UI_MI.init(command);
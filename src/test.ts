@@ -9,6 +9,7 @@ declare function ColonioModule(): Promise<any>;
 
 const TESTS = [
   "test/test_api_core.wasm",
+  "test/test_lib_oinari.wasm",
   "test/test_node.wasm",
 ];
 
@@ -111,7 +112,7 @@ async function sidenode(param: SideNodeParam) {
   systemMpx.setHandlerFunc("nodeReady", (_1: any, _2: Map<string, string>, writer: CL.ResponseWriter) => {
     writer.replySuccess("");
     let command = new CM.Commands(crosslink);
-    command.connect("https://localhost:8080/seed", param.account, "", param.nodeName, param.nodeType).then(() => {
+    command.connect("https://localhost:8080/seed", param.account, { accountKey: "", accountCertificate: "", authorityKey: "" }, "", param.nodeName, param.nodeType).then(() => {
       return command.setPosition({
         x: param.longitude,
         y: param.latitude,
@@ -28,6 +28,7 @@ let overlayView: OinariOverlayView;
 let position: POS.Position;
 let hadReady: boolean = false;
 let onReady: () => void;
+let interactionListener: V.InteractionListener;
 
 const mapOptions = {
   tilt: 67.5,
@@ -41,9 +42,10 @@ const mapOptions = {
   keyboardShortcuts: false,
 };
 
-export function init(frontendMpx: CL.MultiPlexer, pos: POS.Position, ready: () => void): void {
+export function init(frontendMpx: CL.MultiPlexer, pos: POS.Position, listener: V.InteractionListener, ready: () => void): void {
   initHandler(frontendMpx);
   position = pos;
+  interactionListener = listener;
 
   if (hadReady) {
     ready();
@@ -68,6 +70,17 @@ export function show(): void {
 
 function start(): void {
   overlayView = new OinariOverlayView(position);
+
+  let viewEl = document.getElementById(mainViewElID) as HTMLElement;
+  viewEl.addEventListener("click", (event: MouseEvent) => {
+    let target = overlayView.pick(viewEl, event);
+    if (target !== undefined) {
+      interactionListener(target, "click");
+    }
+  });
+  viewEl.addEventListener("mousemove", (event: MouseEvent) => {
+    overlayView.hover(overlayView.pick(viewEl, event));
+  });
 }
 
 function initHandler(frontendMpx: CL.MultiPlexer): void {
@@ -89,6 +102,8 @@ class OinariOverlayView extends ThreeJSOverlayView {
   deletingObjects: Set<string>;
   objects: Map<string, V.ObjectWrapper>;
   scene: THREE.Scene;
+  hovering: V.InteractionTarget | undefined;
+  nodePosition: POS.Position;
 
   constructor(position: POS.Position) {
     let coordinate = position.coordinate;
@@ -127,6 +142,7 @@ class OinariOverlayView extends ThreeJSOverlayView {
     this.deletingObjects = new Set<string>();
     this.objects = new Map<string, V.ObjectWrapper>();
     this.scene = scene;
+    this.nodePosition = position;
   }
 
   applyObjects(objects: V.Object[]): void {
@@ -142,13 +158,48 @@ class OinariOverlayView extends ThreeJSOverlayView {
     }
   }
 
+  // pick returns the object under the mouse pointer
+  pick(viewEl: HTMLElement, event: MouseEvent): V.InteractionTarget | undefined {
+    let rect = viewEl.getBoundingClientRect();
+    let pointer = new THREE.Vector2(
+      ((event.clientX - rect.left) / rect.width) * 2 - 1,
+      -((event.clientY - rect.top) / rect.height) * 2 + 1,
+    );
+    try {
+      let intersections = this.raycast(pointer, Array.from(this.objects.values()), { recursive: true });
+      for (let intersection of intersections) {
+        let target = V.findInteractionTarget(intersection.object);
+        if (target !== undefined) {
+          return target;
+        }
+      }
+    } catch (e) {
+      // raycasting for some kinds of objects needs the camera, ignore them
+      console.debug(e);
+    }
+    return undefined;
+  }
+
+  hover(target: V.InteractionTarget | undefined): void {
+    if (this.hovering?.uuid === target?.uuid && this.hovering?.part === target?.part) {
+      return;
+    }
+    if (this.hovering !== undefined) {
+      interactionListener(this.hovering, "hoverEnd");
+    }
+    if (target !== undefined) {
+      interactionListener(target, "hoverStart");
+    }
+    this.hovering = target;
+  }
+
   onDraw({ gl, transformer }: google.maps.WebGLDrawOptions): void {
     super.onDraw({ gl, transformer });
 
     for (const [uuid, obj] of this.applyingObjects) {
       let wrapper = this.objects.get(uuid);
       if (wrapper === undefined) {
-        wrapper = new V.ObjectWrapper(V.ScaleModeLandScape);
+        wrapper = new V.ObjectWrapper(uuid, V.ScaleModeLandScape);
         this.objects.set(uuid, wrapper);
         this.scene.add(wrapper);
       }
@@ -166,7 +217,11 @@ class OinariOverlayView extends ThreeJSOverlayView {
     }
     this.deletingObjects.clear();
 
+    let now = Date.now();
+    let coordinate = this.nodePosition.coordinate;
     for (const [_, wrapper] of this.objects) {
+      wrapper.updateVisibility(coordinate.latitude, coordinate.longitude);
+      wrapper.animate(now);
       // wrapper.transformPosition(transformer);
       // TODO: this code is workaround. i couldn't find the correct way.
       wrapper.position.copy(this.latLngAltitudeToVector3({
@@ -24,6 +24,7 @@ let processing: boolean = false;
 let account: string;
 let nodeID: string;
 let spinners = ["procListByAccountSpinner1", "procListByAccountSpinner2"];
+let watching: boolean = false;
 
 export function init(cmd: CMD.Commands, localSettings: LS.LocalSettings, nID: string): void {
   command = cmd;
@@ -36,11 +37,26 @@ export function init(cmd: CMD.Commands, localSettings: LS.LocalSettings, nID: st
   btnProcRefresh?.addEventListener("click", reload);
 }
 
+// reload the list when processes are added to or moved in the account
+function watchAccount(): void {
+  if (watching) {
+    return;
+  }
+  watching = true;
+  command.watchAccount(() => {
+    reload();
+  }).catch((e) => {
+    console.error(e);
+    watching = false;
+  });
+}
+
 async function reload(): Promise<void> {
   if (processing) {
     return;
   }
   processing = true;
+  watchAccount();
 
   // show spinner
   for (let id of spinners) {
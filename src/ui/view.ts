@@ -27,6 +27,13 @@ export interface DeleteObjectsRequest {
 export interface Object {
   meta: ObjectMeta;
   spec: ObjectSpec;
+  acl?: ObjectACL;
+}
+
+interface ObjectACL {
+  read?: string;
+  write?: string;
+  interact?: string;
 }
 
 interface ObjectMeta {
@@ -36,6 +43,9 @@ interface ObjectMeta {
   creatorNode: string;
   uuid: string;
   deletionTimestamp: string;
+  parent?: string;
+  apiVersion?: string;
+  resourceVersion?: number;
 }
 
 interface ObjectSpec {
@@ -43,15 +53,28 @@ interface ObjectSpec {
   materials: MaterialSpec[];
   maps: TextureSpec[];
   position: Vec3;
+  animations?: AnimationSpec[];
+  // radius in meters where the object is visible for each scale mode
+  visibleRadius?: VisibleRadius;
+}
+
+interface VisibleRadius {
+  default?: number;
+  landscape?: number;
+  xr?: number;
 }
 
 interface PartSpec {
   name: string;
-  sprite: SpriteSpec;
+  mesh?: MeshSpec;
+  sprite?: SpriteSpec;
+  group?: GroupSpec;
 }
 
 interface PartBaseSpec {
   scale: Scale;
+  position?: Vec3;
+  rotation?: Vec3;
 }
 
 interface Scale {
@@ -60,18 +83,70 @@ interface Scale {
   landscape: Vec3;
 }
 
+interface MeshSpec extends PartBaseSpec {
+  geometry: GeometrySpec;
+  material: string;
+}
+
 interface SpriteSpec extends PartBaseSpec {
   material: string;
   center: Vec2;
 }
 
+interface GroupSpec extends PartBaseSpec {
+  parts: PartSpec[];
+}
+
+interface GeometrySpec {
+  box?: BoxGeometrySpec;
+  sphere?: SphereGeometrySpec;
+  plane?: PlaneGeometrySpec;
+  cylinder?: CylinderGeometrySpec;
+}
+
+interface BoxGeometrySpec {
+  width?: number;
+  height?: number;
+  depth?: number;
+  widthSegments?: number;
+  heightSegments?: number;
+  depthSegments?: number;
+}
+
+interface SphereGeometrySpec {
+  radius?: number;
+  widthSegments?: number;
+  heightSegments?: number;
+}
+
+interface PlaneGeometrySpec {
+  width?: number;
+  height?: number;
+  widthSegments?: number;
+  heightSegments?: number;
+}
+
+interface CylinderGeometrySpec {
+  radiusTop?: number;
+  radiusBottom?: number;
+  height?: number;
+  radialSegments?: number;
+  heightSegments?: number;
+  openEnded?: boolean;
+}
+
 interface MaterialSpec {
   name: string;
-  spriteMaterial: SpriteMaterialSpec;
+  spriteMaterial?: SpriteMaterialSpec;
+  meshBasicMaterial?: MeshBasicMaterialSpec;
+  meshStandardMaterial?: MeshStandardMaterialSpec;
 }
 
 interface MaterialBaseSpec {
   alphaTest: number;
+  side?: number;
+  transparent?: boolean;
+  opacity?: number;
 }
 
 interface SpriteMaterialSpec extends MaterialBaseSpec {
@@ -79,6 +154,21 @@ interface SpriteMaterialSpec extends MaterialBaseSpec {
   mapTexture: string;
 }
 
+interface MeshBasicMaterialSpec extends MaterialBaseSpec {
+  color?: Color;
+  mapTexture?: string;
+  wireframe?: boolean;
+}
+
+interface MeshStandardMaterialSpec extends MaterialBaseSpec {
+  color?: Color;
+  mapTexture?: string;
+  emissive?: Color;
+  roughness?: number;
+  metalness?: number;
+  wireframe?: boolean;
+}
+
 interface TextureSpec {
   name: string;
   urlTexture: URLTextureSpec;
@@ -95,6 +185,34 @@ interface URLTextureSpec extends TextureBaseSpec {
   url: string;
 }
 
+interface AnimationSpec {
+  name: string;
+  duration: number;
+  loop: string;
+  startAt?: string;
+  tracks?: AnimationTrackSpec[];
+  spriteSheet?: SpriteSheetAnimationSpec;
+}
+
+interface AnimationTrackSpec {
+  part: string;
+  property: string;
+  interpolation?: string;
+  keyframes: KeyframeSpec[];
+}
+
+interface KeyframeSpec {
+  time: number;
+  value: Vec3;
+}
+
+interface SpriteSheetAnimationSpec {
+  map: string;
+  columns: number;
+  rows: number;
+  frames?: number[];
+}
+
 interface Color {
   r: number;
   g: number;
@@ -117,25 +235,80 @@ interface TextureEntry {
   url: string
 }
 
+interface PartEntry {
+  object: THREE.Object3D
+  // the spec of the local transform, animations are applied on it
+  base?: PartBaseSpec
+  // json of the geometry spec to detect changes, only for mesh
+  geometry?: string
+}
+
+interface AnimationEntry {
+  spec: AnimationSpec
+  // json of the spec to keep the phase while the animation is not changed
+  key: string
+  // epoch time in milliseconds
+  startAt: number
+}
+
+interface MaterialEntry {
+  material: THREE.Material
+  // json of the mesh material spec to detect changes
+  spec?: string
+}
+
+export interface InteractionTarget {
+  uuid: string
+  part: string
+}
+
+export type InteractionListener = (target: InteractionTarget, type: string) => void;
+
+// findInteractionTarget returns the object and the part containing the intersected three.js object
+export function findInteractionTarget(object: THREE.Object3D | null): InteractionTarget | undefined {
+  let part = "";
+  while (object !== null) {
+    if (part === "" && object.userData.part !== undefined) {
+      part = object.userData.part;
+    }
+    if (object instanceof ObjectWrapper) {
+      return { uuid: object.objectUUID, part: part };
+    }
+    object = object.parent;
+  }
+  return undefined;
+}
+
 export const ScaleModeLandScape = "landscape";
 export const ScaleModeXR = "xr";
 export type ScaleMode = typeof ScaleModeLandScape | typeof ScaleModeXR;
 
+const DefaultVisibleRadius = 1000;
+const EarthRadius = 6378137;
+
 export class ObjectWrapper extends THREE.Group {
-  sprites: Map<string, THREE.Sprite>;
-  materials: Map<string, THREE.Material>;
+  // key: part name, parts in the groups are also contained
+  parts: Map<string, PartEntry>;
+  materials: Map<string, MaterialEntry>;
   textures: Map<string, TextureEntry>;
+  animations: AnimationEntry[];
   objPosition: Vec3;
+  visibleRadius: number;
   position!: THREE.Vector3;
   scaleMode: ScaleMode;
+  // uuid of the oinari object, `uuid` is the id of three.js
+  objectUUID: string;
 
-  constructor(scaleMode: ScaleMode) {
+  constructor(uuid: string, scaleMode: ScaleMode) {
     super();
 
-    this.sprites = new Map<string, THREE.Sprite>();
-    this.materials = new Map<string, THREE.Material>();
+    this.objectUUID = uuid;
+    this.parts = new Map<string, PartEntry>();
+    this.materials = new Map<string, MaterialEntry>();
     this.textures = new Map<string, TextureEntry>();
+    this.animations = [];
     this.objPosition = { x: 0, y: 0, z: 0 } as Vec3;
+    this.visibleRadius = DefaultVisibleRadius;
     this.scaleMode = scaleMode;
   }
 
@@ -143,7 +316,20 @@ export class ObjectWrapper extends THREE.Group {
     this.applyTextures(obj.spec.maps);
     this.applyMaterials(obj.spec.materials);
     this.applyParts(obj.spec.parts);
+    this.applyAnimations(obj.spec.animations ?? []);
     this.objPosition = obj.spec.position;
+    this.visibleRadius = this.selectVisibleRadius(obj.spec.visibleRadius ?? {});
+  }
+
+  // hide the object farther than the visible radius from the viewer
+  updateVisibility(latitude: number, longitude: number): void {
+    let lat1 = latitude * Math.PI / 180;
+    let lat2 = this.objPosition.y * Math.PI / 180;
+    let dLat = lat2 - lat1;
+    let dLng = (this.objPosition.x - longitude) * Math.PI / 180;
+    let a = Math.sin(dLat / 2) ** 2 + Math.cos(lat1) * Math.cos(lat2) * Math.sin(dLng / 2) ** 2;
+    let distance = 2 * EarthRadius * Math.asin(Math.min(1, Math.sqrt(a)));
+    this.visible = distance <= this.visibleRadius;
   }
 
   transformPosition(transformer: google.maps.CoordinateTransformer): void {
@@ -157,28 +343,49 @@ export class ObjectWrapper extends THREE.Group {
   }
 
   applyParts(parts: PartSpec[]): void {
-    for (let part of parts) {
-      this.applySprite(part);
+    let using = new Set<string>();
+    this.applyPartsTo(this, parts, using);
+
+    for (let [name, entry] of this.parts) {
+      if (!using.has(name)) {
+        this.disposePart(entry);
+        this.parts.delete(name);
+      }
     }
+  }
 
-    for (let [name, obj] of this.sprites) {
-      let using = false;
-      for (let part of parts) {
-        if (name === part.name) {
-          using = true;
-          break;
-        }
+  applyPartsTo(parent: THREE.Object3D, parts: PartSpec[], using: Set<string>): void {
+    for (let part of parts) {
+      using.add(part.name);
+
+      let object: THREE.Object3D;
+      let base: PartBaseSpec;
+      if (part.sprite !== undefined) {
+        object = this.applySprite(part.name, part.sprite);
+        base = part.sprite;
+      } else if (part.mesh !== undefined) {
+        object = this.applyMesh(part.name, part.mesh);
+        base = part.mesh;
+      } else if (part.group !== undefined) {
+        object = this.applyGroup(part.name, part.group, using);
+        base = part.group;
+      } else {
+        throw new Error("part type is not specified");
       }
-      if (!using) {
-        this.remove(obj);
-        this.sprites.delete(name);
+
+      // `add` moves the object from the previous parent
+      if (object.parent !== parent) {
+        parent.add(object);
       }
+      this.parts.get(part.name)!.base = base;
+      object.userData.part = part.name;
+      this.applyTransform(object, base);
     }
   }
 
-  applySprite(part: PartSpec): void {
-    let sprite = this.sprites.get(part.name);
-    let material = this.materials.get(part.sprite.material);
+  applySprite(name: string, spec: SpriteSpec): THREE.Object3D {
+    let entry = this.parts.get(name);
+    let material = this.materials.get(spec.material)?.material;
     if (material === undefined) {
       throw new Error("material not found");
     }
@@ -186,22 +393,244 @@ export class ObjectWrapper extends THREE.Group {
       throw new Error("material is not SpriteMaterial");
     }
 
-    if (sprite !== undefined && sprite.material !== material) {
-      this.remove(sprite);
-      sprite = undefined;
+    if (entry !== undefined && (!(entry.object instanceof THREE.Sprite) || entry.object.material !== material)) {
+      this.disposePart(entry);
+      entry = undefined;
+    }
+
+    if (entry === undefined) {
+      entry = {
+        object: new THREE.Sprite(material as THREE.SpriteMaterial),
+      };
+      this.parts.set(name, entry);
+    }
+
+    let sprite = entry.object as THREE.Sprite;
+    if (spec.center !== undefined) {
+      sprite.center.set(spec.center.x, spec.center.y);
+    }
+
+    return sprite;
+  }
+
+  applyMesh(name: string, spec: MeshSpec): THREE.Object3D {
+    let entry = this.parts.get(name);
+    let material = this.materials.get(spec.material)?.material;
+    if (material === undefined) {
+      throw new Error("material not found");
+    }
+    if (material.type !== "MeshBasicMaterial" && material.type !== "MeshStandardMaterial") {
+      throw new Error("material is not for mesh");
+    }
+
+    let geometryKey = JSON.stringify(spec.geometry);
+    if (entry !== undefined && !(entry.object instanceof THREE.Mesh)) {
+      this.disposePart(entry);
+      entry = undefined;
+    }
+
+    if (entry === undefined) {
+      entry = {
+        object: new THREE.Mesh(this.makeGeometry(spec.geometry), material),
+        geometry: geometryKey,
+      };
+      this.parts.set(name, entry);
+      return entry.object;
+    }
+
+    let mesh = entry.object as THREE.Mesh;
+    if (entry.geometry !== geometryKey) {
+      mesh.geometry.dispose();
+      mesh.geometry = this.makeGeometry(spec.geometry);
+      entry.geometry = geometryKey;
+    }
+    if (mesh.material !== material) {
+      mesh.material = material;
+    }
+
+    return mesh;
+  }
+
+  applyGroup(name: string, spec: GroupSpec, using: Set<string>): THREE.Object3D {
+    let entry = this.parts.get(name);
+    if (entry !== undefined && !(entry.object instanceof THREE.Group)) {
+      this.disposePart(entry);
+      entry = undefined;
     }
 
-    if (sprite === undefined) {
-      sprite = new THREE.Sprite(material as THREE.SpriteMaterial);
-      this.add(sprite);
-      this.sprites.set(part.name, sprite);
+    if (entry === undefined) {
+      entry = {
+        object: new THREE.Group(),
+      };
+      this.parts.set(name, entry);
     }
 
-    if (part.sprite.scale !== undefined) {
-      let scale = this.selectScale(part.sprite.scale);
+    this.applyPartsTo(entry.object, spec.parts ?? [], using);
+    return entry.object;
+  }
+
+  applyTransform(object: THREE.Object3D, base: PartBaseSpec): void {
+    if (base.scale !== undefined) {
+      let scale = this.selectScale(base.scale);
       if (scale && scale.x !== 0 && scale.y !== 0 && scale.z !== 0) {
-        sprite.scale.set(scale.x, scale.y, scale.z);
+        object.scale.set(scale.x, scale.y, scale.z);
+      }
+    }
+    if (base.position !== undefined) {
+      object.position.set(base.position.x, base.position.y, base.position.z);
+    }
+    if (base.rotation !== undefined) {
+      object.rotation.set(base.rotation.x, base.rotation.y, base.rotation.z);
+    }
+  }
+
+  applyAnimations(animations: AnimationSpec[]): void {
+    let prev = this.animations;
+    this.animations = [];
+    for (let spec of animations) {
+      let key = JSON.stringify(spec);
+      let entry = prev.find((e) => e.key === key);
+      if (entry === undefined) {
+        entry = {
+          spec: spec,
+          key: key,
+          startAt: spec.startAt !== undefined ? Date.parse(spec.startAt) : Date.now(),
+        };
+      }
+      this.animations.push(entry);
+    }
+
+    // restore the transforms changed by the stopped animations
+    for (let entry of prev) {
+      if (this.animations.includes(entry)) {
+        continue;
+      }
+      for (let track of entry.spec.tracks ?? []) {
+        let part = this.parts.get(track.part);
+        if (part !== undefined && part.base !== undefined) {
+          this.applyTransform(part.object, part.base);
+        }
+      }
+    }
+  }
+
+  // animate should be called on each frame with the epoch time in milliseconds
+  animate(now: number): void {
+    for (let entry of this.animations) {
+      let elapsed = now - entry.startAt;
+      if (elapsed < 0) {
+        continue;
+      }
+      let spec = entry.spec;
+      let t = this.animationPhase(elapsed, spec.duration, spec.loop);
+
+      for (let track of spec.tracks ?? []) {
+        let part = this.parts.get(track.part);
+        if (part === undefined) {
+          continue;
+        }
+        let v = this.sampleTrack(track, t);
+        switch (track.property) {
+          case "position":
+            part.object.position.set(v.x, v.y, v.z);
+            break;
+          case "rotation":
+            part.object.rotation.set(v.x, v.y, v.z);
+            break;
+          case "scale": {
+            let scale = part.base?.scale !== undefined ? this.selectScale(part.base.scale) : undefined;
+            if (scale === undefined || scale.x === 0 || scale.y === 0 || scale.z === 0) {
+              scale = { x: 1, y: 1, z: 1 };
+            }
+            part.object.scale.set(scale.x * v.x, scale.y * v.y, scale.z * v.z);
+            break;
+          }
+        }
+      }
+
+      if (spec.spriteSheet !== undefined) {
+        this.animateSpriteSheet(spec.spriteSheet, t / spec.duration);
+      }
+    }
+  }
+
+  animationPhase(elapsed: number, duration: number, loop: string): number {
+    switch (loop) {
+      case "repeat":
+        return elapsed % duration;
+      case "pingpong": {
+        let cycle = elapsed % (duration * 2);
+        return cycle <= duration ? cycle : duration * 2 - cycle;
+      }
+      default:
+        return Math.min(elapsed, duration);
+    }
+  }
+
+  sampleTrack(track: AnimationTrackSpec, t: number): Vec3 {
+    let keyframes = track.keyframes;
+    if (t <= keyframes[0].time) {
+      return keyframes[0].value;
+    }
+    for (let i = 0; i < keyframes.length - 1; i++) {
+      let from = keyframes[i];
+      let to = keyframes[i + 1];
+      if (t >= to.time) {
+        continue;
+      }
+      if (track.interpolation === "discrete") {
+        return from.value;
       }
+      let r = (t - from.time) / (to.time - from.time);
+      return {
+        x: from.value.x + (to.value.x - from.value.x) * r,
+        y: from.value.y + (to.value.y - from.value.y) * r,
+        z: from.value.z + (to.value.z - from.value.z) * r,
+      };
+    }
+    return keyframes[keyframes.length - 1].value;
+  }
+
+  // progress is 0 to 1 in the cycle
+  animateSpriteSheet(sheet: SpriteSheetAnimationSpec, progress: number): void {
+    let texture = this.textures.get(sheet.map)?.texture;
+    if (texture === undefined) {
+      return;
+    }
+    let count = sheet.frames !== undefined && sheet.frames.length !== 0 ? sheet.frames.length : sheet.columns * sheet.rows;
+    let idx = Math.min(Math.floor(progress * count), count - 1);
+    let cell = sheet.frames !== undefined && sheet.frames.length !== 0 ? sheet.frames[idx] : idx;
+    let column = cell % sheet.columns;
+    let row = Math.floor(cell / sheet.columns);
+    // the origin of the texture is the bottom-left
+    texture.repeat.set(1 / sheet.columns, 1 / sheet.rows);
+    texture.offset.set(column / sheet.columns, 1 - (row + 1) / sheet.rows);
+  }
+
+  makeGeometry(spec: GeometrySpec): THREE.BufferGeometry {
+    if (spec.box !== undefined) {
+      let g = spec.box;
+      return new THREE.BoxGeometry(g.width, g.height, g.depth, g.widthSegments, g.heightSegments, g.depthSegments);
+    }
+    if (spec.sphere !== undefined) {
+      let g = spec.sphere;
+      return new THREE.SphereGeometry(g.radius, g.widthSegments, g.heightSegments);
+    }
+    if (spec.plane !== undefined) {
+      let g = spec.plane;
+      return new THREE.PlaneGeometry(g.width, g.height, g.widthSegments, g.heightSegments);
+    }
+    if (spec.cylinder !== undefined) {
+      let g = spec.cylinder;
+      return new THREE.CylinderGeometry(g.radiusTop, g.radiusBottom, g.height, g.radialSegments, g.heightSegments, g.openEnded);
+    }
+    throw new Error("geometry type is not specified");
+  }
+
+  disposePart(entry: PartEntry): void {
+    entry.object.removeFromParent();
+    if (entry.object instanceof THREE.Mesh) {
+      entry.object.geometry.dispose();
     }
   }
 
@@ -209,6 +638,8 @@ export class ObjectWrapper extends THREE.Group {
     for (let material of materials) {
       if (material.spriteMaterial !== undefined) {
         this.applySpriteMaterial(material);
+      } else if (material.meshBasicMaterial !== undefined || material.meshStandardMaterial !== undefined) {
+        this.applyMeshMaterial(material);
       }
     }
 
@@ -227,36 +658,39 @@ export class ObjectWrapper extends THREE.Group {
   }
 
   applySpriteMaterial(material: MaterialSpec): void {
-    let entry = this.materials.get(material.name);
+    let spec = material.spriteMaterial!;
+    let entry = this.materials.get(material.name)?.material;
     if (entry === undefined || entry.type !== "SpriteMaterial") {
       let color: THREE.Color;
-      if (material.spriteMaterial === undefined || material.spriteMaterial.color === undefined) {
+      if (spec.color === undefined) {
         color = new THREE.Color(0xffffff);
       } else {
-        color = new THREE.Color(material.spriteMaterial.color.r, material.spriteMaterial.color.g, material.spriteMaterial.color.b);
+        color = new THREE.Color(spec.color.r, spec.color.g, spec.color.b);
       }
       entry = new THREE.SpriteMaterial({
-        alphaTest: material.spriteMaterial.alphaTest,
+        alphaTest: spec.alphaTest,
         color: color,
-        map: this.textures.get(material.spriteMaterial.mapTexture)?.texture,
+        map: this.textures.get(spec.mapTexture)?.texture,
       });
-      this.materials.set(material.name, entry);
+      this.applyMaterialBase(entry, spec);
+      this.materials.set(material.name, { material: entry });
       return;
     }
 
     let spriteMaterial = entry as THREE.SpriteMaterial;
-    let texture = this.textures.get(material.spriteMaterial.mapTexture)?.texture;
+    let texture = this.textures.get(spec.mapTexture)?.texture;
     if (spriteMaterial.map !== texture) {
-      let color = material.spriteMaterial.color !== undefined ? { r: 1, g: 1, b: 1 } as THREE.Color : undefined;
+      let color = spec.color !== undefined ? { r: 1, g: 1, b: 1 } as THREE.Color : undefined;
       entry = new THREE.SpriteMaterial({
         color: color,
-        map: this.textures.get(material.spriteMaterial.mapTexture)?.texture,
+        map: this.textures.get(spec.mapTexture)?.texture,
       });
-      this.materials.set(material.name, entry);
+      this.applyMaterialBase(entry, spec);
+      this.materials.set(material.name, { material: entry });
       return;
     }
 
-    if (material.spriteMaterial.color === undefined) {
+    if (spec.color === undefined) {
       if (spriteMaterial.color !== undefined) {
         spriteMaterial.color = new THREE.Color(0xffffff);
       }
@@ -264,10 +698,60 @@ export class ObjectWrapper extends THREE.Group {
     } else if (spriteMaterial.color === undefined) {
       spriteMaterial.color = { r: 1, g: 1, b: 1 } as THREE.Color;
 
-    } else if (spriteMaterial.color.r !== material.spriteMaterial.color.r ||
-      spriteMaterial.color.g !== material.spriteMaterial.color.g ||
-      spriteMaterial.color.b !== material.spriteMaterial.color.b) {
-      spriteMaterial.color.setRGB(material.spriteMaterial.color.r, material.spriteMaterial.color.g, material.spriteMaterial.color.b);
+    } else if (spriteMaterial.color.r !== spec.color.r ||
+      spriteMaterial.color.g !== spec.color.g ||
+      spriteMaterial.color.b !== spec.color.b) {
+      spriteMaterial.color.setRGB(spec.color.r, spec.color.g, spec.color.b);
+    }
+  }
+
+  applyMeshMaterial(material: MaterialSpec): void {
+    // mesh materials are recreated when the spec or the texture changes, meshes refer to the new one on applyMesh
+    let key = JSON.stringify([material.meshBasicMaterial, material.meshStandardMaterial]);
+    let spec = (material.meshBasicMaterial ?? material.meshStandardMaterial)!;
+    let texture = spec.mapTexture !== undefined ? this.textures.get(spec.mapTexture)?.texture : undefined;
+    let entry = this.materials.get(material.name);
+    if (entry !== undefined && entry.spec === key && (entry.material as THREE.MeshBasicMaterial).map === (texture ?? null)) {
+      return;
+    }
+
+    let color = spec.color !== undefined ? new THREE.Color(spec.color.r, spec.color.g, spec.color.b) : new THREE.Color(0xffffff);
+    let m: THREE.Material;
+    if (material.meshBasicMaterial !== undefined) {
+      m = new THREE.MeshBasicMaterial({
+        color: color,
+        map: texture,
+        wireframe: spec.wireframe ?? false,
+      });
+    } else {
+      let standard = material.meshStandardMaterial!;
+      m = new THREE.MeshStandardMaterial({
+        color: color,
+        map: texture,
+        wireframe: standard.wireframe ?? false,
+        emissive: standard.emissive !== undefined ? new THREE.Color(standard.emissive.r, standard.emissive.g, standard.emissive.b) : undefined,
+        roughness: standard.roughness,
+        metalness: standard.metalness,
+      });
+    }
+    this.applyMaterialBase(m, spec);
+
+    if (entry !== undefined) {
+      entry.material.dispose();
+    }
+    this.materials.set(material.name, { material: m, spec: key });
+  }
+
+  applyMaterialBase(m: THREE.Material, spec: MaterialBaseSpec): void {
+    if (spec.alphaTest !== undefined) {
+      m.alphaTest = spec.alphaTest;
+    }
+    if (spec.side !== undefined) {
+      m.side = spec.side as THREE.Side;
+    }
+    if (spec.transparent) {
+      m.transparent = true;
+      m.opacity = spec.opacity ?? 0;
     }
   }
 
@@ -310,6 +794,16 @@ export class ObjectWrapper extends THREE.Group {
     }
   }
 
+  selectVisibleRadius(radius: VisibleRadius): number {
+    if (this.scaleMode === ScaleModeLandScape && radius.landscape !== undefined) {
+      return radius.landscape;
+    } else if (this.scaleMode === ScaleModeXR && radius.xr !== undefined) {
+      return radius.xr;
+    } else {
+      return radius.default ?? DefaultVisibleRadius;
+    }
+  }
+
   selectScale(scale: Scale): Vec3 {
     if (this.scaleMode === ScaleModeLandScape && scale.landscape !== undefined) {
       return scale.landscape;
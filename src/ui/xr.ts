@@ -19,12 +19,15 @@ import * as POS from "../position";
 import * as V from "./view";
 
 import * as AFRAME from "aframe"
+import * as THREE from "three";
 
 const mainViewElID = "mainView";
 
 let xrView: XrView;
+let interactionListener: V.InteractionListener;
 
-export function init(frontendMpx: CL.MultiPlexer, pos: POS.Position): void {
+export function init(frontendMpx: CL.MultiPlexer, pos: POS.Position, listener: V.InteractionListener): void {
+  interactionListener = listener;
   xrView = new XrView(pos);
   document.body.style.overflow = "hidden";
   initHandler(frontendMpx);
@@ -49,6 +52,7 @@ class XrView {
   camera: AFRAME.Entity;
   objects: Map<string, V.ObjectWrapper>;
   entities: Map<string, AFRAME.Entity>;
+  nodePosition: POS.Position;
 
   constructor(pos: POS.Position) {
     let viewEl = document.getElementById(mainViewElID);
@@ -81,6 +85,44 @@ class XrView {
 
     this.objects = new Map<string, V.ObjectWrapper>();
     this.entities = new Map<string, AFRAME.Entity>();
+    this.nodePosition = pos;
+
+    this.animate();
+
+    // tapping the screen selects the object in front of the camera
+    this.scene.addEventListener("click", () => {
+      let target = this.pickCenter();
+      if (target !== undefined) {
+        interactionListener(target, "select");
+      }
+    });
+  }
+
+  pickCenter(): V.InteractionTarget | undefined {
+    let camera = this.camera.getObject3D("camera") as THREE.Camera | undefined;
+    if (camera === undefined) {
+      return undefined;
+    }
+    let raycaster = new THREE.Raycaster();
+    raycaster.setFromCamera(new THREE.Vector2(0, 0), camera);
+    let intersections = raycaster.intersectObjects(Array.from(this.objects.values()), true);
+    for (let intersection of intersections) {
+      let target = V.findInteractionTarget(intersection.object);
+      if (target !== undefined) {
+        return target;
+      }
+    }
+    return undefined;
+  }
+
+  animate(): void {
+    let now = Date.now();
+    let coordinate = this.nodePosition.coordinate;
+    for (const [_, wrapper] of this.objects) {
+      wrapper.updateVisibility(coordinate.latitude, coordinate.longitude);
+      wrapper.animate(now);
+    }
+    requestAnimationFrame(() => this.animate());
   }
 
   applyObjects(objects: V.Object[]): void {
@@ -94,7 +136,7 @@ class XrView {
         this.entities.set(uuid, entity);
         entity.setAttribute("scale", "1 1 1");
 
-        wrapper = new V.ObjectWrapper(V.ScaleModeXR);
+        wrapper = new V.ObjectWrapper(uuid, V.ScaleModeXR);
         this.objects.set(uuid, wrapper);
 
         entity.object3D.add(wrapper);